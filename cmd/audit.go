@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/audit"
+	"github.com/spf13/cobra"
+)
+
+var auditLimit int64
+var auditOperation string
+var auditSince string
+var auditJSON bool
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Audit log of the destructive operations",
+}
+
+var auditListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the destructive operations, most recent first",
+	Long: `List the destructive operations recorded in the audit collection, most recent first:
+who ran them, when, on what, and their result
+  delete          the games of a user (delete {user})
+  import-undo     the games of an import batch (import undo {batch})
+  backup-prune    the backups beyond the retention (backup --keep)
+  trash-purge     the deletions of the trash (trash purge, and the expired ones at every delete)
+  restore         a deletion put back from the trash (trash restore)
+
+  audit list --operation delete --since 2024-01-01`,
+	Args: cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		switch auditOperation {
+		case "", audit.OpDelete, audit.OpImportUndo, audit.OpBackupPrune, audit.OpTrashPurge, audit.OpRestore:
+		default:
+			return fmt.Errorf("invalid operation %q (%s, %s, %s, %s or %s)", auditOperation,
+				audit.OpDelete, audit.OpImportUndo, audit.OpBackupPrune, audit.OpTrashPurge, audit.OpRestore)
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := audit.Filter{Operation: auditOperation, Limit: auditLimit}
+		if auditSince != "" {
+			since, err := time.Parse("2006-01-02", auditSince)
+			if err != nil {
+				exit(fmt.Errorf("invalid date %q: YYYY-MM-DD", auditSince))
+			}
+			filter.Since = since
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		entries, err := audit.List(ctx, filter)
+		if err != nil {
+			exit(err)
+		}
+
+		if auditJSON {
+			printResult(true, entries, "")
+			return
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "Time\tActor\tOperation\tTarget\tResult")
+		for _, entry := range entries {
+			result := fmt.Sprint(entry.Details)
+			if entry.Error != "" {
+				result = "error: " + entry.Error
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Actor, entry.Operation, entry.Target, result)
+		}
+		writer.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.AddCommand(auditListCmd)
+	auditListCmd.Flags().Int64Var(&auditLimit, "limit", 50, "most recent operations (0 for all)")
+	auditListCmd.Flags().StringVar(&auditOperation, "operation", "", "operations of this kind only: delete, import-undo, backup-prune, trash-purge or restore")
+	auditListCmd.RegisterFlagCompletionFunc("operation", completeValues(audit.OpDelete, audit.OpImportUndo, audit.OpBackupPrune, audit.OpTrashPurge, audit.OpRestore))
+	auditListCmd.Flags().StringVar(&auditSince, "since", "", "operations of this day (YYYY-MM-DD, UTC) and later")
+	auditListCmd.Flags().BoolVar(&auditJSON, "json", false, "print the operations as JSON")
+}
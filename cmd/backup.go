@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/flutterbar/chess-explorer-go/internal/backup"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var backupS3 bool
+var backupKeep int
+var backupJSON bool
+
+var backupCmd = &cobra.Command{
+	Use:   "backup [directory]",
+	Short: "Back up the mongo database to a directory or an S3-compatible bucket",
+	Long: `Back up all collections of the mongo database (games and download history) to a gzipped file
+chess-explorer-{database}-{time}.jsonl.gz, in a directory (current directory by default) or in an S3-compatible bucket
+
+S3 settings (config file): s3-endpoint, s3-region, s3-bucket, s3-prefix, s3-access-key, s3-secret-key
+  backup --s3 --keep 7
+  backup /var/backups/chess --keep 30
+
+Restore with: restore {file}`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if backupS3 && len(args) > 0 {
+			log.Error("A directory cannot be used with --s3")
+			os.Exit(exitUsage)
+		}
+
+		var result *backup.Result
+		var err error
+		if backupS3 {
+			result, err = backup.ToS3(backupKeep)
+		} else {
+			dir := "."
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			result, err = backup.ToDirectory(dir, backupKeep)
+		}
+		if err != nil {
+			exit(err)
+		}
+		printResult(backupJSON, result, fmt.Sprintf("Backed up %d documents to %s (%d bytes, %d old backups removed)",
+			result.Documents, result.Location, result.Size, len(result.Removed)))
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore {file}",
+	Short: "Restore a backup into the mongo database",
+	Long: `Restore a backup file written by the backup command into the mongo database
+Documents already in the database are kept as they are (a restore can be run again)`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		inserted, skipped, err := backup.Restore(args[0])
+		if err != nil {
+			exit(err)
+		}
+		printResult(backupJSON, map[string]int{"inserted": inserted, "skipped": skipped},
+			"Restored "+strconv.Itoa(inserted)+" documents, "+strconv.Itoa(skipped)+" already in the database")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+
+	backupCmd.Flags().BoolVar(&backupS3, "s3", false, "upload to the S3-compatible bucket of the config file")
+	backupCmd.Flags().IntVar(&backupKeep, "keep", 0, "keep the N most recent backups, delete older ones (0: keep all)")
+	backupCmd.Flags().BoolVar(&backupJSON, "json", false, "print the result as JSON")
+	restoreCmd.Flags().BoolVar(&backupJSON, "json", false, "print the result as JSON")
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/flutterbar/chess-explorer-go/internal/bench"
+	"github.com/flutterbar/chess-explorer-go/internal/demo"
+	"github.com/flutterbar/chess-explorer-go/internal/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var benchFilter = map[string]*string{}
+var benchRuns int
+var benchDemo bool
+var benchJSON bool
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure the latency of a representative query mix against the database",
+	Long: `Measure the latency of a representative query mix against the configured database:
+next moves from the start, after 2 moves and after 12 moves (mongo and algorithmic paths),
+lookup of a position by FEN, export of the games of a line and of the last month.
+Every query runs once to warm up the caches, then --runs times; the percentiles of the
+latencies (milliseconds) compare backends, indexes and hardware.
+The filter flags restrict the games (the mix sets the line or the position):
+  bench
+  bench --runs 50 --white lichess.org:me --json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if benchDemo {
+			games, err := demo.Games()
+			if err != nil {
+				exit(err)
+			}
+			server.UseDemo(games, demo.Player)
+		}
+		filter := gameFilterValues(benchFilter)
+		filter.Del("pgn")
+		filter.Del("fen")
+		report, err := bench.Run(filter, bench.Mix(), benchRuns)
+		if err != nil {
+			exit(err)
+		}
+
+		if benchJSON {
+			printResult(true, report, "")
+			return
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "Query\tResults\tErrors\tMin\tp50\tp90\tp99\tMax\tMean\t")
+		for _, stats := range report.Queries {
+			fmt.Fprintf(writer, "%s\t%d\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t\n", stats.Name, stats.Results, stats.Errors,
+				stats.Min, stats.P50, stats.P90, stats.P99, stats.Max, stats.Mean)
+		}
+		writer.Flush()
+		for _, stats := range report.Queries {
+			if stats.Error != "" {
+				log.Warn(stats.Name + ": " + stats.Error)
+			}
+		}
+		log.Infof("%d runs of %d queries in %.1f s (latencies in ms)", report.Runs, len(report.Queries), report.TotalMs/1000)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+
+	addGameFilterFlags(benchCmd, benchFilter)
+	benchCmd.Flags().IntVar(&benchRuns, "runs", 10, "measured runs of every query")
+	benchCmd.Flags().BoolVar(&benchDemo, "demo", false, "measure the sample games in memory instead of the database")
+	benchCmd.Flags().BoolVar(&benchJSON, "json", false, "print the latencies as JSON")
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/flutterbar/chess-explorer-go/internal/book"
+	"github.com/flutterbar/chess-explorer-go/internal/server"
+	"github.com/spf13/cobra"
+)
+
+var bookFilter = map[string]*string{}
+var bookOptions book.Options
+
+var bookCmd = &cobra.Command{
+	Use:   "book",
+	Short: "Answer UCI on the standard input with the moves of your games, as an opening book",
+	Long: `Answer UCI on the standard input with the moves of your games, as an opening book
+
+Add the command as an engine in a GUI (or call it from an engine) to play your own repertoire live:
+the moves come from the games of the filter (the profile), bestmove (none) when out of book.
+  book --white lichess.org:me --speed blitz,rapid
+  book --black lichess.org:me --weight score --mingames 3
+Over HTTP, the server answers /book?fen={FEN} with the same filters.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		bookOptions.Values = gameFilterValues(bookFilter)
+		if err := book.Run(os.Stdin, os.Stdout, bookOptions); err != nil {
+			exit(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(bookCmd)
+
+	addGameFilterFlags(bookCmd, bookFilter)
+	bookCmd.Flags().StringVar(&bookOptions.Weighting, "weight", server.BookWeightGames, "how the move is chosen: games (as often as played), score (as often as it scored) or best (the most played)")
+	bookCmd.Flags().IntVar(&bookOptions.MinGames, "mingames", 1, "moves played in less games are left out")
+}
@@ -1,11 +1,14 @@
 package cmd
 
 import (
-	chesscom "github.com/flutterbar/chess-explorer-go/internal/chesscom"
+	"os"
+
+	"github.com/flutterbar/chess-explorer-go/pkg/explorer"
 	"github.com/spf13/cobra"
 )
 
 var chesscomPgn string
+var chesscomJSON bool
 
 var chesscomCmd = &cobra.Command{
 	Use:   "chesscom [user]",
@@ -13,8 +16,15 @@ var chesscomCmd = &cobra.Command{
 	Long:  `Download games for a given user from Chess.com`,
 	Args:  cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		for _, arg := range args {
-			chesscom.DownloadGames(arg, chesscomPgn)
+		ingestor := explorer.FromSettings().Ingestor().Command(cmd.CommandPath()).Keep(chesscomPgn)
+		results, err := ingestor.Users("chess.com", args...)
+		total := explorer.Summary{}
+		for _, result := range results {
+			total = total.Plus(result.Summary)
+		}
+		printResult(chesscomJSON, results, summaryText(total))
+		if err != nil {
+			os.Exit(exitCode(err))
 		}
 	},
 }
@@ -22,5 +32,8 @@ var chesscomCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(chesscomCmd)
 
+	chesscomCmd.ValidArgsFunction = completeTrackedUsers("chess.com", false)
+
 	chesscomCmd.Flags().StringVar(&chesscomPgn, "keep", "", "file where the PGN will be kept")
+	chesscomCmd.Flags().BoolVar(&chesscomJSON, "json", false, "print the summary (per user) as JSON")
 }
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+/*
+Dynamic shell completion. The completion command itself (completion bash|zsh|fish|powershell) is provided by cobra.
+
+Initializers (reading the config file) are not run when completing, hence the call to initConfig.
+*/
+
+// completeTrackedUsers ... usernames found in the database (lastgames), for site {site} only if not empty
+// with site prefix (lichess.org:username) if {withSite}
+func completeTrackedUsers(site string, withSite bool) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		initConfig()
+		users, err := pgntodb.TrackedUsers()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		names := make([]string, 0)
+		seen := make(map[string]bool)
+		for _, user := range users {
+			if site != "" && user.Site != site {
+				continue
+			}
+			candidates := []string{user.Username}
+			if withSite {
+				candidates = append(candidates, user.Site+":"+user.Username)
+			}
+			for _, candidate := range candidates {
+				if !seen[candidate] && strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(toComplete)) {
+					seen[candidate] = true
+					names = append(names, candidate)
+				}
+			}
+		}
+		sort.Strings(names)
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeProfiles ... profile names found in the config file
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	initConfig()
+	names := make([]string, 0)
+	for name := range viper.GetStringMap("profiles") {
+		if strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeValues ... fixed list of values for a flag
+func completeValues(values ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeDatabases ... databases of the MongoDB server (--mongo-url)
+func completeDatabases(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	initConfig()
+	names, err := mongodb.DatabaseNames()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	ret := make([]string, 0, len(names))
+	for _, name := range names {
+		if strings.HasPrefix(name, toComplete) {
+			ret = append(ret, name)
+		}
+	}
+	return ret, cobra.ShellCompDirectiveNoFileComp
+}
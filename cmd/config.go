@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/flutterbar/chess-explorer-go/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var configJSON bool
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and check the configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective configuration and where each setting comes from",
+	Long: `Show the effective configuration and where each setting comes from
+(flag, environment variable, profile, config file or default). Tokens and passwords are hidden.
+  config show --profile club`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		settings := config.Settings(func(key string) bool {
+			flag := cmd.Flags().Lookup(key)
+			return flag != nil && flag.Changed
+		})
+		if configJSON {
+			printResult(true, settings, "")
+			return
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "Setting\tValue\tSource")
+		for _, setting := range settings {
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", setting.Key, setting.Value, setting.Source)
+		}
+		writer.Flush()
+	},
+}
+
+var configCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the configuration: config file, database, engine, proxy, lichess token and users",
+	Long: `Check the configuration: config file, database, engine, proxy, lichess token and users
+
+The exit code is not 0 when a check fails (see Exit codes in the README).`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		checks := config.Checks()
+		if configJSON {
+			printResult(true, checks, "")
+		} else {
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, check := range checks {
+				fmt.Fprintf(writer, "%s\t%s\t%s\n", check.Status, check.Name, check.Message)
+			}
+			writer.Flush()
+		}
+		for _, check := range checks {
+			if check.Err != nil {
+				os.Exit(exitCode(check.Err))
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configCheckCmd)
+
+	configCmd.PersistentFlags().BoolVar(&configJSON, "json", false, "print the result as JSON")
+}
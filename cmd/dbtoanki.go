@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/flutterbar/chess-explorer-go/internal/anki"
+	"github.com/flutterbar/chess-explorer-go/internal/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var dbToAnkiFilter = map[string]*string{}
+var dbToAnkiOptions anki.Options
+
+var dbToAnkiCmd = &cobra.Command{
+	Use:   "dbtoanki [csv file]",
+	Short: "Export repertoire lines from mongo database to an Anki deck",
+	Long: `Export repertoire lines from mongo database to a CSV file for Anki (File > Import)
+
+Every position of your games where you are to move becomes a card: the board, the moves so far,
+and on the back the move you played most often. Replies of the opponents seen in at least
+--min-games games are followed, up to --depth moves after --pgn.
+
+Filters are the same as in the web page (also available at /anki on the server):
+  dbtoanki --white lichess.org:me --depth 12 --min-games 3 white.csv
+  dbtoanki --black lichess.org:me --pgn "1. e4 c5" sicilian.csv`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		values := gameFilterValues(dbToAnkiFilter)
+
+		file, err := os.Create(args[0])
+		if err != nil {
+			exit(err)
+		}
+		defer file.Close()
+
+		count, err := server.AnkiDeck(file, server.NewGameFilter(values), dbToAnkiOptions)
+		if err != nil {
+			exit(err)
+		}
+		log.Println("Exported " + strconv.Itoa(count) + " cards to " + args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dbToAnkiCmd)
+
+	addGameFilterFlags(dbToAnkiCmd, dbToAnkiFilter)
+	dbToAnkiCmd.Flags().StringVar(&dbToAnkiOptions.Color, "color", "", "repertoire side: white or black (default: the side of the filtered player)")
+	dbToAnkiCmd.Flags().IntVar(&dbToAnkiOptions.Depth, "depth", 16, "moves (plies) after --pgn")
+	dbToAnkiCmd.Flags().IntVar(&dbToAnkiOptions.MinGames, "min-games", 2, "leave out positions seen in fewer games")
+	dbToAnkiCmd.Flags().StringVar(&dbToAnkiOptions.Deck, "deck", "", "name of the deck in Anki (default \"Chess repertoire (white)\" or black)")
+	dbToAnkiCmd.RegisterFlagCompletionFunc("color", completeValues("white", "black"))
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/flutterbar/chess-explorer-go/internal/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var dbToPgnFilter = map[string]*string{}
+
+var dbToPgnCmd = &cobra.Command{
+	Use:   "dbtopgn [pgn file]",
+	Short: "Export games from mongo database to a pgn file",
+	Long: `Export games from mongo database to a pgn file
+
+Filters are the same as in the web page (also available at /export on the server):
+  dbtopgn --white lichess.org:me --from 2023-01-01 out.pgn
+  dbtopgn --site chess.com --timecontrol 600 --pgn "1. e4 e5" out.pgn`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		values := gameFilterValues(dbToPgnFilter)
+
+		file, err := os.Create(args[0])
+		if err != nil {
+			exit(err)
+		}
+		defer file.Close()
+
+		count, err := server.Export(context.Background(), file, server.NewGameFilter(values))
+		if err != nil {
+			exit(err)
+		}
+		log.Println("Exported " + strconv.Itoa(count) + " games to " + args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dbToPgnCmd)
+
+	addGameFilterFlags(dbToPgnCmd, dbToPgnFilter)
+}
+
+// addGameFilterFlags ... flags of the filter form of the web page, values in {filter}
+func addGameFilterFlags(cmd *cobra.Command, filter map[string]*string) {
+	flags := []struct{ name, usage string }{
+		{"white", "white player(s), comma separated (username, lichess.org:username or chess.com:username)"},
+		{"black", "black player(s), comma separated"},
+		{"from", "first date (YYYY-MM-DD)"},
+		{"to", "last date (YYYY-MM-DD)"},
+		{"tz", "time zone of --from and --to: Europe/Paris, +02:00 (default: UTC)"},
+		{"site", "site(s), comma separated (lichess.org, chess.com, iccf.com), -site to exclude one (-iccf.com)"},
+		{"timecontrol", "time control(s), comma separated"},
+		{"speed", "speed(s), comma separated: bullet, blitz, rapid, classical, correspondence"},
+		{"minelo", "minimum elo of both players"},
+		{"maxelo", "maximum elo of both players"},
+		{"unknownelo", "include or exclude the games with an unknown rating (default: excluded by --minelo and --maxelo)"},
+		{"pgn", "opening line, for example \"1. e4 e5\""},
+		{"fen", "position instead of --pgn, reached with any move order (games imported with an older version: run reindex first)"},
+		{"result", "1-0, 0-1, 1/2-1/2, or win, loss, draw of the white player(s) (black player(s) without --white)"},
+		{"batch", "import batch(es), comma separated (see import list)"},
+		{"tag", "tag(s) of the games, comma separated"},
+	}
+	for _, flag := range flags {
+		filter[flag.name] = cmd.Flags().String(flag.name, "", flag.usage)
+	}
+}
+
+// gameFilterValues ... values of the flags set by addGameFilterFlags
+func gameFilterValues(filter map[string]*string) url.Values {
+	values := url.Values{}
+	for name, value := range filter {
+		if *value != "" {
+			values.Set(name, *value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"strconv"
+
+	"github.com/flutterbar/chess-explorer-go/internal/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var dbToStudyFilter = map[string]*string{}
+var dbToStudyAlternatives int
+var dbToStudySamples int
+var dbToStudyColor string
+var dbToStudyToken string
+
+var dbToStudyCmd = &cobra.Command{
+	Use:   "dbtostudy [lichess study URL]",
+	Short: "Add an explorer line to a lichess.org study",
+	Long: `Add the line of --pgn to one of your lichess.org studies, as new chapters:
+  - the line, with the most played next move and --alternatives other moves as variations (results as comments)
+  - the --samples most recent games of the line
+
+The token needs the study:write scope (https://lichess.org/account/oauth/token/create?scopes[]=study:write)
+Filters are the same as in the web page (also in the web page when logged in with lichess):
+  dbtostudy https://lichess.org/study/AbCd1234 --white lichess.org:me --pgn "1. e4 c5 2. Nf3" --token lip_...`,
+	Args: cobra.ExactArgs(1),
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// bound here: lichess binds its own --token flag to the same setting
+		viper.BindPFlag("lichess-token", cmd.Flags().Lookup("token"))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := server.NewGameFilter(gameFilterValues(dbToStudyFilter))
+		count, err := server.ExportToStudy(viper.GetString("lichess-token"), args[0], filter, dbToStudyAlternatives, dbToStudySamples, dbToStudyColor)
+		if err != nil {
+			if count > 0 {
+				log.Warn(strconv.Itoa(count) + " chapters added before the error")
+			}
+			exit(err)
+		}
+		log.Println("Added " + strconv.Itoa(count) + " chapters to " + args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dbToStudyCmd)
+
+	addGameFilterFlags(dbToStudyCmd, dbToStudyFilter)
+	dbToStudyCmd.Flags().IntVar(&dbToStudyAlternatives, "alternatives", 3, "next moves shown as variations, besides the most played one")
+	dbToStudyCmd.Flags().IntVar(&dbToStudySamples, "samples", 5, "most recent games of the line added as chapters")
+	dbToStudyCmd.Flags().StringVar(&dbToStudyColor, "color", "", "board orientation: white or black (default: the side of the filtered player)")
+	dbToStudyCmd.Flags().StringVar(&dbToStudyToken, "token", "", "your lichess.org personal API access token (study:write scope)")
+	dbToStudyCmd.RegisterFlagCompletionFunc("color", completeValues("white", "black"))
+}
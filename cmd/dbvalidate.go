@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgnvalidate"
+	"github.com/flutterbar/chess-explorer-go/internal/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var dbValidateFilter = map[string]*string{}
+var dbValidateJSON bool
+
+var dbValidateCmd = &cobra.Command{
+	Use:   "dbvalidate",
+	Short: "Check the games of the mongo database",
+	Long: `Check the games of the mongo database, as pgnvalidate does for a pgn file
+
+Every game is replayed. Illegal moves and results contradicting the game termination or
+the final position (a checkmate scored as a draw, a stalemate scored as a win...) are reported:
+these games skew the statistics of the explorer. Remove them with delete, fix the pgn file
+(pgnvalidate) and import it again.
+Filters are the same as in the web page:
+  dbvalidate
+  dbvalidate --white lichess.org:me --json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		invalid, count, err := pgnvalidate.Database(server.NewGameFilter(gameFilterValues(dbValidateFilter)))
+		if err != nil {
+			exit(err)
+		}
+		if dbValidateJSON {
+			printResult(true, invalid, "")
+		} else {
+			for _, game := range invalid {
+				for _, problem := range game.Problems {
+					fmt.Println(game.ID + ": " + problem)
+				}
+			}
+			log.Printf("Games: %d, invalid: %d", count, len(invalid))
+		}
+		if len(invalid) > 0 {
+			os.Exit(exitError)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dbValidateCmd)
+
+	addGameFilterFlags(dbValidateCmd, dbValidateFilter)
+	dbValidateCmd.Flags().BoolVar(&dbValidateJSON, "json", false, "print the invalid games as JSON")
+}
@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"strconv"
+
 	"github.com/flutterbar/chess-explorer-go/internal/delete"
 	"github.com/spf13/cobra"
 )
 
+var deleteJSON bool
+var deletePurge bool
+
 var deleteCmd = &cobra.Command{
 	Use:   "delete [user]",
 	Short: "Delete user in database",
@@ -12,13 +18,29 @@ var deleteCmd = &cobra.Command{
 Username can have 3 forms:
 - username
 - lichess.org:username
-- chess.com:username`,
+- chess.com:username
+
+The games go to the trash for trash-days (config file, default 30): trash restore puts them back.
+With --purge they are removed for good.`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		delete.Games(args[0])
+		result, err := delete.Games(context.Background(), args[0], deletePurge)
+		if err != nil {
+			exit(err)
+		}
+		text := strconv.FormatInt(result.Games, 10) + " games and " + strconv.FormatInt(result.Users, 10) + " users deleted"
+		if !result.Purged {
+			text += ", in the trash as " + result.Deletion + " (trash restore " + args[0] + " to put them back)"
+		}
+		printResult(deleteJSON, result, text)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(deleteCmd)
+
+	deleteCmd.ValidArgsFunction = completeTrackedUsers("", true)
+
+	deleteCmd.Flags().BoolVar(&deleteJSON, "json", false, "print what was deleted as JSON")
+	deleteCmd.Flags().BoolVar(&deletePurge, "purge", false, "remove the games for good instead of moving them to the trash")
 }
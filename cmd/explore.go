@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/flutterbar/chess-explorer-go/internal/explore"
+	"github.com/spf13/cobra"
+)
+
+var exploreFilter = map[string]*string{}
+var exploreBoard bool
+
+var exploreCmd = &cobra.Command{
+	Use:   "explore",
+	Short: "Browse the opening tree of your games in the terminal",
+	Long: `Browse the opening tree of your games in the terminal (no browser needed, for example over SSH)
+
+Type a move (Nf3, e4, O-O or g1f3) or a line number to see the replies with their results,
+b to take back a move, q to quit. Filters are the same as in the web page:
+  explore --white lichess.org:me --timecontrol 600
+  explore --pgn "1. e4 c5" --board
+  explore --fen "rnbqkb1r/ppp2ppp/4pn2/3p4/2PP4/5N2/PP2PPPP/RNBQKB1R w KQkq - 0 4"`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := explore.Run(os.Stdin, os.Stdout, gameFilterValues(exploreFilter), exploreBoard); err != nil {
+			exit(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exploreCmd)
+
+	addGameFilterFlags(exploreCmd, exploreFilter)
+	exploreCmd.Flags().BoolVar(&exploreBoard, "board", false, "show the board (toggle with d)")
+	exploreFilter["aggregation"] = exploreCmd.Flags().String("aggregation", "", "mongo or algorithmic to force how the next moves are counted (default: mongo below 20 moves)")
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+var genDocsFormat string
+
+var genDocsCmd = &cobra.Command{
+	Use:   "gendocs [directory]",
+	Short: "Generate man pages or markdown documentation of all commands",
+	Long: `Generate man pages or markdown documentation of all commands (one file per command), for packaging
+  gendocs --format man docs/man
+  gendocs --format markdown docs/commands`,
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := args[0]
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			exit(err)
+		}
+
+		rootCmd.DisableAutoGenTag = true
+		var err error
+		switch genDocsFormat {
+		case "man":
+			header := &doc.GenManHeader{Title: "CHESS-EXPLORER", Section: "1", Source: "chess-explorer", Manual: "Chess Explorer Manual"}
+			err = doc.GenManTree(rootCmd, header, dir)
+		case "markdown":
+			err = doc.GenMarkdownTree(rootCmd, dir)
+		default:
+			log.Error("Unknown format: " + genDocsFormat + " (expected man or markdown)")
+			os.Exit(exitUsage)
+		}
+		if err != nil {
+			exit(err)
+		}
+		files, _ := filepath.Glob(filepath.Join(dir, "*"))
+		log.Infof("%d files written to %s", len(files), dir)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(genDocsCmd)
+
+	genDocsCmd.Flags().StringVar(&genDocsFormat, "format", "man", "man or markdown")
+	genDocsCmd.RegisterFlagCompletionFunc("format", completeValues("man", "markdown"))
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/progress"
+	"github.com/flutterbar/chess-explorer-go/pkg/explorer"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var generateOptions = explorer.GenerateOptions{}
+var generateFrom string
+var generateTo string
+var generatePgn string
+var generateJSON bool
+
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate random games for load testing and demos",
+	Long: `Generate realistic random games (popular openings then random moves, ratings, dates,
+time controls and clocks) and import them into the database, as one import batch:
+performance work and demos without real downloads.
+The players are {prefix}001... on lichess.org and chess.com; the same seed and dates give the same games.
+  generate --games 1000000
+  generate --games 5000 --pgn synthetic.pgn       (write the PGN file only)
+  import undo {batch}                             (remove the generated games)`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		generateOptions.To = time.Now().UTC().Truncate(24 * time.Hour) // the same games all day long
+		if generateTo != "" {
+			if generateOptions.To, err = time.Parse("2006-01-02", generateTo); err != nil {
+				exit(fmt.Errorf("invalid date %q: YYYY-MM-DD", generateTo))
+			}
+		}
+		generateOptions.From = generateOptions.To.AddDate(-3, 0, 0)
+		if generateFrom != "" {
+			if generateOptions.From, err = time.Parse("2006-01-02", generateFrom); err != nil {
+				exit(fmt.Errorf("invalid date %q: YYYY-MM-DD", generateFrom))
+			}
+		}
+
+		bar := progress.Count(int64(generateOptions.Games), "Generating")
+		generated := func(written int) { bar.Set(written) }
+		if generatePgn != "" {
+			file, err := os.Create(generatePgn)
+			if err != nil {
+				exit(err)
+			}
+			err = explorer.WriteGenerated(file, generateOptions, generated)
+			bar.Finish()
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				exit(err)
+			}
+			printResult(generateJSON, map[string]interface{}{"games": generateOptions.Games, "pgn": generatePgn},
+				fmt.Sprintf("%d games written to %s", generateOptions.Games, generatePgn))
+			return
+		}
+
+		ingestor := explorer.FromSettings().Ingestor().Command(cmd.CommandPath())
+		summary, err := ingestor.Generate(generateOptions, generated)
+		bar.Finish()
+		if err != nil {
+			exit(err)
+		}
+		log.Info("import batch " + ingestor.Batch() + " (import undo " + ingestor.Batch() + " removes the generated games)")
+		printResult(generateJSON, summary, summaryText(summary))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(generateCmd)
+
+	generateCmd.Flags().IntVar(&generateOptions.Games, "games", 1000, "number of games")
+	generateCmd.Flags().IntVar(&generateOptions.Players, "players", 100, "number of players (4 at least)")
+	generateCmd.Flags().StringVar(&generateOptions.Prefix, "prefix", "synthetic", "usernames of the players: {prefix}001...")
+	generateCmd.Flags().Int64Var(&generateOptions.Seed, "seed", 1, "seed of the random games")
+	generateCmd.Flags().StringVar(&generateFrom, "from", "", "date of the first game, YYYY-MM-DD (default: 3 years before --to)")
+	generateCmd.Flags().StringVar(&generateTo, "to", "", "date of the last game, YYYY-MM-DD (default: today)")
+	generateCmd.Flags().StringVar(&generatePgn, "pgn", "", "write the games to this PGN file instead of importing them")
+	generateCmd.Flags().BoolVar(&generateJSON, "json", false, "print the summary as JSON")
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"github.com/flutterbar/chess-explorer-go/internal/iccf"
+	"github.com/flutterbar/chess-explorer-go/pkg/explorer"
+	"github.com/spf13/cobra"
+)
+
+var iccfPlayer string
+var iccfJSON bool
+
+var iccfCmd = &cobra.Command{
+	Use:   "iccf [pgn files or folders]",
+	Short: "Import ICCF correspondence games",
+	Long: `Import the PGN exports of the ICCF games archive (https://www.iccf.com)
+
+ICCF has no download API: export your games (or the games of an event) from the site and import the files.
+The games are stored with the site iccf.com, to include them in the filters (site iccf.com) or exclude
+them (site -iccf.com). Names are stored as "Firstname Surname" and time controls as seconds per move
+(10/50: 1/432000). ICCF games imported with pgntodb get the same treatment.
+  iccf games.pgn --player "Surname, Firstname"
+  iccf exports/`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		player := ""
+		if iccfPlayer != "" {
+			player = iccf.PlayerName(iccfPlayer)
+		}
+		ingestor := explorer.FromSettings().Ingestor().Command(cmd.CommandPath())
+		summary := explorer.Summary{}
+		for _, arg := range args {
+			imported, err := ingestor.PGNFile(arg, player)
+			if err != nil {
+				exit(err)
+			}
+			summary = summary.Plus(imported)
+		}
+		printResult(iccfJSON, summary, summaryText(summary))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(iccfCmd)
+
+	iccfCmd.Flags().StringVar(&iccfPlayer, "player", "", "your ICCF name (\"Surname, Firstname\"), recorded as a user of the database as pgntodb --username does")
+	iccfCmd.Flags().BoolVar(&iccfJSON, "json", false, "print the summary as JSON")
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/delete"
+	"github.com/flutterbar/chess-explorer-go/pkg/explorer"
+	"github.com/spf13/cobra"
+)
+
+var importListLimit int
+var importListJSON bool
+var importUndoDryRun bool
+var importUndoJSON bool
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import batches: the games inserted by each import",
+	Long: `Every game inserted by an import run (pgntodb, iccf, lichess, chesscom, a synchronization, the login of a
+visitor) records its batch, the command, the file or URL it was read from, when and by which version.
+The filters of the web page and of the commands select the games of a batch (batch=...), import undo
+removes them.`,
+}
+
+var importListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the import batches, most recent first",
+	Long: `List the import batches, most recent first, with their number of games and sources
+Games imported before the provenance was stored are not listed.
+  import list
+  import list --limit 0 --json
+  dbtopgn --batch {batch} check.pgn`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		batches, err := explorer.FromSettings().Reports().Batches(ctx, importListLimit)
+		if err != nil {
+			exit(err)
+		}
+		if importListJSON {
+			printResult(true, batches, "")
+			return
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "Batch\tImported\tGames\tCommand\tVersion\tSources")
+		for _, batch := range batches {
+			sources := batch.Sources
+			if len(sources) > 3 {
+				sources = append(sources[:3:3], fmt.Sprintf("... (%d)", len(batch.Sources)))
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%d\t%s\t%s\t%s\n", batch.ID, batch.First.Local().Format("2006-01-02 15:04"), batch.Games,
+				batch.Command, batch.Version, strings.Join(sources, ", "))
+		}
+		writer.Flush()
+	},
+}
+
+var importUndoCmd = &cobra.Command{
+	Use:   "undo [batch]",
+	Short: "Move the games inserted by an import batch to the trash",
+	Long: `Move exactly the games inserted by an import batch (see import list) to the trash, after importing the wrong file
+for example. Games of the batch which were already in the database (duplicates) are not removed: they belong to an
+older batch. The most recent game of the users is moved back, so their next download or synchronization gets the
+removed games again; users without games left are removed.
+The games stay in the trash for trash-days (config file, default 30): trash restore {batch} puts them back.
+  import undo 20240101T101500-a1b2c3 --dry-run
+  import undo 20240101T101500-a1b2c3`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result, err := delete.Batch(context.Background(), args[0], importUndoDryRun)
+		if err != nil {
+			exit(err)
+		}
+		verb := "removed"
+		if importUndoDryRun {
+			verb = "to remove"
+		}
+		text := fmt.Sprintf("%d games %s, %d users moved back to an older game, %d users without games left",
+			result.Games, verb, result.UsersUpdated, result.Users)
+		if result.Deletion != "" {
+			text += ", in the trash as " + result.Deletion + " (trash restore " + args[0] + " to put them back)"
+		}
+		printResult(importUndoJSON, result, text)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.AddCommand(importListCmd)
+	importCmd.AddCommand(importUndoCmd)
+
+	importListCmd.Flags().IntVar(&importListLimit, "limit", 20, "number of batches (0 for all)")
+	importListCmd.Flags().BoolVar(&importListJSON, "json", false, "print the batches as JSON")
+	importUndoCmd.Flags().BoolVar(&importUndoDryRun, "dry-run", false, "count the games and users, remove nothing")
+	importUndoCmd.Flags().BoolVar(&importUndoJSON, "json", false, "print what was removed as JSON")
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/advisor"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var indexSlowMs int
+var indexOff bool
+var indexSince time.Duration
+var indexApply bool
+var indexJSON bool
+
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Indexes of the games collection for the slow queries",
+	Long: `Indexes of the games collection for the slow queries of your database:
+  index profile --slowms 100     record the queries slower than 100 ms (MongoDB profiler)
+  ... use the explorer, the reports ...
+  index advise                   indexes for the slow queries recorded
+  index advise --apply           and create them`,
+}
+
+var indexProfileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Record the slow queries of the database (MongoDB profiler)",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		client, err := mongodb.Connect(ctx)
+		if err != nil {
+			exit(err)
+		}
+		defer client.Disconnect(ctx)
+
+		slowMs, text := indexSlowMs, fmt.Sprintf("queries slower than %d ms recorded (index advise)", indexSlowMs)
+		if indexOff {
+			slowMs, text = -1, "slow queries no longer recorded"
+		}
+		if err = advisor.Profile(ctx, client, slowMs); err != nil {
+			exit(err)
+		}
+		log.Info(text)
+	},
+}
+
+var indexAdviseCmd = &cobra.Command{
+	Use:   "advise",
+	Short: "Suggest indexes for the slow queries recorded by index profile",
+	Long: `Suggest indexes of the games collection for the slow queries recorded by index profile:
+the queries which read the whole collection, or many more games than they returned, grouped by
+the fields they filter and sort on; fields already at the start of an index are left out.
+Most time saved first; --apply creates the indexes.
+  index advise --since 72h
+  index advise --apply`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+		defer cancel()
+		client, err := mongodb.Connect(ctx)
+		if err != nil {
+			exit(err)
+		}
+		defer client.Disconnect(ctx)
+
+		suggestions, err := advisor.Advise(ctx, client, time.Now().Add(-indexSince))
+		if err != nil {
+			exit(err)
+		}
+		if indexApply {
+			if err = advisor.Apply(ctx, client, suggestions); err != nil {
+				exit(err)
+			}
+		}
+
+		if indexJSON {
+			printResult(true, suggestions, "")
+			return
+		}
+		if len(suggestions) == 0 {
+			log.Info("No index to suggest: no slow query without index since " + indexSince.String() + " (see index profile)")
+			return
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "Index\tQueries\tTime (ms)\tExamined\tReturned\tCreated\tExample")
+		for _, suggestion := range suggestions {
+			fmt.Fprintf(writer, "%s\t%d\t%d\t%d\t%d\t%t\t%s\n", suggestion, suggestion.Queries, suggestion.TotalMs,
+				suggestion.Examined, suggestion.Returned, suggestion.Applied, suggestion.Example)
+		}
+		writer.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(indexCmd)
+	indexCmd.AddCommand(indexProfileCmd)
+	indexCmd.AddCommand(indexAdviseCmd)
+
+	indexProfileCmd.Flags().IntVar(&indexSlowMs, "slowms", 100, "record the queries slower than this (milliseconds)")
+	indexProfileCmd.Flags().BoolVar(&indexOff, "off", false, "stop recording the slow queries")
+	indexAdviseCmd.Flags().DurationVar(&indexSince, "since", 24*time.Hour, "slow queries of this period")
+	indexAdviseCmd.Flags().BoolVar(&indexApply, "apply", false, "create the suggested indexes")
+	indexAdviseCmd.Flags().BoolVar(&indexJSON, "json", false, "print the suggestions as JSON")
+}
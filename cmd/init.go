@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/flutterbar/chess-explorer-go/internal/sync"
+	"github.com/flutterbar/chess-explorer-go/internal/wizard"
+	homedir "github.com/mitchellh/go-homedir"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create the config file interactively",
+	Long: `Create the config file interactively (database, chess.com and lichess.org accounts)
+and optionally download the games of these accounts`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		configFile := cfgFile
+		if configFile == "" {
+			home, err := homedir.Dir()
+			if err != nil {
+				exit(err)
+			}
+			configFile = filepath.Join(home, ".chess-explorer.yaml")
+		}
+
+		answers, err := wizard.Run(os.Stdin, os.Stdout, configFile)
+		if err != nil {
+			exit(err)
+		}
+		if answers == nil || !answers.Download {
+			return
+		}
+
+		viper.Set("mongo-url", answers.MongoURL)
+		viper.Set("mongo-db-name", answers.MongoDBName)
+		viper.Set("users", answers.Users)
+		viper.Set("lichess-token", answers.LichessToken)
+		results, err := sync.All(context.Background())
+		total := 0
+		for _, result := range results {
+			total += result.Inserted
+		}
+		log.Info(strconv.Itoa(total) + " games imported for " + strconv.Itoa(len(results)) + " users")
+		if err != nil {
+			exit(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+}
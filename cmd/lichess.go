@@ -1,13 +1,16 @@
 package cmd
 
 import (
-	"github.com/flutterbar/chess-explorer-go/internal/lichess"
+	"os"
+
+	"github.com/flutterbar/chess-explorer-go/pkg/explorer"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var userToken string
 var lichessPgn string
+var lichessJSON bool
 
 var lichessCmd = &cobra.Command{
 	Use:   "lichess [user]",
@@ -15,8 +18,15 @@ var lichessCmd = &cobra.Command{
 	Long:  `Download games for a given user from Lichess.org`,
 	Args:  cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		for _, arg := range args {
-			lichess.DownloadGames(arg, lichessPgn)
+		ingestor := explorer.FromSettings().Ingestor().Command(cmd.CommandPath()).Keep(lichessPgn)
+		results, err := ingestor.Users("lichess.org", args...)
+		total := explorer.Summary{}
+		for _, result := range results {
+			total = total.Plus(result.Summary)
+		}
+		printResult(lichessJSON, results, summaryText(total))
+		if err != nil {
+			os.Exit(exitCode(err))
 		}
 	},
 }
@@ -24,8 +34,11 @@ var lichessCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(lichessCmd)
 
+	lichessCmd.ValidArgsFunction = completeTrackedUsers("lichess.org", false)
+
 	lichessCmd.Flags().StringVar(&userToken, "token", "", "your lichess.org personal API access token")
 	lichessCmd.Flags().StringVar(&lichessPgn, "keep", "", "file where the PGN will be kept")
+	lichessCmd.Flags().BoolVar(&lichessJSON, "json", false, "print the summary (per user) as JSON")
 
 	// To be able to support the config file, we need to bind with viper (and read with viper.GetString())
 	viper.BindPFlag("lichess-token", lichessCmd.Flags().Lookup("token"))
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	log "github.com/sirupsen/logrus"
+)
+
+// Exit codes
+const (
+	exitError    = 1 // any other error
+	exitUsage    = 2 // wrong command line
+	exitDatabase = 3 // the database cannot be reached
+	exitNetwork  = 4 // chess.com or lichess.org cannot be reached (or refused the request)
+	exitFile     = 5 // a file cannot be read or written
+)
+
+// exit ... log {err} and exit with a code telling its cause
+func exit(err error) {
+	log.Error(err)
+	os.Exit(exitCode(err))
+}
+
+func exitCode(err error) int {
+	var urlError *url.Error
+	var pathError *fs.PathError
+	switch {
+	case errors.Is(err, mongodb.ErrUnavailable):
+		return exitDatabase
+	case errors.As(err, &urlError):
+		return exitNetwork
+	case errors.As(err, &pathError):
+		return exitFile
+	default:
+		return exitError
+	}
+}
+
+// printResult ... {result} as JSON on the standard output (--json) or {text} as a log message
+func printResult(asJSON bool, result interface{}, text string) {
+	if !asJSON {
+		log.Info(text)
+		return
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		exit(err)
+	}
+}
+
+func summaryText(summary pgntodb.Summary) string {
+	return fmt.Sprintf("%d games imported, %d already in the database, %d skipped (%d games read in %d files)",
+		summary.Inserted, summary.Duplicates, summary.Skipped, summary.Games, summary.Files)
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/flutterbar/chess-explorer-go/internal/engine"
+	"github.com/flutterbar/chess-explorer-go/internal/pgnannotate"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var pgnAnnotateOutput string
+
+var pgnAnnotateCmd = &cobra.Command{
+	Use:   "pgnannotate [pgn file]",
+	Short: "Annotate a pgn file with a UCI engine",
+	Long: `Annotate a pgn file with a UCI engine (stockfish ...)
+
+Every mainline move gets an [%eval] comment. Inaccuracies, mistakes and blunders
+get a NAG ($6 ?!, $2 ?, $4 ??). No database is needed.
+  pgnannotate games.pgn --output annotated.pgn --engine /usr/bin/stockfish --depth 18`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pgnannotate.Process(args[0], pgnAnnotateOutput, engine.SettingsFromConfig())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pgnAnnotateCmd)
+
+	pgnAnnotateCmd.Flags().StringVarP(&pgnAnnotateOutput, "output", "o", "", "file where the annotated games will be written")
+	pgnAnnotateCmd.MarkFlagRequired("output")
+	pgnAnnotateCmd.Flags().String("engine", "", "UCI engine executable")
+	pgnAnnotateCmd.Flags().Int("depth", 16, "search depth")
+	pgnAnnotateCmd.Flags().Int("movetime", 0, "search time per position in milliseconds (instead of depth)")
+	pgnAnnotateCmd.Flags().Int("threads", 0, "engine threads (default: engine default)")
+	pgnAnnotateCmd.Flags().Int("hash", 0, "engine hash size in MB (default: engine default)")
+
+	// To be able to support the config file, we need to bind with viper (and read with viper.GetString())
+	viper.BindPFlag("engine-path", pgnAnnotateCmd.Flags().Lookup("engine"))
+	viper.BindPFlag("engine-depth", pgnAnnotateCmd.Flags().Lookup("depth"))
+	viper.BindPFlag("engine-movetime", pgnAnnotateCmd.Flags().Lookup("movetime"))
+	viper.BindPFlag("engine-threads", pgnAnnotateCmd.Flags().Lookup("threads"))
+	viper.BindPFlag("engine-hash", pgnAnnotateCmd.Flags().Lookup("hash"))
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/flutterbar/chess-explorer-go/internal/pgntopgn"
+	"github.com/spf13/cobra"
+)
+
+var pgnMergeCmd = &cobra.Command{
+	Use:   "pgnmerge [output file] [pgn file or folder]...",
+	Short: "Merge pgn files",
+	Long: `Merge pgn files
+
+Games of all the input files are deduplicated (same players, date and moves),
+sorted by date and written to the output file.
+Same as: pgntopgn [pgn file or folder]... --output [output file] --dedupe --sort`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		pgntopgn.Process(args[1:], pgntopgn.Options{Output: args[0], Dedupe: true, Sort: true})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pgnMergeCmd)
+}
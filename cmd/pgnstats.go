@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/flutterbar/chess-explorer-go/internal/pgnstats"
+	"github.com/spf13/cobra"
+)
+
+var pgnStatsJSON bool
+var pgnStatsTop int
+
+var pgnStatsCmd = &cobra.Command{
+	Use:   "pgnstats [pgn file or folder]...",
+	Short: "Statistics of a pgn file",
+	Long:  `Count games by result, ECO, time control, player and year, directly from pgn files (no database needed)`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pgnstats.Process(args, pgnStatsJSON, pgnStatsTop)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pgnStatsCmd)
+
+	pgnStatsCmd.Flags().BoolVar(&pgnStatsJSON, "json", false, "print statistics as JSON")
+	pgnStatsCmd.Flags().IntVar(&pgnStatsTop, "top", 20, "maximum number of rows per table (0: no limit)")
+}
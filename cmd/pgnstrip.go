@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"github.com/flutterbar/chess-explorer-go/internal/pgntopgn"
+	"github.com/spf13/cobra"
+)
+
+var pgnStripRemove string
+
+var pgnStripCmd = &cobra.Command{
+	Use:   "pgnstrip [output file] [pgn file or folder]...",
+	Short: "Remove annotations from pgn files",
+	Long: `Remove annotations from pgn files
+
+By default, comments, variations, NAGs and clocks are all removed, leaving the mainline only.
+Same as: pgntopgn [pgn file or folder]... --output [output file] --strip all
+  pgnstrip clean.pgn games.pgn
+  pgnstrip noclocks.pgn games.pgn --remove clocks`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		pgntopgn.Process(args[1:], pgntopgn.Options{Output: args[0], Strip: pgnStripRemove})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pgnStripCmd)
+
+	pgnStripCmd.Flags().StringVar(&pgnStripRemove, "remove", "all", "comments, variations, nags, clocks or all (comma separated)")
+	pgnStripCmd.RegisterFlagCompletionFunc("remove", completeValues("all", "comments", "variations", "nags", "clocks"))
+}
@@ -1,20 +1,37 @@
 package cmd
 
 import (
-	pgntodb "github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"errors"
+
+	"github.com/flutterbar/chess-explorer-go/pkg/explorer"
 	"github.com/spf13/cobra"
 )
 
 var username string
+var pgnToDbJSON bool
+var pgnToDbReference bool
 
 var pgnToDbCmd = &cobra.Command{
 	Use:   "pgntodb [pgn file]",
 	Short: "Parse a pgn file and feed mongo database",
-	Long:  `Parse a pgn file and feed mongo database. Designed for chess.com and lichess.org`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Parse a pgn file and feed mongo database. Designed for chess.com and lichess.org
+
+With --reference, the games go to the reference database (lichess elite, OTB mega databases...)
+instead of the games of the players: the explorer compares the moves of the players with it`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		lastGame := pgntodb.LastGame{Username: username}
-		pgntodb.Process(args[0], &lastGame)
+		ingestor := explorer.FromSettings().Ingestor().Command(cmd.CommandPath())
+		if pgnToDbReference {
+			if username != "" {
+				exit(errors.New("--username and --reference cannot be used together: the reference games have no tracked user"))
+			}
+			ingestor.Reference()
+		}
+		summary, err := ingestor.PGNFile(args[0], username)
+		if err != nil {
+			exit(err)
+		}
+		printResult(pgnToDbJSON, summary, summaryText(summary))
 	},
 }
 
@@ -22,5 +39,8 @@ func init() {
 	rootCmd.AddCommand(pgnToDbCmd)
 
 	pgnToDbCmd.Flags().StringVar(&username, "username", "", "username for whom you are downloading games")
+	pgnToDbCmd.Flags().BoolVar(&pgnToDbJSON, "json", false, "print the summary as JSON")
+	pgnToDbCmd.Flags().BoolVar(&pgnToDbReference, "reference", false, "import into the reference database (comparison source of the explorer)")
+	pgnToDbCmd.RegisterFlagCompletionFunc("username", completeTrackedUsers("", false))
 
 }
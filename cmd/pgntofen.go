@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"github.com/flutterbar/chess-explorer-go/internal/pgntofen"
+	"github.com/spf13/cobra"
+)
+
+var pgnToFenOptions pgntofen.Options
+var pgnToFenMove int
+
+var pgnToFenCmd = &cobra.Command{
+	Use:   "pgntofen [pgn file]",
+	Short: "Extract positions (EPD or FEN) from a pgn file",
+	Long: `Extract positions (EPD or FEN) from a pgn file
+
+By default, the final position of each game is written.
+EPD lines carry the game metadata as opcodes (id, c0 white, c1 black, c2 date,
+c3 result, c4 ECO, c5 ply, sm move played in this position, hmvc, fmvn).
+  pgntofen games.pgn --move 10 --output suite.epd   (position after black's 10th move)
+  pgntofen games.pgn --ply 15                      (position after white's 8th move)
+  pgntofen games.pgn --every-ply --format fen`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if pgnToFenMove > 0 {
+			pgnToFenOptions.Ply = 2 * pgnToFenMove
+		}
+		pgntofen.Process(args[0], pgnToFenOptions)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pgnToFenCmd)
+
+	pgnToFenCmd.Flags().IntVar(&pgnToFenOptions.Ply, "ply", 0, "position after this ply (half move)")
+	pgnToFenCmd.Flags().IntVar(&pgnToFenMove, "move", 0, "position after this move (both sides have played)")
+	pgnToFenCmd.Flags().BoolVar(&pgnToFenOptions.Every, "every-ply", false, "position after every ply")
+	pgnToFenCmd.Flags().StringVar(&pgnToFenOptions.Format, "format", "epd", "epd or fen")
+	pgnToFenCmd.RegisterFlagCompletionFunc("format", completeValues("epd", "fen"))
+	pgnToFenCmd.Flags().StringVarP(&pgnToFenOptions.Output, "output", "o", "", "file where the positions will be written (default standard output)")
+}
@@ -5,16 +5,60 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var pgnToPgnOptions pgntopgn.Options
+
 var pgnToPgnCmd = &cobra.Command{
-	Use:   "pgntopgn [pgn file]",
+	Use:   "pgntopgn [pgn file or folder]...",
 	Short: "Filter a pgn file",
-	Long:  `Filter a pgn file`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Filter a pgn file
+
+Games can be written to a new file, optionally split by month, player or opening:
+  pgntopgn games.pgn --output out.pgn --split month   (out-2023-05.pgn, out-2023-06.pgn, ...)
+  pgntopgn games.pgn --output out.pgn --split player  (out-Carlsen.pgn, ...)
+  pgntopgn games.pgn --output out.pgn --split opening (out-B01.pgn, ...)
+
+Duplicate games (same players, date and moves) can be removed within and across files:
+  pgntopgn 2022.pgn 2023.pgn --output all.pgn --dedupe
+
+Headers can be normalized (dates, missing Result, site names, UTF-8):
+  pgntopgn games.pgn --output clean.pgn --normalize
+
+Moves can be rewritten in SAN (e4), long algebraic (e2e4, Ng1f3) or UCI (e2e4, g1f3)
+notation. Comments and variations are dropped:
+  pgntopgn games.pgn --output uci.pgn --convert uci
+
+A random sample of games can be taken from an arbitrarily large input:
+  pgntopgn huge.pgn --output subset.pgn --sample 1000
+
+Player names, ratings and links can be removed before sharing a dataset:
+  pgntopgn games.pgn --output shared.pgn --anonymize pseudonymize --anonymize-salt secret
+
+Comments, variations, NAGs and clocks can be removed (see also pgnstrip):
+  pgntopgn games.pgn --output clean.pgn --strip clocks,nags`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		pgntopgn.Process(args[0])
+		pgntopgn.Process(args, pgnToPgnOptions)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(pgnToPgnCmd)
+
+	pgnToPgnCmd.Flags().StringVarP(&pgnToPgnOptions.Output, "output", "o", "", "file where the games will be written")
+	pgnToPgnCmd.Flags().StringVar(&pgnToPgnOptions.Split, "split", "", "split the output by month, player or opening")
+	pgnToPgnCmd.Flags().BoolVar(&pgnToPgnOptions.Dedupe, "dedupe", false, "remove duplicate games (same players, date and moves)")
+	pgnToPgnCmd.Flags().BoolVar(&pgnToPgnOptions.Normalize, "normalize", false, "fix common header problems (dates, result, site names, UTF-8)")
+	pgnToPgnCmd.Flags().StringVar(&pgnToPgnOptions.Strip, "strip", "", "remove comments, variations, nags, clocks or all (comma separated)")
+	pgnToPgnCmd.Flags().BoolVar(&pgnToPgnOptions.Sort, "sort", false, "sort games by date (oldest first)")
+	pgnToPgnCmd.Flags().IntVar(&pgnToPgnOptions.Sample, "sample", 0, "keep this number of randomly chosen games")
+	pgnToPgnCmd.Flags().Int64Var(&pgnToPgnOptions.Seed, "seed", 0, "random seed for --sample (same seed, same sample)")
+	pgnToPgnCmd.Flags().StringVar(&pgnToPgnOptions.Anonymize, "anonymize", "", "strip or pseudonymize player names (comments included), ratings and links")
+	pgnToPgnCmd.Flags().StringVar(&pgnToPgnOptions.AnonymizeSalt, "anonymize-salt", "", "secret giving the same pseudonyms across runs (default: random)")
+	pgnToPgnCmd.Flags().BoolVar(&pgnToPgnOptions.KeepRatings, "keep-ratings", false, "keep ratings when anonymizing")
+	pgnToPgnCmd.Flags().StringVar(&pgnToPgnOptions.Convert, "convert", "", "rewrite moves in san, lan or uci notation (games that cannot be replayed are skipped)")
+
+	pgnToPgnCmd.RegisterFlagCompletionFunc("split", completeValues("month", "player", "opening"))
+	pgnToPgnCmd.RegisterFlagCompletionFunc("convert", completeValues("san", "lan", "uci"))
+	pgnToPgnCmd.RegisterFlagCompletionFunc("anonymize", completeValues("strip", "pseudonymize"))
+	pgnToPgnCmd.RegisterFlagCompletionFunc("strip", completeValues("all", "comments", "variations", "nags", "clocks"))
 }
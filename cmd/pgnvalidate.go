@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"github.com/flutterbar/chess-explorer-go/internal/pgnvalidate"
+	"github.com/spf13/cobra"
+)
+
+var pgnValidateOutput string
+
+var pgnValidateCmd = &cobra.Command{
+	Use:   "pgnvalidate [pgn file]",
+	Short: "Check the games of a pgn file",
+	Long: `Check the games of a pgn file
+
+Every game is replayed. Illegal moves, result/header mismatches, results
+contradicting the final position (checkmate, stalemate, insufficient material)
+and truncated games are reported with their line number.
+dbvalidate checks the games of the database.
+Valid games can be written to a new file with --output.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pgnvalidate.Process(args[0], pgnValidateOutput)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pgnValidateCmd)
+
+	pgnValidateCmd.Flags().StringVarP(&pgnValidateOutput, "output", "o", "", "file where the valid games will be written")
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var pipelineOptions pipeline.Options
+var pipelineJSON bool
+
+var pipelineCmd = &cobra.Command{
+	Use:   "pipeline",
+	Short: "Synchronize, analyse the new games and compute the reports, for a nightly cron entry",
+	Long: `Synchronize, analyse the new games and compute the reports, for a nightly cron entry
+
+The steps run one after the other:
+  1. sync: recent games of all users
+  2. analysis of the games imported by this run with the engine (engine-path in the config file,
+     skipped without), their evaluations are stored as those of the analysed lichess.org games
+  3. report precompute: the heavy reports of the players
+A failed step does not stop the next ones; the exit code is not 0 when a step failed.
+A lock file stops a second run started meanwhile.
+
+  0 3 * * * chess-explorer pipeline --json >> /var/log/chess-explorer.log`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		summary, err := pipeline.Run(context.Background(), pipelineOptions)
+		if summary.Duration != "" {
+			printResult(pipelineJSON, summary, summary.String())
+		}
+		if err != nil {
+			exit(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pipelineCmd)
+
+	pipelineCmd.Flags().BoolVar(&pipelineJSON, "json", false, "print the summary as JSON")
+	pipelineCmd.Flags().StringVar(&pipelineOptions.Lock, "lock", "", "lock file (default: in the temporary directory, one per database)")
+	pipelineCmd.Flags().DurationVar(&pipelineOptions.LockTimeout, "lock-timeout", 12*time.Hour, "a lock file older than this was left by a crashed run and is taken over (0: never)")
+	pipelineCmd.Flags().Int64Var(&pipelineOptions.MaxAnalyze, "max-analyze", 200, "new games analysed at most (0: all)")
+	pipelineCmd.Flags().BoolVar(&pipelineOptions.NoAnalyze, "no-analyze", false, "skip the engine analysis")
+	pipelineCmd.Flags().BoolVar(&pipelineOptions.NoReports, "no-reports", false, "skip the reports")
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/spf13/cobra"
+)
+
+var reindexAll bool
+var reindexJSON bool
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Compute the position hashes and time controls of the games imported before they were stored",
+	Long: `Compute the Zobrist hash of the position after every move of the games imported before the hashes were stored,
+and create their index (position search, transpositions)
+Also stores the time controls in a structured form (base, increment, speed) for the games imported before.
+New games get their hashes and time controls when they are imported.
+  reindex
+  reindex --all`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+		defer cancel()
+		client, err := mongodb.Connect(ctx)
+		if err != nil {
+			exit(err)
+		}
+		defer client.Disconnect(ctx)
+
+		result, err := pgntodb.Reindex(ctx, client, reindexAll)
+		if err != nil {
+			exit(err)
+		}
+		printResult(reindexJSON, result, fmt.Sprintf("%d games reindexed (%d read, %d with an illegal move)",
+			result.Updated, result.Games, result.Invalid))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reindexCmd)
+
+	reindexCmd.Flags().BoolVar(&reindexAll, "all", false, "compute the hashes and time controls of every game, not only the games without them")
+	reindexCmd.Flags().BoolVar(&reindexJSON, "json", false, "print the result as JSON")
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/precompute"
+	"github.com/flutterbar/chess-explorer-go/internal/server"
+	"github.com/flutterbar/chess-explorer-go/pkg/explorer"
+	"github.com/spf13/cobra"
+)
+
+var reportOpeningsPlayer string
+var reportOpeningsColor string
+var reportOpeningsLimit int
+var reportOpeningsFilter = map[string]*string{}
+var reportOpeningsJSON bool
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Reports on the games of the database",
+}
+
+var reportOpeningsCmd = &cobra.Command{
+	Use:   "openings",
+	Short: "Most played openings of a player with their scores",
+	Long: `Most played openings of a player with their scores (points per game, win 1, draw 0.5)
+
+Games imported before openings were stored are grouped by their first moves.
+  report openings --player lichess.org:me --color white
+  report openings --player me --timecontrol 600 --from 2023-01-01 --limit 0`,
+	Args: cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		switch reportOpeningsColor {
+		case "white", "black", "both":
+			return nil
+		}
+		return fmt.Errorf("invalid color %q (white, black or both)", reportOpeningsColor)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		filter := explorer.Filter{
+			From:        *reportOpeningsFilter["from"],
+			To:          *reportOpeningsFilter["to"],
+			TimeZone:    *reportOpeningsFilter["tz"],
+			Site:        *reportOpeningsFilter["site"],
+			TimeControl: *reportOpeningsFilter["timecontrol"],
+		}
+		openings, err := explorer.FromSettings().Reports().Openings(ctx, reportOpeningsPlayer, reportOpeningsColor, filter)
+		if err != nil {
+			exit(err)
+		}
+		if reportOpeningsLimit > 0 && len(openings) > reportOpeningsLimit {
+			openings = openings[:reportOpeningsLimit]
+		}
+
+		if reportOpeningsJSON {
+			printResult(true, openings, "")
+			return
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "Opening\tECO\tGames\tWins\tDraws\tLosses\tScore")
+		for _, opening := range openings {
+			fmt.Fprintf(writer, "%s\t%s\t%d\t%d\t%d\t%d\t%.0f%%\n", opening.Name, opening.ECO, opening.Games, opening.Wins, opening.Draws, opening.Losses, opening.Score*100)
+		}
+		writer.Flush()
+	},
+}
+
+var reportPrecomputeJSON bool
+
+var reportPrecomputeCmd = &cobra.Command{
+	Use:   "precompute",
+	Short: "Compute the heavy reports of the players in advance",
+	Long: `Compute the heavy reports of the players in advance (repertoire with white and black,
+results by speed, notable games), stored in the reports collection: the web server
+then returns them at once, with the time they were computed.
+
+The server computes them on schedule with precompute-schedule in the config file
+(cron expression, "0 3 * * *" every night at 3:00). precompute-reports selects the
+reports (openings-white, openings-black, results, notable), precompute-players the
+players (default: the users downloaded and those of users:).`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		result, err := precompute.Run(context.Background())
+		printResult(reportPrecomputeJSON, result, fmt.Sprintf("%d reports computed for %d players in %s", result.Reports, result.Players, result.Duration))
+		if err != nil {
+			exit(err)
+		}
+	},
+}
+
+var reportDiffA string
+var reportDiffB string
+var reportDiffStart string
+var reportDiffColor string
+var reportDiffDepth int
+var reportDiffMinGames int
+var reportDiffMinChange int
+var reportDiffJSON bool
+
+var reportDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Diff of the opening trees of two filters",
+	Long: `Diff of the opening trees of two filters (my 2022 games and my 2024 games, me and a teammate):
+the lines added (played in b only), abandoned (played in a only) and the common lines whose score changed
+
+The filters are query strings of the web server filter (white, black, from, to, timecontrol, site...),
+--pgn the first line of both.
+  report diff --a "white=lichess.org:me&to=2022-12-31" --b "white=lichess.org:me&from=2024-01-01"
+  report diff --a "black=me" --b "black=teammate" --color black --pgn "1. e4 c5" --json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		filters := make([]*server.GameFilter, 0, 2)
+		for _, query := range []string{reportDiffA, reportDiffB} {
+			values, err := url.ParseQuery(query)
+			if err != nil {
+				exit(fmt.Errorf("invalid filter %q: %v", query, err))
+			}
+			values.Set("pgn", reportDiffStart)
+			filters = append(filters, server.NewGameFilter(values))
+		}
+		diff, err := server.DiffRepertoires(filters[0], filters[1], reportDiffColor, reportDiffDepth, reportDiffMinGames, float64(reportDiffMinChange)/100)
+		if err != nil {
+			exit(err)
+		}
+
+		if reportDiffJSON {
+			printResult(true, diff, "")
+			return
+		}
+		percent := func(score *float64) string {
+			if score == nil {
+				return "-"
+			}
+			return fmt.Sprintf("%.0f%%", *score*100)
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "Status\tLine\tGames a\tGames b\tScore a\tScore b\tChange")
+		for _, lines := range [][]server.LineDiff{diff.Added, diff.Abandoned, diff.Changed} {
+			for _, line := range lines {
+				change := ""
+				if line.Status == server.LineChanged {
+					change = fmt.Sprintf("%+.0f%%", line.Change*100)
+				}
+				fmt.Fprintf(writer, "%s\t%s\t%d\t%d\t%s\t%s\t%s\n", line.Status, line.Line, line.GamesA, line.GamesB, percent(line.ScoreA), percent(line.ScoreB), change)
+			}
+		}
+		writer.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.AddCommand(reportOpeningsCmd)
+	reportCmd.AddCommand(reportPrecomputeCmd)
+	reportPrecomputeCmd.Flags().BoolVar(&reportPrecomputeJSON, "json", false, "print the summary as JSON")
+
+	reportCmd.AddCommand(reportDiffCmd)
+	reportDiffCmd.Flags().StringVar(&reportDiffA, "a", "", "first filter as a query string (white=lichess.org:me&to=2022-12-31)")
+	reportDiffCmd.MarkFlagRequired("a")
+	reportDiffCmd.Flags().StringVar(&reportDiffB, "b", "", "second filter as a query string (white=lichess.org:me&from=2024-01-01)")
+	reportDiffCmd.MarkFlagRequired("b")
+	reportDiffCmd.Flags().StringVar(&reportDiffStart, "pgn", "", "first line of both trees (default: the starting position)")
+	reportDiffCmd.Flags().StringVar(&reportDiffColor, "color", "white", "scores of white or black")
+	reportDiffCmd.RegisterFlagCompletionFunc("color", completeValues("white", "black"))
+	reportDiffCmd.Flags().IntVar(&reportDiffDepth, "depth", 8, "plies of the trees")
+	reportDiffCmd.Flags().IntVar(&reportDiffMinGames, "mingames", 3, "lines played in less games are left out")
+	reportDiffCmd.Flags().IntVar(&reportDiffMinChange, "minchange", 10, "score changes of less percent are left out")
+	reportDiffCmd.Flags().BoolVar(&reportDiffJSON, "json", false, "print the diff as JSON")
+
+	reportOpeningsCmd.Flags().StringVar(&reportOpeningsPlayer, "player", "", "player, username or site:username (lichess.org:username or chess.com:username)")
+	reportOpeningsCmd.MarkFlagRequired("player")
+	reportOpeningsCmd.RegisterFlagCompletionFunc("player", completeTrackedUsers("", true))
+	reportOpeningsCmd.Flags().StringVar(&reportOpeningsColor, "color", "both", "games of the player with white, black or both")
+	reportOpeningsCmd.RegisterFlagCompletionFunc("color", completeValues("white", "black", "both"))
+	reportOpeningsCmd.Flags().IntVar(&reportOpeningsLimit, "limit", 20, "number of openings (0 for all)")
+	reportOpeningsCmd.Flags().BoolVar(&reportOpeningsJSON, "json", false, "print the openings as JSON")
+	flags := []struct{ name, usage string }{
+		{"from", "first date (YYYY-MM-DD)"},
+		{"to", "last date (YYYY-MM-DD)"},
+		{"tz", "time zone of --from and --to: Europe/Paris, +02:00 (default: UTC)"},
+		{"site", "site(s), comma separated (lichess.org, chess.com, iccf.com), -site to exclude one (-iccf.com)"},
+		{"timecontrol", "time control(s), comma separated"},
+	}
+	for _, flag := range flags {
+		reportOpeningsFilter[flag.name] = reportOpeningsCmd.Flags().String(flag.name, "", flag.usage)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
 	homedir "github.com/mitchellh/go-homedir"
@@ -13,6 +14,13 @@ import (
 var cfgFile string
 var mongoURL string
 var mongoDBName string
+var profile string
+var verbose bool
+var quiet bool
+var logFormat string
+var logFile string
+var noProgress bool
+var proxy string
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -30,9 +38,10 @@ https://github.com/flutterbar/chess-explorer-go`,
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	// errors of the commands themselves are handled by exit()
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 }
 
@@ -44,11 +53,29 @@ func init() {
 	// will be global for your application.
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.chess-explorer.yaml)")
-	rootCmd.PersistentFlags().StringVar(&mongoURL, "mongo-url", "mongodb://127.0.0.1:27017", "MongoDB connection URL")
-	rootCmd.PersistentFlags().StringVar(&mongoDBName, "mongo-db-name", "chess-explorer", "MongoDB database name")
+	rootCmd.PersistentFlags().StringVar(&mongoURL, "mongo-url", "mongodb://127.0.0.1:27017", "MongoDB connection URL (overrides the config file and the profile)")
+	rootCmd.PersistentFlags().StringVar(&mongoDBName, "mongo-db-name", "chess-explorer", "MongoDB database name (overrides the config file and the profile)")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "named profile of the config file (settings under profiles.{name})")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "show debug messages")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "show warnings and errors only")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "log format: text or json")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "write log messages to this file instead of the standard error")
+	rootCmd.PersistentFlags().StringVar(&proxy, "proxy", "", "proxy for chess.com and lichess.org downloads: http://, https:// or socks5:// URL (default: HTTP_PROXY and HTTPS_PROXY environment variables)")
+	rootCmd.PersistentFlags().BoolVar(&noProgress, "no-progress", false, "do not display progress bars (never displayed when the standard error is not a terminal)")
 
 	viper.BindPFlag("mongo-url", rootCmd.PersistentFlags().Lookup("mongo-url"))
 	viper.BindPFlag("mongo-db-name", rootCmd.PersistentFlags().Lookup("mongo-db-name"))
+	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	viper.BindPFlag("quiet", rootCmd.PersistentFlags().Lookup("quiet"))
+	viper.BindPFlag("log-format", rootCmd.PersistentFlags().Lookup("log-format"))
+	viper.BindPFlag("log-file", rootCmd.PersistentFlags().Lookup("log-file"))
+	viper.BindPFlag("no-progress", rootCmd.PersistentFlags().Lookup("no-progress"))
+	viper.BindPFlag("proxy", rootCmd.PersistentFlags().Lookup("proxy"))
+
+	rootCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+	rootCmd.RegisterFlagCompletionFunc("mongo-db-name", completeDatabases)
+	rootCmd.RegisterFlagCompletionFunc("log-format", completeValues("text", "json"))
 
 }
 
@@ -73,7 +100,68 @@ func initConfig() {
 	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+	configRead := viper.ReadInConfig() == nil
+
+	applyProfile()
+
+	// the config file and the profile may hold logging settings
+	initLogging()
+
+	if configRead {
+		log.Info("Using config file: ", viper.ConfigFileUsed())
+	}
+	if name := viper.GetString("profile"); name != "" {
+		log.Info("Using profile: ", name)
+	}
+}
+
+// initLogging ... level, format and destination of log messages
+func initLogging() {
+	switch {
+	case viper.GetBool("verbose"):
+		log.SetLevel(log.DebugLevel)
+	case viper.GetBool("quiet"):
+		log.SetLevel(log.WarnLevel)
+	default:
+		log.SetLevel(log.InfoLevel)
+	}
+
+	switch viper.GetString("log-format") {
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	case "text", "":
+		log.SetFormatter(&log.TextFormatter{FullTimestamp: true})
+	default:
+		fmt.Println("Unknown log format:", viper.GetString("log-format"), "(expected text or json)")
+		os.Exit(1)
+	}
+
+	if path := viper.GetString("log-file"); path != "" {
+		// kept open until the program exits
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Println("Cannot open log file", path+":", err)
+			os.Exit(1)
+		}
+		log.SetOutput(file)
+	}
+}
+
+// applyProfile ... settings of the selected profile (profiles.{name} in the config file) replace the top level settings
+// (command line flags and environment variables still win)
+func applyProfile() {
+	name := viper.GetString("profile")
+	if name == "" {
+		return
+	}
+
+	settings := viper.GetStringMap("profiles." + name)
+	if len(settings) == 0 {
+		fmt.Println("Unknown profile:", name)
+		os.Exit(1)
+	}
+	if err := viper.MergeConfigMap(settings); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
 }
@@ -1,20 +1,78 @@
 package cmd
 
 import (
+	"errors"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/demo"
+	"github.com/flutterbar/chess-explorer-go/internal/precompute"
 	server "github.com/flutterbar/chess-explorer-go/internal/server"
+	"github.com/flutterbar/chess-explorer-go/internal/sync"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var serverPort int
 var startBrowser bool
+var withSync bool
+var syncInterval time.Duration
+var demoMode bool
+var readOnlyMode bool
 
 var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "Start a web server to access data via a web browser",
-	Long:  `Start a web server to access data via a web browser`,
+	Long: `Start a web server to access data via a web browser
+
+With --with-sync, the games of all users are also downloaded in the background
+every --sync-interval (a single process keeps the data fresh and serves it)
+
+With --demo, sample games are served from memory: try the explorer before
+installing MongoDB and downloading your games
+
+With --engine (or engine-path in the config file), the explorer also shows the
+preferred move of the engine in every position (engine-depth or engine-movetime)
+
+With precompute-schedule in the config file (cron expression: "0 3 * * *"), the heavy
+reports of the players are computed on schedule (see report precompute)
+
+With --read-only, the requests changing the database (upload of games, tags, notes,
+bookmarks, share links, teams) are refused and a login does not download games:
+a curated database can be exposed publicly`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// engine-path is also the --engine flag of pgnannotate
+		viper.BindPFlag("engine-path", cmd.Flags().Lookup("engine"))
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		server.Start()
+		if demoMode {
+			if viper.GetBool("with-sync") {
+				exit(errors.New("--demo and --with-sync cannot be used together"))
+			}
+			games, err := demo.Games()
+			if err != nil {
+				exit(err)
+			}
+			server.UseDemo(games, demo.Player)
+			log.Infof("Demo mode: %d sample games of player %s, nothing is stored", len(games), demo.Player)
+		}
+		if viper.GetBool("with-sync") {
+			interval := viper.GetDuration("sync-interval")
+			if interval <= 0 {
+				exit(errors.New("sync-interval must be a positive duration (for example 30m or 6h)"))
+			}
+			go sync.Every(interval)
+		}
+		if expression := viper.GetString("precompute-schedule"); expression != "" && !demoMode {
+			schedule, err := precompute.ParseSchedule(expression)
+			if err != nil {
+				exit(err)
+			}
+			go precompute.Every(schedule)
+		}
+		if err := server.Start(); err != nil {
+			exit(err)
+		}
 	},
 }
 
@@ -23,8 +81,16 @@ func init() {
 
 	serverCmd.Flags().IntVar(&serverPort, "server-port", 52825, "server http port")
 	serverCmd.Flags().BoolVar(&startBrowser, "start-browser", false, "automatically start a browser (default false)")
+	serverCmd.Flags().BoolVar(&withSync, "with-sync", false, "also download recent games of all users in the background")
+	serverCmd.Flags().BoolVar(&demoMode, "demo", false, "serve sample games from memory (no database needed)")
+	serverCmd.Flags().BoolVar(&readOnlyMode, "read-only", false, "refuse the requests changing the database")
+	serverCmd.Flags().String("engine", "", "UCI engine executable, for the engine move of the explorer")
+	serverCmd.Flags().DurationVar(&syncInterval, "sync-interval", time.Hour, "time between two synchronizations (with --with-sync)")
 
 	// To be able to support the config file, we need to bind with viper (and read with viper.GetString())
 	viper.BindPFlag("server-port", serverCmd.Flags().Lookup("server-port"))
 	viper.BindPFlag("start-browser", serverCmd.Flags().Lookup("start-browser"))
+	viper.BindPFlag("with-sync", serverCmd.Flags().Lookup("with-sync"))
+	viper.BindPFlag("sync-interval", serverCmd.Flags().Lookup("sync-interval"))
+	viper.BindPFlag("read-only", serverCmd.Flags().Lookup("read-only"))
 }
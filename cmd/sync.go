@@ -1,19 +1,43 @@
 package cmd
 
 import (
+	"context"
+	"strconv"
+	"time"
+
 	"github.com/flutterbar/chess-explorer-go/internal/sync"
 	"github.com/spf13/cobra"
 )
 
+var syncJSON bool
+var syncEvery time.Duration
+
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Download recent games for all users in database",
 	Long:  `Download recent games for all users in database`,
 	Run: func(cmd *cobra.Command, args []string) {
-		sync.All()
+		if syncEvery > 0 {
+			sync.Every(syncEvery) // never returns
+		}
+
+		results, err := sync.All(context.Background())
+		if results != nil {
+			total := 0
+			for _, result := range results {
+				total += result.Inserted
+			}
+			printResult(syncJSON, results, strconv.Itoa(total)+" games imported for "+strconv.Itoa(len(results))+" users")
+		}
+		if err != nil {
+			exit(err)
+		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(syncCmd)
+
+	syncCmd.Flags().BoolVar(&syncJSON, "json", false, "print the summary (per user) as JSON")
+	syncCmd.Flags().DurationVar(&syncEvery, "every", 0, "keep running and synchronize again after this time (for example 30m or 6h)")
 }
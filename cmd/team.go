@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/flutterbar/chess-explorer-go/internal/server"
+	"github.com/spf13/cobra"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var teamJSON bool
+
+var teamCmd = &cobra.Command{
+	Use:   "team",
+	Short: "Teams: named sets of players, team:{name} wherever a player is expected",
+	Long: `Teams: named sets of players (a club, a team of a league), usable wherever a player is expected
+as team:{name} (white, black and player of the web server, filters of the commands)
+
+Teams are stored in the database, or in the config file:
+  teams:
+    club: [lichess.org:alice, chess.com:bob]`,
+}
+
+var teamListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the teams of the config file and of the database",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var teams []server.Team
+		withTeams(func(ctx context.Context, collection *mongo.Collection) (err error) {
+			teams, err = server.Teams(ctx, collection, pgntodb.Owner())
+			return err
+		})
+		if teamJSON {
+			printResult(true, teams, "")
+			return
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "Team\tSource\tPlayers")
+		for _, team := range teams {
+			source := "database"
+			if team.Config {
+				source = "config"
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", team.Name, source, strings.Join(team.Players, ", "))
+		}
+		writer.Flush()
+	},
+}
+
+var teamSetCmd = &cobra.Command{
+	Use:   "set [name] [players...]",
+	Short: "Create or replace a team of the database",
+	Long: `Create or replace a team of the database
+Players are usernames or site:usernames (lichess.org:username, chess.com:username).
+  team set club lichess.org:alice chess.com:bob`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		team := server.Team{Name: args[0], Players: args[1:], Owner: pgntodb.Owner()}
+		withTeams(func(ctx context.Context, collection *mongo.Collection) (err error) {
+			team, err = server.SaveTeam(ctx, collection, team)
+			return err
+		})
+		printResult(teamJSON, team, fmt.Sprintf("team %s: %d players", team.Name, len(team.Players)))
+	},
+}
+
+var teamRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Remove a team of the database",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		withTeams(func(ctx context.Context, collection *mongo.Collection) error {
+			return server.DeleteTeam(ctx, collection, pgntodb.Owner(), args[0])
+		})
+		printResult(teamJSON, struct {
+			Removed string `json:"removed"`
+		}{args[0]}, "team "+args[0]+" removed")
+	},
+}
+
+// withTeams ... run {action} on the teams collection, exit on error
+func withTeams(action func(ctx context.Context, collection *mongo.Collection) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		exit(err)
+	}
+	defer client.Disconnect(ctx)
+	if err = action(ctx, mongodb.Collection(client, "teams")); err != nil {
+		exit(err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(teamCmd)
+	teamCmd.AddCommand(teamListCmd)
+	teamCmd.AddCommand(teamSetCmd)
+	teamCmd.AddCommand(teamRemoveCmd)
+	teamCmd.PersistentFlags().BoolVar(&teamJSON, "json", false, "print the result as JSON")
+}
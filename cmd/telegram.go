@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/flutterbar/chess-explorer-go/internal/telegram"
+	"github.com/spf13/cobra"
+)
+
+var telegramCmd = &cobra.Command{
+	Use:   "telegram",
+	Short: "Run a Telegram bot answering questions about the games of the database",
+	Long: `Run a Telegram bot answering questions about the games of the database:
+  /prep {opponent}                      openings of an opponent as white and as black
+  /stats [speed] [last30d] [player]     results by speed (players of the users setting by default)
+
+Config file:
+  telegram-token: {token from @BotFather}
+  telegram-chat-id: {chat receiving the synchronization summaries, allowed to ask questions}
+  telegram-allowed-chats: [{other chats allowed to ask questions}]
+
+Ask /start from another chat to get its id.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := telegram.Run(); err != nil {
+			exit(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(telegramCmd)
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/flutterbar/chess-explorer-go/internal/delete"
+	"github.com/spf13/cobra"
+)
+
+var trashJSON bool
+var trashPurgeAll bool
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "Games deleted with delete, kept trash-days before they are removed for good",
+	Long: `Games deleted with delete (without --purge), kept trash-days (config file, default 30)
+before they are removed for good: a typo in a username can be undone
+
+  trash list
+  trash restore lichess.org:username`,
+}
+
+var trashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the deletions in the trash, most recent first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		deletions, err := delete.Trash()
+		if err != nil {
+			exit(err)
+		}
+		if trashJSON {
+			printResult(true, deletions, "")
+			return
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "Deletion\tUser\tDeleted\tGames\tUsers\tPurged after")
+		for _, deletion := range deletions {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%d\t%s\n", deletion.ID, deletion.Target, deletion.Deleted.Local().Format("2006-01-02 15:04"),
+				deletion.Games, deletion.Users, deletion.Expires.Local().Format("2006-01-02"))
+		}
+		writer.Flush()
+	},
+}
+
+var trashRestoreCmd = &cobra.Command{
+	Use:   "restore [user or deletion]",
+	Short: "Put back the games of a deletion",
+	Long: `Put back the games and the user of a deletion: the most recent deletion of a user
+(as given to delete), or a deletion of trash list
+Games imported again since the deletion are left as they are.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result, err := delete.Restore(context.Background(), args[0])
+		if err != nil {
+			exit(err)
+		}
+		printResult(trashJSON, result, fmt.Sprintf("%s: %d games and %d users restored, %d already in the database",
+			result.Deletion, result.Games, result.Users, result.Duplicates))
+	},
+}
+
+var trashPurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Remove for good the deletions older than trash-days",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		purged, err := delete.PurgeTrash(trashPurgeAll)
+		if err != nil {
+			exit(err)
+		}
+		printResult(trashJSON, map[string]int64{"purged": purged}, strconv.FormatInt(purged, 10)+" documents removed from the trash")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(trashCmd)
+	trashCmd.AddCommand(trashListCmd)
+	trashCmd.AddCommand(trashRestoreCmd)
+	trashCmd.AddCommand(trashPurgeCmd)
+	trashCmd.PersistentFlags().BoolVar(&trashJSON, "json", false, "print the result as JSON")
+	trashPurgeCmd.Flags().BoolVar(&trashPurgeAll, "all", false, "empty the trash, whatever the age of the deletions")
+}
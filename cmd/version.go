@@ -5,15 +5,19 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var versionJSON bool
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Display build information",
-	Long:  `Display build information`,
+	Long:  `Display build information (version, git commit, build date, Go version and platform)`,
 	Run: func(cmd *cobra.Command, args []string) {
-		version.DisplayVersion()
+		version.DisplayVersion(versionJSON)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(versionCmd)
+
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "print build information as JSON")
 }
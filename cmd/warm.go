@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/flutterbar/chess-explorer-go/internal/progress"
+	"github.com/flutterbar/chess-explorer-go/internal/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var warmFilter = map[string]*string{}
+var warmPlayer string
+var warmDepth int
+var warmMinGames int
+var warmClear bool
+var warmJSON bool
+
+var warmCmd = &cobra.Command{
+	Use:   "warm",
+	Short: "Compute the top of the opening tree in advance",
+	Long: `Compute the next moves of the top of the opening tree in advance and store them in the
+treecache collection: after a big import, the first moves of the web page are returned at once
+instead of being aggregated on cold caches.
+--player warms the trees of the player with white and with black, the filter flags select the
+games (--pgn the first line); the moves of less than --mingames games are not followed.
+The nodes stay valid until the games change (import, deletion, tags, analysis, reindex), and
+tree-cache-days days (config file, default 7, 0 disables the cache).
+  warm --player lichess.org:me
+  warm --depth 8 --player lichess.org:me --speed blitz --mingames 5
+  warm --clear`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if warmClear {
+			removed, err := server.ClearTreeCache()
+			if err != nil {
+				exit(err)
+			}
+			printResult(warmJSON, map[string]int64{"removed": removed}, fmt.Sprintf("%d nodes removed from the cache", removed))
+			return
+		}
+
+		filter := gameFilterValues(warmFilter)
+		filters := []url.Values{filter}
+		if warmPlayer = strings.TrimSpace(warmPlayer); warmPlayer != "" {
+			if filter.Get("white") != "" || filter.Get("black") != "" {
+				exit(fmt.Errorf("--player sets --white and --black"))
+			}
+			filters = nil
+			for _, color := range []string{"white", "black"} {
+				values := url.Values{}
+				for key, value := range filter {
+					values[key] = value
+				}
+				values.Set(color, warmPlayer)
+				filters = append(filters, values)
+			}
+		}
+
+		results := []*server.WarmResult{}
+		for _, values := range filters {
+			bar := progress.Count(-1, "Warming")
+			result, err := server.Warm(values, warmDepth, warmMinGames, func(nodes int, line string) { bar.Set(nodes) })
+			bar.Finish()
+			if err != nil {
+				exit(err)
+			}
+			for _, failed := range result.Failed {
+				log.Warn(failed)
+			}
+			results = append(results, result)
+		}
+
+		if warmJSON {
+			printResult(true, results, "")
+			return
+		}
+		for i, result := range results {
+			log.Infof("%s: %d nodes (%d moves, %d plies deep) computed on %d games in %s", filters[i].Encode(),
+				result.Nodes, result.Moves, result.Depth, result.Games, result.Duration)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(warmCmd)
+
+	addGameFilterFlags(warmCmd, warmFilter)
+	warmCmd.Flags().StringVar(&warmPlayer, "player", "", "player whose trees with white and black are warmed (username, lichess.org:username or chess.com:username)")
+	warmCmd.Flags().IntVar(&warmDepth, "depth", 6, "plies of the tree computed after the first line")
+	warmCmd.Flags().IntVar(&warmMinGames, "mingames", 2, "moves played in less games are not followed")
+	warmCmd.Flags().BoolVar(&warmClear, "clear", false, "remove every node of the cache")
+	warmCmd.Flags().BoolVar(&warmJSON, "json", false, "print the result as JSON")
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/winmodel"
+	"github.com/spf13/cobra"
+)
+
+var winmodelMinGames int
+var winmodelJSON bool
+
+var winmodelCmd = &cobra.Command{
+	Use:   "winmodel",
+	Short: "Win probability model of the expected scores",
+	Long: `Win probability model of the expected scores shown next to the actual scores of the explorer moves:
+a logistic model of the rating difference, the evaluation after the move and the time control
+
+Without training the model uses the Elo formula and the lichess winning chances of the evaluations.`,
+}
+
+var winmodelTrainCmd = &cobra.Command{
+	Use:   "train",
+	Short: "Fit the model on the rated games of the database",
+	Long: `Fit the model, by speed (bullet, blitz, rapid, classical, correspondence), on the games of the database with both ratings
+Evaluations come from the games imported with them (lichess), around move 10.
+Speeds with fewer games than --min-games keep the default coefficients.
+  winmodel train
+  winmodel train --min-games 1000 --json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+		client, err := mongodb.Connect(ctx)
+		if err != nil {
+			exit(err)
+		}
+		defer client.Disconnect(ctx)
+
+		model, err := winmodel.Train(ctx, client, winmodelMinGames)
+		if err != nil {
+			exit(err)
+		}
+		printModel(model)
+	},
+}
+
+var winmodelShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Coefficients of the model used by the explorer",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		client, err := mongodb.Connect(ctx)
+		if err != nil {
+			exit(err)
+		}
+		defer client.Disconnect(ctx)
+
+		model, err := winmodel.Load(ctx, client)
+		if err != nil {
+			exit(err)
+		}
+		printModel(model)
+	},
+}
+
+// printModel ... coefficients by speed, as a table or JSON
+func printModel(model winmodel.Model) {
+	coefficients := make([]winmodel.Coefficients, 0, len(model))
+	for _, speed := range winmodel.Speeds {
+		coefficients = append(coefficients, model[speed])
+	}
+	if winmodelJSON {
+		printResult(true, coefficients, "")
+		return
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "Speed\tBias\tPer 100 Elo\tPer pawn\tGames")
+	for _, c := range coefficients {
+		games := "default"
+		if c.Games > 0 {
+			games = fmt.Sprint(c.Games)
+		}
+		fmt.Fprintf(writer, "%s\t%.3f\t%.3f\t%.3f\t%s\n", c.Speed, c.Bias, c.Rating, c.Eval, games)
+	}
+	writer.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(winmodelCmd)
+	winmodelCmd.AddCommand(winmodelTrainCmd)
+	winmodelCmd.AddCommand(winmodelShowCmd)
+
+	winmodelTrainCmd.Flags().IntVar(&winmodelMinGames, "min-games", 200, "games of a speed needed to train it")
+	winmodelCmd.PersistentFlags().BoolVar(&winmodelJSON, "json", false, "print the coefficients as JSON")
+}
@@ -0,0 +1,303 @@
+package advisor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+/*
+Index advisor (chess-explorer index advise): the slow queries on the games collection, recorded by the profiler of
+MongoDB (index profile turns it on), are grouped by the fields they filter and sort on. A group of queries which read the
+whole collection or many more documents than they returned gets an index, equality fields first, then the sort, then the
+ranges; unless an index of the collection already starts with these fields. index advise --apply creates them.
+*/
+
+// maxKeys ... fields of a suggested index at most
+const maxKeys = 4
+
+// Suggestion ... an index for slow queries
+type Suggestion struct {
+	Keys     []string `json:"keys"`     // fields of the index, in order (ascending)
+	Queries  int      `json:"queries"`  // slow queries it serves
+	TotalMs  int64    `json:"totalMs"`  // time of these queries
+	Examined int64    `json:"examined"` // documents read by these queries
+	Returned int64    `json:"returned"` // documents returned by these queries
+	Example  string   `json:"example"`  // filter of the slowest query
+	Applied  bool     `json:"applied,omitempty"`
+}
+
+// profileEntry ... a slow operation recorded by the profiler (system.profile)
+type profileEntry struct {
+	Op           string   `bson:"op"`
+	Command      bson.Raw `bson:"command"`
+	Millis       int64    `bson:"millis"`
+	DocsExamined int64    `bson:"docsExamined"`
+	NReturned    int64    `bson:"nreturned"`
+	PlanSummary  string   `bson:"planSummary"`
+}
+
+// Profile ... record the operations of the database slower than {slowMs} milliseconds (negative: stop recording)
+func Profile(ctx context.Context, client *mongodb.Client, slowMs int) error {
+	command := bson.D{{Key: "profile", Value: 1}, {Key: "slowms", Value: slowMs}}
+	if slowMs < 0 {
+		command = bson.D{{Key: "profile", Value: 0}}
+	}
+	return client.Database(client.Settings().Name).RunCommand(ctx, command).Err()
+}
+
+// Advise ... indexes for the slow queries on the games collection recorded since {since}, most time saved first
+func Advise(ctx context.Context, client *mongodb.Client, since time.Time) ([]Suggestion, error) {
+	database := client.Settings().Name
+	cursor, err := mongodb.Collection(client, "system.profile").Find(ctx, bson.M{
+		"ns": database + ".games",
+		"ts": bson.M{"$gte": since},
+		"op": bson.M{"$in": bson.A{"query", "command"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var entries []profileEntry
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	existing, err := indexKeys(ctx, mongodb.Collection(client, "games"))
+	if err != nil {
+		return nil, err
+	}
+
+	byKeys := map[string]*Suggestion{}
+	slowest := map[string]int64{}
+	for _, entry := range entries {
+		if !entry.inefficient() {
+			continue
+		}
+		filter, sortKeys := entry.filterAndSort()
+		keys := indexFor(filter, sortKeys)
+		if len(keys) == 0 || covered(keys, filter, existing) {
+			continue
+		}
+		group := strings.Join(keys, ",")
+		suggestion, ok := byKeys[group]
+		if !ok {
+			suggestion = &Suggestion{Keys: keys}
+			byKeys[group] = suggestion
+		}
+		suggestion.Queries++
+		suggestion.TotalMs += entry.Millis
+		suggestion.Examined += entry.DocsExamined
+		suggestion.Returned += entry.NReturned
+		if entry.Millis >= slowest[group] {
+			slowest[group] = entry.Millis
+			suggestion.Example = filter.String()
+		}
+	}
+
+	suggestions := []Suggestion{}
+	for _, suggestion := range byKeys {
+		suggestions = append(suggestions, *suggestion)
+	}
+	sort.Slice(suggestions, func(i, j int) bool { return suggestions[i].TotalMs > suggestions[j].TotalMs })
+	return suggestions, nil
+}
+
+// Apply ... create the indexes of {suggestions} on the games collection
+func Apply(ctx context.Context, client *mongodb.Client, suggestions []Suggestion) error {
+	if len(suggestions) == 0 {
+		return nil
+	}
+	models := make([]mongo.IndexModel, len(suggestions))
+	for i, suggestion := range suggestions {
+		keys := bson.D{}
+		for _, key := range suggestion.Keys {
+			keys = append(keys, bson.E{Key: key, Value: 1})
+		}
+		models[i] = mongo.IndexModel{Keys: keys}
+	}
+	if _, err := mongodb.Collection(client, "games").Indexes().CreateMany(ctx, models); err != nil {
+		return err
+	}
+	for i := range suggestions {
+		suggestions[i].Applied = true
+	}
+	return nil
+}
+
+// inefficient ... the operation read the whole collection, or ten times more documents than it returned
+func (entry *profileEntry) inefficient() bool {
+	if strings.HasPrefix(entry.PlanSummary, "COLLSCAN") {
+		return true
+	}
+	returned := entry.NReturned
+	if returned < 1 {
+		returned = 1
+	}
+	return entry.DocsExamined > 1000 && entry.DocsExamined > 10*returned
+}
+
+// filterAndSort ... the filter and the sort of a find, or of the first $match and $sort of an aggregation
+func (entry *profileEntry) filterAndSort() (bson.Raw, bson.Raw) {
+	if filter, ok := entry.Command.Lookup("filter").DocumentOK(); ok {
+		sortKeys, _ := entry.Command.Lookup("sort").DocumentOK()
+		return filter, sortKeys
+	}
+	var filter, sortKeys bson.Raw
+	stages, _ := entry.Command.Lookup("pipeline").Array().Values()
+	for _, stage := range stages {
+		document, ok := stage.DocumentOK()
+		if !ok {
+			continue
+		}
+		if match, ok := document.Lookup("$match").DocumentOK(); ok && filter == nil {
+			filter = match
+		}
+		if sortStage, ok := document.Lookup("$sort").DocumentOK(); ok && sortKeys == nil {
+			sortKeys = sortStage
+		}
+	}
+	return filter, sortKeys
+}
+
+// indexFor ... the fields of an index for {filter} and {sortKeys}: equality fields (sorted), sort fields, range fields
+func indexFor(filter bson.Raw, sortKeys bson.Raw) []string {
+	equality, ranges := map[string]bool{}, map[string]bool{}
+	fieldsOf(filter, equality, ranges)
+	keys := []string{}
+	for field := range equality {
+		keys = append(keys, field)
+	}
+	sort.Strings(keys)
+	seen := map[string]bool{}
+	for _, key := range keys {
+		seen[key] = true
+	}
+	if elements, err := sortKeys.Elements(); err == nil {
+		for _, element := range elements {
+			if key := element.Key(); !seen[key] {
+				keys = append(keys, key)
+				seen[key] = true
+			}
+		}
+	}
+	rangeKeys := []string{}
+	for field := range ranges {
+		if !seen[field] {
+			rangeKeys = append(rangeKeys, field)
+		}
+	}
+	sort.Strings(rangeKeys)
+	keys = append(keys, rangeKeys...)
+	if len(keys) > maxKeys {
+		keys = keys[:maxKeys]
+	}
+	return keys
+}
+
+// fieldsOf ... the fields {filter} compares for equality (a value, $eq, $in) and with a range ($gt, $lt, $regex...);
+// the fields of $and are the fields of the filter, $or, $nor and $expr are left out (an index cannot serve all branches)
+func fieldsOf(filter bson.Raw, equality map[string]bool, ranges map[string]bool) {
+	elements, err := filter.Elements()
+	if err != nil {
+		return
+	}
+	for _, element := range elements {
+		key := element.Key()
+		switch {
+		case key == "$and":
+			values, _ := element.Value().Array().Values()
+			for _, value := range values {
+				if document, ok := value.DocumentOK(); ok {
+					fieldsOf(document, equality, ranges)
+				}
+			}
+		case strings.HasPrefix(key, "$") || key == "_id":
+		default:
+			operators, ok := element.Value().DocumentOK()
+			if !ok {
+				equality[key] = true
+				continue
+			}
+			first, err := operators.IndexErr(0)
+			if err != nil || !strings.HasPrefix(first.Key(), "$") {
+				equality[key] = true // an embedded document
+				continue
+			}
+			switch first.Key() {
+			case "$eq", "$in", "$all":
+				equality[key] = true
+			case "$ne", "$nin", "$exists", "$not":
+				// hardly selective
+			default:
+				ranges[key] = true
+			}
+		}
+	}
+}
+
+// indexKeys ... the fields of the indexes of {collection}
+func indexKeys(ctx context.Context, collection *mongo.Collection) ([][]string, error) {
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var indexes []struct {
+		Key bson.D `bson:"key"`
+	}
+	if err = cursor.All(ctx, &indexes); err != nil {
+		return nil, err
+	}
+	ret := make([][]string, len(indexes))
+	for i, index := range indexes {
+		for _, key := range index.Key {
+			ret[i] = append(ret[i], key.Key)
+		}
+	}
+	return ret, nil
+}
+
+// covered ... an index of {existing} starts with the equality fields of {filter} (the first field of {keys} without them)
+func covered(keys []string, filter bson.Raw, existing [][]string) bool {
+	equality := map[string]bool{}
+	fieldsOf(filter, equality, map[string]bool{})
+	prefix := len(equality)
+	if prefix == 0 {
+		prefix = 1
+	}
+	if prefix > len(keys) {
+		prefix = len(keys)
+	}
+	wanted := map[string]bool{}
+	for _, key := range keys[:prefix] {
+		wanted[key] = true
+	}
+	for _, index := range existing {
+		if len(index) < prefix {
+			continue
+		}
+		found := 0
+		for _, key := range index[:prefix] {
+			if wanted[key] {
+				found++
+			}
+		}
+		if found == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// String ... a suggestion as in the shell: { white: 1, m01: 1 }
+func (suggestion Suggestion) String() string {
+	keys := make([]string, len(suggestion.Keys))
+	for i, key := range suggestion.Keys {
+		keys[i] = fmt.Sprintf("%s: 1", key)
+	}
+	return "{ " + strings.Join(keys, ", ") + " }"
+}
@@ -0,0 +1,189 @@
+package analyze
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/engine"
+	"github.com/flutterbar/chess-explorer-go/internal/evals"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/notnil/chess"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxEval ... mates and huge advantages count the same (centipawns, as the evaluations of the imports)
+const maxEval = 1000
+
+// Result ... games evaluated by the engine
+type Result struct {
+	Games     int `json:"games"`     // games evaluated
+	Positions int `json:"positions"` // positions evaluated
+	Failed    int `json:"failed"`    // games not evaluated (illegal move, engine error)
+	Engines   int `json:"engines"`   // engines run at once (engine-instances)
+	Restarts  int `json:"restarts"`  // crashed or stuck engines replaced
+	Cached    int `json:"cached"`    // positions already evaluated (evals collection)
+}
+
+// NewGames ... evaluate with the engine (or the cloud of lichess.org, eval-source) the positions of the games imported since {since} without evaluations
+// ({limit} games at most, 0: all, most recent imports first) and store the evaluations (evals, as the analysed games of lichess.org),
+// with settings.Instances engines at once
+func NewGames(ctx context.Context, since time.Time, limit int64, settings engine.Settings) (Result, error) {
+	result := Result{}
+
+	// Connect to DB
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(connectCtx)
+	if err != nil {
+		return result, err
+	}
+	defer client.Disconnect(context.Background())
+	games := mongodb.Collection(client, "games")
+
+	query := bson.M{
+		"provenance.importedat": bson.M{"$gte": since},
+		"evals":                 bson.M{"$exists": false},
+	}
+	findOptions := options.Find().
+		SetSort(bson.M{"provenance.importedat": -1}).
+		SetProjection(bson.M{"_id": 1, "pgn": 1})
+	if limit > 0 {
+		findOptions.SetLimit(limit)
+	}
+	cursor, err := games.Find(ctx, query, findOptions)
+	if err != nil {
+		return result, err
+	}
+	defer cursor.Close(ctx)
+
+	var toAnalyse []pgntodb.Game
+	if err = cursor.All(ctx, &toAnalyse); err != nil {
+		return result, err
+	}
+	if len(toAnalyse) == 0 {
+		return result, nil
+	}
+
+	source, err := evals.Source()
+	if err != nil {
+		return result, err
+	}
+	cache := evals.NewCache(client)
+	defer func() { result.Cached, _ = cache.Stats() }()
+	if source == evals.SourceCloud {
+		cloud, err := evals.Cloud(cache)
+		if err != nil {
+			return result, err
+		}
+		// one at a time: requests-per-minute of lichess.org
+		analyse(ctx, games, toAnalyse, 1, &result, func(game pgntodb.Game) ([]int, error) {
+			return evaluations(ctx, game.PGN, cloud)
+		})
+		return result, ctx.Err()
+	}
+
+	pool, err := engine.NewPool(settings)
+	if err != nil {
+		return result, err
+	}
+	defer pool.Close()
+	result.Engines = pool.Size()
+	analyse(ctx, games, toAnalyse, pool.Size(), &result, func(game pgntodb.Game) (gameEvals []int, err error) {
+		err = pool.Do(func(uci *engine.Engine) error {
+			if err := uci.NewGame(); err != nil {
+				return err
+			}
+			gameEvals, err = evaluations(ctx, game.PGN, evals.Engine(cache, uci, settings))
+			return err
+		})
+		return gameEvals, err
+	})
+	result.Restarts = pool.Stats().Restarts
+	return result, ctx.Err()
+}
+
+// analyse ... evaluate {toAnalyse} with {workers} goroutines and store the evaluations, counted in {result}
+func analyse(ctx context.Context, games *mongo.Collection, toAnalyse []pgntodb.Game, workers int, result *Result, evaluate func(game pgntodb.Game) ([]int, error)) {
+	queue := make(chan pgntodb.Game)
+	var mutex sync.Mutex
+	var running sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			for game := range queue {
+				gameEvals, err := evaluate(game)
+				if err == nil {
+					_, err = games.UpdateOne(ctx, bson.M{"_id": game.ID}, bson.M{"$set": bson.M{"evals": gameEvals}})
+				}
+				mutex.Lock()
+				if err != nil {
+					log.Warn("game " + game.ID + " not analysed: " + err.Error())
+					result.Failed++
+				} else {
+					result.Games++
+					result.Positions += len(gameEvals)
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, game := range toAnalyse {
+		if ctx.Err() != nil {
+			break
+		}
+		queue <- game
+	}
+	close(queue)
+	running.Wait()
+	if result.Games > 0 {
+		pgntodb.ForgetAllTrees(mongodb.ClientOf(games))
+	}
+}
+
+// evaluations ... centipawns (white's point of view, +/-maxEval) after every move of {moves} (e4 c5 Nf3: without the move numbers),
+// up to the first position {evaluate} has no evaluation for (cloud evaluations: the openings)
+func evaluations(ctx context.Context, moves string, evaluate evals.Evaluate) ([]int, error) {
+	position := chess.StartingPosition()
+	sans := pgn.Moves(moves)
+	scores := make([]int, 0, len(sans))
+	for _, san := range sans {
+		move, err := pgn.DecodeMove(position, san)
+		if err != nil {
+			return nil, err
+		}
+		position = position.Update(move)
+
+		var eval engine.Eval
+		switch position.Status() {
+		case chess.Checkmate:
+			eval.Mate = 1
+			if position.Turn() == chess.White {
+				eval.Mate = -1
+			}
+		case chess.Stalemate:
+		default:
+			found := false
+			if eval, found, err = evaluate(ctx, position); err != nil {
+				return nil, err
+			}
+			if !found {
+				return scores, nil
+			}
+		}
+		score := eval.Score()
+		if score > maxEval {
+			score = maxEval
+		} else if score < -maxEval {
+			score = -maxEval
+		}
+		scores = append(scores, score)
+	}
+	return scores, nil
+}
@@ -0,0 +1,301 @@
+package anki
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+/*
+Repertoire cards for Anki (spaced repetition), from the opening tree of a selection of games:
+  - at every position of the line where the repertoire side is to move, a card asks for the move played most often
+  - the opponent replies seen in at least MinGames games are followed
+
+File: CSV for File > Import in Anki (2.1.54 or later reads the header lines: separator, html, deck)
+  front  board diagram (inline SVG, from the side of the repertoire) and the moves so far
+  back   the expected move and how often it was played, with its score
+  tags   chess-explorer, white or black, the ECO code when known
+
+An Anki package (apkg) is not written: it is a SQLite database, a CSV import gives the same cards.
+*/
+
+// Options ... lines of the deck
+type Options struct {
+	Color    string // repertoire side: white or black
+	Depth    int    // moves (plies) after the starting line
+	MinGames int    // positions seen in fewer games are left out
+	Deck     string // name of the deck in Anki
+}
+
+// Deck ... opening tree of the games added with Add
+type Deck struct {
+	options Options
+	root    []string // moves of the starting line
+	tree    *node
+}
+
+type node struct {
+	games  int
+	points float64 // points of the repertoire side
+	moves  map[string]*node
+	order  []string // moves in order of appearance (stable output)
+	eco    string
+}
+
+// Card ... a question of the deck
+type Card struct {
+	Line     []string // moves before the question
+	Move     string   // expected move
+	Games    int      // games with this move
+	Total    int      // games reaching the position
+	Score    float64  // points per game of the repertoire side after the move
+	ECO      string
+	Position *chess.Position
+}
+
+// New ... a deck of the lines starting with {rootPgn} ("1. e4 c5", "" for the initial position)
+func New(rootPgn string, options Options) (*Deck, error) {
+	if options.Color != "white" && options.Color != "black" {
+		return nil, errors.New("Repertoire color must be white or black")
+	}
+	if options.Depth <= 0 {
+		options.Depth = 16
+	}
+	if options.MinGames <= 0 {
+		options.MinGames = 1
+	}
+	if options.Deck == "" {
+		options.Deck = "Chess repertoire (" + options.Color + ")"
+	}
+	return &Deck{options: options, root: moves(rootPgn), tree: newNode()}, nil
+}
+
+func newNode() *node {
+	return &node{moves: map[string]*node{}}
+}
+
+// Add ... a game ({pgn}: moves and result, as stored) to the tree
+func (deck *Deck) Add(pgn string, result string, eco string) {
+	gameMoves := moves(pgn)
+	if len(gameMoves) < len(deck.root) {
+		return
+	}
+	for i, move := range deck.root {
+		if gameMoves[i] != move {
+			return
+		}
+	}
+
+	points := 0.5
+	switch {
+	case result == "1-0" && deck.options.Color == "white", result == "0-1" && deck.options.Color == "black":
+		points = 1
+	case result == "1-0", result == "0-1":
+		points = 0
+	case result != "1/2-1/2":
+		return // unfinished game
+	}
+
+	current := deck.tree
+	current.games++
+	current.points += points
+	last := len(deck.root) + deck.options.Depth
+	for i := len(deck.root); i < len(gameMoves) && i < last; i++ {
+		next, found := current.moves[gameMoves[i]]
+		if !found {
+			next = newNode()
+			current.moves[gameMoves[i]] = next
+			current.order = append(current.order, gameMoves[i])
+		}
+		if next.eco == "" {
+			next.eco = eco
+		}
+		next.games++
+		next.points += points
+		current = next
+	}
+}
+
+// Cards ... the questions of the deck, in the order of the lines
+func (deck *Deck) Cards() ([]Card, error) {
+	game := chess.NewGame()
+	for _, move := range deck.root {
+		if err := game.MoveStr(move); err != nil {
+			return nil, fmt.Errorf("Invalid starting line: %w", err)
+		}
+	}
+	cards := make([]Card, 0)
+	err := deck.walk(deck.tree, game, append([]string{}, deck.root...), &cards)
+	return cards, err
+}
+
+func (deck *Deck) walk(current *node, game *chess.Game, line []string, cards *[]Card) error {
+	if current.games < deck.options.MinGames || len(current.moves) == 0 {
+		return nil
+	}
+	repertoireTurn := (game.Position().Turn() == chess.White) == (deck.options.Color == "white")
+
+	if repertoireTurn {
+		// the move played most often, the best score on a tie
+		best := ""
+		for _, move := range current.order {
+			child := current.moves[move]
+			if best == "" || child.games > current.moves[best].games ||
+				(child.games == current.moves[best].games && child.points > current.moves[best].points) {
+				best = move
+			}
+		}
+		child := current.moves[best]
+		if child.games < deck.options.MinGames {
+			return nil
+		}
+		*cards = append(*cards, Card{
+			Line:     append([]string{}, line...),
+			Move:     best,
+			Games:    child.games,
+			Total:    current.games,
+			Score:    child.points / float64(child.games),
+			ECO:      child.eco,
+			Position: game.Position(),
+		})
+		return deck.follow(current, best, game, line, cards)
+	}
+
+	for _, move := range current.order {
+		if current.moves[move].games >= deck.options.MinGames {
+			if err := deck.follow(current, move, game, line, cards); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// follow ... walk the tree after {move}
+func (deck *Deck) follow(current *node, move string, game *chess.Game, line []string, cards *[]Card) error {
+	next := game.Clone()
+	if err := next.MoveStr(move); err != nil {
+		// a move the chess library does not read (variant games): the line stops here
+		return nil
+	}
+	return deck.walk(current.moves[move], next, append(line, move), cards)
+}
+
+// Write ... the cards of the deck as a CSV file for Anki, number of cards
+func (deck *Deck) Write(w io.Writer) (int, error) {
+	cards, err := deck.Cards()
+	if err != nil {
+		return 0, err
+	}
+
+	header := "#separator:Comma\n#html:true\n#notetype:Basic\n#deck:" + deck.options.Deck + "\n#tags column:3\n"
+	if _, err = io.WriteString(w, header); err != nil {
+		return 0, err
+	}
+	writer := csv.NewWriter(w)
+	for _, card := range cards {
+		tags := "chess-explorer " + deck.options.Color
+		if card.ECO != "" {
+			tags += " " + card.ECO
+		}
+		if err = writer.Write([]string{deck.front(card), back(card), tags}); err != nil {
+			return 0, err
+		}
+	}
+	writer.Flush()
+	return len(cards), writer.Error()
+}
+
+func (deck *Deck) front(card Card) string {
+	flip := deck.options.Color == "black"
+	text := "Your move"
+	if len(card.Line) > 0 {
+		text = html.EscapeString(LineText(card.Line)) + " ..."
+	}
+	return boardSVG(card.Position.Board(), flip) + "<br>" + text
+}
+
+func back(card Card) string {
+	move := card.Move
+	if len(card.Line)%2 == 1 {
+		move = strconv.Itoa(len(card.Line)/2+1) + "... " + move
+	} else {
+		move = strconv.Itoa(len(card.Line)/2+1) + ". " + move
+	}
+	return fmt.Sprintf("<b>%s</b><br>played in %d of %d games, score %.0f%%",
+		html.EscapeString(move), card.Games, card.Total, 100*card.Score)
+}
+
+// LineText ... {line} with move numbers: 1. e4 c5 2. Nf3
+func LineText(line []string) string {
+	var builder strings.Builder
+	for i, move := range line {
+		if i > 0 {
+			builder.WriteString(" ")
+		}
+		if i%2 == 0 {
+			builder.WriteString(strconv.Itoa(i/2+1) + ". ")
+		}
+		builder.WriteString(move)
+	}
+	return builder.String()
+}
+
+// moves ... moves of a stored pgn (move numbers and result removed)
+func moves(pgn string) []string {
+	ret := make([]string, 0)
+	for _, token := range strings.Fields(pgn) {
+		if strings.HasSuffix(token, ".") {
+			continue
+		}
+		switch token {
+		case "1-0", "0-1", "1/2-1/2", "*":
+			continue
+		}
+		ret = append(ret, token)
+	}
+	return ret
+}
+
+// boardSVG ... diagram of {board}, black at the bottom when {flip}
+func boardSVG(board *chess.Board, flip bool) string {
+	const size = 30
+	pieces := map[chess.PieceType]string{
+		chess.King: "♚", chess.Queen: "♛", chess.Rook: "♜", chess.Bishop: "♝", chess.Knight: "♞", chess.Pawn: "♟",
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		8*size, 8*size, 8*size, 8*size))
+	squares := board.SquareMap()
+	for square := chess.A1; square <= chess.H8; square++ {
+		file, rank := int(square.File()), int(square.Rank())
+		x, y := file, 7-rank
+		if flip {
+			x, y = 7-file, rank
+		}
+		color := "#f0d9b5"
+		if (file+rank)%2 == 0 {
+			color = "#b58863"
+		}
+		builder.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`, x*size, y*size, size, size, color))
+		piece, found := squares[square]
+		if !found || piece == chess.NoPiece {
+			continue
+		}
+		fill, stroke := "#fff", "#000"
+		if piece.Color() == chess.Black {
+			fill, stroke = "#000", "#fff"
+		}
+		builder.WriteString(fmt.Sprintf(`<text x="%d" y="%d" font-size="%d" text-anchor="middle" fill="%s" stroke="%s" stroke-width="0.8">%s</text>`,
+			x*size+size/2, y*size+size-5, size-4, fill, stroke, pieces[piece.Type()]))
+	}
+	builder.WriteString("</svg>")
+	return builder.String()
+}
@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+Audit log of the destructive operations, in the audit collection: who, when, what (chess-explorer audit list).
+  delete          the games of a user (delete {user})
+  import-undo     the games of an import batch (import undo {batch})
+  backup-prune    the backups beyond the retention (backup --keep)
+  trash-purge     the deletions of the trash (trash purge, and the expired ones at every delete)
+  restore         a deletion put back from the trash (trash restore)
+An operation is recorded once done, with its error if it failed halfway; a failure to record is logged and does not
+fail the operation.
+*/
+
+// Operations
+const (
+	OpDelete      = "delete"
+	OpImportUndo  = "import-undo"
+	OpBackupPrune = "backup-prune"
+	OpTrashPurge  = "trash-purge"
+	OpRestore     = "restore"
+)
+
+// Entry ... a destructive operation
+type Entry struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Time      time.Time          `json:"time" bson:"time"`
+	Actor     string             `json:"actor" bson:"actor"` // {user}@{host} for the commands
+	Operation string             `json:"operation" bson:"operation"`
+	Target    string             `json:"target" bson:"target"`                       // user, batch... the operation was run on
+	Details   interface{}        `json:"details,omitempty" bson:"details,omitempty"` // result of the operation: games deleted...
+	Error     string             `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// Filter ... entries to list
+type Filter struct {
+	Operation string    // "": all
+	Since     time.Time // zero: all
+	Limit     int64     // most recent entries at most (0: all)
+}
+
+// LocalActor ... actor of the commands: {user}@{host} of the system
+func LocalActor() string {
+	name := "unknown"
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return name
+	}
+	return name + "@" + host
+}
+
+// Record ... store the operation {operation} of {actor} on {target} with its result {details} and error {opErr}
+// (a failure to record is logged)
+func Record(ctx context.Context, client *mongodb.Client, actor string, operation string, target string, details interface{}, opErr error) {
+	entry := Entry{Time: time.Now().UTC(), Actor: actor, Operation: operation, Target: target, Details: details}
+	if opErr != nil {
+		entry.Error = opErr.Error()
+	}
+	if _, err := mongodb.Collection(client, "audit").InsertOne(ctx, entry); err != nil {
+		log.Warn("audit of " + operation + " " + target + " not recorded: " + err.Error())
+	}
+}
+
+// RecordNow ... Record with a connection of its own
+func RecordNow(actor string, operation string, target string, details interface{}, opErr error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		log.Warn("audit of " + operation + " " + target + " not recorded: " + err.Error())
+		return
+	}
+	defer client.Disconnect(ctx)
+	Record(ctx, client, actor, operation, target, details, opErr)
+}
+
+// List ... entries of {filter}, most recent first
+func List(ctx context.Context, filter Filter) ([]Entry, error) {
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Disconnect(ctx)
+
+	query := bson.M{}
+	if filter.Operation != "" {
+		query["operation"] = filter.Operation
+	}
+	if !filter.Since.IsZero() {
+		query["time"] = bson.M{"$gte": filter.Since}
+	}
+	findOptions := options.Find().SetSort(bson.D{{Key: "time", Value: -1}})
+	if filter.Limit > 0 {
+		findOptions.SetLimit(filter.Limit)
+	}
+	cursor, err := mongodb.Collection(client, "audit").Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	entries := []Entry{}
+	err = cursor.All(ctx, &entries)
+	return entries, err
+}
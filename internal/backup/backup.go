@@ -0,0 +1,320 @@
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/audit"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+Backup of the database: every collection, one document per line (MongoDB extended JSON), gzipped
+  {"collection":"games","document":{...}}
+
+File names: chess-explorer-{database}-{UTC time}.jsonl.gz, written to a directory or to an S3 bucket (see s3.go)
+*/
+
+// line ... a document of a backup
+type line struct {
+	Collection string          `json:"collection"`
+	Document   json.RawMessage `json:"document"`
+}
+
+// Result ... a backup that was written
+type Result struct {
+	Name      string   `json:"name"`     // file name
+	Location  string   `json:"location"` // path or s3://bucket/key
+	Documents int      `json:"documents"`
+	Size      int64    `json:"size"`    // bytes
+	Removed   []string `json:"removed"` // older backups deleted (retention)
+}
+
+// prefix ... start of the file names of the backups of the database
+func prefix() string {
+	return "chess-explorer-" + viper.GetString("mongo-db-name") + "-"
+}
+
+// ownBackup ... {name} (file or key) is a backup of the database: the prefix followed by the time only, so that the
+// backups of database games-test are not those of database games
+func ownBackup(name string) bool {
+	return regexp.MustCompile(`^` + regexp.QuoteMeta(prefix()) + `\d{8}T\d{6}Z\.jsonl\.gz$`).MatchString(filepath.Base(name))
+}
+
+// ownBackups ... the backups of the database among {names}
+func ownBackups(names []string) []string {
+	own := []string{}
+	for _, name := range names {
+		if ownBackup(name) {
+			own = append(own, name)
+		}
+	}
+	return own
+}
+
+// ToDirectory ... write a backup in {dir} and keep the {keep} most recent ones (0: all)
+func ToDirectory(dir string, keep int) (*Result, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	result := Result{Name: prefix() + time.Now().UTC().Format("20060102T150405Z") + ".jsonl.gz"}
+	result.Location = filepath.Join(dir, result.Name)
+
+	file, err := os.Create(result.Location)
+	if err != nil {
+		return nil, err
+	}
+	result.Documents, err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(result.Location)
+		return nil, err
+	}
+	if info, err := os.Stat(result.Location); err == nil {
+		result.Size = info.Size()
+	}
+
+	names, err := filepath.Glob(filepath.Join(dir, prefix()+"*.jsonl.gz"))
+	if err != nil {
+		return &result, err
+	}
+	for _, old := range expired(ownBackups(names), keep) {
+		if err = os.Remove(old); err != nil {
+			break
+		}
+		log.Info("Removed old backup " + old)
+		result.Removed = append(result.Removed, old)
+	}
+	auditPrune(dir, result.Removed, err)
+	return &result, err
+}
+
+// ToS3 ... upload a backup to the bucket of the settings and keep the {keep} most recent ones (0: all)
+func ToS3(keep int) (*Result, error) {
+	bucket, err := newS3()
+	if err != nil {
+		return nil, err
+	}
+
+	// written to a temporary file first: the upload needs the size and the hash of the content
+	file, err := ioutil.TempFile("", "chess-explorer-backup-*.jsonl.gz")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	hash := sha256.New()
+	result := Result{Name: prefix() + time.Now().UTC().Format("20060102T150405Z") + ".jsonl.gz"}
+	if result.Documents, err = write(io.MultiWriter(file, hash)); err != nil {
+		return nil, err
+	}
+	if result.Size, err = file.Seek(0, io.SeekCurrent); err != nil {
+		return nil, err
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	key := bucket.prefix + result.Name
+	if err = bucket.put(key, file, result.Size, hex.EncodeToString(hash.Sum(nil))); err != nil {
+		return nil, err
+	}
+	result.Location = "s3://" + bucket.bucket + "/" + key
+
+	keys, err := bucket.list(bucket.prefix + prefix())
+	if err != nil {
+		return &result, err
+	}
+	for _, old := range expired(ownBackups(keys), keep) {
+		if err = bucket.delete(old); err != nil {
+			break
+		}
+		log.Info("Removed old backup s3://" + bucket.bucket + "/" + old)
+		result.Removed = append(result.Removed, "s3://"+bucket.bucket+"/"+old)
+	}
+	auditPrune("s3://"+bucket.bucket+"/"+bucket.prefix, result.Removed, err)
+	return &result, err
+}
+
+// auditPrune ... audit of the removal of the old backups {removed} of {location} (nothing removed: no audit)
+func auditPrune(location string, removed []string, err error) {
+	if len(removed) > 0 || err != nil {
+		audit.RecordNow(audit.LocalActor(), audit.OpBackupPrune, location, removed, err)
+	}
+}
+
+// expired ... the backups of {names} beyond the {keep} most recent ones (names sort by time)
+func expired(names []string, keep int) []string {
+	if keep <= 0 || len(names) <= keep {
+		return nil
+	}
+	sort.Strings(names)
+	return names[:len(names)-keep]
+}
+
+// write ... all collections of the database to {w}, number of documents
+func write(w io.Writer) (int, error) {
+	ctx := context.Background()
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(connectCtx)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Disconnect(ctx)
+
+	names, err := client.Database(client.Settings().Name).ListCollectionNames(ctx, bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(names)
+
+	zipper := gzip.NewWriter(w)
+	writer := bufio.NewWriter(zipper)
+	count := 0
+	for _, name := range names {
+		cursor, err := mongodb.Collection(client, name).Find(ctx, bson.M{})
+		if err != nil {
+			return count, err
+		}
+		for cursor.Next(ctx) {
+			// canonical extended JSON: int64 and double values are restored with their type
+			document, err := bson.MarshalExtJSON(cursor.Current, true, false)
+			if err != nil {
+				cursor.Close(ctx)
+				return count, err
+			}
+			data, err := json.Marshal(line{Collection: name, Document: document})
+			if err != nil {
+				cursor.Close(ctx)
+				return count, err
+			}
+			writer.Write(data)
+			if err = writer.WriteByte('\n'); err != nil {
+				cursor.Close(ctx)
+				return count, err
+			}
+			count++
+		}
+		err = cursor.Err()
+		cursor.Close(ctx)
+		if err != nil {
+			return count, err
+		}
+		log.Debug("Backed up collection " + name)
+	}
+	if err = writer.Flush(); err != nil {
+		return count, err
+	}
+	return count, zipper.Close()
+}
+
+// Restore ... insert the documents of the backup {path} (documents already in the database are skipped)
+func Restore(path string) (inserted int, skipped int, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+	unzipper, err := gzip.NewReader(file)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s is not a backup: %w", path, err)
+	}
+
+	ctx := context.Background()
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(connectCtx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer client.Disconnect(ctx)
+
+	gamesRestored := false
+	defer func() {
+		if gamesRestored {
+			pgntodb.ForgetAllTrees(client)
+		}
+	}()
+	batches := map[string][]interface{}{}
+	flush := func(collection string) error {
+		batch := batches[collection]
+		if len(batch) == 0 {
+			return nil
+		}
+		batches[collection] = batch[:0]
+		_, err := mongodb.Collection(client, collection).InsertMany(ctx, batch, options.InsertMany().SetOrdered(false))
+		failed := 0
+		var bulkError mongo.BulkWriteException
+		if errors.As(err, &bulkError) {
+			for _, writeError := range bulkError.WriteErrors {
+				if writeError.Code != 11000 {
+					return errors.New(writeError.Message)
+				}
+			}
+			failed = len(bulkError.WriteErrors)
+		} else if err != nil {
+			return err
+		}
+		inserted += len(batch) - failed
+		skipped += failed
+		if collection == "games" && len(batch) > failed {
+			gamesRestored = true
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(unzipper)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var documentLine line
+		if err = json.Unmarshal(scanner.Bytes(), &documentLine); err != nil {
+			return inserted, skipped, fmt.Errorf("%s is not a backup: %w", path, err)
+		}
+		var document bson.D
+		if err = bson.UnmarshalExtJSON(documentLine.Document, true, &document); err != nil {
+			return inserted, skipped, err
+		}
+		if strings.TrimSpace(documentLine.Collection) == "" {
+			return inserted, skipped, fmt.Errorf("%s is not a backup: no collection", path)
+		}
+		batches[documentLine.Collection] = append(batches[documentLine.Collection], document)
+		if len(batches[documentLine.Collection]) >= 1000 {
+			if err = flush(documentLine.Collection); err != nil {
+				return inserted, skipped, err
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return inserted, skipped, err
+	}
+	for collection := range batches {
+		if err = flush(collection); err != nil {
+			return inserted, skipped, err
+		}
+	}
+	return inserted, skipped, nil
+}
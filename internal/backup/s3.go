@@ -0,0 +1,270 @@
+package backup
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/httpclient"
+	"github.com/spf13/viper"
+)
+
+/*
+S3-compatible bucket (AWS S3, MinIO, Backblaze B2, Cloudflare R2, ...), requests signed with AWS Signature Version 4:
+  s3-endpoint    https://s3.eu-west-3.amazonaws.com, http://localhost:9000 ...
+  s3-region      default us-east-1
+  s3-bucket      bucket name
+  s3-prefix      start of the object keys, backups/ for example
+  s3-access-key  or the AWS_ACCESS_KEY_ID environment variable
+  s3-secret-key  or the AWS_SECRET_ACCESS_KEY environment variable
+
+Path-style URLs ({endpoint}/{bucket}/{key}), understood by all S3-compatible services.
+A backup is uploaded in a single request: 5 GB at most.
+*/
+
+type s3Bucket struct {
+	client    *httpclient.Client
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+}
+
+func newS3() (*s3Bucket, error) {
+	bucket := s3Bucket{
+		region:    viper.GetString("s3-region"),
+		bucket:    viper.GetString("s3-bucket"),
+		prefix:    viper.GetString("s3-prefix"),
+		accessKey: viper.GetString("s3-access-key"),
+		secretKey: viper.GetString("s3-secret-key"),
+	}
+	if bucket.region == "" {
+		bucket.region = "us-east-1"
+	}
+	if bucket.accessKey == "" {
+		bucket.accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if bucket.secretKey == "" {
+		bucket.secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+
+	endpoint := viper.GetString("s3-endpoint")
+	if endpoint == "" || bucket.bucket == "" {
+		return nil, errors.New("S3 backups need the s3-endpoint and s3-bucket settings")
+	}
+	if bucket.accessKey == "" || bucket.secretKey == "" {
+		return nil, errors.New("S3 backups need the s3-access-key and s3-secret-key settings")
+	}
+	var err error
+	if bucket.endpoint, err = url.Parse(strings.TrimSuffix(endpoint, "/")); err != nil {
+		return nil, fmt.Errorf("Invalid s3-endpoint %s: %w", endpoint, err)
+	}
+	if bucket.endpoint.Scheme != "http" && bucket.endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("Invalid s3-endpoint %s: expected an http:// or https:// URL", endpoint)
+	}
+
+	if bucket.client, err = httpclient.New(); err != nil {
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+// put ... upload {body} ({size} bytes, {payloadHash}: hex sha256) as {key}
+func (bucket *s3Bucket) put(key string, body io.ReadSeeker, size int64, payloadHash string) error {
+	req, err := http.NewRequest("PUT", bucket.objectURL(key, nil), ioutil.NopCloser(body))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.GetBody = func() (io.ReadCloser, error) {
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(body), nil
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	_, err = bucket.do(req, payloadHash)
+	return err
+}
+
+// list ... keys starting with {prefix}
+func (bucket *s3Bucket) list(prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	continuation := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if continuation != "" {
+			query.Set("continuation-token", continuation)
+		}
+		req, err := http.NewRequest("GET", bucket.objectURL("", query), nil)
+		if err != nil {
+			return nil, err
+		}
+		body, err := bucket.do(req, emptyHash)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		if err = xml.Unmarshal(body, &result); err != nil {
+			return nil, err
+		}
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		continuation = result.NextContinuationToken
+	}
+}
+
+// delete ... remove {key}
+func (bucket *s3Bucket) delete(key string) error {
+	req, err := http.NewRequest("DELETE", bucket.objectURL(key, nil), nil)
+	if err != nil {
+		return err
+	}
+	_, err = bucket.do(req, emptyHash)
+	return err
+}
+
+// emptyHash ... sha256 of an empty payload
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func (bucket *s3Bucket) objectURL(key string, query url.Values) string {
+	objectURL := *bucket.endpoint
+	objectURL.Path = bucket.endpoint.Path + "/" + bucket.bucket + "/" + key
+	objectURL.RawPath = bucket.endpoint.Path + "/" + escapePath(bucket.bucket+"/"+key)
+	objectURL.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+	return objectURL.String()
+}
+
+// do ... sign and send {req}, body of the answer
+func (bucket *s3Bucket) do(req *http.Request, payloadHash string) ([]byte, error) {
+	bucket.sign(req, payloadHash, time.Now().UTC())
+	resp, err := bucket.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var s3Error struct {
+			Code    string
+			Message string
+		}
+		message := resp.Status
+		if xml.Unmarshal(body, &s3Error) == nil && s3Error.Code != "" {
+			message += " " + s3Error.Code + ": " + s3Error.Message
+		}
+		return nil, &url.Error{Op: req.Method, URL: req.URL.Redacted(), Err: errors.New(message)}
+	}
+	return body, nil
+}
+
+// sign ... add the AWS Signature Version 4 headers to {req}
+func (bucket *s3Bucket) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("Content-Type") != "" {
+		signedHeaders = append(signedHeaders, "content-type")
+		sort.Strings(signedHeaders)
+	}
+	canonicalHeaders := ""
+	for _, header := range signedHeaders {
+		value := req.Header.Get(header)
+		if header == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders += header + ":" + strings.TrimSpace(value) + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + bucket.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+bucket.secretKey), day)
+	key = hmacSHA256(key, bucket.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+bucket.accessKey+"/"+scope+
+		", SignedHeaders="+strings.Join(signedHeaders, ";")+", Signature="+signature)
+}
+
+// canonicalQuery ... query sorted by name, values escaped as AWS expects
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, escape(name)+"="+escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escape ... URI encoding of AWS: everything but A-Z a-z 0-9 - _ . ~
+func escape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+// escapePath ... escape, the slashes kept
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,149 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/server"
+)
+
+/*
+Benchmark of the configured database (chess-explorer bench): a representative mix of queries of the web page and the
+exports, run several times, with the percentiles of their latencies. Every query connects to the database as a request
+of the server does, so the numbers compare backends, indexes and hardware as the users see them.
+*/
+
+// ruyLopez ... a long main line (Breyer variation), 24 plies
+const ruyLopez = "1. e4 e5 2. Nf3 Nc6 3. Bb5 a6 4. Ba4 Nf6 5. O-O Be7 6. Re1 b5 7. Bb3 d6 8. c3 O-O 9. h3 Nb8 10. d4 Nbd7 11. Nbd2 Bb7 12. Bc2 Re8"
+
+// queenGambitDeclined ... a position reached by many move orders
+const queenGambitDeclined = "rnbqkb1r/ppp2ppp/4pn2/3p4/2PP4/5N2/PP2PPPP/RNBQKB1R w KQkq - 2 4"
+
+// Query ... a query of the mix
+type Query struct {
+	Name   string
+	Values url.Values // pgn, fen, aggregation... on top of the filter of the benchmark
+	Export bool       // export of the games as PGN, instead of the next moves
+}
+
+// Stats ... latencies of a query, in milliseconds
+type Stats struct {
+	Name    string  `json:"name"`
+	Runs    int     `json:"runs"`
+	Errors  int     `json:"errors"`
+	Error   string  `json:"error,omitempty"` // last error
+	Results int     `json:"results"`         // moves or games of the last run
+	Min     float64 `json:"min"`
+	P50     float64 `json:"p50"`
+	P90     float64 `json:"p90"`
+	P99     float64 `json:"p99"`
+	Max     float64 `json:"max"`
+	Mean    float64 `json:"mean"`
+}
+
+// Report ... the latencies of the mix
+type Report struct {
+	Runs    int     `json:"runs"`
+	Queries []Stats `json:"queries"`
+	TotalMs float64 `json:"totalMs"`
+}
+
+// Mix ... the queries of the benchmark: shallow and deep next moves (both aggregation paths), lookup of a position,
+// exports
+func Mix() []Query {
+	since := time.Now().UTC().AddDate(0, -1, 0).Format("2006-01-02")
+	return []Query{
+		{Name: "nextmoves start", Values: url.Values{"pgn": {""}}},
+		{Name: "nextmoves shallow", Values: url.Values{"pgn": {"1. e4 c5"}}},
+		{Name: "nextmoves deep", Values: url.Values{"pgn": {ruyLopez}}},
+		{Name: "nextmoves deep algorithmic", Values: url.Values{"pgn": {ruyLopez}, "aggregation": {"algorithmic"}}},
+		{Name: "fen lookup", Values: url.Values{"fen": {queenGambitDeclined}}},
+		{Name: "export line", Values: url.Values{"pgn": {"1. e4 e5 2. Nf3 Nc6 3. Bb5"}}, Export: true},
+		{Name: "export last month", Values: url.Values{"from": {since}}, Export: true},
+	}
+}
+
+// Run ... run every query of {queries} {runs} times on the games of {filter}, after a run to warm up the caches
+// (not measured)
+func Run(filter url.Values, queries []Query, runs int) (*Report, error) {
+	if runs < 1 {
+		return nil, fmt.Errorf("invalid number of runs: %d (1 at least)", runs)
+	}
+	start := time.Now()
+	report := Report{Runs: runs, Queries: []Stats{}}
+	for _, query := range queries {
+		values := url.Values{}
+		for key, value := range filter {
+			values[key] = value
+		}
+		for key, value := range query.Values {
+			values[key] = value
+		}
+		if _, err := run(query, values); err != nil {
+			return nil, fmt.Errorf("%s: %w", query.Name, err)
+		}
+
+		stats := Stats{Name: query.Name, Runs: runs}
+		durations := make([]time.Duration, 0, runs)
+		for i := 0; i < runs; i++ {
+			queryStart := time.Now()
+			results, err := run(query, values)
+			if err != nil {
+				stats.Errors++
+				stats.Error = err.Error()
+				continue
+			}
+			durations = append(durations, time.Since(queryStart))
+			stats.Results = results
+		}
+		stats.summarize(durations)
+		report.Queries = append(report.Queries, stats)
+	}
+	report.TotalMs = milliseconds(time.Since(start))
+	return &report, nil
+}
+
+// run ... run {query} once with the filter {values}: moves or games found
+func run(query Query, values url.Values) (int, error) {
+	filter := server.NewGameFilter(values)
+	if query.Export {
+		return server.Export(context.Background(), io.Discard, filter)
+	}
+	moves, err := server.Explore(context.Background(), filter)
+	return len(moves), err
+}
+
+// summarize ... the percentiles of {durations} (nearest rank)
+func (stats *Stats) summarize(durations []time.Duration) {
+	if len(durations) == 0 {
+		return
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	percentile := func(p float64) float64 {
+		rank := int(math.Ceil(p/100*float64(len(durations)))) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		return milliseconds(durations[rank])
+	}
+	total := time.Duration(0)
+	for _, duration := range durations {
+		total += duration
+	}
+	stats.Min = milliseconds(durations[0])
+	stats.P50 = percentile(50)
+	stats.P90 = percentile(90)
+	stats.P99 = percentile(99)
+	stats.Max = milliseconds(durations[len(durations)-1])
+	stats.Mean = milliseconds(total / time.Duration(len(durations)))
+}
+
+// milliseconds ... {duration} in milliseconds, to the hundredth
+func milliseconds(duration time.Duration) float64 {
+	return math.Round(float64(duration.Microseconds())/10) / 100
+}
@@ -0,0 +1,167 @@
+package book
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/server"
+	"github.com/notnil/chess"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+/*
+Opening book answering a minimal UCI (chess-explorer book): a GUI adds it as an engine and gets the moves of the
+games of a profile (the filter) while in book, bestmove (none) afterwards.
+  uci, isready, ucinewgame, quit
+  position startpos [moves e2e4 ...] | position fen {FEN} [moves ...]
+  go ...                                a book move: bestmove e2e4 (info string with the candidates)
+*/
+
+// Options ... the book
+type Options struct {
+	Values    url.Values // filter form values: the profile (pgn and fen are ignored)
+	Weighting string     // server.BookWeightGames, BookWeightScore or BookWeightBest
+	MinGames  int        // moves played in less games are left out
+}
+
+// Run ... answer the UCI commands of {in} on {out} until quit or the end of {in}
+func Run(in io.Reader, out io.Writer, options Options) error {
+	valid := false
+	for _, weighting := range server.BookWeightings {
+		valid = valid || options.Weighting == weighting
+	}
+	if !valid {
+		return fmt.Errorf("invalid weight %q: %s", options.Weighting, strings.Join(server.BookWeightings, ", "))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	client, err := mongodb.Connect(ctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+	games := mongodb.Collection(client, "games")
+
+	// the filter is checked once, with the moves of the starting position
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+	candidates, err := server.BookCandidates(ctx, games, server.NewGameFilter(withPosition(options.Values, chess.StartingPosition())), options.Weighting, options.MinGames)
+	cancel()
+	if err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Book ready: %d first moves", len(candidates)))
+
+	position := chess.StartingPosition()
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "uci":
+			fmt.Fprintln(out, "id name chess-explorer book")
+			fmt.Fprintln(out, "id author chess-explorer")
+			fmt.Fprintln(out, "uciok")
+		case "isready":
+			fmt.Fprintln(out, "readyok")
+		case "ucinewgame":
+			position = chess.StartingPosition()
+		case "position":
+			if position, err = parsePosition(fields[1:]); err != nil {
+				fmt.Fprintln(out, "info string "+err.Error())
+				position = nil
+			}
+		case "go":
+			fmt.Fprintln(out, "bestmove "+bookMove(games, position, options, out))
+		case "quit":
+			return nil
+		default:
+			fmt.Fprintln(out, "info string unknown command "+fields[0])
+		}
+	}
+	return scanner.Err()
+}
+
+// bookMove ... UCI move of the book in {position}, (none) out of book
+func bookMove(games *mongo.Collection, position *chess.Position, options Options, out io.Writer) string {
+	if position == nil {
+		return "(none)"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	candidates, err := server.BookCandidates(ctx, games, server.NewGameFilter(withPosition(options.Values, position)), options.Weighting, options.MinGames)
+	if err != nil {
+		log.Warn(err)
+		fmt.Fprintln(out, "info string "+err.Error())
+		return "(none)"
+	}
+	move := server.ChooseBookMove(candidates, nil)
+	if move == nil {
+		return "(none)"
+	}
+	descriptions := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		descriptions[i] = candidate.String()
+	}
+	fmt.Fprintln(out, "info string book "+strings.Join(descriptions, ", "))
+	return move.UCI
+}
+
+// parsePosition ... position of the arguments of the position command: startpos or fen {FEN}, then moves {UCI moves}
+func parsePosition(args []string) (*chess.Position, error) {
+	var game *chess.Game
+	switch {
+	case len(args) > 0 && args[0] == "startpos":
+		game = chess.NewGame()
+		args = args[1:]
+	case len(args) > 0 && args[0] == "fen":
+		end := len(args)
+		for i, arg := range args {
+			if arg == "moves" {
+				end = i
+				break
+			}
+		}
+		option, err := chess.FEN(strings.Join(args[1:end], " "))
+		if err != nil {
+			return nil, fmt.Errorf("invalid FEN: %v", err)
+		}
+		game = chess.NewGame(option)
+		args = args[end:]
+	default:
+		return nil, fmt.Errorf("invalid position command: position startpos or position fen {FEN}, then moves")
+	}
+	if len(args) > 0 && args[0] == "moves" {
+		for _, uci := range args[1:] {
+			move, err := chess.UCINotation{}.Decode(game.Position(), uci)
+			if err != nil {
+				return nil, fmt.Errorf("invalid move %s: %v", uci, err)
+			}
+			if err = game.Move(move); err != nil {
+				return nil, fmt.Errorf("illegal move %s: %v", uci, err)
+			}
+		}
+	}
+	return game.Position(), nil
+}
+
+// withPosition ... filter form values of the profile {values} in {position}
+func withPosition(values url.Values, position *chess.Position) url.Values {
+	ret := url.Values{}
+	for key := range values {
+		if key != "pgn" && key != "fen" {
+			ret.Set(key, values.Get(key))
+		}
+	}
+	ret.Set("fen", position.String())
+	return ret
+}
@@ -1,15 +1,17 @@
 package chesscom
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"log"
-	http "net/http"
-	"os"
-
-	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/httpclient"
+	"github.com/flutterbar/chess-explorer-go/internal/sites"
+	log "github.com/sirupsen/logrus"
 )
 
 /*
@@ -23,117 +25,85 @@ type archivesContainer struct {
 	Archives []string `json:"archives"`
 }
 
-// DownloadGames ... Downloads games from Chess.com for {username}
-func DownloadGames(username string, keepPgn string) {
+// Client ... chess.com games (sites.SiteClient), one archive per month
+type Client struct{}
 
-	// Download archive list
-	client := &http.Client{}
-	archivesURL := "https://api.chess.com/pub/player/" + username + "/games/archives"
+func init() {
+	sites.Register(Client{})
+}
+
+// Name ... site of the games
+func (Client) Name() string {
+	return "chess.com"
+}
 
-	archivesContainer := archivesContainer{}
-	resp, err := client.Get(archivesURL)
+// ListArchives ... monthly archives of {username} from the month of {since}, most recent first
+func (Client) ListArchives(ctx context.Context, client *httpclient.Client, username string, since time.Time) ([]sites.Archive, error) {
+	archivesURL := "https://api.chess.com/pub/player/" + username + "/games/archives"
+	req, err := http.NewRequestWithContext(ctx, "GET", archivesURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := sites.Fetch(client, req)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	json.NewDecoder(resp.Body).Decode(&archivesContainer)
 	defer resp.Body.Close()
 
-	// Get most recent game from database to avoid downloading duplicates
-	lastGame := pgntodb.FindLastGame(username, "chess.com")
-	if lastGame.DateTime.IsZero() {
-		log.Println("New user")
-	} else {
-		log.Println("Most recent game in database: " + lastGame.GameID)
+	container := archivesContainer{}
+	if err = json.NewDecoder(resp.Body).Decode(&container); err != nil {
+		return nil, err
 	}
 
-	// Create the keep file if needed
-	var keepPgnFile *os.File
-	if keepPgn != "" {
-		keepPgnFile, err = os.OpenFile(keepPgn, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-		if err != nil {
-			log.Fatal(err)
+	archives := make([]sites.Archive, 0, len(container.Archives))
+	for i := len(container.Archives) - 1; i > -1; i-- {
+		if month := archiveMonth(container.Archives[i]); !since.IsZero() && !month.IsZero() && month.Before(monthOf(since)) {
+			break // older months are in the database
 		}
-		defer keepPgnFile.Close()
+		archives = append(archives, sites.Archive{URL: container.Archives[i] + "/pgn"})
 	}
-
-	// Download PGN files most recent first
-	// Store games in database
-	// Stop on first duplicate
-	for i := len(archivesContainer.Archives) - 1; i > -1; i-- {
-		log.Println("GET " + archivesContainer.Archives[i] + "/pgn")
-		goOn := downloadArchive(client, archivesContainer.Archives[i]+"/pgn", lastGame, keepPgnFile)
-		if goOn == false {
-			break
-		}
+	for i := range archives {
+		archives[i].Description = fmt.Sprintf("Archive %d/%d", i+1, len(archives))
 	}
+	return archives, nil
 }
 
-func downloadArchive(client *http.Client, url string, lastGame *pgntodb.LastGame, keepPgnFile *os.File) bool {
-
-	// Random file name
-	tmpfile, err := ioutil.TempFile("", "chesscom")
+// DownloadSince ... PGN of the month of {archive} (all its games: the import stops at the most recent game of the database)
+func (Client) DownloadSince(ctx context.Context, client *httpclient.Client, archive sites.Archive, since time.Time) (*http.Response, error) {
+	log.Println("GET " + archive.URL)
+	req, err := http.NewRequestWithContext(ctx, "GET", archive.URL, nil)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	defer os.Remove(tmpfile.Name()) // clean up
+	return sites.Fetch(client, req)
+}
 
-	// Create the temp file
-	f, err := os.OpenFile(tmpfile.Name(), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-	if err != nil {
-		log.Fatal(err)
+// Normalize ... the Site tag of chess.com is Chess.com
+func (Client) Normalize(gameMap map[string]string) {
+	if strings.EqualFold(gameMap["Site"], "chess.com") {
+		gameMap["Site"] = "chess.com"
 	}
-	defer f.Close()
+}
 
-	// Send request
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Fatal(err)
+// archiveMonth ... first day of the month of an archive URL (.../games/2021/03), zero if not found
+func archiveMonth(archiveURL string) time.Time {
+	parts := strings.Split(strings.TrimSuffix(archiveURL, "/"), "/")
+	if len(parts) < 2 {
+		return time.Time{}
 	}
-
-	resp, err := client.Do(req)
-
+	year, err := strconv.Atoi(parts[len(parts)-2])
 	if err != nil {
-		log.Fatal(err)
+		return time.Time{}
 	}
-
-	// stream response
-	buf := make([]byte, 10000)
-
-	numBytesRead := 0
-	// Read the response body
-	for {
-		n, err := resp.Body.Read(buf)
-
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatal(err)
-		}
-		numBytesRead += n
-		fmt.Print(".")
-
-		n, err = f.Write(buf[0:n])
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		if keepPgnFile != nil {
-			n, err = keepPgnFile.Write(buf[0:n])
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
-
-		if err != nil {
-			log.Fatal("Error reading HTTP response: ", err.Error())
-		}
+	month, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil || month < 1 || month > 12 {
+		return time.Time{}
 	}
+	return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+}
 
-	fmt.Println()
-
-	log.Println(numBytesRead, " bytes read")
-
-	// parse file
-	return pgntodb.Process(tmpfile.Name(), lastGame)
+// monthOf ... first day of the month of {t} (UTC, as the archives)
+func monthOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
 }
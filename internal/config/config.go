@@ -0,0 +1,244 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/engine"
+	"github.com/flutterbar/chess-explorer-go/internal/httpclient"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/sites"
+	"github.com/spf13/viper"
+)
+
+/*
+Effective configuration (chess-explorer config show) and its verification (chess-explorer config check)
+
+Precedence: command line flag > environment variable > profile > config file > default
+*/
+
+// Setting ... effective value of a setting and where it comes from
+type Setting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"` // flag, env {NAME}, profile {name}, config file, default
+}
+
+// Settings ... all settings, {flagChanged} tells whether the command line set a key
+func Settings(flagChanged func(key string) bool) []Setting {
+	profileSettings := map[string]interface{}{}
+	profile := viper.GetString("profile")
+	if profile != "" {
+		profileSettings = viper.GetStringMap("profiles." + profile)
+	}
+
+	settings := make([]Setting, 0)
+	for _, key := range viper.AllKeys() {
+		if strings.HasPrefix(key, "profiles.") {
+			continue
+		}
+		setting := Setting{Key: key, Value: display(key, viper.Get(key))}
+		_, inProfile := profileSettings[key]
+		switch {
+		case flagChanged(key):
+			setting.Source = "flag"
+		case os.Getenv(strings.ToUpper(key)) != "":
+			setting.Source = "env " + strings.ToUpper(key)
+		case inProfile:
+			setting.Source = "profile " + profile
+		case viper.InConfig(key):
+			setting.Source = "config file"
+		default:
+			setting.Source = "default"
+		}
+		settings = append(settings, setting)
+	}
+	sort.Slice(settings, func(i, j int) bool {
+		return settings[i].Key < settings[j].Key
+	})
+	return settings
+}
+
+// display ... {value} as text, secrets hidden (tokens, secret keys, password of the MongoDB URL)
+func display(key string, value interface{}) string {
+	text := fmt.Sprint(value)
+	if slice, ok := value.([]interface{}); ok {
+		bits := make([]string, len(slice))
+		for i, bit := range slice {
+			bits[i] = fmt.Sprint(bit)
+		}
+		text = strings.Join(bits, ", ")
+	}
+	switch {
+	case text == "":
+		return ""
+	case strings.Contains(key, "token") || strings.Contains(key, "secret"):
+		if len(text) <= 4 {
+			return "****"
+		}
+		return "****" + text[len(text)-4:]
+	case strings.HasSuffix(key, "url"):
+		if parsed, err := url.Parse(text); err == nil {
+			return parsed.Redacted()
+		}
+	}
+	return text
+}
+
+// Check statuses
+const (
+	StatusOK      = "ok"
+	StatusWarning = "warning"
+	StatusError   = "error"
+)
+
+// Check ... result of a verification
+type Check struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"` // ok, warning or error
+	Message string `json:"message"`
+	Err     error  `json:"-"`
+}
+
+// Checks ... verify the config file, the database, the engine, the downloads settings and the users
+func Checks() []Check {
+	return []Check{
+		checkConfigFile(),
+		checkDatabase(),
+		checkEngine(),
+		checkProxy(),
+		checkLichessToken(),
+		checkUsers(),
+	}
+}
+
+func ok(name string, message string) Check {
+	return Check{Name: name, Status: StatusOK, Message: message}
+}
+
+func warning(name string, message string) Check {
+	return Check{Name: name, Status: StatusWarning, Message: message}
+}
+
+func failed(name string, err error) Check {
+	return Check{Name: name, Status: StatusError, Message: err.Error(), Err: err}
+}
+
+func checkConfigFile() Check {
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		return warning("config file", "no config file, using defaults (chess-explorer init creates one)")
+	}
+	file := viper.New()
+	file.SetConfigFile(path)
+	if err := file.ReadInConfig(); err != nil {
+		return failed("config file", err)
+	}
+	return ok("config file", path)
+}
+
+func checkDatabase() Check {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		return failed("database", err)
+	}
+	defer client.Disconnect(ctx)
+
+	games, err := mongodb.Collection(client, "games").EstimatedDocumentCount(ctx)
+	if err != nil {
+		return failed("database", err)
+	}
+	return ok("database", fmt.Sprintf("%s, database %s (%d games)", display("mongo-url", viper.GetString("mongo-url")), viper.GetString("mongo-db-name"), games))
+}
+
+func checkEngine() Check {
+	settings := engine.SettingsFromConfig()
+	if settings.Path == "" {
+		return warning("engine", "no engine-path, commands analyzing games need --engine")
+	}
+	path, err := exec.LookPath(settings.Path)
+	if err != nil {
+		return failed("engine", err)
+	}
+
+	// a program which is not a UCI engine may never answer
+	started := make(chan error, 1)
+	go func() {
+		running, err := engine.Start(settings)
+		if err == nil {
+			running.Close()
+		}
+		started <- err
+	}()
+	select {
+	case err = <-started:
+		if err != nil {
+			return failed("engine", err)
+		}
+	case <-time.After(10 * time.Second):
+		return failed("engine", errors.New(path+" does not answer as a UCI engine"))
+	}
+	return ok("engine", path)
+}
+
+func checkProxy() Check {
+	if _, err := httpclient.New(); err != nil {
+		return failed("proxy", err)
+	}
+	if viper.GetString("proxy") == "" {
+		return ok("proxy", "none (or HTTP_PROXY and HTTPS_PROXY environment variables)")
+	}
+	return ok("proxy", display("proxy", viper.GetString("proxy")))
+}
+
+func checkLichessToken() Check {
+	token := viper.GetString("lichess-token")
+	if token == "" {
+		return ok("lichess token", "none (slower lichess.org downloads)")
+	}
+	client, err := httpclient.New()
+	if err != nil {
+		return failed("lichess token", err)
+	}
+	req, err := http.NewRequest("GET", "https://lichess.org/api/account", nil)
+	if err != nil {
+		return failed("lichess token", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return failed("lichess token", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return failed("lichess token", errors.New("rejected by lichess.org ("+resp.Status+")"))
+	}
+	return ok("lichess token", "accepted by lichess.org")
+}
+
+func checkUsers() Check {
+	users := viper.GetStringSlice("users")
+	if len(users) == 0 {
+		return ok("users", "none in the config file")
+	}
+	for _, user := range users {
+		parts := strings.SplitN(user, ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return failed("users", errors.New("invalid user "+user+" (expected site:username, lichess.org:username for example)"))
+		}
+		if _, found := sites.Get(parts[0]); !found {
+			return failed("users", errors.New("invalid user "+user+": "+sites.UnknownSiteError(parts[0]).Error()))
+		}
+	}
+	return ok("users", strings.Join(users, ", "))
+}
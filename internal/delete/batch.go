@@ -0,0 +1,144 @@
+package delete
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/audit"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BatchResult ... what was deleted by undoing an import batch
+type BatchResult struct {
+	Batch        string `json:"batch"`
+	Games        int64  `json:"games"`
+	UsersUpdated int    `json:"usersupdated"`       // most recent game of the user is now an older one (next download gets the games again)
+	Users        int    `json:"users"`              // users without games left (the batch downloaded their games first)
+	Deletion     string `json:"deletion,omitempty"` // in the trash (see trash.go)
+	DryRun       bool   `json:"dryrun,omitempty"`
+}
+
+// Batch ... move the games inserted by the import batch {batch} (see pgntodb.Provenance) to the trash,
+// the most recent game of the users is moved back so their next download gets the games again
+// ({dryRun}: count only); trash restore {batch} puts the games and the most recent games back
+// (the database of {ctx}, see mongodb.WithSettings)
+func Batch(ctx context.Context, batch string, dryRun bool) (BatchResult, error) {
+	result := BatchResult{Batch: batch, DryRun: dryRun}
+
+	// Connect to DB
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer client.Disconnect(ctx)
+
+	gamesCollection := mongodb.Collection(client, "games")
+	inBatch := bson.M{"provenance.batch": batch}
+	result.Games, err = gamesCollection.CountDocuments(ctx, inBatch)
+	if err != nil {
+		return result, err
+	}
+	if result.Games == 0 {
+		return result, fmt.Errorf("No games in batch %s (see import list)", batch)
+	}
+
+	// Users whose most recent game comes from the batch
+	lastgamesCollection := mongodb.Collection(client, "lastgames")
+	cursor, err := lastgamesCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return result, err
+	}
+	var allUsers []pgntodb.LastGame
+	if err = cursor.All(ctx, &allUsers); err != nil {
+		return result, err
+	}
+	users := make([]pgntodb.LastGame, 0)
+	for _, user := range allUsers {
+		count, err := gamesCollection.CountDocuments(ctx, bson.M{"_id": user.GameID, "provenance.batch": batch})
+		if err != nil {
+			return result, err
+		}
+		if count > 0 {
+			users = append(users, user)
+		}
+	}
+
+	// Their most recent game out of the batch
+	collation := options.Collation{Locale: "en", Strength: 2} // case insensitive search
+	updates := make(map[int]*pgntodb.LastGame)
+	for i, user := range users {
+		findOptions := options.FindOne().SetSort(bson.M{"datetime": -1}).SetCollation(&collation).
+			SetProjection(bson.M{"datetime": 1})
+		var game pgntodb.Game
+		err = gamesCollection.FindOne(ctx, bson.M{
+			"site":             user.Site,
+			"$or":              bson.A{bson.M{"white": user.Username}, bson.M{"black": user.Username}},
+			"provenance.batch": bson.M{"$ne": batch},
+			"owner":            pgntodb.OwnerValue(user.Owner),
+		}, findOptions).Decode(&game)
+		switch {
+		case err == mongo.ErrNoDocuments:
+			result.Users++
+			updates[i] = nil
+		case err != nil:
+			return result, err
+		default:
+			result.UsersUpdated++
+			updates[i] = &pgntodb.LastGame{Username: user.Username, Site: user.Site, DateTime: game.DateTime, GameID: game.ID, Owner: user.Owner}
+		}
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	now := time.Now().UTC()
+	deletion := Deletion{ID: batch + "@" + now.Format("20060102T150405Z"), Target: batch, Deleted: now}
+	err = removeBatch(ctx, client, inBatch, users, updates, deletion, &result)
+	audit.Record(ctx, client, audit.LocalActor(), audit.OpImportUndo, batch, result, err)
+	if err == nil {
+		if _, purgeErr := purgeTrash(ctx, client, false); purgeErr != nil {
+			log.Warn("trash not purged: " + purgeErr.Error())
+		}
+	}
+	return result, err
+}
+
+// removeBatch ... move the games of {inBatch} and the most recent games of {users} to the trash as {deletion}, then
+// set the most recent games of {users} to {updates} (nil: the user has no games left)
+func removeBatch(ctx context.Context, client *mongodb.Client, inBatch bson.M, users []pgntodb.LastGame,
+	updates map[int]*pgntodb.LastGame, deletion Deletion, result *BatchResult) error {
+	defer pgntodb.ForgetAllTrees(client)
+	result.Deletion = deletion.ID
+	moved, err := moveToTrash(ctx, client, "games", inBatch, nil, deletion)
+	result.Games = moved
+	if err != nil || len(users) == 0 {
+		return err
+	}
+
+	userFilters := make(bson.A, len(users))
+	for i, user := range users {
+		userFilters[i] = bson.M{"site": user.Site, "username": user.Username, "owner": pgntodb.OwnerValue(user.Owner)}
+	}
+	if _, err = moveToTrash(ctx, client, "lastgames", bson.M{"$or": userFilters}, nil, deletion); err != nil {
+		return err
+	}
+	lastgamesCollection := mongodb.Collection(client, "lastgames")
+	for i, update := range updates {
+		if update == nil {
+			continue
+		}
+		_, err = lastgamesCollection.ReplaceOne(ctx, userFilters[i], update, options.Replace().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
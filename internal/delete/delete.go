@@ -2,15 +2,16 @@ package delete
 
 import (
 	"context"
-	"log"
+	"errors"
 	"strings"
 	"time"
 
-	"github.com/spf13/viper"
+	"github.com/flutterbar/chess-explorer-go/internal/audit"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	log "github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 type user struct {
@@ -21,8 +22,17 @@ type game struct {
 	ID string `json:"_id,omitempty"`
 }
 
-// Games ... Delete games for user {username} or lichess.org:{username} or chess.com:{username}
-func Games(username string) {
+// Result ... what was deleted
+type Result struct {
+	Games    int64  `json:"games"`
+	Users    int64  `json:"users"`
+	Deletion string `json:"deletion,omitempty"` // in the trash (see trash.go), empty when purged
+	Purged   bool   `json:"purged,omitempty"`   // removed for good
+}
+
+// Games ... Delete games for user {username} or lichess.org:{username} or chess.com:{username}:
+// moved to the trash, removed for good with {purge} (the database of {ctx}, see mongodb.WithSettings)
+func Games(ctx context.Context, username string, purge bool) (Result, error) {
 	// process argument
 	site := ""
 
@@ -34,34 +44,25 @@ func Games(username string) {
 	}
 
 	// Connect to DB
-	client, err := mongo.NewClient(options.Client().ApplyURI(viper.GetString("mongo-url")))
-	if err != nil {
-		log.Fatal(err)
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
 	defer cancel()
-	err = client.Connect(ctx)
+	client, err := mongodb.Connect(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return Result{}, err
 	}
 	defer client.Disconnect(ctx)
 
-	// Ping MongoDB
-	if err = client.Ping(ctx, readpref.Primary()); err != nil {
-		log.Fatal("Cannot connect to DB " + viper.GetString("mongo-url"))
-	}
-
 	// Gather names of users whose games we must not delete
-	lastgamesCollection := client.Database(viper.GetString("mongo-db-name")).Collection("lastgames")
+	lastgamesCollection := mongodb.Collection(client, "lastgames")
 	findOptions := options.Find().SetProjection(bson.M{"site": 1, "username": 1})
 	cursor, err := lastgamesCollection.Find(ctx, bson.M{}, findOptions)
 	if err != nil {
-		log.Fatal(err)
+		return Result{}, err
 	}
 
 	var users []user
 	if err = cursor.All(ctx, &users); err != nil {
-		log.Fatal(err)
+		return Result{}, err
 	}
 
 	// Delete games
@@ -91,31 +92,60 @@ func Games(username string) {
 	gameFilter := bson.M{}
 	switch len(andClause) {
 	case 0:
-		log.Fatal("Unexpected")
+		return Result{}, errors.New("Unexpected")
 	case 1:
 		gameFilter = andClause[0]
 	default:
 		gameFilter = bson.M{"$and": andClause}
 	}
 
-	gamesCollection := client.Database(viper.GetString("mongo-db-name")).Collection("games")
+	gamesCollection := mongodb.Collection(client, "games")
 
 	collation := options.Collation{Locale: "en", Strength: 2}
 	deleteOptions := options.DeleteOptions{Collation: &collation} // case insensitive search
 
-	_, err = gamesCollection.DeleteMany(ctx, gameFilter, &deleteOptions)
-	if err != nil {
-		log.Fatal(err)
+	result := Result{Purged: purge}
+	target := username
+	if site != "" {
+		target = site + ":" + username
 	}
-
-	// Delete user
+	defer func() {
+		audit.Record(context.Background(), client, audit.LocalActor(), audit.OpDelete, target, result, err)
+	}()
 	deleteUsersFilter := bson.M{"username": username}
 	if site != "" {
 		deleteUsersFilter = bson.M{"username": username, "site": site}
 	}
-	_, err = lastgamesCollection.DeleteMany(ctx, deleteUsersFilter, &deleteOptions)
+
+	defer pgntodb.ForgetAllTrees(client)
+	if !purge {
+		// to the trash, then the expired deletions of the trash are purged
+		now := time.Now().UTC()
+		deletion := Deletion{ID: target + "@" + now.Format("20060102T150405Z"), Target: target, Deleted: now}
+		result.Deletion = deletion.ID
+		if result.Games, err = moveToTrash(ctx, client, "games", gameFilter, &collation, deletion); err != nil {
+			return result, err
+		}
+		if result.Users, err = moveToTrash(ctx, client, "lastgames", deleteUsersFilter, &collation, deletion); err != nil {
+			return result, err
+		}
+		if _, purgeErr := purgeTrash(ctx, client, false); purgeErr != nil {
+			log.Warn("trash not purged: " + purgeErr.Error())
+		}
+		return result, nil
+	}
+
+	deletedGames, err := gamesCollection.DeleteMany(ctx, gameFilter, &deleteOptions)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
 	}
+	result.Games = deletedGames.DeletedCount
 
+	// Delete user
+	deletedUsers, err := lastgamesCollection.DeleteMany(ctx, deleteUsersFilter, &deleteOptions)
+	if err != nil {
+		return result, err
+	}
+	result.Users = deletedUsers.DeletedCount
+	return result, nil
 }
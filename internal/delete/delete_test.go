@@ -0,0 +1,112 @@
+package delete
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// testClient ... client of a scratch database (dropped at the end of the test) on the MongoDB server of TEST_MONGO_URL,
+// and a context connecting to it; the test is skipped without it
+func testClient(t *testing.T) (*mongodb.Client, context.Context) {
+	mongoURL := os.Getenv("TEST_MONGO_URL")
+	if mongoURL == "" {
+		t.Skip("TEST_MONGO_URL not set: no MongoDB server for the test")
+	}
+	settings := mongodb.Settings{URL: mongoURL, Name: fmt.Sprintf("chess-explorer-test-%d", time.Now().UnixNano())}
+	ctx := mongodb.WithSettings(context.Background(), settings)
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(connectCtx)
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		client.Database(settings.Name).Drop(ctx)
+		client.Disconnect(ctx)
+	})
+	return client, ctx
+}
+
+func TestUndoBatchThenRestore(t *testing.T) {
+	client, ctx := testClient(t)
+	games := mongodb.Collection(client, "games")
+	lastgames := mongodb.Collection(client, "lastgames")
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	game := func(id string, batch string, days int) bson.M {
+		return bson.M{"_id": id, "site": "lichess.org", "white": "alice", "black": "bob", "datetime": day.AddDate(0, 0, days),
+			"provenance": bson.M{"batch": batch, "command": "pgntodb"}}
+	}
+	if _, err := games.InsertMany(ctx, []interface{}{game("old", "first", 0), game("new1", "wrong", 1), game("new2", "wrong", 2)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lastgames.InsertOne(ctx, pgntodb.LastGame{Username: "alice", Site: "lichess.org", DateTime: day.AddDate(0, 0, 2), GameID: "new2"}); err != nil {
+		t.Fatal(err)
+	}
+	lastGameID := func() string {
+		var lastgame pgntodb.LastGame
+		if err := lastgames.FindOne(ctx, bson.M{"username": "alice"}).Decode(&lastgame); err != nil {
+			t.Fatal(err)
+		}
+		return lastgame.GameID
+	}
+
+	undone, err := Batch(ctx, "wrong", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if undone.Games != 2 || undone.UsersUpdated != 1 || undone.Deletion == "" {
+		t.Errorf("import undo: %+v, want 2 games, 1 user moved back, a deletion", undone)
+	}
+	if count, _ := games.CountDocuments(ctx, bson.M{}); count != 1 {
+		t.Errorf("%d games after import undo, want 1", count)
+	}
+	if id := lastGameID(); id != "old" {
+		t.Errorf("most recent game %s after import undo, want old", id)
+	}
+
+	restored, err := Restore(ctx, "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Deletion != undone.Deletion || restored.Games != 2 || restored.Users != 1 {
+		t.Errorf("trash restore: %+v, want the 2 games and the user of %s", restored, undone.Deletion)
+	}
+	if count, _ := games.CountDocuments(ctx, bson.M{}); count != 3 {
+		t.Errorf("%d games after trash restore, want 3", count)
+	}
+	if id := lastGameID(); id != "new2" {
+		t.Errorf("most recent game %s after trash restore, want new2", id)
+	}
+}
+
+func TestUndoBatchDryRun(t *testing.T) {
+	client, ctx := testClient(t)
+	games := mongodb.Collection(client, "games")
+	game := bson.M{"_id": "game", "site": "lichess.org", "white": "alice", "black": "bob", "provenance": bson.M{"batch": "wrong"}}
+	if _, err := games.InsertOne(ctx, game); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Batch(ctx, "unknown", false); err == nil {
+		t.Errorf("import undo of an unknown batch: no error")
+	}
+	result, err := Batch(ctx, "wrong", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.DryRun || result.Games != 1 || result.Deletion != "" {
+		t.Errorf("dry run: %+v, want 1 game counted and no deletion", result)
+	}
+	if count, _ := games.CountDocuments(ctx, bson.M{}); count != 1 {
+		t.Errorf("%d games after a dry run, want 1", count)
+	}
+}
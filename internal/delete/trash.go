@@ -0,0 +1,282 @@
+package delete
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/audit"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+Trash: the games and lastgames deleted by delete (without --purge) or by import undo go to the trash collection, one
+document per deleted document, with the deletion they belong to ({target}@{UTC time}, target: the user or the batch). trash restore puts a deletion back, the
+deletions older than trash-days (config file, default 30) are purged at the next delete or by trash purge.
+*/
+
+// trashBatch ... documents moved to the trash at once
+const trashBatch = 1000
+
+// trashed ... a deleted document in the trash
+type trashed struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Deletion   string             `bson:"deletion"`
+	Target     string             `bson:"target"`
+	Collection string             `bson:"collection"` // games or lastgames
+	Deleted    time.Time          `bson:"deleted"`
+	Document   bson.Raw           `bson:"document"`
+}
+
+// Deletion ... documents deleted together, in the trash
+type Deletion struct {
+	ID      string    `json:"id" bson:"_id"`
+	Target  string    `json:"target" bson:"target"` // user, or import batch
+	Deleted time.Time `json:"deleted" bson:"deleted"`
+	Games   int64     `json:"games" bson:"games"`
+	Users   int64     `json:"users" bson:"users"`
+	Expires time.Time `json:"expires" bson:"-"` // purged after this time
+}
+
+// RestoreResult ... what was put back
+type RestoreResult struct {
+	Deletion   string `json:"deletion"`
+	Games      int64  `json:"games"`
+	Users      int64  `json:"users"`
+	Duplicates int64  `json:"duplicates"` // documents imported again since the deletion, left as they are
+}
+
+// trashDays ... days a deletion stays in the trash (trash-days)
+func trashDays() int {
+	if viper.IsSet("trash-days") {
+		return viper.GetInt("trash-days")
+	}
+	return 30
+}
+
+// moveToTrash ... move the documents of {collection} matching {filter} to the trash as part of {deletion}: documents moved
+func moveToTrash(ctx context.Context, client *mongodb.Client, collection string, filter bson.M, collation *options.Collation, deletion Deletion) (int64, error) {
+	source := mongodb.Collection(client, collection)
+	trash := mongodb.Collection(client, "trash")
+	cursor, err := source.Find(ctx, filter, options.Find().SetCollation(collation))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	moved := int64(0)
+	documents := make([]interface{}, 0, trashBatch)
+	ids := make(bson.A, 0, trashBatch)
+	flush := func() error {
+		if len(documents) == 0 {
+			return nil
+		}
+		if _, err := trash.InsertMany(ctx, documents); err != nil {
+			return err
+		}
+		deleted, err := source.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return err
+		}
+		moved += deleted.DeletedCount
+		documents, ids = documents[:0], ids[:0]
+		return nil
+	}
+	for cursor.Next(ctx) {
+		document := make(bson.Raw, len(cursor.Current))
+		copy(document, cursor.Current)
+		documents = append(documents, trashed{Deletion: deletion.ID, Target: deletion.Target, Collection: collection, Deleted: deletion.Deleted, Document: document})
+		ids = append(ids, document.Lookup("_id"))
+		if len(documents) == trashBatch {
+			if err = flush(); err != nil {
+				return moved, err
+			}
+		}
+	}
+	if err = cursor.Err(); err != nil {
+		return moved, err
+	}
+	return moved, flush()
+}
+
+// Trash ... the deletions in the trash, most recent first
+func Trash() ([]Deletion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Disconnect(ctx)
+	return deletions(ctx, client, bson.M{})
+}
+
+// deletions ... the deletions of the trash documents matching {match} (case insensitive, as delete), most recent first
+func deletions(ctx context.Context, client *mongodb.Client, match bson.M) ([]Deletion, error) {
+	collation := options.Collation{Locale: "en", Strength: 2}
+	cursor, err := mongodb.Collection(client, "trash").Aggregate(ctx, []bson.M{
+		{"$match": match},
+		{"$group": bson.M{
+			"_id":     "$deletion",
+			"target":  bson.M{"$first": "$target"},
+			"deleted": bson.M{"$first": "$deleted"},
+			"games":   bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$collection", "games"}}, 1, 0}}},
+			"users":   bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$collection", "lastgames"}}, 1, 0}}},
+		}},
+		{"$sort": bson.M{"deleted": -1}},
+	}, options.Aggregate().SetCollation(&collation))
+	if err != nil {
+		return nil, err
+	}
+	found := []Deletion{}
+	if err = cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	for i := range found {
+		found[i].Expires = found[i].Deleted.AddDate(0, 0, trashDays())
+	}
+	return found, nil
+}
+
+// Restore ... put back the deletion {target}: a deletion id, or a user (username or site:username) or an import batch for
+// their most recent deletion (the database of {ctx}, see mongodb.WithSettings)
+func Restore(ctx context.Context, target string) (RestoreResult, error) {
+	result := RestoreResult{}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer client.Disconnect(ctx)
+
+	found, err := deletions(ctx, client, bson.M{"$or": bson.A{bson.M{"deletion": target}, bson.M{"target": target}}})
+	if err != nil {
+		return result, err
+	}
+	if len(found) == 0 {
+		return result, fmt.Errorf("Nothing to restore for %s in the trash (see trash list)", target)
+	}
+	result.Deletion = found[0].ID
+	defer func() {
+		audit.Record(context.Background(), client, audit.LocalActor(), audit.OpRestore, result.Deletion, result, err)
+	}()
+
+	defer pgntodb.ForgetAllTrees(client)
+	trash := mongodb.Collection(client, "trash")
+	cursor, err := trash.Find(ctx, bson.M{"deletion": result.Deletion})
+	if err != nil {
+		return result, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var document trashed
+		if err = cursor.Decode(&document); err != nil {
+			return result, err
+		}
+		duplicate := false
+		if document.Collection == "lastgames" {
+			duplicate, err = restoreLastGame(ctx, mongodb.Collection(client, "lastgames"), document.Document)
+		} else {
+			_, err = mongodb.Collection(client, document.Collection).InsertOne(ctx, document.Document)
+			var writeException mongo.WriteException
+			if errors.As(err, &writeException) && len(writeException.WriteErrors) > 0 && writeException.WriteErrors[0].Code == 11000 {
+				duplicate, err = true, nil
+			}
+		}
+		switch {
+		case duplicate:
+			result.Duplicates++
+		case err != nil:
+			return result, err
+		case document.Collection == "games":
+			result.Games++
+		default:
+			result.Users++
+		}
+		if _, err = trash.DeleteOne(ctx, bson.M{"_id": document.ID}); err != nil {
+			return result, err
+		}
+	}
+	err = cursor.Err()
+	return result, err
+}
+
+// restoreLastGame ... put back the most recent game of a user, {document} of the trash, unless the user was downloaded
+// again since the deletion with a game as recent (true: left as it is): one lastgame per site, user and owner
+func restoreLastGame(ctx context.Context, lastgames *mongo.Collection, document bson.Raw) (bool, error) {
+	var restored pgntodb.LastGame
+	if err := bson.Unmarshal(document, &restored); err != nil {
+		return false, err
+	}
+	collation := options.Collation{Locale: "en", Strength: 2} // case insensitive, as delete
+	filter := bson.M{"site": restored.Site, "username": restored.Username, "owner": pgntodb.OwnerValue(restored.Owner)}
+	var current pgntodb.LastGame
+	err := lastgames.FindOne(ctx, filter, options.FindOne().SetCollation(&collation)).Decode(&current)
+	switch {
+	case err == nil && !current.DateTime.Before(restored.DateTime):
+		return true, nil
+	case err != nil && err != mongo.ErrNoDocuments:
+		return false, err
+	}
+	// without its _id: the lastgame of a new download keeps its own
+	var replacement bson.D
+	if err := bson.Unmarshal(document, &replacement); err != nil {
+		return false, err
+	}
+	fields := replacement[:0]
+	for _, field := range replacement {
+		if field.Key != "_id" {
+			fields = append(fields, field)
+		}
+	}
+	_, err = lastgames.ReplaceOne(ctx, filter, fields, options.Replace().SetUpsert(true).SetCollation(&collation))
+	return false, err
+}
+
+// PurgeTrash ... remove for good the deletions of the trash older than trash-days ({all}: every deletion): documents removed
+func PurgeTrash(all bool) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Disconnect(ctx)
+	return purgeTrash(ctx, client, all)
+}
+
+// purgeTrash ... PurgeTrash with {client}
+func purgeTrash(ctx context.Context, client *mongodb.Client, all bool) (int64, error) {
+	filter := bson.M{"deleted": bson.M{"$lt": time.Now().UTC().AddDate(0, 0, -trashDays())}}
+	target := fmt.Sprintf("older than %d days", trashDays())
+	if all {
+		filter, target = bson.M{}, "all"
+	}
+	expired, err := deletions(ctx, client, filter)
+	if err != nil || len(expired) == 0 {
+		return 0, err
+	}
+	result, err := mongodb.Collection(client, "trash").DeleteMany(ctx, filter)
+	purged := int64(0)
+	if result != nil {
+		purged = result.DeletedCount
+	}
+	ids := make([]string, len(expired))
+	for i, deletion := range expired {
+		ids[i] = deletion.ID
+	}
+	audit.Record(context.Background(), client, audit.LocalActor(), audit.OpTrashPurge, target, ids, err)
+	if err == nil {
+		log.Info(fmt.Sprintf("%d deletions purged from the trash", len(expired)))
+	}
+	return purged, err
+}
@@ -0,0 +1,24 @@
+package demo
+
+import (
+	"bytes"
+	_ "embed" // games.pgn
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+)
+
+/*
+Sample games for the demo mode (chess-explorer server --demo): a dozen famous games played from 1750 to 1912,
+three of them by Paul Morphy, kept in memory, no database needed
+*/
+
+// Player ... the player of the sample games shown as the tracked user
+const Player = "Morphy"
+
+//go:embed games.pgn
+var gamesPgn []byte
+
+// Games ... the sample games
+func Games() ([]pgntodb.Game, error) {
+	return pgntodb.ReadGames(bytes.NewReader(gamesPgn))
+}
@@ -6,6 +6,7 @@
 // https://github.com/jhlywa/chess.js
 
 var apiHost = location.protocol + '//' + location.host
+var timeZone = Intl.DateTimeFormat().resolvedOptions().timeZone || '' // from/to are days of the browser
 var board = null
 var game = new Chess()
 var openingTable = null // see https://raw.githubusercontent.com/kevinludwig/chess-eco-codes/master/codes.json
@@ -13,18 +14,23 @@ var openingTable = null // see https://raw.githubusercontent.com/kevinludwig/che
 // states
 var mostPopularMove = ''
 var simplifyTimecontrol = true // make m+s equivalent to m (for example: 600 will include 600+5) and 1/n equivalent to -
+var anyMoveOrder = false // explore the position of the board (transpositions) instead of the line
 var uiMode = 'opening' // opening, replay
 var playerInputMode = 'white' // changes when input fields are clicked
 var gameReplaying
+var bookmarks = [] // of the user, see getBookmarks
 
 // mustache templates
 var nextMovesTpl = document.getElementById('nextMovesTpl').innerHTML;
 var usernameListTpl = document.getElementById('usernameListTpl').innerHTML;
 var timecontrolListTpl = document.getElementById('timecontrolListTpl').innerHTML;
 var nameListTpl = document.getElementById('nameListTpl').innerHTML;
+var opponentsTpl = document.getElementById('opponentsTpl').innerHTML;
 var openingBreadcrumbsTpl = document.getElementById('openingBreadcrumbsTpl').innerHTML;
 var replayBreadcrumbsTpl = document.getElementById('replayBreadcrumbsTpl').innerHTML;
 var gameDetailsTpl = document.getElementById('gameDetailsTpl').innerHTML;
+var notesTpl = document.getElementById('notesTpl').innerHTML;
+var bookmarksTpl = document.getElementById('bookmarksTpl').innerHTML;
 
 
 // events
@@ -70,6 +76,22 @@ $('#site').change(function() {
     getNextMoves()
 });
 
+$('#result').change(function() {
+    getNextMoves()
+});
+
+$('#unknownelo').change(function() {
+    getNextMoves()
+});
+
+$('#speed').change(function() {
+    getNextMoves()
+});
+
+$('#tag').change(function() {
+    getNextMoves()
+});
+
 $('#swap').click(function(e) {
     e.preventDefault();
     var black = $('#black').val()
@@ -132,10 +154,29 @@ $('#reset-sites').click(function(e) {
     getNextMoves()
 });
 
+$('#reset-result').click(function(e) {
+    e.preventDefault();
+    $('#result').val('')
+    getNextMoves()
+});
+
+$('#reset-speed').click(function(e) {
+    e.preventDefault();
+    $('#speed').val('')
+    getNextMoves()
+});
+
+$('#reset-tag').click(function(e) {
+    e.preventDefault();
+    $('#tag').val('')
+    getNextMoves()
+});
+
 $('#reset-elos').click(function(e) {
     e.preventDefault();
     $('#minelo').val('')
     $('#maxelo').val('')
+    $('#unknownelo').val('')
     getNextMoves()
 });
 
@@ -181,6 +222,11 @@ $('#edit-pgn-link').click(function(e) {
         $('#edit-pgn').show()
         $('#edit-pgn').change(function() {
             $(this).hide()
+            // a game URL of lichess.org or chess.com replays the stored game
+            if (/^https?:\/\//.test($('#edit-pgn').val().trim())) {
+                replayGame('', $('#edit-pgn').val().trim())
+                return
+            }
             game.load_pgn($('#edit-pgn').val())
             board.position(game.fen())
             openingUpdated()
@@ -214,8 +260,12 @@ $('#reset-all').click(function(e) {
     $('#from').val('')
     $('#to').val('')
     $('#site').val('')
+    $('#result').val('')
+    $('#speed').val('')
+    $('#tag').val('')
     $('#minelo').val('')
     $('#maxelo').val('')
+    $('#unknownelo').val('')
     resetBoard()
 });
 
@@ -235,6 +285,22 @@ $('#simplify-timecontrol-unchecked').click(function(e) {
     $('#simplify-timecontrol-checked').show()
 });
 
+$('#any-move-order-checked').click(function(e) {
+    e.preventDefault();
+    $(this).hide()
+    anyMoveOrder = false
+    getNextMoves()
+    $('#any-move-order-unchecked').show()
+});
+
+$('#any-move-order-unchecked').click(function(e) {
+    e.preventDefault();
+    $(this).hide()
+    anyMoveOrder = true
+    getNextMoves()
+    $('#any-move-order-checked').show()
+});
+
 $('#show-fen-checked').click(function(e) {
     e.preventDefault();
     $('#fen-container').hide()
@@ -254,6 +320,65 @@ $('#show-search-fen-form').click(function(e) {
     $('#search-fen-form').show()
 });
 
+// repertoire cards of the current line and filter, from the side at the bottom of the board
+$('#anki-deck').click(function(e) {
+    e.preventDefault();
+    window.location = `${apiHost}/anki?` + $.param({
+        pgn: game.pgn(),
+        white: $('#white').val(),
+        black: $('#black').val(),
+        timecontrol: $('#timecontrol').val(),
+        simplifyTimecontrol: simplifyTimecontrol,
+        from: $('#from').val(),
+        to: $('#to').val(),
+        tz: timeZone,
+        minelo: $('#minelo').val(),
+        maxelo: $('#maxelo').val(),
+        site: $('#site').val(),
+        result: $('#result').val(),
+        speed: $('#speed').val(),
+        tag: $('#tag').val(),
+        unknownelo: $('#unknownelo').val(),
+        color: board.orientation(),
+        mingames: 2
+    })
+});
+
+// current line, top alternatives and recent games as chapters of one of the user's lichess studies
+$('#lichess-study').click(function(e) {
+    e.preventDefault();
+    var study = window.prompt('URL of your lichess study (new chapters are added)')
+    if (!study) {
+        return
+    }
+    $.post(`${apiHost}/study`, {
+        study: study,
+        pgn: game.pgn(),
+        white: $('#white').val(),
+        black: $('#black').val(),
+        timecontrol: $('#timecontrol').val(),
+        simplifyTimecontrol: simplifyTimecontrol,
+        from: $('#from').val(),
+        to: $('#to').val(),
+        tz: timeZone,
+        minelo: $('#minelo').val(),
+        maxelo: $('#maxelo').val(),
+        site: $('#site').val(),
+        result: $('#result').val(),
+        speed: $('#speed').val(),
+        tag: $('#tag').val(),
+        unknownelo: $('#unknownelo').val(),
+        color: board.orientation()
+    }, function(response) {
+        var jsonResponse = JSON.parse(response)
+        if (jsonResponse.error != undefined && jsonResponse.error != '') {
+            showError(jsonResponse.error)
+        } else {
+            window.open(jsonResponse.data.url, '_blank')
+        }
+    }).fail(requestFailed);
+});
+
 $('#cancel-search-fen-form').click(function(e) {
     e.preventDefault();
     $('#search-fen-form').hide()
@@ -272,13 +397,69 @@ $('#search-fen').click(function(e) {
         simplifyTimecontrol: simplifyTimecontrol,
         from: $('#from').val(),
         to: $('#to').val(),
+        tz: timeZone,
         minelo: $('#minelo').val(),
         maxelo: $('#maxelo').val(),
-        site: $('#site').val()
-    }, function(response) {}).fail(function() {
-        showError('Error connecting to ' + apiHost)
-    });
+        site: $('#site').val(),
+        result: $('#result').val(),
+        speed: $('#speed').val(),
+        tag: $('#tag').val(),
+        unknownelo: $('#unknownelo').val()
+    }, function(response) {}).fail(requestFailed);
+
+});
 
+// tag the game replayed
+$('#game-details').on('change', '#game-tag', function() {
+    var tag = $(this).val().trim()
+    if (tag == '') {
+        return
+    }
+    $.post(`${apiHost}/games/tags`, {
+        id: $(this).attr('data-id'),
+        add: tag
+    }, function(response) {
+        var jsonResponse = typeof response == 'string' ? JSON.parse(response) : response
+        if (jsonResponse.error != undefined && jsonResponse.error != '') {
+            showError(jsonResponse.error)
+            return
+        }
+        $('#game-tags').append($('<span class="label secondary"></span>').text(tag)).append(' ')
+        $('#game-tag').val('')
+    }).fail(requestFailed);
+});
+
+$('#show-import-pgn-form').click(function(e) {
+    e.preventDefault();
+    $('#import-pgn-form').show()
+});
+
+$('#cancel-import-pgn-form').click(function(e) {
+    e.preventDefault();
+    $('#import-pgn-form').hide()
+});
+
+// the games are added to the database, the first one is replayed
+$('#import-pgn').click(function(e) {
+    e.preventDefault();
+    $.ajax({
+        url: `${apiHost}/import/pgn`,
+        type: 'POST',
+        contentType: 'application/x-chess-pgn',
+        data: $('#import-pgn-text').val()
+    }).done(function(response) {
+        var jsonResponse = typeof response == 'string' ? JSON.parse(response) : response
+        if (jsonResponse.error != undefined && jsonResponse.error != '') {
+            showError(jsonResponse.error)
+            return
+        }
+        $('#import-pgn-form').hide()
+        $('#import-pgn-text').val('')
+        var ids = jsonResponse.data.ids.concat(jsonResponse.data.duplicates)
+        if (ids.length > 0) {
+            replayGame(ids[0])
+        }
+    }).fail(requestFailed);
 });
 
 $('#book-moves-db-master-unchecked').click(function(e) {
@@ -368,34 +549,325 @@ function getNextMoves() {
     $('#next-moves').html('');
     $.post(`${apiHost}/nextmoves`, {
         pgn: game.pgn(),
+        fen: anyMoveOrder ? game.fen() : '',
         white: $('#white').val(),
         black: $('#black').val(),
         timecontrol: $('#timecontrol').val(),
         simplifyTimecontrol: simplifyTimecontrol,
         from: $('#from').val(),
         to: $('#to').val(),
+        tz: timeZone,
         minelo: $('#minelo').val(),
         maxelo: $('#maxelo').val(),
-        site: $('#site').val()
+        site: $('#site').val(),
+        result: $('#result').val(),
+        speed: $('#speed').val(),
+        tag: $('#tag').val(),
+        unknownelo: $('#unknownelo').val()
     }, function(response) {
         var jsonResponse = JSON.parse(response)
         if (jsonResponse.error != undefined && jsonResponse.error != '') {
             showError(jsonResponse.error)
         } else {
-            handleNextMovesResponse(jsonResponse.data);
+            handleNextMovesResponse(jsonResponse.data, jsonResponse.engine);
+            $('#position-notes').html(Mustache.render(notesTpl, jsonResponse.notes || []))
+        }
+    }).fail(requestFailed);
+}
+
+// notes of the position (whatever the move order) and of the game replayed
+$('#add-position-note').click(function(e) {
+    e.preventDefault();
+    addNote({ pgn: game.pgn(), text: $('#position-note-text').val() }, '#position-notes', '#position-note-text')
+});
+
+$('#game-details').on('click', '#add-game-note', function(e) {
+    e.preventDefault();
+    addNote({ gameId: $(this).attr('data-id'), text: $('#game-note-text').val() }, '#game-notes', '#game-note-text')
+});
+
+$(document).on('click', '.delete-note', function(e) {
+    e.preventDefault();
+    var note = $(this).parent()
+    $.ajax({
+        url: `${apiHost}/notes?` + $.param({ id: $(this).attr('data-id') }),
+        type: 'DELETE'
+    }).done(function(response) {
+        var jsonResponse = typeof response == 'string' ? JSON.parse(response) : response
+        if (jsonResponse.error != undefined && jsonResponse.error != '') {
+            showError(jsonResponse.error)
+        } else {
+            note.remove()
+        }
+    }).fail(requestFailed);
+});
+
+// bookmarks of the user logged in, or of the API key of this browser
+function apiKey() {
+    var key = localStorage.getItem('chess-explorer-key')
+    if (!key) {
+        var random = new Uint8Array(16)
+        crypto.getRandomValues(random)
+        key = Array.from(random, b => b.toString(16).padStart(2, '0')).join('')
+        localStorage.setItem('chess-explorer-key', key)
+    }
+    return key
+}
+
+function bookmarksRequest(type, data, done) {
+    $.ajax({
+        url: `${apiHost}/bookmarks` + (type == 'DELETE' ? '?' + $.param(data) : ''),
+        type: type,
+        headers: { 'X-Api-Key': apiKey() },
+        data: type == 'DELETE' ? undefined : data
+    }).done(function(response) {
+        var jsonResponse = typeof response == 'string' ? JSON.parse(response) : response
+        if (jsonResponse.error != undefined && jsonResponse.error != '') {
+            showError(jsonResponse.error)
+        } else {
+            done(jsonResponse.data)
+        }
+    }).fail(requestFailed);
+}
+
+function getBookmarks() {
+    bookmarksRequest('GET', {}, function(data) {
+        bookmarks = data
+        $('#bookmarks').html(Mustache.render(bookmarksTpl, bookmarks))
+        $('#bookmarks-panel').show()
+    })
+}
+
+$('#bookmark').click(function(e) {
+    e.preventDefault();
+    var name = prompt('Name of the bookmark', '')
+    if (name == null) {
+        return
+    }
+    bookmarksRequest('POST', {
+        name: name,
+        pgn: game.pgn(),
+        fen: anyMoveOrder ? game.fen() : '',
+        white: $('#white').val(),
+        black: $('#black').val(),
+        timecontrol: $('#timecontrol').val(),
+        simplifyTimecontrol: simplifyTimecontrol,
+        from: $('#from').val(),
+        to: $('#to').val(),
+        tz: timeZone,
+        minelo: $('#minelo').val(),
+        maxelo: $('#maxelo').val(),
+        site: $('#site').val(),
+        result: $('#result').val(),
+        speed: $('#speed').val(),
+        tag: $('#tag').val(),
+        unknownelo: $('#unknownelo').val()
+    }, getBookmarks)
+});
+
+$('#show-bookmarks').click(function(e) {
+    e.preventDefault();
+    getBookmarks()
+});
+
+$('#hide-bookmarks').click(function(e) {
+    e.preventDefault();
+    $('#bookmarks-panel').hide()
+});
+
+$('#bookmarks').on('click', '.delete-bookmark', function(e) {
+    e.preventDefault();
+    bookmarksRequest('DELETE', { id: $(this).attr('data-id') }, getBookmarks)
+});
+
+// back to the position and the filter of a bookmark
+$('#bookmarks').on('click', '.open-bookmark', function(e) {
+    e.preventDefault();
+    var id = $(this).attr('data-id')
+    var bookmark = bookmarks.find(b => b.id == id)
+    if (!bookmark) {
+        return
+    }
+    openView(bookmark.pgn, bookmark.fen, bookmark.filter || {})
+});
+
+// share link of the line (or the position) and of the filter
+$('#share').click(function(e) {
+    e.preventDefault();
+    $.post(`${apiHost}/views`, {
+        pgn: game.pgn(),
+        fen: anyMoveOrder ? game.fen() : '',
+        white: $('#white').val(),
+        black: $('#black').val(),
+        timecontrol: $('#timecontrol').val(),
+        simplifyTimecontrol: simplifyTimecontrol,
+        from: $('#from').val(),
+        to: $('#to').val(),
+        tz: timeZone,
+        minelo: $('#minelo').val(),
+        maxelo: $('#maxelo').val(),
+        site: $('#site').val(),
+        result: $('#result').val(),
+        speed: $('#speed').val(),
+        tag: $('#tag').val(),
+        unknownelo: $('#unknownelo').val()
+    }, function(response) {
+        var jsonResponse = typeof response == 'string' ? JSON.parse(response) : response
+        if (jsonResponse.error != undefined && jsonResponse.error != '') {
+            showError(jsonResponse.error)
+            return
+        }
+        $('#share-link').val(location.origin + location.pathname + '?view=' + jsonResponse.data.token)
+        $('#share-panel').show()
+        $('#share-link').select()
+    }).fail(requestFailed);
+});
+
+$('#hide-share').click(function(e) {
+    e.preventDefault();
+    $('#share-panel').hide()
+});
+
+// the view of a share link (/?view={token})
+function openSharedView() {
+    var token = new URLSearchParams(location.search).get('view')
+    if (!token) {
+        return
+    }
+    $.get(`${apiHost}/views`, { token: token }, function(response) {
+        var jsonResponse = typeof response == 'string' ? JSON.parse(response) : response
+        if (jsonResponse.error != undefined && jsonResponse.error != '') {
+            showError(jsonResponse.error)
+            return
+        }
+        var view = jsonResponse.data
+        openView(view.pgn, view.fen, view.filter || {})
+    }).fail(requestFailed);
+}
+
+// show the line {pgn} (or the position {fen}) with the values of the filter form {filter}
+function openView(pgn, fen, filter) {
+    ['white', 'black', 'timecontrol', 'from', 'to', 'minelo', 'maxelo', 'unknownelo', 'site', 'result', 'speed', 'tag'].forEach(name => {
+        $('#' + name).val(filter[name] || '')
+    })
+    simplifyTimecontrol = filter.simplifyTimecontrol != 'false'
+    $('#simplify-timecontrol-checked').toggle(simplifyTimecontrol)
+    $('#simplify-timecontrol-unchecked').toggle(!simplifyTimecontrol)
+    setOpeningMode()
+    if (pgn) {
+        game.load_pgn(pgn)
+    } else {
+        game.load(fen)
+    }
+    board.position(game.fen())
+    openingUpdated()
+}
+
+function addNote(data, notes, input) {
+    if (data.text.trim() == '') {
+        return
+    }
+    $.post(`${apiHost}/notes`, data, function(response) {
+        var jsonResponse = typeof response == 'string' ? JSON.parse(response) : response
+        if (jsonResponse.error != undefined && jsonResponse.error != '') {
+            showError(jsonResponse.error)
+        } else {
+            $(notes).append(Mustache.render(notesTpl, jsonResponse.data))
+            $(input).val('')
+        }
+    }).fail(requestFailed);
+}
+
+// continuations grouped by the opponents of the player(s) of one side
+$('#show-opponents').click(function(e) {
+    e.preventDefault();
+    getOpponents()
+});
+
+function getOpponents() {
+    $.post(`${apiHost}/opponents`, {
+        pgn: game.pgn(),
+        white: $('#white').val(),
+        black: $('#black').val(),
+        timecontrol: $('#timecontrol').val(),
+        simplifyTimecontrol: simplifyTimecontrol,
+        from: $('#from').val(),
+        to: $('#to').val(),
+        tz: timeZone,
+        minelo: $('#minelo').val(),
+        maxelo: $('#maxelo').val(),
+        site: $('#site').val(),
+        result: $('#result').val(),
+        speed: $('#speed').val(),
+        tag: $('#tag').val(),
+        unknownelo: $('#unknownelo').val()
+    }, function(response) {
+        var jsonResponse = JSON.parse(response)
+        if (jsonResponse.error != undefined && jsonResponse.error != '') {
+            showError(jsonResponse.error)
+            return
+        }
+        var opponents = (jsonResponse.data || []).map(opponent => {
+            opponent.scoreText = Math.round(100 * opponent.score) + '%'
+            opponent.moves = opponent.moves.slice(0, 3)
+            return opponent
+        })
+        $('#next-moves').html(Mustache.render(opponentsTpl, opponents))
+        $('#back-to-moves').bind('click', function(e) {
+            e.preventDefault();
+            getNextMoves()
+        });
+        $('#next-moves .next-move').bind('click', function(e) {
+            e.preventDefault();
+            move($(this).html())
+        });
+        // only the games against this opponent
+        $('.opponent').bind('click', function(e) {
+            e.preventDefault();
+            var side = $('#white').val() != '' ? '#black' : '#white'
+            $(side).val($(this).attr('data-opponent'))
+            getNextMoves()
+        });
+    }).fail(requestFailed);
+}
+
+// logged in with lichess: games from the user's perspective by default (swap for the black side)
+function getMe() {
+    $.get(`${apiHost}/me`, function(response) {
+        var jsonResponse = JSON.parse(response)
+        if (jsonResponse.error == undefined || jsonResponse.error == '') {
+            handleMeResponse(jsonResponse.data)
         }
+        resetBoard()
     }).fail(function() {
-        showError('Error connecting to ' + apiHost)
+        resetBoard()
     });
 }
 
+function handleMeResponse(data) {
+    if (!data.enabled) {
+        return
+    }
+    $('#account').show()
+    if (data.username) {
+        $('#me').text(data.username)
+        $('#login-link').hide()
+        $('#logged-in').show()
+        $('#lichess-study-item').show()
+        if ($('#white').val() == '' && $('#black').val() == '') {
+            $('#white').val(data.site + ':' + data.username)
+        }
+    }
+}
+
 function updateReport() {
     clearTimeControlValues()
     $.get(`${apiHost}/report`, {
         white: $('#white').val(),
         black: $('#black').val(),
         from: $('#from').val(),
-        to: $('#to').val()
+        to: $('#to').val(),
+        tz: timeZone
     }, function(response) {
         var jsonResponse = JSON.parse(response);
         if (jsonResponse.error != undefined && jsonResponse.error != '') {
@@ -403,9 +875,7 @@ function updateReport() {
         } else {
             handleReportResponse(jsonResponse.data)
         }
-    }).fail(function() {
-        showError('Error connecting to ' + apiHost)
-    });
+    }).fail(requestFailed);
 }
 
 
@@ -592,7 +1062,62 @@ function compareTimecontrolsByName(itemA, itemB) {
     }
 }
 
-function handleNextMovesResponse(dataObject) {
+// average thinking time: 4.2s, 1m05s (empty without clocks)
+function formatThinkingTime(seconds) {
+    if (!seconds) {
+        return ''
+    }
+    if (seconds < 60) {
+        return seconds.toFixed(1) + 's'
+    }
+    var rest = Math.round(seconds % 60)
+    return Math.floor(seconds / 60) + 'm' + (rest < 10 ? '0' : '') + rest + 's'
+}
+
+// why a game ended: "Black resigned", "White won on time", "Draw by repetition" (empty if unknown)
+function formatEnding(termination, result) {
+    var winner = result == '1-0' ? 'White' : 'Black'
+    var loser = result == '1-0' ? 'Black' : 'White'
+    var draw = result != '1-0' && result != '0-1'
+    switch (termination) {
+        case 'checkmate':
+            return winner + ' won by checkmate'
+        case 'resignation':
+            return loser + ' resigned'
+        case 'time':
+            return draw ? 'Draw on time' : winner + ' won on time'
+        case 'abandoned':
+            return loser + ' abandoned'
+        case 'time vs insufficient material':
+            return 'Draw: time vs insufficient material'
+        case 'stalemate':
+        case 'repetition':
+        case 'insufficient material':
+        case '50 moves':
+        case 'agreement':
+            return 'Draw by ' + termination
+    }
+    return ''
+}
+
+// score of white next to its expected score: 45/55% (empty without rated games)
+function formatExpectedScore(element) {
+    if (element.expectedscore == undefined) {
+        return ''
+    }
+    var score = Math.round(100 * (element.white + element.draw / 2) / element.total)
+    return score + '/' + Math.round(100 * element.expectedscore) + '%'
+}
+
+// average evaluation in pawns: +0.35, -1.20 (empty without evaluations)
+function formatEval(pawns) {
+    if (pawns == undefined) {
+        return ''
+    }
+    return (pawns > 0 ? '+' : '') + pawns.toFixed(2)
+}
+
+function handleNextMovesResponse(dataObject, engineMove) {
     mostPopularMove = ''
     if (Array.isArray(dataObject) == false) {
         console.log('not an array')
@@ -633,6 +1158,7 @@ function handleNextMovesResponse(dataObject) {
             if (element.game.site == 'lichess.org') {
                 element.game.userlink = 'https://lichess.org/@/'
             }
+            var endingText = formatEnding(element.termination, element.game.result)
             // white,draw,black
             var white = false
             var black = false
@@ -655,6 +1181,9 @@ function handleNextMovesResponse(dataObject) {
                 draw: draw,
                 game: element.game,
                 move: element.move,
+                label: element.move == 'End' && endingText != '' ? endingText : element.move,
+                endingText: element.move != 'End' ? endingText : '',
+                finalFen: element.finalfen,
             })
         } else {
             openingLink = true
@@ -672,6 +1201,9 @@ function handleNextMovesResponse(dataObject) {
                 whitePercentText: whitePercentText,
                 blackPercentText: blackPercentText,
                 drawPercentText: drawPercentText,
+                avgTimeText: formatThinkingTime(element.avgtime),
+                avgEvalText: formatEval(element.avgeval),
+                scoreText: formatExpectedScore(element),
             })
         }
         grandTotal += element.total
@@ -681,6 +1213,14 @@ function handleNextMovesResponse(dataObject) {
     var grandBlackPercent = Math.round(100 * grandBlack / grandTotal)
     var grandDrawPercent = 100 - grandWhitePercent - grandBlackPercent
 
+    if (engineMove) {
+        moves.unshift({
+            engineLink: true,
+            move: engineMove.move,
+            eval: engineMove.eval,
+            depth: engineMove.depth,
+        })
+    }
     $('#next-moves').html(Mustache.render(nextMovesTpl, moves))
     $('.next-move').bind('click', function(e) {
         e.preventDefault();
@@ -738,19 +1278,18 @@ function setOpeningMode() {
 
 }
 
-function replayGame(gameId) {
+function replayGame(gameId, link) {
     setReplayMode()
         // load data
-    $.get(`${apiHost}/game`, { gameId: gameId }, function(response) {
+    $.get(`${apiHost}/game`, link ? { link: link } : { gameId: gameId }, function(response) {
         var jsonResponse = JSON.parse(response)
         if (jsonResponse.error != undefined && jsonResponse.error != '') {
             showError(jsonResponse.error)
         } else {
             handleGameResponse(jsonResponse.data)
+            $('#game-notes').html(Mustache.render(notesTpl, jsonResponse.notes || []))
         }
-    }).fail(function() {
-        showError('Error connecting to ' + apiHost)
-    });
+    }).fail(requestFailed);
 }
 
 function handleGameResponse(data) {
@@ -804,6 +1343,18 @@ function showError(error) {
     $('#error').html('<p>' + error + '</p>')
 }
 
+// a request refused by the server (400: invalid filter value) or not sent
+function requestFailed(xhr) {
+    try {
+        var jsonResponse = JSON.parse(xhr.responseText)
+        if (jsonResponse.error != undefined && jsonResponse.error != '') {
+            showError(jsonResponse.error)
+            return
+        }
+    } catch (e) {}
+    showError('Error connecting to ' + apiHost)
+}
+
 function replayNext() {
     var round = Math.floor(game.history().length / 2)
     if (game.history().length % 2 == 0) {
@@ -1044,7 +1595,8 @@ var config = {
 board = Chessboard('myBoard', config)
 board.resize()
 
-resetBoard()
+getMe()
+openSharedView()
 
 // initialize opening table
 $.getJSON("https://raw.githubusercontent.com/kevinludwig/chess-eco-codes/master/codes.json", function(data) {
@@ -0,0 +1,262 @@
+package engine
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+/*
+Minimal UCI client: http://wbec-ridderkerk.nl/html/UCIProtocol.html
+
+Evaluations are always given from white's point of view.
+*/
+
+// Settings ... how to run the engine
+type Settings struct {
+	Path     string        // engine executable (stockfish ...)
+	Depth    int           // search depth (used if MoveTime is 0)
+	MoveTime time.Duration // search time per position
+	Threads  int           // UCI option Threads (0: engine default)
+	Hash     int           // UCI option Hash in MB (0: engine default)
+
+	Instances int           // engines of a Pool
+	Timeout   time.Duration // an evaluation taking longer kills the engine (0: no limit)
+}
+
+// SettingsFromConfig ... settings from the configuration (engine-path, engine-depth ...)
+func SettingsFromConfig() Settings {
+	settings := Settings{
+		Path:     viper.GetString("engine-path"),
+		Depth:    viper.GetInt("engine-depth"),
+		MoveTime: time.Duration(viper.GetInt("engine-movetime")) * time.Millisecond,
+		Threads:  viper.GetInt("engine-threads"),
+		Hash:     viper.GetInt("engine-hash"),
+
+		Instances: viper.GetInt("engine-instances"),
+		Timeout:   viper.GetDuration("engine-timeout"),
+	}
+	if settings.Depth == 0 && settings.MoveTime == 0 {
+		settings.Depth = 16
+	}
+	if settings.Instances <= 0 {
+		settings.Instances = 1
+	}
+	if !viper.IsSet("engine-timeout") {
+		settings.Timeout = time.Minute
+		if settings.MoveTime*10 > settings.Timeout {
+			settings.Timeout = settings.MoveTime * 10
+		}
+	}
+	return settings
+}
+
+// Eval ... evaluation of a position
+type Eval struct {
+	CP       int    // centipawns (white's point of view), meaningless if Mate != 0
+	Mate     int    // moves to mate (> 0 white mates, < 0 black mates), 0 if no mate found
+	BestMove string // UCI notation
+	Depth    int
+}
+
+// String ... lichess %eval format: 0.35 or #-3
+func (eval Eval) String() string {
+	if eval.Mate != 0 {
+		return "#" + strconv.Itoa(eval.Mate)
+	}
+	return strconv.FormatFloat(float64(eval.CP)/100, 'f', 2, 64)
+}
+
+// Score ... centipawns (white's point of view) with mates mapped to +/- 10000
+func (eval Eval) Score() int {
+	switch {
+	case eval.Mate > 0:
+		return 10000 - eval.Mate
+	case eval.Mate < 0:
+		return -10000 - eval.Mate
+	default:
+		return eval.CP
+	}
+}
+
+// Engine ... a running UCI engine
+type Engine struct {
+	settings Settings
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	stdout   *bufio.Scanner
+	stopped  bool // the process exited (crash, timeout): the engine cannot be used anymore
+}
+
+// Start ... start the engine and wait until it is ready
+func Start(settings Settings) (*Engine, error) {
+	if settings.Path == "" {
+		return nil, errors.New("no engine: set engine-path in the configuration or use --engine")
+	}
+
+	cmd := exec.Command(settings.Path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("cannot start engine %s: %v", settings.Path, err)
+	}
+
+	engine := Engine{settings: settings, cmd: cmd, stdin: stdin, stdout: bufio.NewScanner(stdout)}
+	if err = engine.send("uci"); err != nil {
+		return nil, err
+	}
+	if _, err = engine.waitFor("uciok"); err != nil {
+		return nil, err
+	}
+	if settings.Threads > 0 {
+		engine.send("setoption name Threads value " + strconv.Itoa(settings.Threads))
+	}
+	if settings.Hash > 0 {
+		engine.send("setoption name Hash value " + strconv.Itoa(settings.Hash))
+	}
+	if err = engine.IsReady(); err != nil {
+		return nil, err
+	}
+	return &engine, nil
+}
+
+// IsReady ... check that the engine answers
+func (engine *Engine) IsReady() error {
+	if err := engine.send("isready"); err != nil {
+		return err
+	}
+	_, err := engine.waitFor("readyok")
+	return err
+}
+
+// NewGame ... tell the engine that the next positions belong to another game
+func (engine *Engine) NewGame() error {
+	if err := engine.send("ucinewgame"); err != nil {
+		return err
+	}
+	return engine.IsReady()
+}
+
+// Evaluate ... evaluate a position given as FEN
+func (engine *Engine) Evaluate(fen string) (Eval, error) {
+	eval := Eval{}
+	if err := engine.send("position fen " + fen); err != nil {
+		return eval, err
+	}
+	goCommand := "go depth " + strconv.Itoa(engine.settings.Depth)
+	if engine.settings.MoveTime > 0 {
+		goCommand = "go movetime " + strconv.FormatInt(engine.settings.MoveTime.Milliseconds(), 10)
+	}
+	if err := engine.send(goCommand); err != nil {
+		return eval, err
+	}
+	if engine.settings.Timeout > 0 {
+		// a stuck engine never answers bestmove: killing it ends the loop below
+		watchdog := time.AfterFunc(engine.settings.Timeout, engine.kill)
+		defer watchdog.Stop()
+	}
+
+	blackToMove := len(strings.Fields(fen)) > 1 && strings.Fields(fen)[1] == "b"
+	for engine.stdout.Scan() {
+		fields := strings.Fields(engine.stdout.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "info":
+			parseInfo(fields, &eval)
+		case "bestmove":
+			if len(fields) > 1 && fields[1] != "(none)" {
+				eval.BestMove = fields[1]
+			}
+			if blackToMove {
+				eval.CP, eval.Mate = -eval.CP, -eval.Mate
+			}
+			return eval, nil
+		}
+	}
+	return eval, engine.exited()
+}
+
+// Close ... stop the engine
+func (engine *Engine) Close() error {
+	engine.send("quit")
+	engine.stdin.Close()
+	return engine.cmd.Wait()
+}
+
+// Stopped ... the engine process exited (crash, killed after Timeout)
+func (engine *Engine) Stopped() bool {
+	return engine.stopped
+}
+
+// kill ... stop the process at once
+func (engine *Engine) kill() {
+	engine.cmd.Process.Kill()
+}
+
+// info depth 20 seldepth 25 multipv 1 score cp 35 nodes ... pv e2e4 e7e5
+func parseInfo(fields []string, eval *Eval) {
+	for i := 1; i < len(fields)-1; i++ {
+		switch fields[i] {
+		case "depth":
+			eval.Depth, _ = strconv.Atoi(fields[i+1])
+		case "multipv":
+			if fields[i+1] != "1" {
+				return // only the main line
+			}
+		case "score":
+			if i+2 < len(fields) {
+				value, err := strconv.Atoi(fields[i+2])
+				if err != nil {
+					return
+				}
+				switch fields[i+1] {
+				case "cp":
+					eval.CP, eval.Mate = value, 0
+				case "mate":
+					eval.Mate = value
+				}
+			}
+		}
+	}
+}
+
+func (engine *Engine) send(command string) error {
+	_, err := io.WriteString(engine.stdin, command+"\n")
+	if err != nil {
+		return engine.exited()
+	}
+	return nil
+}
+
+func (engine *Engine) waitFor(answer string) (string, error) {
+	for engine.stdout.Scan() {
+		line := strings.TrimSpace(engine.stdout.Text())
+		if line == answer {
+			return line, nil
+		}
+	}
+	return "", engine.exited()
+}
+
+func (engine *Engine) exited() error {
+	engine.stopped = true
+	if err := engine.stdout.Err(); err != nil {
+		return fmt.Errorf("engine %s: %v", engine.settings.Path, err)
+	}
+	return fmt.Errorf("engine %s stopped unexpectedly", engine.settings.Path)
+}
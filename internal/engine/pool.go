@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+Pool of engines for long analyses (thousands of games overnight): Settings.Instances engines run at once,
+an engine is checked before every use (isready, answered within healthTimeout) and a crashed or stuck engine
+is replaced by a new one; the work it was doing is given to the new engine once.
+*/
+
+// healthTimeout ... time for an engine to answer isready
+const healthTimeout = 10 * time.Second
+
+// maxRestarts ... engines replaced in a row without a successful use: the engine cannot run, the pool gives up
+const maxRestarts = 5
+
+// ErrPoolClosed ... the pool was closed
+var ErrPoolClosed = errors.New("engine pool closed")
+
+// Pool ... engines shared by goroutines
+type Pool struct {
+	settings Settings
+	idle     chan *Engine
+
+	mutex    sync.Mutex
+	restarts int // engines replaced since the pool started
+	failures int // engines replaced in a row
+	closed   bool
+}
+
+// PoolStats ... state of a pool
+type PoolStats struct {
+	Instances int `json:"instances"`
+	Restarts  int `json:"restarts"` // crashed or stuck engines replaced
+}
+
+// NewPool ... start settings.Instances engines
+func NewPool(settings Settings) (*Pool, error) {
+	if settings.Instances <= 0 {
+		settings.Instances = 1
+	}
+	pool := Pool{settings: settings, idle: make(chan *Engine, settings.Instances)}
+	for i := 0; i < settings.Instances; i++ {
+		engine, err := Start(settings)
+		if err != nil {
+			for ; i > 0; i-- {
+				(<-pool.idle).Close()
+			}
+			return nil, err
+		}
+		pool.idle <- engine
+	}
+	return &pool, nil
+}
+
+// Size ... engines of the pool
+func (pool *Pool) Size() int {
+	return pool.settings.Instances
+}
+
+// Stats ... engines and restarts
+func (pool *Pool) Stats() PoolStats {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	return PoolStats{Instances: pool.settings.Instances, Restarts: pool.restarts}
+}
+
+// Do ... run {work} with an engine of the pool (waiting for a free one); when the engine crashes or gets stuck
+// during {work}, it is replaced and {work} runs again with the new engine (so it must start from scratch: NewGame)
+func (pool *Pool) Do(work func(engine *Engine) error) error {
+	engine, err := pool.take()
+	if err != nil {
+		return err
+	}
+	err = work(engine)
+	if engine.Stopped() {
+		log.Warn(fmt.Sprintf("engine %s stopped (%v), restarting it", pool.settings.Path, err))
+		if engine, err = pool.replace(engine); err != nil {
+			pool.give(nil)
+			return err
+		}
+		err = work(engine)
+	}
+	if !engine.Stopped() {
+		pool.mutex.Lock()
+		pool.failures = 0
+		pool.mutex.Unlock()
+	}
+	pool.give(engine)
+	return err
+}
+
+// Close ... stop the engines (waits for the running Do)
+func (pool *Pool) Close() {
+	pool.mutex.Lock()
+	if pool.closed {
+		pool.mutex.Unlock()
+		return
+	}
+	pool.closed = true
+	pool.mutex.Unlock()
+	for i := 0; i < pool.settings.Instances; i++ {
+		if engine := <-pool.idle; engine != nil {
+			engine.Close()
+		}
+	}
+}
+
+// take ... a free engine, healthy or replaced
+func (pool *Pool) take() (*Engine, error) {
+	pool.mutex.Lock()
+	closed := pool.closed
+	pool.mutex.Unlock()
+	if closed {
+		return nil, ErrPoolClosed
+	}
+
+	engine := <-pool.idle
+	if engine != nil && healthy(engine) {
+		return engine, nil
+	}
+	engine, err := pool.replace(engine)
+	if err != nil {
+		pool.give(nil)
+		return nil, err
+	}
+	return engine, nil
+}
+
+// give ... {engine} is free again (nil: its place, to replace by the next take)
+func (pool *Pool) give(engine *Engine) {
+	pool.idle <- engine
+}
+
+// replace ... stop {engine} (nil: none) and start a new one
+func (pool *Pool) replace(engine *Engine) (*Engine, error) {
+	if engine != nil {
+		engine.kill()
+		engine.Close()
+	}
+	pool.mutex.Lock()
+	pool.restarts++
+	pool.failures++
+	failures := pool.failures
+	pool.mutex.Unlock()
+	if failures > maxRestarts {
+		return nil, fmt.Errorf("engine %s: %d restarts in a row, giving up", pool.settings.Path, maxRestarts)
+	}
+	return Start(pool.settings)
+}
+
+// healthy ... the engine answers isready within healthTimeout (otherwise it is killed)
+func healthy(engine *Engine) bool {
+	if engine.Stopped() {
+		return false
+	}
+	watchdog := time.AfterFunc(healthTimeout, engine.kill)
+	defer watchdog.Stop()
+	return engine.IsReady() == nil
+}
@@ -0,0 +1,171 @@
+package evals
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/engine"
+	"github.com/flutterbar/chess-explorer-go/internal/lichess"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/zobrist"
+	"github.com/notnil/chess"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+Evaluations of positions shared by all the analyses, in the evals collection by position (Zobrist hash):
+a position met in hundreds of games is evaluated once, whatever the source (local engine or cloud of lichess.org,
+eval-source in the config file). A stored evaluation is used when it is as deep as the one asked
+(engine-depth; any with engine-movetime), and replaced by a deeper one.
+The positions lichess.org has no cloud evaluation for are asked again after missingTTL.
+*/
+
+// Sources of the evaluations (eval-source)
+const (
+	SourceEngine = "engine" // local UCI engine (engine-path), the default
+	SourceCloud  = "cloud"  // lichess.org cloud evaluations
+)
+
+// missingTTL ... positions without cloud evaluation are asked again after this time (analysed by lichess.org users meanwhile)
+const missingTTL = 30 * 24 * time.Hour
+
+// Source ... eval-source setting
+func Source() (string, error) {
+	source := viper.GetString("eval-source")
+	switch source {
+	case "":
+		return SourceEngine, nil
+	case SourceEngine, SourceCloud:
+		return source, nil
+	}
+	return "", errors.New("invalid eval-source " + source + ": " + SourceEngine + " or " + SourceCloud)
+}
+
+// Entry ... evaluation of a position
+type Entry struct {
+	Hash     int64     `bson:"_id"`
+	FEN      string    `bson:"fen"`
+	CP       int       `bson:"cp"` // white's point of view
+	Mate     int       `bson:"mate,omitempty"`
+	BestMove string    `bson:"bestmove,omitempty"` // UCI
+	Depth    int       `bson:"depth"`
+	Source   string    `bson:"source"`
+	Missing  bool      `bson:"missing,omitempty"` // the source has no evaluation of the position
+	Updated  time.Time `bson:"updated"`
+}
+
+// Eval ... the evaluation of the entry
+func (entry Entry) Eval() engine.Eval {
+	return engine.Eval{CP: entry.CP, Mate: entry.Mate, BestMove: entry.BestMove, Depth: entry.Depth}
+}
+
+// Evaluate ... evaluation of a position, false when the source has none
+type Evaluate func(ctx context.Context, position *chess.Position) (engine.Eval, bool, error)
+
+// Cache ... the evals collection
+type Cache struct {
+	collection *mongo.Collection
+
+	mutex  sync.Mutex
+	hits   int // positions found in the collection
+	misses int // positions evaluated by the source
+}
+
+// NewCache ... the evals collection of {client}
+func NewCache(client *mongodb.Client) *Cache {
+	return &Cache{collection: mongodb.Collection(client, "evals")}
+}
+
+// Stats ... positions found in the collection and positions evaluated by the sources
+func (cache *Cache) Stats() (hits int, misses int) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return cache.hits, cache.misses
+}
+
+// Cached ... {evaluate} ({source}) through the cache: a stored evaluation of {minDepth} at least is used,
+// otherwise the evaluation of {evaluate} is stored
+func (cache *Cache) Cached(source string, minDepth int, evaluate Evaluate) Evaluate {
+	return func(ctx context.Context, position *chess.Position) (engine.Eval, bool, error) {
+		hash := zobrist.Hash(position)
+		var entry Entry
+		err := cache.collection.FindOne(ctx, bson.M{"_id": hash}).Decode(&entry)
+		switch {
+		case err == mongo.ErrNoDocuments:
+		case err != nil:
+			return engine.Eval{}, false, err
+		case !entry.Missing && entry.Depth >= minDepth:
+			cache.count(true)
+			return entry.Eval(), true, nil
+		case entry.Missing && entry.Source == source && time.Since(entry.Updated) < missingTTL:
+			cache.count(true)
+			return engine.Eval{}, false, nil
+		}
+
+		eval, found, err := evaluate(ctx, position)
+		if err != nil {
+			return eval, false, err
+		}
+		cache.count(false)
+		entry = Entry{
+			Hash: hash, FEN: position.String(), CP: eval.CP, Mate: eval.Mate, BestMove: eval.BestMove, Depth: eval.Depth,
+			Source: source, Missing: !found, Updated: time.Now().UTC(),
+		}
+		return eval, found, cache.store(ctx, entry)
+	}
+}
+
+// store ... {entry} unless a deeper evaluation is stored (a missing evaluation does not replace an evaluation)
+func (cache *Cache) store(ctx context.Context, entry Entry) error {
+	filter := bson.M{"_id": entry.Hash, "$or": bson.A{bson.M{"missing": true}, bson.M{"depth": bson.M{"$lt": entry.Depth}}}}
+	if entry.Missing {
+		filter = bson.M{"_id": entry.Hash, "missing": true}
+	}
+	_, err := cache.collection.ReplaceOne(ctx, filter, entry, options.Replace().SetUpsert(true))
+	if writeErr, ok := err.(mongo.WriteException); ok && len(writeErr.WriteErrors) == 1 && writeErr.WriteErrors[0].Code == 11000 {
+		return nil // the stored evaluation is better: kept
+	}
+	return err
+}
+
+func (cache *Cache) count(hit bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if hit {
+		cache.hits++
+	} else {
+		cache.misses++
+	}
+}
+
+// Cloud ... lichess.org cloud evaluations through {cache}
+func Cloud(cache *Cache) (Evaluate, error) {
+	cloud, err := lichess.NewCloudEval()
+	if err != nil {
+		return nil, err
+	}
+	return cache.Cached(SourceCloud, 0, func(ctx context.Context, position *chess.Position) (engine.Eval, bool, error) {
+		return cloud.Evaluate(position.String())
+	}), nil
+}
+
+// Engine ... evaluations of {uci} through {cache}, stored evaluations are used when they are as deep as {settings} asks
+func Engine(cache *Cache, uci *engine.Engine, settings engine.Settings) Evaluate {
+	return cache.Cached(SourceEngine, MinDepth(settings), func(ctx context.Context, position *chess.Position) (engine.Eval, bool, error) {
+		eval, err := uci.Evaluate(position.String())
+		return eval, err == nil, err
+	})
+}
+
+// MinDepth ... depth of the stored evaluations used instead of evaluations with {settings} (any with a move time)
+func MinDepth(settings engine.Settings) int {
+	if settings.MoveTime > 0 {
+		return 0
+	}
+	return settings.Depth
+}
@@ -0,0 +1,127 @@
+package events
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/httpclient"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+/*
+Events for other programs, sent for every batch of new games stored in the database:
+  - webhook: POST of the event as JSON to the webhook-url setting, signed when webhook-secret is set
+    (header X-Chess-Explorer-Signature: sha256={HMAC-SHA256 of the body, hex})
+  - message broker: MQTT or NATS, events-broker-url setting (see broker.go)
+  - library: Subscribe returns a channel of events
+
+A failing webhook or broker is logged, the import goes on.
+*/
+
+// TypeGamesIngested ... type of GamesIngested events
+const TypeGamesIngested = "games.ingested"
+
+// GamesIngested ... a batch of new games in the database
+type GamesIngested struct {
+	Type     string    `json:"type"`
+	Username string    `json:"username,omitempty"` // empty for pgn files imported without a username
+	Site     string    `json:"site,omitempty"`
+	Count    int       `json:"count"`
+	GameIDs  []string  `json:"gameIds"`
+	Time     time.Time `json:"time"`
+}
+
+var subscribers = map[chan GamesIngested]bool{}
+var mutex sync.Mutex
+
+// Subscribe ... channel receiving the events (buffered by {size}, events are dropped when it is full)
+// call the returned function to unsubscribe
+func Subscribe(size int) (<-chan GamesIngested, func()) {
+	channel := make(chan GamesIngested, size)
+	mutex.Lock()
+	subscribers[channel] = true
+	mutex.Unlock()
+
+	return channel, func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if subscribers[channel] {
+			delete(subscribers, channel)
+			close(channel)
+		}
+	}
+}
+
+// Publish ... send {event} to the subscribers, to the webhook and to the broker
+func Publish(event GamesIngested) {
+	event.Type = TypeGamesIngested
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	mutex.Lock()
+	for channel := range subscribers {
+		select {
+		case channel <- event:
+		default:
+			log.Warn("Event dropped: subscriber is not reading")
+		}
+	}
+	mutex.Unlock()
+
+	if webhook := viper.GetString("webhook-url"); webhook != "" {
+		if err := post(webhook, event); err != nil {
+			log.Warn("Webhook failed: " + err.Error())
+		}
+	}
+	if broker := viper.GetString("events-broker-url"); broker != "" {
+		if err := publishToBroker(broker, event); err != nil {
+			log.Warn("Event broker failed: " + err.Error())
+		}
+	}
+}
+
+func post(webhook string, event GamesIngested) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	client, err := httpclient.New()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret := viper.GetString("webhook-secret"); secret != "" {
+		req.Header.Set("X-Chess-Explorer-Signature", "sha256="+sign(body, secret))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &url.Error{Op: "Post", URL: webhook, Err: errors.New(resp.Status)}
+	}
+	return nil
+}
+
+// sign ... HMAC-SHA256 of {body} with {secret}, hex encoded
+func sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
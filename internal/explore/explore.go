@@ -0,0 +1,254 @@
+package explore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/server"
+	"github.com/notnil/chess"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+/*
+Opening explorer in the terminal (chess-explorer explore)
+
+Same tree as the web page: the moves played in the position, with the results of the games
+*/
+
+const barWidth = 30
+
+const help = `  {move}     play a move (Nf3, e4, O-O or UCI g1f3)
+  {number}   play the move of this line
+  b, back    take back the last move
+  t, top     back to the first position
+  d, board   show or hide the board
+  q, quit    leave`
+
+// explorer ... session reading commands from {in}
+type explorer struct {
+	games  *mongo.Collection
+	values url.Values // filter form values, without pgn and fen
+	start  string     // FEN of the first position, "" for the starting position
+	moves  []string   // SAN of the current line
+	board  bool
+	in     *bufio.Reader
+	out    io.Writer
+}
+
+// Run ... explore the games matching {values} (filter form values, pgn or fen is the first position), commands from {in}
+// from a fen, the positions are explored whatever the move order
+func Run(in io.Reader, out io.Writer, values url.Values, board bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	client, err := mongodb.Connect(ctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+
+	e := explorer{
+		games:  mongodb.Collection(client, "games"),
+		values: url.Values{},
+		board:  board,
+		in:     bufio.NewReader(in),
+		out:    out,
+	}
+	for key := range values {
+		if key != "pgn" && key != "fen" {
+			e.values.Set(key, values.Get(key))
+		}
+	}
+	if e.start = values.Get("fen"); e.start != "" {
+		if values.Get("pgn") != "" {
+			return errors.New("Set a pgn or a fen, not both")
+		}
+		if _, err = e.game(); err != nil {
+			return err
+		}
+	}
+	for _, bit := range strings.Fields(values.Get("pgn")) {
+		if strings.HasSuffix(bit, ".") {
+			continue
+		}
+		if err = e.play(bit); err != nil {
+			return fmt.Errorf("Invalid pgn %q: %w", values.Get("pgn"), err)
+		}
+	}
+
+	fmt.Fprintln(out, "Type a move or a line number (? for help)")
+	for {
+		nextMoves, err := e.nextMoves()
+		if err != nil {
+			return err
+		}
+		e.print(nextMoves)
+
+		fmt.Fprint(out, "> ")
+		line, err := e.in.ReadString('\n')
+		if err == io.EOF && line == "" {
+			fmt.Fprintln(out)
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		command := strings.TrimSpace(line)
+		switch command {
+		case "":
+		case "q", "quit", "exit":
+			return nil
+		case "?", "h", "help":
+			fmt.Fprintln(out, help)
+		case "b", "back":
+			if len(e.moves) > 0 {
+				e.moves = e.moves[:len(e.moves)-1]
+			}
+		case "t", "top":
+			e.moves = nil
+		case "d", "board":
+			e.board = !e.board
+		default:
+			if number, err := strconv.Atoi(command); err == nil {
+				if number < 1 || number > len(nextMoves) || nextMoves[number-1].Move == "End" {
+					fmt.Fprintln(out, "No move on line "+command)
+					continue
+				}
+				command = nextMoves[number-1].Move
+			}
+			if err := e.play(command); err != nil {
+				fmt.Fprintln(out, err)
+			}
+		}
+	}
+}
+
+// play ... add {move} (SAN or UCI) to the current line
+func (e *explorer) play(move string) error {
+	game, err := e.game()
+	if err != nil {
+		return err
+	}
+	position := game.Position()
+
+	decoded, err := chess.AlgebraicNotation{}.Decode(position, move)
+	if err != nil {
+		decoded, err = chess.UCINotation{}.Decode(position, move)
+	}
+	if err != nil {
+		return errors.New("Illegal move " + move)
+	}
+	// encode the valid move (with check tags) to get the SAN of the games (Bb5+)
+	for _, valid := range position.ValidMoves() {
+		if valid.S1() == decoded.S1() && valid.S2() == decoded.S2() && valid.Promo() == decoded.Promo() {
+			e.moves = append(e.moves, chess.AlgebraicNotation{}.Encode(position, valid))
+			return nil
+		}
+	}
+	return errors.New("Illegal move " + move)
+}
+
+// game ... the current line played from the first position
+func (e *explorer) game() (*chess.Game, error) {
+	game := chess.NewGame()
+	if e.start != "" {
+		option, err := chess.FEN(e.start)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid fen %q: %w", e.start, err)
+		}
+		game = chess.NewGame(option)
+	}
+	for _, move := range e.moves {
+		if err := game.MoveStr(move); err != nil {
+			return nil, err
+		}
+	}
+	return game, nil
+}
+
+// pgn ... the current line as in the games (1. e4 e5 2. Nf3)
+func (e *explorer) pgn() string {
+	bits := make([]string, 0, len(e.moves)*3/2)
+	for i, move := range e.moves {
+		if i%2 == 0 {
+			bits = append(bits, strconv.Itoa(i/2+1)+".")
+		}
+		bits = append(bits, move)
+	}
+	return strings.Join(bits, " ")
+}
+
+func (e *explorer) nextMoves() ([]server.NextMove, error) {
+	values := url.Values{}
+	for key := range e.values {
+		values.Set(key, e.values.Get(key))
+	}
+	if e.start != "" {
+		game, err := e.game()
+		if err != nil {
+			return nil, err
+		}
+		values.Set("fen", game.Position().String())
+	} else {
+		values.Set("pgn", e.pgn())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return server.NextMoves(ctx, e.games, server.NewGameFilter(values))
+}
+
+func (e *explorer) print(nextMoves []server.NextMove) {
+	fmt.Fprintln(e.out)
+	if e.board {
+		if game, err := e.game(); err == nil {
+			fmt.Fprint(e.out, game.Position().Board().Draw())
+		}
+	}
+	switch {
+	case e.start != "" && len(e.moves) == 0:
+		fmt.Fprintln(e.out, e.start)
+	case e.start != "":
+		fmt.Fprintln(e.out, e.start+": "+strings.Join(e.moves, " "))
+	case len(e.moves) == 0:
+		fmt.Fprintln(e.out, "Start position")
+	default:
+		fmt.Fprintln(e.out, e.pgn())
+	}
+	if len(nextMoves) == 0 {
+		fmt.Fprintln(e.out, "  No games")
+		return
+	}
+
+	fmt.Fprintf(e.out, "  %3s  %-8s %7s  %s\n", "#", "Move", "Games", "White / Draw / Black")
+	for i, nextMove := range nextMoves {
+		white, draw, black := percents(nextMove)
+		fmt.Fprintf(e.out, "  %3d  %-8s %7d  %s  %3d%% %3d%% %3d%%\n", i+1, nextMove.Move, nextMove.Total, bar(white, draw), white, draw, black)
+	}
+}
+
+// percents ... white, draw and black results of {nextMove}, adding up to 100
+func percents(nextMove server.NextMove) (white int, draw int, black int) {
+	if nextMove.Total == 0 {
+		return 0, 0, 0
+	}
+	white = int(nextMove.White * 100 / nextMove.Total)
+	black = int(nextMove.Black * 100 / nextMove.Total)
+	draw = 100 - white - black
+	return white, draw, black
+}
+
+// bar ... █ white wins, ▒ draws, ░ black wins
+func bar(white int, draw int) string {
+	whiteWidth := white * barWidth / 100
+	drawWidth := (white+draw)*barWidth/100 - whiteWidth
+	return strings.Repeat("█", whiteWidth) + strings.Repeat("▒", drawWidth) + strings.Repeat("░", barWidth-whiteWidth-drawWidth)
+}
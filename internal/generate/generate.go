@@ -0,0 +1,345 @@
+package generate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/flutterbar/chess-explorer-go/internal/zobrist"
+	"github.com/notnil/chess"
+)
+
+/*
+Synthetic games for load testing and demos (chess-explorer generate): random but plausible games, written as PGN
+and imported as a download would be.
+  - a pool of players on lichess.org and chess.com, each with a rating of their own
+  - a popular opening (weighted as in real databases), then random legal moves, captures preferred
+  - a time control of every speed, clocks after every move, a result following the ratings
+  - dates spread over the period, one game at a time (the ids of the games are unique)
+The games of a seed are always the same: generate twice with the same seed for the same database.
+*/
+
+// Options ... what to generate
+type Options struct {
+	Games   int       // number of games
+	Players int       // size of the pool of players
+	Prefix  string    // usernames of the players: {prefix}001...
+	From    time.Time // first game
+	To      time.Time // last game
+	Seed    int64
+}
+
+// opening ... a popular line, with its weight
+type opening struct {
+	eco    string
+	name   string
+	moves  string
+	weight int
+}
+
+var openings = []opening{
+	{"B90", "Sicilian Defense: Najdorf Variation", "e4 c5 Nf3 d6 d4 cxd4 Nxd4 Nf6 Nc3 a6", 9},
+	{"B33", "Sicilian Defense: Lasker-Pelikan Variation", "e4 c5 Nf3 Nc6 d4 cxd4 Nxd4 Nf6 Nc3 e5", 5},
+	{"C65", "Ruy Lopez: Berlin Defense", "e4 e5 Nf3 Nc6 Bb5 Nf6", 7},
+	{"C84", "Ruy Lopez: Closed", "e4 e5 Nf3 Nc6 Bb5 a6 Ba4 Nf6 O-O Be7", 6},
+	{"C50", "Italian Game: Giuoco Piano", "e4 e5 Nf3 Nc6 Bc4 Bc5 c3 Nf6", 8},
+	{"C42", "Petrov's Defense", "e4 e5 Nf3 Nf6 Nxe5 d6 Nf3 Nxe4", 3},
+	{"C00", "French Defense: Normal Variation", "e4 e6 d4 d5", 6},
+	{"C11", "French Defense: Classical Variation", "e4 e6 d4 d5 Nc3 Nf6", 3},
+	{"B12", "Caro-Kann Defense: Advance Variation", "e4 c6 d4 d5 e5 Bf5", 5},
+	{"B01", "Scandinavian Defense", "e4 d5 exd5 Qxd5 Nc3 Qa5", 5},
+	{"B07", "Pirc Defense", "e4 d6 d4 Nf6 Nc3 g6", 3},
+	{"D37", "Queen's Gambit Declined", "d4 d5 c4 e6 Nc3 Nf6 Nf3 Be7", 6},
+	{"D20", "Queen's Gambit Accepted", "d4 d5 c4 dxc4 e4", 3},
+	{"D02", "Queen's Pawn Game: London System", "d4 d5 Nf3 Nf6 Bf4", 7},
+	{"E60", "King's Indian Defense", "d4 Nf6 c4 g6 Nc3 Bg7 e4 d6", 5},
+	{"E20", "Nimzo-Indian Defense", "d4 Nf6 c4 e6 Nc3 Bb4", 4},
+	{"A10", "English Opening", "c4 e5 Nc3 Nf6", 4},
+	{"A04", "Zukertort Opening", "Nf3 d5 g3 Nf6 Bg2", 3},
+	{"C44", "Scotch Game", "e4 e5 Nf3 Nc6 d4 exd4 Nxd4", 4},
+	{"A00", "Van't Kruijs Opening", "e3 e5", 1},
+}
+
+// timeControls ... time controls, weighted as on the sites
+var timeControls = []struct {
+	timeControl string
+	weight      int
+}{
+	{"60+0", 6}, {"120+1", 3}, {"180+0", 8}, {"180+2", 6}, {"300+0", 8}, {"300+3", 5},
+	{"600+0", 7}, {"600+5", 5}, {"900+10", 4}, {"1800+0", 2}, {"1800+20", 1},
+}
+
+// player ... a player of the pool
+type player struct {
+	site     string
+	username string
+	rating   float64
+}
+
+// Write ... write the games of {options} to {w} as PGN, oldest first; {progress} (when not nil) is called with the games
+// written so far, every 1000 games
+func Write(w io.Writer, options Options, progress func(written int)) error {
+	if options.Games < 1 || options.Players < 4 {
+		return fmt.Errorf("invalid options: %d games, %d players (1 game and 4 players at least)", options.Games, options.Players)
+	}
+	if !options.To.After(options.From) {
+		return fmt.Errorf("invalid period: %s is not after %s", options.To.Format("2006-01-02"), options.From.Format("2006-01-02"))
+	}
+	lines, err := openingLines()
+	if err != nil {
+		return err
+	}
+	pool := players(options)
+	step := options.To.Sub(options.From) / time.Duration(options.Games)
+	if step < time.Second {
+		step = time.Second // ids of the games: one game a second at most
+	}
+
+	// games generated in parallel by chunks, written in order
+	const chunk = 1000
+	chunks := (options.Games + chunk - 1) / chunk
+	results := make([]chan string, chunks)
+	for i := range results {
+		results[i] = make(chan string, 1)
+	}
+	workers := make(chan struct{}, runtime.NumCPU())
+	go func() {
+		for c := 0; c < chunks; c++ {
+			workers <- struct{}{}
+			go func(c int) {
+				defer func() { <-workers }()
+				var text strings.Builder
+				for i := c * chunk; i < (c+1)*chunk && i < options.Games; i++ {
+					random := rand.New(rand.NewSource(options.Seed + int64(i)))
+					date := options.From.Add(time.Duration(i) * step).Add(time.Duration(random.Int63n(int64(step/time.Second))) * time.Second / 2)
+					text.WriteString(game(random, i, lines, pool, date))
+				}
+				results[c] <- text.String()
+			}(c)
+		}
+	}()
+
+	writer := bufio.NewWriter(w)
+	for c := range results {
+		if _, err := writer.WriteString(<-results[c]); err != nil {
+			return err
+		}
+		if progress != nil {
+			written := (c + 1) * chunk
+			if written > options.Games {
+				written = options.Games
+			}
+			progress(written)
+		}
+	}
+	return writer.Flush()
+}
+
+// openingLines ... the moves of the openings, checked
+func openingLines() ([][]*chess.Move, error) {
+	lines := make([][]*chess.Move, len(openings))
+	for i, opening := range openings {
+		position := chess.StartingPosition()
+		for _, san := range strings.Fields(opening.moves) {
+			move, err := pgn.DecodeMove(position, san)
+			if err != nil {
+				return nil, fmt.Errorf("opening %s: %w", opening.name, err)
+			}
+			lines[i] = append(lines[i], move)
+			position = position.Update(move)
+		}
+	}
+	return lines, nil
+}
+
+// players ... the pool of players of {options}, half on each site, rated from 800 to 2600
+func players(options Options) []player {
+	random := rand.New(rand.NewSource(options.Seed))
+	pool := make([]player, options.Players)
+	for i := range pool {
+		site := "lichess.org"
+		if i%2 == 1 {
+			site = "chess.com"
+		}
+		rating := math.Max(800, math.Min(2600, random.NormFloat64()*300+1500))
+		pool[i] = player{site: site, username: fmt.Sprintf("%s%03d", options.Prefix, i+1), rating: rating}
+	}
+	return pool
+}
+
+// weighted ... an index of {weights}, drawn according to them
+func weighted(random *rand.Rand, weights []int) int {
+	total := 0
+	for _, weight := range weights {
+		total += weight
+	}
+	draw := random.Intn(total)
+	for i, weight := range weights {
+		if draw < weight {
+			return i
+		}
+		draw -= weight
+	}
+	return len(weights) - 1
+}
+
+// game ... the PGN of the game {index}, played at {date} by two players of {pool} of the same site
+func game(random *rand.Rand, index int, lines [][]*chess.Move, pool []player, date time.Time) string {
+	// the players of a site are every other player of the pool
+	first := random.Intn(2)
+	white := pool[first+2*random.Intn((len(pool)-first+1)/2)]
+	black := white
+	for black.username == white.username {
+		black = pool[first+2*random.Intn((len(pool)-first+1)/2)]
+	}
+	weights := make([]int, len(timeControls))
+	for i, control := range timeControls {
+		weights[i] = control.weight
+	}
+	timeControl := timeControls[weighted(random, weights)].timeControl
+	base, increment := 0.0, 0.0
+	fmt.Sscanf(timeControl, "%g+%g", &base, &increment)
+	whiteElo := int(white.rating + random.NormFloat64()*40)
+	blackElo := int(black.rating + random.NormFloat64()*40)
+
+	// result from the ratings (Elo expected score), a third of the balanced games are drawn
+	expected := 1 / (1 + math.Pow(10, float64(blackElo-whiteElo)/400))
+	draw := 0.3 * (1 - math.Abs(2*expected-1))
+	result, termination := "1/2-1/2", "Normal"
+	switch outcome := random.Float64(); {
+	case outcome < expected-draw/2:
+		result = "1-0"
+	case outcome < expected+draw/2:
+	default:
+		result = "0-1"
+	}
+	if result != "1/2-1/2" && random.Intn(4) == 0 {
+		termination = "Time forfeit"
+	}
+
+	// moves: an opening, then random moves (captures preferred) until mate or the length of the game
+	weights = make([]int, len(openings))
+	for i, opening := range openings {
+		weights[i] = opening.weight
+	}
+	chosen := weighted(random, weights)
+	length := 20 + random.Intn(100)
+	position := chess.StartingPosition()
+	seen := map[int64]int{} // positions, for the repetitions
+	clocks := [2]float64{base, base}
+	var movetext strings.Builder
+	for ply := 1; ply <= length; ply++ {
+		var move *chess.Move
+		if ply <= len(lines[chosen]) {
+			move = lines[chosen][ply-1]
+		} else {
+			move = randomMove(random, position)
+		}
+		san := chess.AlgebraicNotation{}.Encode(position, move)
+		position = position.Update(move)
+		seen[zobrist.Hash(position)]++
+
+		side := (ply + 1) % 2
+		spent := math.Min(clocks[side]*(0.01+random.Float64()*0.06), clocks[side]-0.1)
+		clocks[side] = math.Max(0, clocks[side]-spent) + increment
+		if ply%2 == 1 {
+			movetext.WriteString(pgn.MoveNumber(ply) + " ")
+		}
+		movetext.WriteString(fmt.Sprintf("%s { [%%clk %s] } ", san, clock(clocks[side])))
+		// ended by the rules (the games are too short for the 75 moves rule)
+		if position.Status() == chess.Checkmate {
+			result, termination = "1-0", "Normal"
+			if ply%2 == 0 {
+				result = "0-1"
+			}
+			break
+		}
+		if position.Status() == chess.Stalemate || insufficientMaterial(position.Board()) || seen[zobrist.Hash(position)] == 5 {
+			result, termination = "1/2-1/2", "Normal"
+			break
+		}
+	}
+
+	site, link := "https://lichess.org/"+gameCode(random), ""
+	if white.site == "chess.com" {
+		site, link = "Chess.com", fmt.Sprintf("https://www.chess.com/game/live/%d", 10000000000+index)
+	}
+	tags := []string{
+		fmt.Sprintf("[Event \"Rated %s game\"]", strings.Title(pgntodb.Speed(timeControl))),
+		fmt.Sprintf("[Site \"%s\"]", site),
+		fmt.Sprintf("[Date \"%s\"]", date.Format("2006.01.02")),
+		fmt.Sprintf("[White \"%s\"]", white.username),
+		fmt.Sprintf("[Black \"%s\"]", black.username),
+		fmt.Sprintf("[Result \"%s\"]", result),
+		fmt.Sprintf("[UTCDate \"%s\"]", date.Format("2006.01.02")),
+		fmt.Sprintf("[UTCTime \"%s\"]", date.Format("15:04:05")),
+		fmt.Sprintf("[WhiteElo \"%d\"]", whiteElo),
+		fmt.Sprintf("[BlackElo \"%d\"]", blackElo),
+		fmt.Sprintf("[TimeControl \"%s\"]", timeControl),
+		fmt.Sprintf("[ECO \"%s\"]", openings[chosen].eco),
+		fmt.Sprintf("[Opening \"%s\"]", openings[chosen].name),
+		fmt.Sprintf("[Termination \"%s\"]", termination),
+	}
+	if link != "" {
+		tags = append(tags, fmt.Sprintf("[Link \"%s\"]", link))
+	}
+	return strings.Join(tags, "\n") + "\n\n" + movetext.String() + result + "\n\n"
+}
+
+// randomMove ... a legal move of {position} (not the end of the game), a capture twice out of three when there is one
+func randomMove(random *rand.Rand, position *chess.Position) *chess.Move {
+	moves := position.ValidMoves()
+	if random.Intn(3) < 2 {
+		captures := []*chess.Move{}
+		for _, move := range moves {
+			if move.HasTag(chess.Capture) {
+				captures = append(captures, move)
+			}
+		}
+		if len(captures) > 0 {
+			return captures[random.Intn(len(captures))]
+		}
+	}
+	return moves[random.Intn(len(moves))]
+}
+
+// insufficientMaterial ... neither side can mate on {board}: kings and one minor piece at most, or bishops on squares
+// of the same colour (as the rules of notnil/chess)
+func insufficientMaterial(board *chess.Board) bool {
+	knights, bishops := 0, [2]int{}
+	for square, piece := range board.SquareMap() {
+		switch piece.Type() {
+		case chess.Queen, chess.Rook, chess.Pawn:
+			return false
+		case chess.Knight:
+			knights++
+		case chess.Bishop:
+			bishops[(int(square.File())+int(square.Rank()))%2]++
+		}
+	}
+	minors := knights + bishops[0] + bishops[1]
+	return minors <= 1 || (knights == 0 && (bishops[0] == 0 || bishops[1] == 0))
+}
+
+// clock ... {seconds} as in a %clk comment: 0:02:58.4
+func clock(seconds float64) string {
+	tenths := int(math.Round(seconds * 10))
+	return fmt.Sprintf("%d:%02d:%02d.%d", tenths/36000, tenths/600%60, tenths/10%60, tenths%10)
+}
+
+// gameCode ... a game id of lichess.org: 8 letters and digits
+func gameCode(random *rand.Rand) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	code := make([]byte, 8)
+	for i := range code {
+		code[i] = alphabet[random.Intn(len(alphabet))]
+	}
+	return string(code)
+}
@@ -0,0 +1,214 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+/*
+HTTP client of the downloaders (chess.com, lichess.org) and of the webhooks
+
+Proxy: the proxy setting (http://, https:// or socks5:// URL) or else the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+
+Settings (config file):
+  http-connect-timeout   connection to the server, default 10s
+  http-response-timeout  wait for the server to answer, default 30s
+  http-idle-timeout      a download stops when no data is received during this time, default 60s
+  http-retries           attempts after a network error, a 429 (too many requests) or 5xx status, default 3
+  http-backoff           wait before the first retry (doubled for every retry), default 2s
+  http-max-backoff       longest wait between retries, default 1m
+
+Only the idempotent requests (GET, HEAD, OPTIONS, TRACE, PUT, DELETE) are retried: the server may have handled a POST
+which failed, so a POST is sent once unless the caller knows it is harmless twice (DoIdempotent).
+Downloads are streamed: once the server has answered, an interrupted download is not retried.
+The user agent and the request rate of the downloaders are the politeness settings of their site (see sites).
+*/
+
+// Client ... an HTTP client retrying failed requests
+type Client struct {
+	client      *http.Client
+	idleTimeout time.Duration
+	retries     int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	userAgent   string // of the requests without one ("" for the Go default)
+	throttle    func() // waits before every attempt (nil: no limit), see Polite
+}
+
+// New ... a client configured from the settings
+func New() (*Client, error) {
+	connectTimeout := duration("http-connect-timeout", 10*time.Second)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+	transport.ResponseHeaderTimeout = duration("http-response-timeout", 30*time.Second)
+
+	if proxy := viper.GetString("proxy"); proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid proxy %s: %w", proxy, err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("Invalid proxy %s: expected an http://, https:// or socks5:// URL", proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	retries := 3
+	if viper.IsSet("http-retries") {
+		retries = viper.GetInt("http-retries")
+	}
+
+	return &Client{
+		client:      &http.Client{Transport: transport},
+		idleTimeout: duration("http-idle-timeout", 60*time.Second),
+		retries:     retries,
+		backoff:     duration("http-backoff", 2*time.Second),
+		maxBackoff:  duration("http-max-backoff", time.Minute),
+	}, nil
+}
+
+// Polite ... copy of the client sending {userAgent} and calling {throttle} before every request (retries included)
+func (client *Client) Polite(userAgent string, throttle func()) *Client {
+	polite := *client
+	polite.userAgent = userAgent
+	polite.throttle = throttle
+	return &polite
+}
+
+// Get ... GET {rawURL}
+func (client *Client) Get(rawURL string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// Do ... send {req}, again after network errors, 429 and 5xx statuses when its method is idempotent
+// other statuses are returned as they are (the caller checks them)
+func (client *Client) Do(req *http.Request) (*http.Response, error) {
+	return client.do(req, idempotent(req.Method))
+}
+
+// DoIdempotent ... Do retrying {req} whatever its method: the server handles it only once (deduplication id...)
+func (client *Client) DoIdempotent(req *http.Request) (*http.Response, error) {
+	return client.do(req, true)
+}
+
+func (client *Client) do(req *http.Request, retry bool) (*http.Response, error) {
+	wait := client.backoff
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithCancel(req.Context())
+		attemptReq := req.Clone(ctx)
+		if attempt > 0 && req.GetBody != nil {
+			// the body was read by the previous attempt
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			attemptReq.Body = body
+		}
+		if client.userAgent != "" && attemptReq.Header.Get("User-Agent") == "" {
+			attemptReq.Header.Set("User-Agent", client.userAgent)
+		}
+		if client.throttle != nil {
+			client.throttle()
+		}
+		resp, err := client.client.Do(attemptReq)
+
+		failed := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if err != nil && !retry {
+			cancel()
+			return nil, err
+		}
+		if !failed || !retry {
+			resp.Body = newIdleBody(resp.Body, client.idleTimeout, cancel)
+			return resp, nil
+		}
+
+		if err == nil {
+			err = &url.Error{Op: req.Method, URL: req.URL.String(), Err: errors.New(resp.Status)}
+			if seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && seconds > 0 {
+				wait = time.Duration(seconds) * time.Second
+			}
+			resp.Body.Close()
+		}
+		cancel()
+
+		if attempt >= client.retries {
+			return nil, err
+		}
+		if wait > client.maxBackoff {
+			wait = client.maxBackoff
+		}
+		log.Warn(fmt.Sprintf("%s, retrying in %s (%d/%d)", err, wait, attempt+1, client.retries))
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// idleBody ... response body failing when no data is received for {timeout}
+type idleBody struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	expired int32 // 1 once the timer fired (set by the timer goroutine: sync/atomic)
+}
+
+func newIdleBody(body io.ReadCloser, timeout time.Duration, cancel context.CancelFunc) *idleBody {
+	idle := &idleBody{body: body, timeout: timeout, cancel: cancel}
+	idle.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&idle.expired, 1)
+		cancel()
+	})
+	return idle
+}
+
+func (idle *idleBody) Read(p []byte) (int, error) {
+	n, err := idle.body.Read(p)
+	if err != nil && err != io.EOF && atomic.LoadInt32(&idle.expired) == 1 {
+		err = fmt.Errorf("no data received for %s: %w", idle.timeout, err)
+	}
+	idle.timer.Reset(idle.timeout)
+	return n, err
+}
+
+func (idle *idleBody) Close() error {
+	idle.timer.Stop()
+	idle.cancel()
+	return idle.body.Close()
+}
+
+// duration ... setting {key} or {defaultValue} when not set
+// idempotent ... sending a request of {method} twice does the same as once
+func idempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func duration(key string, defaultValue time.Duration) time.Duration {
+	if viper.IsSet(key) {
+		return viper.GetDuration(key)
+	}
+	return defaultValue
+}
@@ -0,0 +1,53 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDoRetriesIdempotentRequests(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	client := &Client{client: server.Client(), idleTimeout: time.Second, retries: 2, backoff: time.Millisecond, maxBackoff: time.Millisecond}
+
+	tests := []struct {
+		method   string
+		opt      bool // DoIdempotent
+		attempts int
+	}{
+		{http.MethodGet, false, 3},
+		{http.MethodPut, false, 3},
+		{http.MethodDelete, false, 3},
+		{http.MethodPost, false, 1},
+		{http.MethodPost, true, 3},
+	}
+	for _, test := range tests {
+		attempts = 0
+		req, _ := http.NewRequest(test.method, server.URL, strings.NewReader("body"))
+		do := client.Do
+		if test.opt {
+			do = client.DoIdempotent
+		}
+		resp, err := do(req)
+		if test.attempts == 1 {
+			// not retried: the 503 goes back to the caller
+			if err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("%s: got %v, %v, want the 503 response", test.method, resp, err)
+			} else {
+				resp.Body.Close()
+			}
+		} else if err == nil {
+			t.Errorf("%s: no error after %d attempts", test.method, attempts)
+		}
+		if attempts != test.attempts {
+			t.Errorf("%s (DoIdempotent %t): %d attempts, want %d", test.method, test.opt, attempts, test.attempts)
+		}
+	}
+}
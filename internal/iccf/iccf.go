@@ -0,0 +1,91 @@
+package iccf
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/httpclient"
+	"github.com/flutterbar/chess-explorer-go/internal/sites"
+)
+
+/*
+ICCF correspondence games (https://www.iccf.com), imported from the PGN exports of the games archive:
+there is no download API, the files are imported with the iccf command (sync skips the ICCF players).
+
+Headers of the exports, fixed before the import:
+  Site         "ICCF", "ICCF email", "ICCF Webserver"...: iccf.com, to include or exclude the games in the filters
+  White/Black  "Surname, Firstname": "Firstname Surname" (commas separate the players of the filters)
+  TimeControl  "10/50" (10 moves in 50 days): 1/432000 (seconds per move, as chess.com daily games), "-" when missing
+               or in another format; every time control of the site is a correspondence one
+*/
+
+// Site ... site of the ICCF games in the database
+const Site = "iccf.com"
+
+// Client ... ICCF games (sites.SiteClient)
+type Client struct{}
+
+func init() {
+	sites.Register(Client{})
+}
+
+// Name ... site of the games
+func (Client) Name() string {
+	return Site
+}
+
+// ListArchives ... no download API
+func (Client) ListArchives(ctx context.Context, client *httpclient.Client, username string, since time.Time) ([]sites.Archive, error) {
+	return nil, fmt.Errorf("%w (iccf command with the PGN export of the games archive)", sites.ErrNoDownload)
+}
+
+// DownloadSince ... no download API
+func (Client) DownloadSince(ctx context.Context, client *httpclient.Client, archive sites.Archive, since time.Time) (*http.Response, error) {
+	return nil, sites.ErrNoDownload
+}
+
+// Normalize ... fix the headers of an ICCF game (see above), other games are not changed
+func (Client) Normalize(gameMap map[string]string) {
+	if !IsICCF(gameMap) {
+		return
+	}
+	gameMap["Site"] = Site
+	gameMap["White"] = PlayerName(gameMap["White"])
+	gameMap["Black"] = PlayerName(gameMap["Black"])
+	gameMap["TimeControl"] = timeControl(gameMap["TimeControl"])
+}
+
+// IsICCF ... the game was played on ICCF (Site tag)
+func IsICCF(gameMap map[string]string) bool {
+	site := strings.ToLower(gameMap["Site"])
+	return site == Site || strings.HasPrefix(site, "iccf")
+}
+
+// PlayerName ... "Firstname Surname" of an ICCF name ("Surname, Firstname")
+func PlayerName(name string) string {
+	parts := strings.SplitN(name, ",", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+		return strings.TrimSpace(name)
+	}
+	return strings.TrimSpace(parts[1]) + " " + strings.TrimSpace(parts[0])
+}
+
+// timeControl ... PGN time control of an ICCF one: 10/50 (moves/days) is 1/432000 (seconds per move)
+func timeControl(iccfTimeControl string) string {
+	parts := strings.SplitN(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(iccfTimeControl)), "d"), "/", 2)
+	if len(parts) == 2 {
+		moves, err := strconv.Atoi(parts[0])
+		days, dayErr := strconv.Atoi(parts[1])
+		if err == nil && dayErr == nil && moves > 0 && days > 0 && days <= 365 { // more: seconds (1/86400)
+			return "1/" + strconv.Itoa(days*86400/moves)
+		}
+	}
+	if strings.HasPrefix(iccfTimeControl, "1/") {
+		return iccfTimeControl // already seconds per move
+	}
+	return "-"
+}
@@ -0,0 +1,84 @@
+package lichess
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	"github.com/flutterbar/chess-explorer-go/internal/engine"
+	"github.com/flutterbar/chess-explorer-go/internal/httpclient"
+	"github.com/flutterbar/chess-explorer-go/internal/sites"
+	log "github.com/sirupsen/logrus"
+)
+
+// CloudEval ... client of the cloud evaluations of lichess.org (positions analysed by its users, mostly openings)
+type CloudEval struct {
+	client *httpclient.Client
+}
+
+// NewCloudEval ... client with the politeness settings of lichess.org
+func NewCloudEval() (*CloudEval, error) {
+	client, err := sites.HTTPClient(Client{}.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &CloudEval{client: client}, nil
+}
+
+type cloudEvalResponse struct {
+	Depth int `json:"depth"`
+	PVs   []struct {
+		Moves string `json:"moves"` // UCI
+		CP    *int   `json:"cp"`
+		Mate  *int   `json:"mate"`
+	} `json:"pvs"`
+}
+
+// Evaluate ... cloud evaluation of the position {fen}, false when lichess.org has none
+// https://lichess.org/api#tag/Analysis/operation/apiCloudEval
+func (cloud *CloudEval) Evaluate(fen string) (engine.Eval, bool, error) {
+	eval := engine.Eval{}
+	url := "https://lichess.org/api/cloud-eval?fen=" + neturl.QueryEscape(fen)
+	log.Debug("GET " + url)
+	resp, err := cloud.client.Get(url)
+	if err != nil {
+		return eval, false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return eval, false, nil
+	default:
+		body, _ := ioutil.ReadAll(resp.Body)
+		message := resp.Status
+		if len(body) > 0 && len(body) < 500 {
+			message += " " + strings.TrimSpace(string(body))
+		}
+		return eval, false, &neturl.Error{Op: "Get", URL: url, Err: fmt.Errorf("%s", message)}
+	}
+
+	var response cloudEvalResponse
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return eval, false, err
+	}
+	if len(response.PVs) == 0 {
+		return eval, false, nil
+	}
+	// white's point of view, as the engine evaluations
+	pv := response.PVs[0]
+	eval.Depth = response.Depth
+	if moves := strings.Fields(pv.Moves); len(moves) > 0 {
+		eval.BestMove = moves[0]
+	}
+	switch {
+	case pv.Mate != nil:
+		eval.Mate = *pv.Mate
+	case pv.CP != nil:
+		eval.CP = *pv.CP
+	}
+	return eval, true, nil
+}
@@ -1,109 +1,85 @@
 package lichess
 
 import (
-	"fmt"
-	"io"
-	"io/ioutil"
-	"log"
+	"context"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/flutterbar/chess-explorer-go/internal/httpclient"
+	"github.com/flutterbar/chess-explorer-go/internal/sites"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
-// DownloadGames ... Downloads games from lichess.org for user {user}
+// Client ... lichess.org games (sites.SiteClient)
 // https://lichess.org/api#operation/apiGamesUser
-func DownloadGames(username string, keepPgn string) {
+type Client struct{}
 
-	url := "https://lichess.org/api/games/user/" + username
-
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// If there is a token in the configuration, use it
-	lichessToken := viper.GetString("lichess-token")
-	if lichessToken != "" {
-		req.Header.Add("Authorization", "Bearer "+lichessToken)
-	}
+func init() {
+	sites.Register(Client{})
+}
 
-	q := req.URL.Query()
+// tokenKey ... key of the lichess.org token of a context
+type tokenKey struct{}
 
-	// Get most recent game to set 'since' if possible
-	lastGame := pgntodb.FindLastGame(username, "lichess.org")
+// WithToken ... {ctx} downloading with the personal API access {token} instead of the lichess-token setting
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
 
-	if lastGame.DateTime.IsZero() {
-		log.Println("New user")
-	} else {
-		log.Println("Most recent game in database: " + lastGame.GameID)
-		since := lastGame.DateTime.UnixNano() / int64(time.Millisecond)
-		since += 1000 // add 1 sec to avoid downloading the most recent game we have
-		q.Add("since", strconv.FormatInt(since, 10))
+// tokenOf ... the token of {ctx}, else the lichess-token setting
+func tokenOf(ctx context.Context) string {
+	if token, ok := ctx.Value(tokenKey{}).(string); ok {
+		return token
 	}
+	return viper.GetString("lichess-token")
+}
 
-	req.URL.RawQuery = q.Encode()
-
-	fmt.Println("GET " + req.URL.String())
+// Name ... site of the games
+func (Client) Name() string {
+	return "lichess.org"
+}
 
-	// Get data
-	resp, err := client.Do(req)
+// ListArchives ... lichess.org streams all the games of {username} in one download
+func (Client) ListArchives(ctx context.Context, client *httpclient.Client, username string, since time.Time) ([]sites.Archive, error) {
+	return []sites.Archive{{URL: "https://lichess.org/api/games/user/" + username, Description: "Downloading"}}, nil
+}
 
+// DownloadSince ... games of {archive} played after {since}
+func (Client) DownloadSince(ctx context.Context, client *httpclient.Client, archive sites.Archive, since time.Time) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", archive.URL, nil)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	fileName := keepPgn
-
-	if fileName == "" {
-		// Create a temp file
-		tmpfile, err := ioutil.TempFile("", "lichess")
-		if err != nil {
-			log.Fatal(err)
-		}
-		fileName = tmpfile.Name()
-		defer os.Remove(tmpfile.Name()) // clean up
+	// If there is a token (in the configuration, or of the caller), use it
+	lichessToken := tokenOf(ctx)
+	if lichessToken != "" {
+		req.Header.Add("Authorization", "Bearer "+lichessToken)
 	}
 
-	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-	if err != nil {
-		log.Fatal(err)
+	q := req.URL.Query()
+	q.Add("clocks", "true") // thinking times of the explorer
+	q.Add("evals", "true")  // evaluations of the explorer (games analysed on lichess)
+	if !since.IsZero() {
+		sinceMillis := since.UnixNano() / int64(time.Millisecond)
+		sinceMillis += 1000 // add 1 sec to avoid downloading the most recent game we have
+		q.Add("since", strconv.FormatInt(sinceMillis, 10))
 	}
-	defer f.Close()
-
-	// stream response
-	buf := make([]byte, 10000)
-
-	numBytesRead := 0
-	// Read the response body
-	for {
-		n, err := resp.Body.Read(buf)
-
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatal(err)
-		}
-		numBytesRead += n
-		fmt.Print(".")
+	req.URL.RawQuery = q.Encode()
 
-		n, err = f.Write(buf[0:n])
-		if err != nil {
-			log.Fatal(err)
-		}
+	log.Debug("GET " + req.URL.String())
+	return sites.Fetch(client, req)
+}
 
-		if err != nil {
-			log.Fatal("Error reading HTTP response: ", err.Error())
+// Normalize ... the Site tag of lichess.org is the link of the game
+func (Client) Normalize(gameMap map[string]string) {
+	if strings.Index(gameMap["Site"], "lichess.org") != -1 {
+		if gameMap["Link"] == "" {
+			gameMap["Link"] = gameMap["Site"]
 		}
+		gameMap["Site"] = "lichess.org"
 	}
-
-	fmt.Println()
-
-	log.Println(numBytesRead, " bytes read")
-	pgntodb.Process(fileName, lastGame)
 }
@@ -0,0 +1,73 @@
+package lichess
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"strings"
+
+	"github.com/flutterbar/chess-explorer-go/internal/sites"
+	log "github.com/sirupsen/logrus"
+)
+
+var studyIDPattern = regexp.MustCompile(`^[A-Za-z0-9]{8}$`)
+
+// StudyID ... id of a study from its URL (https://lichess.org/study/{id}) or the id itself
+func StudyID(study string) (string, error) {
+	id := strings.TrimSpace(study)
+	if parsed, err := neturl.Parse(id); err == nil && parsed.Host != "" {
+		parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+		if len(parts) < 2 || parts[0] != "study" {
+			return "", errors.New("Not a lichess.org study URL: " + study)
+		}
+		id = parts[1]
+	}
+	if !studyIDPattern.MatchString(id) {
+		return "", errors.New("Not a lichess.org study: " + study + " (expected https://lichess.org/study/{id} or the 8 characters id)")
+	}
+	return id, nil
+}
+
+// ImportChapter ... add {pgn} as chapter {name} to the study {studyID}, board seen from {orientation} (white or black)
+// the token needs the study:write scope
+// https://lichess.org/api#tag/Studies/operation/apiStudyImportPGN
+func ImportChapter(token string, studyID string, name string, pgn string, orientation string) error {
+	if token == "" {
+		return errors.New("A lichess.org token with the study:write scope is needed")
+	}
+	client, err := sites.HTTPClient(Client{}.Name())
+	if err != nil {
+		return err
+	}
+
+	form := neturl.Values{"name": {name}, "pgn": {pgn}}
+	if orientation != "" {
+		form.Set("orientation", orientation)
+	}
+	url := "https://lichess.org/api/study/" + studyID + "/import-pgn"
+	req, err := http.NewRequest("POST", url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	log.Debug("POST " + url)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		message := resp.Status
+		if len(body) > 0 && len(body) < 500 {
+			message += " " + strings.TrimSpace(string(body))
+		}
+		return &neturl.Error{Op: "Post", URL: url, Err: fmt.Errorf("%s (is the study yours, and does the token have the study:write scope?)", message)}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// ErrUnavailable ... the database cannot be reached
+var ErrUnavailable = errors.New("cannot connect to DB")
+
+// Settings ... a database of a MongoDB server
+type Settings struct {
+	URL  string // mongodb://127.0.0.1:27017
+	Name string // chess-explorer
+}
+
+// Configured ... the database of the configuration (mongo-url, mongo-db-name)
+func Configured() Settings {
+	return Settings{URL: viper.GetString("mongo-url"), Name: viper.GetString("mongo-db-name")}
+}
+
+// settingsKey ... key of the database of a context
+type settingsKey struct{}
+
+// WithSettings ... {ctx} connecting to the database of {settings} instead of the one of the configuration (library)
+func WithSettings(ctx context.Context, settings Settings) context.Context {
+	return context.WithValue(ctx, settingsKey{}, settings)
+}
+
+// SettingsOf ... the database of {ctx}: the one of WithSettings, or else of the configuration
+func SettingsOf(ctx context.Context) Settings {
+	if settings, ok := ctx.Value(settingsKey{}).(Settings); ok {
+		return settings
+	}
+	return Configured()
+}
+
+// Client ... a connection to a database (Collection)
+type Client struct {
+	*mongo.Client
+	settings Settings
+}
+
+// Settings ... the database of the connection
+func (client *Client) Settings() Settings {
+	return client.settings
+}
+
+// ClientOf ... the connection of {collection} (its Settings have no URL)
+func ClientOf(collection *mongo.Collection) *Client {
+	database := collection.Database()
+	return &Client{Client: database.Client(), settings: Settings{Name: database.Name()}}
+}
+
+// Connect ... connect to the database of {ctx} (SettingsOf: the configuration by default) and check it answers
+func Connect(ctx context.Context) (*Client, error) {
+	settings := SettingsOf(ctx)
+	url := redacted(settings.URL)
+	client, err := mongo.NewClient(options.Client().ApplyURI(settings.URL))
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrUnavailable, url, err)
+	}
+	if err = client.Connect(ctx); err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrUnavailable, url, err)
+	}
+
+	// Ping MongoDB
+	if err = client.Ping(ctx, readpref.Primary()); err != nil {
+		client.Disconnect(ctx)
+		return nil, fmt.Errorf("%w %s", ErrUnavailable, url)
+	}
+	log.Debug("Connected to " + url + ", database " + settings.Name)
+	return &Client{Client: client, settings: settings}, nil
+}
+
+// redacted ... {mongoURL} without its password (for messages)
+func redacted(mongoURL string) string {
+	parsed, err := url.Parse(mongoURL)
+	if err != nil {
+		return mongoURL
+	}
+	return parsed.Redacted()
+}
+
+// DatabaseNames ... databases of the server (mongo-url)
+func DatabaseNames() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	client, err := Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Disconnect(ctx)
+
+	names, err := client.ListDatabaseNames(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Collection ... collection {name} of the database of {client}
+func Collection(client *Client, name string) *mongo.Collection {
+	return client.Database(client.settings.Name).Collection(name)
+}
@@ -0,0 +1,202 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/httpclient"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+Chat notifications (Discord and Slack webhooks, Telegram bot) after synchronizations:
+  notify-discord-url    Discord webhook URL
+  notify-slack-url      Slack incoming webhook URL
+  telegram-token        Telegram bot token (from @BotFather)
+  telegram-chat-id      Telegram chat receiving the notifications
+  notify-losing-streak  losses in a row (same speed) flagged after a synchronization, default 5, 0 to disable
+
+Nothing is sent when no game was imported and no user failed.
+*/
+
+// Enabled ... a chat webhook is configured
+func Enabled() bool {
+	return viper.GetString("notify-discord-url") != "" || viper.GetString("notify-slack-url") != "" ||
+		(viper.GetString("telegram-token") != "" && viper.GetString("telegram-chat-id") != "")
+}
+
+// Send ... post {message} to the configured chats (failures are logged)
+func Send(message string) {
+	if webhook := viper.GetString("notify-discord-url"); webhook != "" {
+		// Discord messages are limited to 2000 characters
+		if len(message) > 2000 {
+			message = message[:1997] + "..."
+		}
+		if err := post(webhook, map[string]string{"content": message}); err != nil {
+			log.Warn("Discord notification failed: " + err.Error())
+		}
+	}
+	if webhook := viper.GetString("notify-slack-url"); webhook != "" {
+		if err := post(webhook, map[string]string{"text": message}); err != nil {
+			log.Warn("Slack notification failed: " + err.Error())
+		}
+	}
+	if chatID := viper.GetString("telegram-chat-id"); chatID != "" && viper.GetString("telegram-token") != "" {
+		if err := Telegram(chatID, message); err != nil {
+			log.Warn("Telegram notification failed: " + err.Error())
+		}
+	}
+}
+
+// Telegram ... send {message} to the Telegram chat {chatID} with the bot of the telegram-token setting
+// https://core.telegram.org/bots/api#sendmessage
+func Telegram(chatID string, message string) error {
+	// Telegram messages are limited to 4096 characters
+	if len(message) > 4096 {
+		message = message[:4093] + "..."
+	}
+	token := viper.GetString("telegram-token")
+	err := post("https://api.telegram.org/bot"+token+"/sendMessage", map[string]string{"chat_id": chatID, "text": message})
+	if err != nil {
+		// the token is part of the URL
+		return errors.New(strings.ReplaceAll(err.Error(), token, "****"))
+	}
+	return nil
+}
+
+// UserSync ... games imported for a user by a synchronization
+type UserSync struct {
+	Site     string
+	Username string
+	Inserted int
+	Error    string
+}
+
+// Sync ... summary of a synchronization and the losing streaks of the users with new games
+func Sync(users []UserSync) {
+	if !Enabled() {
+		return
+	}
+
+	lines := make([]string, 0)
+	total := 0
+	for _, user := range users {
+		total += user.Inserted
+		if user.Inserted > 0 {
+			lines = append(lines, fmt.Sprintf("  %s (%s): %d new games", user.Username, user.Site, user.Inserted))
+		}
+	}
+	for _, user := range users {
+		if user.Error != "" {
+			lines = append(lines, fmt.Sprintf("  %s (%s) failed: %s", user.Username, user.Site, user.Error))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	message := "Synchronization: " + strconv.Itoa(total) + " new games\n" + strings.Join(lines, "\n")
+	if minStreak := losingStreakSetting(); minStreak > 0 {
+		for _, user := range users {
+			if user.Inserted == 0 {
+				continue
+			}
+			speed, streak, err := losingStreak(user.Site, user.Username)
+			if err != nil {
+				log.Warn("Losing streak of " + user.Username + ": " + err.Error())
+				continue
+			}
+			if streak >= minStreak {
+				message += fmt.Sprintf("\n%s (%s) lost %d %s games in a row", user.Username, user.Site, streak, speed)
+			}
+		}
+	}
+	Send(message)
+}
+
+func losingStreakSetting() int {
+	if viper.IsSet("notify-losing-streak") {
+		return viper.GetInt("notify-losing-streak")
+	}
+	return 5
+}
+
+// losingStreak ... losses in a row of {username} at the speed of the last game
+func losingStreak(site string, username string) (string, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	defer client.Disconnect(ctx)
+
+	// case insensitive usernames
+	collation := options.Collation{Locale: "en", Strength: 2}
+	filter := bson.M{"site": site, "$or": bson.A{bson.M{"white": username}, bson.M{"black": username}}}
+	findOptions := options.Find().SetSort(bson.M{"datetime": -1}).SetLimit(100).SetCollation(&collation)
+	cursor, err := mongodb.Collection(client, "games").Find(ctx, filter, findOptions)
+	if err != nil {
+		return "", 0, err
+	}
+	var games []pgntodb.Game
+	if err = cursor.All(ctx, &games); err != nil {
+		return "", 0, err
+	}
+	if len(games) == 0 {
+		return "", 0, nil
+	}
+
+	speed := pgntodb.Speed(games[0].TimeControl)
+	streak := 0
+	for _, game := range games {
+		if pgntodb.Speed(game.TimeControl) != speed {
+			continue
+		}
+		lost := (strings.EqualFold(game.White, username) && game.Result == "0-1") ||
+			(strings.EqualFold(game.Black, username) && game.Result == "1-0")
+		if !lost {
+			break
+		}
+		streak++
+	}
+	return speed, streak, nil
+}
+
+func post(webhook string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	client, err := httpclient.New()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &url.Error{Op: "Post", URL: webhook, Err: errors.New(resp.Status)}
+	}
+	return nil
+}
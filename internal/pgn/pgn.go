@@ -0,0 +1,270 @@
+package pgn
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+/*
+Minimal PGN reader/writer.
+
+Unlike the parsing done in pgntodb (which only keeps what goes to the database),
+games are kept verbatim so that they can be written back to a PGN file.
+*/
+
+// Tag ... a tag pair, for example [Event "Rated Blitz game"]
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Game ... a game as found in a PGN file
+type Game struct {
+	Tags     []Tag  // in file order
+	Movetext string // moves, comments and result (lines joined with a line break: a ; comment ends with its line)
+	Line     int    // line of the first tag in the source file
+	MoveLine int    // line where the movetext starts in the source file
+}
+
+// Get ... value of tag {key} ("" if not found)
+func (game *Game) Get(key string) string {
+	for _, tag := range game.Tags {
+		if tag.Key == key {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// Set ... set tag {key} (appended if not found)
+func (game *Game) Set(key string, value string) {
+	for i := range game.Tags {
+		if game.Tags[i].Key == key {
+			game.Tags[i].Value = value
+			return
+		}
+	}
+	game.Tags = append(game.Tags, Tag{Key: key, Value: value})
+}
+
+// Delete ... remove tag {key}
+func (game *Game) Delete(key string) {
+	i := 0 // output index
+	for _, tag := range game.Tags {
+		if tag.Key != key {
+			game.Tags[i] = tag
+			i++
+		}
+	}
+	game.Tags = game.Tags[:i]
+}
+
+// Reader ... reads games one by one
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+	next    *Game // game whose first tag has already been read
+}
+
+// NewReader ... create a reader
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024) // movetext is often on a single line
+	return &Reader{scanner: scanner}
+}
+
+// Next ... next game, io.EOF when there is no more game
+func (reader *Reader) Next() (*Game, error) {
+	game := reader.next
+	reader.next = nil
+	inMovetext := false
+
+	for reader.scanner.Scan() {
+		reader.line++
+		line := strings.TrimSpace(reader.scanner.Text())
+		if len(line) == 0 || line[0] == '%' {
+			continue
+		}
+		if line[0] == '[' {
+			if inMovetext {
+				// beginning of next game
+				reader.next = &Game{Line: reader.line}
+				reader.next.Tags = append(reader.next.Tags, ParseTag(line))
+				return game, nil
+			}
+			if game == nil {
+				game = &Game{Line: reader.line}
+			}
+			game.Tags = append(game.Tags, ParseTag(line))
+			continue
+		}
+		if game == nil {
+			game = &Game{Line: reader.line} // movetext without tags
+		}
+		if !inMovetext {
+			game.MoveLine = reader.line
+		}
+		inMovetext = true
+		if game.Movetext == "" {
+			game.Movetext = line
+		} else {
+			game.Movetext = game.Movetext + "\n" + line
+		}
+	}
+
+	if err := reader.scanner.Err(); err != nil {
+		return nil, err
+	}
+	if game == nil {
+		return nil, io.EOF
+	}
+	return game, nil
+}
+
+// ParseTag ... [Key "value"]
+func ParseTag(line string) Tag {
+	line = strings.Trim(line, "[] ")
+	if len(line) == 0 {
+		return Tag{}
+	}
+	split := strings.SplitN(line, " ", 2)
+	tag := Tag{Key: split[0]}
+	if len(split) > 1 {
+		value := strings.TrimSpace(split[1])
+		value = strings.TrimPrefix(value, "\"")
+		value = strings.TrimSuffix(value, "\"")
+		tag.Value = strings.ReplaceAll(value, "\\\"", "\"")
+	}
+	return tag
+}
+
+// Write ... write a game in export format
+func Write(w io.Writer, game *Game) error {
+	var sb strings.Builder
+	for _, tag := range game.Tags {
+		sb.WriteString("[" + tag.Key + " \"" + strings.ReplaceAll(tag.Value, "\"", "\\\"") + "\"]\n")
+	}
+	sb.WriteString("\n")
+	sb.WriteString(game.Movetext)
+	sb.WriteString("\n\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// Results ... game termination markers
+var Results = []string{"1-0", "0-1", "1/2-1/2", "*"}
+
+// Move ... a mainline move and the comments that follow it
+type Move struct {
+	SAN     string // as found in the movetext, without move number and annotation symbols
+	Comment string // comments following the move (without braces), "" if none
+}
+
+// Moves ... mainline moves in SAN, without move numbers, comments, variations, NAGs and result
+// 1. e4 {[%clk 0:09:58]} 1... e5 2. Nf3 (2. f4) Nc6 $1 1-0 gives [e4 e5 Nf3 Nc6]
+func Moves(movetext string) []string {
+	mainline := Mainline(movetext)
+	moves := make([]string, len(mainline))
+	for i, move := range mainline {
+		moves[i] = move.SAN
+	}
+	return moves
+}
+
+// Mainline ... mainline moves with their comments
+func Mainline(movetext string) []Move {
+	moves := make([]Move, 0)
+	commentDepth := 0
+	variationDepth := 0
+	lineComment := false // ; until the end of the line
+	var token strings.Builder
+	var comment strings.Builder
+
+	// the comment ending now belongs to the last move of the mainline
+	endComment := func() {
+		if variationDepth == 0 && len(moves) > 0 {
+			text := strings.TrimSpace(comment.String())
+			if moves[len(moves)-1].Comment != "" && text != "" {
+				text = moves[len(moves)-1].Comment + " " + text
+			}
+			if text != "" {
+				moves[len(moves)-1].Comment = text
+			}
+		}
+		comment.Reset()
+	}
+
+	flush := func() {
+		move := token.String()
+		token.Reset()
+		if move == "" {
+			return
+		}
+		// remove move number (1. or 1... possibly glued to the move)
+		if i := strings.LastIndex(move, "."); i != -1 {
+			move = move[i+1:]
+		}
+		if move == "" || move[0] == '$' || IsResult(move) {
+			return
+		}
+		move = strings.TrimRight(move, "!?")
+		if move != "" {
+			moves = append(moves, Move{SAN: move})
+		}
+	}
+
+	for _, c := range movetext {
+		switch {
+		case lineComment:
+			if c == '\n' {
+				lineComment = false
+				endComment()
+			} else {
+				comment.WriteRune(c)
+			}
+		case commentDepth > 0:
+			if c == '}' {
+				commentDepth--
+				endComment()
+			} else {
+				comment.WriteRune(c)
+			}
+		case c == '{':
+			flush()
+			commentDepth++
+		case c == ';':
+			flush()
+			lineComment = true
+		case c == '(':
+			flush()
+			variationDepth++
+		case c == ')':
+			flush()
+			if variationDepth > 0 {
+				variationDepth--
+			}
+		case variationDepth > 0:
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+		default:
+			token.WriteRune(c)
+		}
+	}
+	flush()
+	if lineComment {
+		endComment()
+	}
+
+	return moves
+}
+
+// IsResult ... true for 1-0, 0-1, 1/2-1/2 and *
+func IsResult(token string) bool {
+	for _, result := range Results {
+		if token == result {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package pgn
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		games []Game
+	}{
+		{
+			name:  "empty",
+			input: "",
+			games: nil,
+		},
+		{
+			name:  "one game",
+			input: "[Event \"Casual\"]\n[White \"me\"]\n\n1. e4 e5 2. Nf3 1-0\n",
+			games: []Game{{Tags: []Tag{{"Event", "Casual"}, {"White", "me"}}, Movetext: "1. e4 e5 2. Nf3 1-0", Line: 1, MoveLine: 4}},
+		},
+		{
+			name:  "two games without blank line",
+			input: "[Event \"a\"]\n1. e4 *\n[Event \"b\"]\n1. d4 *\n",
+			games: []Game{
+				{Tags: []Tag{{"Event", "a"}}, Movetext: "1. e4 *", Line: 1, MoveLine: 2},
+				{Tags: []Tag{{"Event", "b"}}, Movetext: "1. d4 *", Line: 3, MoveLine: 4},
+			},
+		},
+		{
+			name:  "movetext lines joined with a line break",
+			input: "[Event \"a\"]\n\n1. e4 ; king pawn\n1... e5 *\n",
+			games: []Game{{Tags: []Tag{{"Event", "a"}}, Movetext: "1. e4 ; king pawn\n1... e5 *", Line: 1, MoveLine: 3}},
+		},
+		{
+			name:  "movetext without tags",
+			input: "\n1. e4 e5 *\n",
+			games: []Game{{Movetext: "1. e4 e5 *", Line: 2, MoveLine: 2}},
+		},
+		{
+			name:  "escape lines and spaces",
+			input: "% exported by a tool\n  [Event \"a \\\"quoted\\\"\"]  \n\n  1. e4 *  \n",
+			games: []Game{{Tags: []Tag{{"Event", "a \"quoted\""}}, Movetext: "1. e4 *", Line: 2, MoveLine: 4}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := NewReader(strings.NewReader(test.input))
+			var games []Game
+			for {
+				game, err := reader.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				games = append(games, *game)
+			}
+			if !reflect.DeepEqual(games, test.games) {
+				t.Errorf("got %+v, want %+v", games, test.games)
+			}
+		})
+	}
+}
+
+func TestMainline(t *testing.T) {
+	tests := []struct {
+		name     string
+		movetext string
+		moves    []Move
+	}{
+		{
+			name:     "move numbers and result",
+			movetext: "1. e4 e5 2. Nf3 Nc6 1-0",
+			moves:    []Move{{SAN: "e4"}, {SAN: "e5"}, {SAN: "Nf3"}, {SAN: "Nc6"}},
+		},
+		{
+			name:     "numbers glued to the moves",
+			movetext: "1.e4 1...e5 2.Nf3 *",
+			moves:    []Move{{SAN: "e4"}, {SAN: "e5"}, {SAN: "Nf3"}},
+		},
+		{
+			name:     "annotations and NAGs",
+			movetext: "1. e4! e5?! 2. Qh5?? $4 Nc6 $1 0-1",
+			moves:    []Move{{SAN: "e4"}, {SAN: "e5"}, {SAN: "Qh5"}, {SAN: "Nc6"}},
+		},
+		{
+			name:     "comments",
+			movetext: "1. e4 {[%clk 0:09:58]} e5 { best } {by test} 1/2-1/2",
+			moves:    []Move{{SAN: "e4", Comment: "[%clk 0:09:58]"}, {SAN: "e5", Comment: "best by test"}},
+		},
+		{
+			name:     "nested variations",
+			movetext: "1. e4 e5 (1... c5 2. Nf3 (2. c3 {Alapin}) d6) 2. Nf3 *",
+			moves:    []Move{{SAN: "e4"}, {SAN: "e5"}, {SAN: "Nf3"}},
+		},
+		{
+			name:     "comment before the first move",
+			movetext: "{Opening} 1. d4 *",
+			moves:    []Move{{SAN: "d4"}},
+		},
+		{
+			name:     "line comments end with their line",
+			movetext: "1. e4 ; the king pawn {not a comment\n1... e5\r\n2. Nf3 ; last",
+			moves:    []Move{{SAN: "e4", Comment: "the king pawn {not a comment"}, {SAN: "e5"}, {SAN: "Nf3", Comment: "last"}},
+		},
+		{
+			name:     "empty",
+			movetext: "*",
+			moves:    []Move{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if moves := Mainline(test.movetext); !reflect.DeepEqual(moves, test.moves) {
+				t.Errorf("Mainline(%q) = %+v, want %+v", test.movetext, moves, test.moves)
+			}
+		})
+	}
+}
+
+func TestMoves(t *testing.T) {
+	movetext := "1. e4 {[%clk 0:09:58]} 1... e5 2. Nf3 (2. f4) Nc6 $1 1-0"
+	want := []string{"e4", "e5", "Nf3", "Nc6"}
+	if moves := Moves(movetext); !reflect.DeepEqual(moves, want) {
+		t.Errorf("Moves(%q) = %v, want %v", movetext, moves, want)
+	}
+}
@@ -0,0 +1,82 @@
+package pgn
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// MoveError ... a move that cannot be played
+type MoveError struct {
+	Ply  int    // 1 for white's first move
+	Move string // as found in the movetext
+	FEN  string // position before the move
+}
+
+func (err *MoveError) Error() string {
+	return "illegal move " + MoveNumber(err.Ply) + " " + err.Move + " in position " + err.FEN
+}
+
+// MoveNumber ... 1 gives "1.", 2 gives "1..."
+func MoveNumber(ply int) string {
+	if ply%2 == 1 {
+		return strconv.Itoa((ply+1)/2) + "."
+	}
+	return strconv.Itoa(ply/2) + "..."
+}
+
+// NewChessGame ... a chess game at the starting position of {game} (FEN tag if the game was set up)
+func NewChessGame(game *Game) (*chess.Game, error) {
+	if fen := game.Get("FEN"); fen != "" {
+		option, err := chess.FEN(fen)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FEN %s: %v", fen, err)
+		}
+		return chess.NewGame(option), nil
+	}
+	return chess.NewGame(), nil
+}
+
+var uciMove = regexp.MustCompile(`^[a-h][1-8][a-h][1-8][qrbnQRBN]?$`)
+
+// DecodeMove ... decode a move written in SAN, long algebraic or UCI notation
+func DecodeMove(position *chess.Position, move string) (*chess.Move, error) {
+	trimmed := strings.TrimRight(move, "+#")
+	if !uciMove.MatchString(trimmed) {
+		return chess.AlgebraicNotation{}.Decode(position, move)
+	}
+
+	// e2e4 is both UCI and long algebraic: use the valid move (it knows about checks and captures)
+	m, err := chess.UCINotation{}.Decode(position, strings.ToLower(trimmed))
+	if err != nil {
+		return nil, err
+	}
+	for _, valid := range position.ValidMoves() {
+		if valid.S1() == m.S1() && valid.S2() == m.S2() && valid.Promo() == m.Promo() {
+			return valid, nil
+		}
+	}
+	return nil, fmt.Errorf("chess: invalid move %s for position %s", move, position.String())
+}
+
+// Replay ... play the mainline of {game}
+// On error (*MoveError for an illegal move), the chess game holds the moves played so far
+func Replay(game *Game) (*chess.Game, error) {
+	chessGame, err := NewChessGame(game)
+	if err != nil {
+		return nil, err
+	}
+	for i, move := range Moves(game.Movetext) {
+		m, err := DecodeMove(chessGame.Position(), move)
+		if err == nil {
+			err = chessGame.Move(m)
+		}
+		if err != nil {
+			return chessGame, &MoveError{Ply: i + 1, Move: move, FEN: chessGame.Position().String()}
+		}
+	}
+	return chessGame, nil
+}
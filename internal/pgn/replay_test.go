@@ -0,0 +1,127 @@
+package pgn
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+func TestDecodeMove(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string // starting position when empty
+		move  string
+		want  string // UCI, "" for an error
+		check bool
+	}{
+		{name: "SAN", move: "e4", want: "e2e4"},
+		{name: "SAN piece", move: "Nf3", want: "g1f3"},
+		{name: "UCI", move: "e2e4", want: "e2e4"},
+		{name: "UCI knight", move: "g1f3", want: "g1f3"},
+		{name: "illegal SAN", move: "e5", want: ""},
+		{name: "illegal UCI", move: "e2e5", want: ""},
+		{name: "not a move", move: "xyz", want: ""},
+		{name: "UCI promotion", fen: "8/P6k/8/8/8/8/8/K7 w - - 0 1", move: "a7a8q", want: "a7a8q"},
+		{name: "UCI promotion upper case", fen: "8/P6k/8/8/8/8/8/K7 w - - 0 1", move: "a7a8N", want: "a7a8n"},
+		{name: "SAN promotion", fen: "8/P6k/8/8/8/8/8/K7 w - - 0 1", move: "a8=Q", want: "a7a8q"},
+		{name: "UCI with check", fen: "k7/8/8/8/8/8/8/KR6 w - - 0 1", move: "b1a1+", want: ""},
+		{name: "long algebraic with check", fen: "k7/8/8/8/8/8/8/K1R5 w - - 0 1", move: "c1c8+", want: "c1c8", check: true},
+		{name: "SAN castling", fen: "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", move: "O-O", want: "e1g1"},
+		{name: "UCI castling", fen: "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", move: "e1c1", want: "e1c1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			position := chess.StartingPosition()
+			if test.fen != "" {
+				option, err := chess.FEN(test.fen)
+				if err != nil {
+					t.Fatal(err)
+				}
+				position = chess.NewGame(option).Position()
+			}
+			move, err := DecodeMove(position, test.move)
+			if test.want == "" {
+				if err == nil {
+					t.Errorf("DecodeMove(%q) = %s, want an error", test.move, move)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeMove(%q): %v", test.move, err)
+			}
+			if uci := (chess.UCINotation{}).Encode(position, move); uci != test.want {
+				t.Errorf("DecodeMove(%q) = %s, want %s", test.move, uci, test.want)
+			}
+			if test.check && !move.HasTag(chess.Check) {
+				t.Errorf("DecodeMove(%q) is not a check", test.move)
+			}
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     []Tag
+		movetext string
+		fen      string // final position
+		plies    int    // moves played
+		errPly   int    // ply of the illegal move, 0 without
+	}{
+		{
+			name:     "fool's mate",
+			movetext: "1. f3 e5 2. g4 Qh4# 0-1",
+			fen:      "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3",
+			plies:    4,
+		},
+		{
+			name:     "from a position",
+			tags:     []Tag{{"SetUp", "1"}, {"FEN", "k7/8/8/8/8/8/8/K1R5 w - - 0 1"}},
+			movetext: "1. Rc8# 1-0",
+			fen:      "k1R5/8/8/8/8/8/8/K7 b - - 1 1",
+			plies:    1,
+		},
+		{
+			name:     "illegal move",
+			movetext: "1. e4 e5 2. Ke3 *",
+			fen:      "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2",
+			plies:    2,
+			errPly:   3,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chessGame, err := Replay(&Game{Tags: test.tags, Movetext: test.movetext})
+			var moveError *MoveError
+			switch {
+			case test.errPly == 0 && err != nil:
+				t.Fatal(err)
+			case test.errPly != 0 && !errors.As(err, &moveError):
+				t.Fatalf("got error %v, want a MoveError", err)
+			case test.errPly != 0 && moveError.Ply != test.errPly:
+				t.Errorf("error at ply %d, want %d", moveError.Ply, test.errPly)
+			}
+			if fen := chessGame.Position().String(); fen != test.fen {
+				t.Errorf("position %s, want %s", fen, test.fen)
+			}
+			if plies := len(chessGame.Moves()); plies != test.plies {
+				t.Errorf("%d moves played, want %d", plies, test.plies)
+			}
+		})
+	}
+}
+
+func TestReplayInvalidFEN(t *testing.T) {
+	if _, err := Replay(&Game{Tags: []Tag{{"FEN", "not a position"}}, Movetext: "1. e4 *"}); err == nil {
+		t.Error("no error for an invalid FEN tag")
+	}
+}
+
+func TestMoveNumber(t *testing.T) {
+	for ply, want := range map[int]string{1: "1.", 2: "1...", 3: "2.", 40: "20..."} {
+		if number := MoveNumber(ply); number != want {
+			t.Errorf("MoveNumber(%d) = %s, want %s", ply, number, want)
+		}
+	}
+}
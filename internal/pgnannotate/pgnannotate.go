@@ -0,0 +1,183 @@
+package pgnannotate
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/flutterbar/chess-explorer-go/internal/engine"
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/notnil/chess"
+	log "github.com/sirupsen/logrus"
+)
+
+// Loss (in centipawns, for the player who moved) giving a NAG
+const (
+	inaccuracy = 50  // $6 ?!
+	mistake    = 100 // $2 ?
+	blunder    = 300 // $4 ??
+)
+
+// Process ... annotate the games of {filepath} with engine evaluations and write them to {output}
+func Process(filepath string, output string, settings engine.Settings) {
+	// Open file
+	file, err := os.Open(filepath)
+	defer file.Close()
+
+	if err != nil {
+		log.Fatal("Cannot open file " + filepath)
+	}
+
+	outFile, err := os.Create(output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer outFile.Close()
+	writer := bufio.NewWriter(outFile)
+	defer writer.Flush()
+
+	uci, err := engine.Start(settings)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer uci.Close()
+
+	reader := pgn.NewReader(file)
+	gameCounter := 0
+	for {
+		game, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		gameCounter++
+
+		if err = Annotate(game, uci); err != nil {
+			log.Println("Game line " + strconv.Itoa(game.Line) + " not annotated: " + err.Error())
+		} else {
+			game.Set("Annotator", "chess-explorer ("+filepathBase(settings.Path)+")")
+		}
+		if err = pgn.Write(writer, game); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Annotated " + strconv.Itoa(gameCounter) + " games")
+	}
+}
+
+var evalCommand = regexp.MustCompile(`\[%eval\s[^\]]*\]\s*`)
+
+// Annotate ... add [%eval] comments and NAGs for inaccuracies, mistakes and blunders to the mainline of {game}
+// (variations and NAGs already present are dropped, comments are kept)
+func Annotate(game *pgn.Game, uci *engine.Engine) error {
+	chessGame, err := pgn.NewChessGame(game)
+	if err != nil {
+		return err
+	}
+	if err = uci.NewGame(); err != nil {
+		return err
+	}
+
+	previous, err := evaluate(chessGame.Position(), uci)
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(chessGame.Position().String())
+	moveNumber, _ := strconv.Atoi(fields[5])
+	whiteToMove := fields[1] == "w"
+
+	var sb strings.Builder
+	for i, move := range pgn.Mainline(game.Movetext) {
+		position := chessGame.Position()
+		m, err := pgn.DecodeMove(position, move.SAN)
+		if err == nil {
+			err = chessGame.Move(m)
+		}
+		if err != nil {
+			return &pgn.MoveError{Ply: i + 1, Move: move.SAN, FEN: position.String()}
+		}
+
+		eval, err := evaluate(chessGame.Position(), uci)
+		if err != nil {
+			return err
+		}
+
+		// loss for the player who moved
+		loss := clamp(previous.Score()) - clamp(eval.Score())
+		if !whiteToMove {
+			loss = -loss
+		}
+
+		sb.WriteString(strconv.Itoa(moveNumber))
+		if whiteToMove {
+			sb.WriteString(". ")
+		} else {
+			sb.WriteString("... ")
+			moveNumber++
+		}
+		sb.WriteString(chess.AlgebraicNotation{}.Encode(position, m))
+		switch {
+		case loss >= blunder:
+			sb.WriteString(" $4")
+		case loss >= mistake:
+			sb.WriteString(" $2")
+		case loss >= inaccuracy:
+			sb.WriteString(" $6")
+		}
+
+		comment := strings.TrimSpace(evalCommand.ReplaceAllString(move.Comment, ""))
+		if chessGame.Position().Status() == chess.NoMethod {
+			comment = strings.TrimSpace("[%eval " + eval.String() + "] " + comment)
+		}
+		if comment != "" {
+			sb.WriteString(" { " + comment + " }")
+		}
+		sb.WriteString(" ")
+
+		previous = eval
+		whiteToMove = !whiteToMove
+	}
+
+	result := game.Get("Result")
+	if !pgn.IsResult(result) {
+		result = "*"
+	}
+	sb.WriteString(result)
+	game.Movetext = sb.String()
+	return nil
+}
+
+// evaluate ... engine evaluation, except for finished games
+func evaluate(position *chess.Position, uci *engine.Engine) (engine.Eval, error) {
+	switch position.Status() {
+	case chess.Checkmate:
+		if position.Turn() == chess.White {
+			return engine.Eval{Mate: -1}, nil
+		}
+		return engine.Eval{Mate: 1}, nil
+	case chess.Stalemate:
+		return engine.Eval{}, nil
+	}
+	return uci.Evaluate(position.String())
+}
+
+// mates and huge advantages count the same (losing a +15 position for a +8 one is not a blunder)
+func clamp(score int) int {
+	if score > 1000 {
+		return 1000
+	}
+	if score < -1000 {
+		return -1000
+	}
+	return score
+}
+
+func filepathBase(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
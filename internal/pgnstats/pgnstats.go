@@ -0,0 +1,167 @@
+package pgnstats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	log "github.com/sirupsen/logrus"
+)
+
+// Count ... number of games for a value (a result, an ECO code ...)
+type Count struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Stats ... counts computed from PGN files
+type Stats struct {
+	TotalGames   int     `json:"totalgames"`
+	Results      []Count `json:"results"`
+	ECOs         []Count `json:"ecos"`
+	TimeControls []Count `json:"timecontrols"`
+	Players      []Count `json:"players"`
+	Years        []Count `json:"years"`
+}
+
+// Process ... compute statistics for files (or all the files of folders) and print them
+func Process(filepaths []string, asJSON bool, top int) {
+	counters := map[string]map[string]int{
+		"results":      {},
+		"ecos":         {},
+		"timecontrols": {},
+		"players":      {},
+		"years":        {},
+	}
+	total := 0
+
+	for _, filepath := range filepaths {
+		info, err := os.Stat(filepath)
+		if os.IsNotExist(err) {
+			log.Fatal("Cannot access " + filepath)
+		}
+
+		if info.IsDir() {
+			fileinfos, err := ioutil.ReadDir(filepath)
+			if err != nil {
+				log.Fatal("Cannot list files in " + filepath)
+			}
+			for _, info := range fileinfos {
+				if !info.IsDir() {
+					total += processFile(path.Join(filepath, info.Name()), counters)
+				}
+			}
+		} else {
+			total += processFile(filepath, counters)
+		}
+	}
+
+	stats := Stats{
+		TotalGames:   total,
+		Results:      sorted(counters["results"], top, false),
+		ECOs:         sorted(counters["ecos"], top, false),
+		TimeControls: sorted(counters["timecontrols"], top, false),
+		Players:      sorted(counters["players"], top, false),
+		Years:        sorted(counters["years"], 0, true),
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(stats)
+	} else {
+		printTables(os.Stdout, &stats)
+	}
+}
+
+func processFile(filepath string, counters map[string]map[string]int) int {
+	// Open file
+	file, err := os.Open(filepath)
+	defer file.Close()
+
+	if err != nil {
+		log.Fatal("Cannot open file " + filepath)
+	}
+
+	reader := pgn.NewReader(file)
+	gameCounter := 0
+	for {
+		game, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		gameCounter++
+
+		counters["results"][valueOrUnknown(game.Get("Result"))]++
+		counters["ecos"][valueOrUnknown(game.Get("ECO"))]++
+		counters["timecontrols"][valueOrUnknown(game.Get("TimeControl"))]++
+		counters["players"][valueOrUnknown(game.Get("White"))]++
+		counters["players"][valueOrUnknown(game.Get("Black"))]++
+
+		date := game.Get("UTCDate")
+		if date == "" {
+			date = game.Get("Date")
+		}
+		counters["years"][valueOrUnknown(strings.Split(date, ".")[0])]++
+	}
+	return gameCounter
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" || strings.Trim(value, "?") == "" {
+		return "?"
+	}
+	return value
+}
+
+// sorted ... most frequent first (or by name), limited to {top} items (0: no limit)
+func sorted(counter map[string]int, top int, byName bool) []Count {
+	counts := make([]Count, 0, len(counter))
+	for name, count := range counter {
+		counts = append(counts, Count{Name: name, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if byName || counts[i].Count == counts[j].Count {
+			return counts[i].Name < counts[j].Name
+		}
+		return counts[i].Count > counts[j].Count
+	})
+	if top > 0 && len(counts) > top {
+		counts = counts[:top]
+	}
+	return counts
+}
+
+func printTables(w io.Writer, stats *Stats) {
+	fmt.Fprintln(w, "Games: "+strconv.Itoa(stats.TotalGames))
+	tables := []struct {
+		title  string
+		counts []Count
+	}{
+		{"Result", stats.Results},
+		{"ECO", stats.ECOs},
+		{"Time control", stats.TimeControls},
+		{"Player", stats.Players},
+		{"Year", stats.Years},
+	}
+	for _, table := range tables {
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, table.title+"\tGames\t")
+		for _, count := range table.counts {
+			fmt.Fprintln(tw, count.Name+"\t"+strconv.Itoa(count.Count)+"\t")
+		}
+		tw.Flush()
+	}
+}
@@ -0,0 +1,88 @@
+package pgntodb
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+)
+
+// lichess (with clocks=true) and chess.com: 1. d4 {[%clk 0:29:56.7]} 1... d5 {[%clk 0:29:52.9]}
+var clockPattern = regexp.MustCompile(`\[%clk\s+(\d+):(\d+):(\d+(?:\.\d+)?)\]`)
+
+// thinkingTimes ... seconds spent on every move of {movetext}, from the clocks after the moves
+// nil when a move has no clock or without a time control in seconds (correspondence)
+func thinkingTimes(movetext string, timeControl string) []float64 {
+	if !strings.Contains(movetext, "[%clk") {
+		return nil
+	}
+	base, increment, ok := parseTimeControl(timeControl)
+	if !ok {
+		return nil
+	}
+
+	moves := pgn.Mainline(movetext)
+	times := make([]float64, len(moves))
+	clocks := make([]float64, len(moves))
+	for i, move := range moves {
+		match := clockPattern.FindStringSubmatch(move.Comment)
+		if match == nil {
+			return nil
+		}
+		hours, _ := strconv.ParseFloat(match[1], 64)
+		minutes, _ := strconv.ParseFloat(match[2], 64)
+		seconds, _ := strconv.ParseFloat(match[3], 64)
+		clocks[i] = hours*3600 + minutes*60 + seconds
+
+		// clock of the player before the move: after their previous move (plus the increment), or the starting time
+		spent := base - clocks[i]
+		if i >= 2 {
+			spent = clocks[i-2] - clocks[i] + increment
+		}
+		if spent < 0 {
+			spent = 0
+		}
+		times[i] = math.Round(spent*10) / 10
+	}
+	return times
+}
+
+// parseTimeControl ... 600+5 gives 600 and 5
+func parseTimeControl(timeControl string) (float64, float64, bool) {
+	parts := strings.SplitN(timeControl, "+", 2)
+	base, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || base <= 0 {
+		return 0, 0, false
+	}
+	increment := 0.0
+	if len(parts) == 2 {
+		if increment, err = strconv.ParseFloat(parts[1], 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return base, increment, true
+}
+
+// ClocksBefore ... seconds on the clock of the player before every move, from the seconds spent on the moves ({times})
+// and the time control, nil when one of them is missing
+func ClocksBefore(times []float64, timeControl string) []float64 {
+	base, increment, ok := parseTimeControl(timeControl)
+	if !ok || len(times) == 0 {
+		return nil
+	}
+	clocks := make([]float64, len(times))
+	after := [2]float64{base, base} // clock of white and black after their last move
+	for i, spent := range times {
+		clocks[i] = after[i%2]
+		after[i%2] = after[i%2] - spent
+		if i >= 2 {
+			after[i%2] += increment // as thinkingTimes: the increment of the previous move
+		}
+		if after[i%2] < 0 {
+			after[i%2] = 0
+		}
+	}
+	return clocks
+}
@@ -0,0 +1,105 @@
+package pgntodb
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // TimeZone tags, without the zoneinfo of the system
+)
+
+/*
+Dates of the games:
+- DateTime: UTC timestamp, from UTCDate/UTCTime (lichess, chess.com) or Date/Time in the
+  zone of the TimeZone tag (UTC without it)
+- LocalDate: Date tag, the day where the game was played (UTCDate without it)
+Unknown parts of a date ("2021.??.??") are the first month/day.
+*/
+
+// createDateTime ... UTC timestamp of the game
+func createDateTime(gameMap map[string]string) (time.Time, error) {
+	if dateTime, ok := parseDateTime(gameMap["UTCDate"], gameMap["UTCTime"], time.UTC); ok {
+		return dateTime, nil
+	}
+	if dateTime, ok := parseDateTime(gameMap["Date"], gameMap["Time"], timeZone(gameMap)); ok {
+		return dateTime.UTC(), nil
+	}
+	return time.Time{}, errors.New("Not a valid date: UTCDate " + gameMap["UTCDate"] + " UTCTime " + gameMap["UTCTime"] + ", Date " + gameMap["Date"])
+}
+
+// localDate ... YYYY-MM-DD of the Date tag ("" if unknown)
+func localDate(gameMap map[string]string) string {
+	for _, tag := range []string{"Date", "UTCDate"} {
+		if year, month, day, ok := parseDate(gameMap[tag]); ok {
+			return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+		}
+	}
+	return ""
+}
+
+// timeZone ... zone of the TimeZone tag, UTC if missing or unknown
+func timeZone(gameMap map[string]string) *time.Location {
+	name := gameMap["TimeZone"]
+	if name == "" {
+		name = gameMap["Timezone"]
+	}
+	if name == "" {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// parseDateTime ... {date} (YYYY.MM.DD) and {clock} (HH:MM:SS, midnight if empty) in {location}
+func parseDateTime(date string, clock string, location *time.Location) (time.Time, bool) {
+	year, month, day, ok := parseDate(date)
+	if !ok {
+		return time.Time{}, false
+	}
+	var hms [3]int
+	if clock != "" && !strings.Contains(clock, "?") {
+		parts := strings.Split(clock, ":")
+		if len(parts) != 3 {
+			return time.Time{}, false
+		}
+		for i, part := range parts {
+			value, err := strconv.Atoi(part)
+			if err != nil || value < 0 || value > 59 || (i == 0 && value > 23) {
+				return time.Time{}, false
+			}
+			hms[i] = value
+		}
+	}
+	return time.Date(year, time.Month(month), day, hms[0], hms[1], hms[2], 0, location), true
+}
+
+// parseDate ... year, month, day of a pgn date (YYYY.MM.DD or YYYY-MM-DD, ?? for unknown month/day)
+func parseDate(date string) (int, int, int, bool) {
+	parts := strings.FieldsFunc(date, func(r rune) bool { return r == '.' || r == '-' || r == '/' })
+	if len(parts) != 3 {
+		return 0, 0, 0, false
+	}
+	values := [3]int{}
+	for i, part := range parts {
+		if strings.Trim(part, "?") == "" && i > 0 {
+			values[i] = 1
+			continue
+		}
+		value, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, 0, 0, false
+		}
+		values[i] = value
+	}
+	year, month, day := values[0], values[1], values[2]
+	if year < 1 || month < 1 || month > 12 || day < 1 || day > 31 {
+		return 0, 0, 0, false
+	}
+	if time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC).Day() != day {
+		return 0, 0, 0, false // 2021.02.30
+	}
+	return year, month, day, true
+}
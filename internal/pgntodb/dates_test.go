@@ -0,0 +1,113 @@
+package pgntodb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		date             string
+		year, month, day int
+		ok               bool
+	}{
+		{"2021.04.17", 2021, 4, 17, true},
+		{"2021-04-17", 2021, 4, 17, true},
+		{"2021/04/17", 2021, 4, 17, true},
+		{"2021.??.??", 2021, 1, 1, true},
+		{"2021.04.??", 2021, 4, 1, true},
+		{"2020.02.29", 2020, 2, 29, true},
+		{"????.04.17", 0, 0, 0, false},
+		{"2021.02.29", 0, 0, 0, false},
+		{"2021.02.30", 0, 0, 0, false},
+		{"2021.13.01", 0, 0, 0, false},
+		{"2021.04.32", 0, 0, 0, false},
+		{"2021.00.10", 0, 0, 0, false},
+		{"2021.04", 0, 0, 0, false},
+		{"", 0, 0, 0, false},
+		{"?", 0, 0, 0, false},
+		{"2021.4a.17", 0, 0, 0, false},
+	}
+	for _, test := range tests {
+		year, month, day, ok := parseDate(test.date)
+		if year != test.year || month != test.month || day != test.day || ok != test.ok {
+			t.Errorf("parseDate(%q) = %d, %d, %d, %v, want %d, %d, %d, %v", test.date, year, month, day, ok,
+				test.year, test.month, test.day, test.ok)
+		}
+	}
+}
+
+func TestParseDateTime(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		date     string
+		clock    string
+		location *time.Location
+		want     time.Time
+		ok       bool
+	}{
+		{"2021.04.17", "12:34:56", time.UTC, time.Date(2021, 4, 17, 12, 34, 56, 0, time.UTC), true},
+		{"2021.04.17", "", time.UTC, time.Date(2021, 4, 17, 0, 0, 0, 0, time.UTC), true},
+		{"2021.04.17", "??:??:??", time.UTC, time.Date(2021, 4, 17, 0, 0, 0, 0, time.UTC), true},
+		{"2021.04.17", "12:00:00", paris, time.Date(2021, 4, 17, 10, 0, 0, 0, time.UTC), true},
+		{"2021.01.17", "12:00:00", paris, time.Date(2021, 1, 17, 11, 0, 0, 0, time.UTC), true},
+		{"2021.??.??", "23:59:59", time.UTC, time.Date(2021, 1, 1, 23, 59, 59, 0, time.UTC), true},
+		{"2021.04.17", "24:00:00", time.UTC, time.Time{}, false},
+		{"2021.04.17", "12:60:00", time.UTC, time.Time{}, false},
+		{"2021.04.17", "12:00", time.UTC, time.Time{}, false},
+		{"2021.04.17", "12:00:xx", time.UTC, time.Time{}, false},
+		{"2021.04.17", "-1:00:00", time.UTC, time.Time{}, false},
+		{"2021.02.30", "12:00:00", time.UTC, time.Time{}, false},
+		{"", "12:00:00", time.UTC, time.Time{}, false},
+	}
+	for _, test := range tests {
+		dateTime, ok := parseDateTime(test.date, test.clock, test.location)
+		if ok != test.ok || !dateTime.Equal(test.want) {
+			t.Errorf("parseDateTime(%q, %q, %s) = %s, %v, want %s, %v", test.date, test.clock, test.location, dateTime, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestCreateDateTime(t *testing.T) {
+	tests := []struct {
+		name string
+		tags map[string]string
+		want time.Time
+		ok   bool
+	}{
+		{
+			name: "UTC tags first",
+			tags: map[string]string{"UTCDate": "2021.04.17", "UTCTime": "10:00:00", "Date": "2021.04.17", "Time": "12:00:00"},
+			want: time.Date(2021, 4, 17, 10, 0, 0, 0, time.UTC),
+			ok:   true,
+		},
+		{
+			name: "Date in the zone of TimeZone",
+			tags: map[string]string{"Date": "2021.04.17", "Time": "12:00:00", "TimeZone": "Europe/Paris"},
+			want: time.Date(2021, 4, 17, 10, 0, 0, 0, time.UTC),
+			ok:   true,
+		},
+		{
+			name: "unknown zone: UTC",
+			tags: map[string]string{"Date": "2021.04.17", "Time": "12:00:00", "TimeZone": "Nowhere/Else"},
+			want: time.Date(2021, 4, 17, 12, 0, 0, 0, time.UTC),
+			ok:   true,
+		},
+		{
+			name: "no date",
+			tags: map[string]string{"Date": "????.??.??"},
+			ok:   false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dateTime, err := createDateTime(test.tags)
+			if (err == nil) != test.ok || !dateTime.Equal(test.want) {
+				t.Errorf("createDateTime(%v) = %s, %v, want %s", test.tags, dateTime, err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package pgntodb
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/flutterbar/chess-explorer-go/internal/engine"
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+)
+
+// lichess (with evals=true, analysed games only) and pgnannotate: 1. e4 { [%eval 0.17] } 1... c5 { [%eval #-3] }
+var evalPattern = regexp.MustCompile(`\[%eval\s+(#)?(-?\d+(?:\.\d+)?)`)
+
+// maxEval ... mates and huge advantages count the same in averages (centipawns, as pgnannotate)
+const maxEval = 1000
+
+// evaluations ... centipawns (white's point of view) after every move of {movetext}, up to the first move without evaluation
+// nil for games without evaluations
+func evaluations(movetext string) []int {
+	if !strings.Contains(movetext, "[%eval") {
+		return nil
+	}
+	evals := make([]int, 0)
+	for _, move := range pgn.Mainline(movetext) {
+		match := evalPattern.FindStringSubmatch(move.Comment)
+		if match == nil {
+			break
+		}
+		eval := engine.Eval{}
+		if match[1] == "#" {
+			eval.Mate, _ = strconv.Atoi(match[2])
+			if eval.Mate == 0 {
+				// #0: mated, the side of the move won
+				eval.Mate = 1
+				if len(evals)%2 == 1 {
+					eval.Mate = -1
+				}
+			}
+		} else {
+			pawns, _ := strconv.ParseFloat(match[2], 64)
+			eval.CP = int(pawns * 100)
+		}
+		score := eval.Score()
+		if score > maxEval {
+			score = maxEval
+		} else if score < -maxEval {
+			score = -maxEval
+		}
+		evals = append(evals, score)
+	}
+	if len(evals) == 0 {
+		return nil
+	}
+	return evals
+}
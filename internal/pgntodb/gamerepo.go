@@ -2,11 +2,17 @@ package pgntodb
 
 import (
 	"context"
-	"log"
+	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/flutterbar/chess-explorer-go/internal/events"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/flutterbar/chess-explorer-go/internal/zobrist"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -20,76 +26,159 @@ type LastGame struct {
 	DateTime time.Time `json:"datetime" bson:"datetime"`
 	GameID   string    `json:"gameid" bson:"gameid"`
 	Logged   string    `json:"logged,omitempty" bson:"logged,omitempty"` // not going to database
+	Owner    string    `json:"owner,omitempty" bson:"owner,omitempty"`   // of the games imported, see Owner
+	// batch of the import (nil: no provenance), not going to database
+	Provenance *Provenance `json:"-" bson:"-"`
+	source     string      // file or URL of the games being read
+	inserted   []string    // ids of the games inserted, kept by ProcessReader
+	duplicates []string    // ids of the games already in the database, kept by ProcessReader
+	keepIDs    bool
+	Reference  bool `json:"-" bson:"-"` // the games go to the reference collection, see reference.go
 }
 
 // Game ... for the database
 type Game struct {
-	ID          string    `json:"_id" bson:"_id"`
-	Site        string    `json:"site,omitempty"`
-	White       string    `json:"white,omitempty"`
-	Black       string    `json:"black,omitempty"`
-	DateTime    time.Time `json:"datetime,omitempty"`
-	Result      string    `json:"result,omitempty"`
-	WhiteElo    uint16    `json:"whiteelo,omitempty"`
-	BlackElo    uint16    `json:"blackelo,omitempty"`
-	TimeControl string    `json:"timecontrol,omitempty"`
-	Link        string    `json:"link,omitempty"`
-	PGN         string    `json:"pgn,omitempty"`
-	Move01      string    `json:"m01,omitempty" bson:"m01,omitempty"`
-	Move02      string    `json:"m02,omitempty" bson:"m02,omitempty"`
-	Move03      string    `json:"m03,omitempty" bson:"m03,omitempty"`
-	Move04      string    `json:"m04,omitempty" bson:"m04,omitempty"`
-	Move05      string    `json:"m05,omitempty" bson:"m05,omitempty"`
-	Move06      string    `json:"m06,omitempty" bson:"m06,omitempty"`
-	Move07      string    `json:"m07,omitempty" bson:"m07,omitempty"`
-	Move08      string    `json:"m08,omitempty" bson:"m08,omitempty"`
-	Move09      string    `json:"m09,omitempty" bson:"m09,omitempty"`
-	Move10      string    `json:"m10,omitempty" bson:"m10,omitempty"`
-	Move11      string    `json:"m11,omitempty" bson:"m11,omitempty"`
-	Move12      string    `json:"m12,omitempty" bson:"m12,omitempty"`
-	Move13      string    `json:"m13,omitempty" bson:"m13,omitempty"`
-	Move14      string    `json:"m14,omitempty" bson:"m14,omitempty"`
-	Move15      string    `json:"m15,omitempty" bson:"m15,omitempty"`
-	Move16      string    `json:"m16,omitempty" bson:"m16,omitempty"`
-	Move17      string    `json:"m17,omitempty" bson:"m17,omitempty"`
-	Move18      string    `json:"m18,omitempty" bson:"m18,omitempty"`
-	Move19      string    `json:"m19,omitempty" bson:"m19,omitempty"`
-	Move20      string    `json:"m20,omitempty" bson:"m20,omitempty"`
+	ID          string      `json:"_id" bson:"_id"`
+	Site        string      `json:"site,omitempty"`
+	White       string      `json:"white,omitempty"`
+	Black       string      `json:"black,omitempty"`
+	DateTime    time.Time   `json:"datetime,omitempty"`                             // UTC
+	LocalDate   string      `json:"localdate,omitempty" bson:"localdate,omitempty"` // YYYY-MM-DD, Date tag: where the game was played
+	Result      string      `json:"result,omitempty"`
+	WhiteElo    *uint16     `json:"whiteelo,omitempty"` // null when unknown (0 in games imported with an older version)
+	BlackElo    *uint16     `json:"blackelo,omitempty"`
+	TimeControl string      `json:"timecontrol,omitempty"`
+	Clock       *Clock      `json:"clock,omitempty" bson:"clock,omitempty"` // structured time control, nil when unknown
+	Link        string      `json:"link,omitempty"`
+	ECO         string      `json:"eco,omitempty" bson:"eco,omitempty"`
+	Opening     string      `json:"opening,omitempty" bson:"opening,omitempty"`
+	PGN         string      `json:"pgn,omitempty"`
+	Move01      string      `json:"m01,omitempty" bson:"m01,omitempty"`
+	Move02      string      `json:"m02,omitempty" bson:"m02,omitempty"`
+	Move03      string      `json:"m03,omitempty" bson:"m03,omitempty"`
+	Move04      string      `json:"m04,omitempty" bson:"m04,omitempty"`
+	Move05      string      `json:"m05,omitempty" bson:"m05,omitempty"`
+	Move06      string      `json:"m06,omitempty" bson:"m06,omitempty"`
+	Move07      string      `json:"m07,omitempty" bson:"m07,omitempty"`
+	Move08      string      `json:"m08,omitempty" bson:"m08,omitempty"`
+	Move09      string      `json:"m09,omitempty" bson:"m09,omitempty"`
+	Move10      string      `json:"m10,omitempty" bson:"m10,omitempty"`
+	Move11      string      `json:"m11,omitempty" bson:"m11,omitempty"`
+	Move12      string      `json:"m12,omitempty" bson:"m12,omitempty"`
+	Move13      string      `json:"m13,omitempty" bson:"m13,omitempty"`
+	Move14      string      `json:"m14,omitempty" bson:"m14,omitempty"`
+	Move15      string      `json:"m15,omitempty" bson:"m15,omitempty"`
+	Move16      string      `json:"m16,omitempty" bson:"m16,omitempty"`
+	Move17      string      `json:"m17,omitempty" bson:"m17,omitempty"`
+	Move18      string      `json:"m18,omitempty" bson:"m18,omitempty"`
+	Move19      string      `json:"m19,omitempty" bson:"m19,omitempty"`
+	Move20      string      `json:"m20,omitempty" bson:"m20,omitempty"`
+	Times       []float64   `json:"times,omitempty" bson:"times,omitempty"`             // seconds spent on every move, from the clocks
+	Evals       []int       `json:"evals,omitempty" bson:"evals,omitempty"`             // centipawns after every move (white's point of view, +/-1000 max)
+	Termination string      `json:"termination,omitempty" bson:"termination,omitempty"` // Termination tag
+	Hashes      []int64     `json:"-" bson:"hashes,omitempty"`                          // Zobrist hash of the position after every move (see zobrist)
+	Provenance  *Provenance `json:"provenance,omitempty" bson:"provenance,omitempty"`   // how the game entered the database
+	Tags        []string    `json:"tags,omitempty" bson:"tags,omitempty"`               // set by the user: "tournament prep", "model game"
+	Owner       string      `json:"-" bson:"owner,omitempty"`                           // who imported the game (isolation of the web server)
+	Raw         string      `json:"-" bson:"raw,omitempty"`                             // the game as read: tags, comments, clocks, evals (raw-pgn)
 }
 
-var client *mongo.Client
+// WithoutRaw ... projection of the games without their text as read, for the queries reading many games
+var WithoutRaw = bson.M{"raw": 0}
+
+// rawPgn ... the games are stored with their text as read (raw-pgn, true by default)
+func rawPgn() bool {
+	if viper.IsSet("raw-pgn") {
+		return viper.GetBool("raw-pgn")
+	}
+	return true
+}
+
+// Summary ... what was imported
+type Summary struct {
+	Files      int `json:"files"`
+	Games      int `json:"games"`      // games read
+	Inserted   int `json:"inserted"`   // new games in the database
+	Duplicates int `json:"duplicates"` // games already in the database
+	Skipped    int `json:"skipped"`    // variants, games from a position and abandoned games
+}
+
+// Minus ... what was imported between {before} and {summary}
+func (summary Summary) Minus(before Summary) Summary {
+	return Summary{
+		Files:      summary.Files - before.Files,
+		Games:      summary.Games - before.Games,
+		Inserted:   summary.Inserted - before.Inserted,
+		Duplicates: summary.Duplicates - before.Duplicates,
+		Skipped:    summary.Skipped - before.Skipped,
+	}
+}
+
+// Plus ... what was imported by {summary} and {other}
+func (summary Summary) Plus(other Summary) Summary {
+	return Summary{
+		Files:      summary.Files + other.Files,
+		Games:      summary.Games + other.Games,
+		Inserted:   summary.Inserted + other.Inserted,
+		Duplicates: summary.Duplicates + other.Duplicates,
+		Skipped:    summary.Skipped + other.Skipped,
+	}
+}
+
+var client *mongodb.Client
 
 var queue []interface{} // queue for insert many
 
+var totals Summary // everything imported since the program started
+
+var indexed = map[string]bool{} // indexes created (first flush), by database and collection
+
+// Totals ... everything imported since the program started
+func Totals() Summary {
+	return totals
+}
+
+// importing ... one import at a time: the queue, the totals and the indexes created are shared
+var importing sync.Mutex
+
+// LockImport ... wait for the running import (a synchronization, a PGN posted to the server...) and hold the import
+// until the function returned is called: Totals().Minus(before) is then the summary of this import alone
+func LockImport() (unlock func()) {
+	importing.Lock()
+	return importing.Unlock
+}
+
 // FindLastGame ... find last game (allowing prevention of duplicates)
-func findLastGame(username string, site string, client *mongo.Client) *LastGame {
+func findLastGame(username string, site string, client *mongodb.Client) (*LastGame, error) {
 	lastGame := LastGame{
 		Site:     site,
 		Username: username,
+		Owner:    Owner(),
 	}
 
-	lastgames := client.Database(viper.GetString("mongo-db-name")).Collection("lastgames")
-	filter := bson.M{"site": site, "username": username}
+	lastgames := mongodb.Collection(client, "lastgames")
+	filter := bson.M{"site": site, "username": username, "owner": OwnerValue(lastGame.Owner)}
 	collation := options.Collation{Locale: "en", Strength: 2}
 	findOneOptions := options.FindOneOptions{Collation: &collation} // case insensitive search
 
 	result := lastgames.FindOne(context.TODO(), filter, &findOneOptions)
 
-	if result != nil {
-		result.Decode(&lastGame)
+	// no document: new user
+	if err := result.Decode(&lastGame); err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
 	}
 
-	return &lastGame
+	return &lastGame, nil
 }
 
-func logLastGame(username string, game Game, client *mongo.Client) {
+func logLastGame(username string, game Game, client *mongodb.Client) error {
 	if username != "" {
 		if strings.ToLower(username) == strings.ToLower(game.White) {
 			username = game.White
 		} else if strings.ToLower(username) == strings.ToLower(game.Black) {
 			username = game.Black
 		} else {
-			log.Fatal("username "+username+"is not a player of ", game)
+			return fmt.Errorf("username %s is not a player of game %s", username, game.ID)
 		}
 
 		lastGame := LastGame{
@@ -97,10 +186,11 @@ func logLastGame(username string, game Game, client *mongo.Client) {
 			Site:     game.Site,
 			DateTime: game.DateTime,
 			GameID:   game.ID,
+			Owner:    game.Owner,
 		}
 
-		lastgames := client.Database(viper.GetString("mongo-db-name")).Collection("lastgames")
-		filter := bson.M{"site": game.Site, "username": username}
+		lastgames := mongodb.Collection(client, "lastgames")
+		filter := bson.M{"site": game.Site, "username": username, "owner": OwnerValue(game.Owner)}
 		updateOptions := options.Update().SetUpsert(true)
 		update := bson.M{
 			"$set": lastGame,
@@ -110,99 +200,207 @@ func logLastGame(username string, game Game, client *mongo.Client) {
 		_, error := lastgames.UpdateOne(context.TODO(), filter, update, updateOptions)
 
 		if error != nil {
-			log.Fatal(error)
+			return error
 		}
 
 		log.Println("Most recent game is now: " + lastGame.GameID)
 	}
+	return nil
 }
 
-func pushGame(gameMap map[string]string, client *mongo.Client, lastGame *LastGame) bool {
+func pushGame(gameMap map[string]string, client *mongodb.Client, lastGame *LastGame) error {
 	game := Game{}
-	mapToGame(gameMap, &game)
+	if err := mapToGame(gameMap, &game); err != nil {
+		return err
+	}
+	game.Provenance = provenanceOf(lastGame.Provenance, lastGame.source)
+	if game.Owner = lastGame.Owner; game.Owner == "" && !lastGame.Reference {
+		game.Owner = Owner()
+	}
+	game.ID = ownedID(game.Owner, game.ID)
 	queue = append(queue, game)
 	if len(queue) > 9999 {
 		return flushGames(client, lastGame)
 	}
-	return true
+	return nil
 }
 
-func flushGames(client *mongo.Client, lastGame *LastGame) bool {
+// publishInserted ... event for the games of the queue which were inserted
+func publishInserted(lastGame *LastGame, notInserted map[int]bool) {
+	event := events.GamesIngested{Username: lastGame.Username, Site: lastGame.Site}
+	for i, game := range queue {
+		if !notInserted[i] {
+			event.GameIDs = append(event.GameIDs, game.(Game).ID)
+		}
+	}
+	if lastGame.keepIDs {
+		lastGame.inserted = append(lastGame.inserted, event.GameIDs...)
+	}
+	event.Count = len(event.GameIDs)
+	events.Publish(event)
+}
+
+func flushGames(client *mongodb.Client, lastGame *LastGame) error {
 	log.Println("Flushing " + strconv.Itoa(len(queue)) + " games to DB")
 	if len(queue) > 0 {
-		games := client.Database(viper.GetString("mongo-db-name")).Collection("games")
+		collection, ensureIndexes := "games", EnsureIndexes
+		if lastGame.Reference {
+			collection, ensureIndexes = ReferenceCollection, EnsureReferenceIndexes
+		}
+		games := mongodb.Collection(client, collection)
+		if key := client.Settings().Name + "." + collection; !indexed[key] {
+			if err := ensureIndexes(context.TODO(), client); err != nil {
+				queue = queue[:0]
+				return err
+			}
+			indexed[key] = true
+		}
 
 		insertManyOptions := options.InsertMany().SetOrdered(false) // continue if duplicates are found
 		_, error := games.InsertMany(context.TODO(), queue, insertManyOptions)
 
-		if error != nil {
-			//log.Println(error)
-			//log.Println("It is possible to have duplicate key errors when importing games for a user who has played again a user we already have games for).")
+		// It is possible to have duplicate key errors when importing games for a user who has played again a user we already have games for
+		failed := 0
+		notInserted := make(map[int]bool)
+		if bulkError, ok := error.(mongo.BulkWriteException); ok {
+			for _, writeError := range bulkError.WriteErrors {
+				notInserted[writeError.Index] = true
+				if writeError.Code == 11000 {
+					totals.Duplicates++
+					if lastGame.keepIDs {
+						lastGame.duplicates = append(lastGame.duplicates, queue[writeError.Index].(Game).ID)
+					}
+				} else {
+					log.Warn(writeError.Message)
+				}
+			}
+			failed = len(bulkError.WriteErrors)
+		} else if error != nil {
+			queue = queue[:0]
+			return error
 		}
-		if lastGame.Logged == "" {
-			logLastGame(lastGame.Username, queue[0].(Game), client)
+		totals.Inserted += len(queue) - failed
+		if len(queue) > failed {
+			publishInserted(lastGame, notInserted)
+			if !lastGame.Reference {
+				ForgetTrees(client, queue[0].(Game).Owner)
+			}
+		}
+		if lastGame.Logged == "" && !lastGame.Reference {
+			if err := logLastGame(lastGame.Username, queue[0].(Game), client); err != nil {
+				queue = queue[:0]
+				return err
+			}
 			lastGame.Logged = "Done"
 		}
 	}
 
 	queue = queue[:0]
-	return true
+	return nil
+}
+
+// normalizers ... tag fixes of the sites, applied to every game (see sites.Register)
+var normalizers []func(gameMap map[string]string)
+
+// AddNormalizer ... fix the tags of the games with {normalize} before they are stored
+// (every game is given to every normalizer: it checks the site of the game)
+func AddNormalizer(normalize func(gameMap map[string]string)) {
+	normalizers = append(normalizers, normalize)
 }
 
-func mapToGame(gameMap map[string]string, game *Game) {
+func mapToGame(gameMap map[string]string, game *Game) error {
 	// Clean up data
-	if strings.Index(gameMap["Site"], "lichess.org") != -1 {
-		gameMap["Link"] = gameMap["Site"]
-		gameMap["Site"] = "lichess.org"
+	for _, normalize := range normalizers {
+		normalize(gameMap)
 	}
 	gameMap["Site"] = strings.ToLower(gameMap["Site"])
 
-	whiteelo := 0
-	blackelo := 0
-	var error error
-	if gameMap["WhiteElo"] != "" && strings.Index(gameMap["WhiteElo"], "?") == -1 {
-		whiteelo, error = strconv.Atoi(gameMap["WhiteElo"])
-		if error != nil {
-			log.Fatal("Not a valid ELO: " + gameMap["WhiteElo"] + " for white " + gameMap["White"])
-		}
-	}
-	if gameMap["BlackElo"] != "" && strings.Index(gameMap["BlackElo"], "?") == -1 {
-		blackelo, error = strconv.Atoi(gameMap["BlackElo"])
-		if error != nil {
-			log.Fatal("Not a valid ELO: " + gameMap["BlackElo"] + " for black " + gameMap["Black"])
-		}
+	dateTime, error := createDateTime(gameMap)
+	if error != nil {
+		return error
 	}
 
 	game.ID = createGameID(gameMap)
 	game.Site = gameMap["Site"]
 	game.White = gameMap["White"]
 	game.Black = gameMap["Black"]
-	game.DateTime = createDateTime(gameMap)
+	game.DateTime = dateTime
+	game.LocalDate = localDate(gameMap)
 	game.Result = gameMap["Result"]
-	game.WhiteElo = uint16(whiteelo)
-	game.BlackElo = uint16(blackelo)
+	game.WhiteElo = parseElo(gameMap["WhiteElo"])
+	game.BlackElo = parseElo(gameMap["BlackElo"])
 	game.TimeControl = gameMap["TimeControl"]
+	game.Clock = NormalizeTimeControl(game.TimeControl)
 	game.Link = gameMap["Link"]
+	game.ECO = gameMap["ECO"]
+	game.Opening = openingName(gameMap)
 	game.PGN = gameMap["PGN"]
+	game.Times = thinkingTimes(gameMap["Movetext"], game.TimeControl)
+	game.Evals = evaluations(gameMap["Movetext"])
+	game.Termination = gameMap["Termination"]
+	if rawPgn() {
+		game.Raw = gameMap["Raw"]
+	}
+	// up to the first illegal move, if any
+	game.Hashes, _ = zobrist.Hashes(pgn.Moves(game.PGN))
 
 	// Itemize first moves of the pgn
 	itemizePgn(game)
+	return nil
 }
 
-func createDateTime(gameMap map[string]string) time.Time {
-	// Create a time.Time object
-	utcDate := strings.ReplaceAll(gameMap["UTCDate"], ".", "-")
-	dateTimeAsUTCString := utcDate + "T" + gameMap["UTCTime"] + "+00:00"
+// maxElo ... higher ratings are typos
+const maxElo = 4000
 
-	dateTime, error := time.Parse(time.RFC3339, dateTimeAsUTCString)
-	if error != nil {
-		log.Fatal("Not a valid date: " + dateTimeAsUTCString)
+// parseElo ... rating of a WhiteElo or BlackElo tag, nil when unknown: missing, "?", "-", "1500?" or not a rating
+func parseElo(tag string) *uint16 {
+	elo, err := strconv.Atoi(strings.TrimSpace(tag))
+	if err != nil || elo <= 0 || elo > maxElo {
+		if tag = strings.TrimSpace(tag); tag != "" && tag != "?" && tag != "-" {
+			log.Warn("Not a valid Elo, stored as unknown: " + tag)
+		}
+		return nil
+	}
+	rating := uint16(elo)
+	return &rating
+}
+
+// WhiteRating ... Elo of white, 0 when unknown
+func (game *Game) WhiteRating() int {
+	return rating(game.WhiteElo)
+}
+
+// BlackRating ... Elo of black, 0 when unknown
+func (game *Game) BlackRating() int {
+	return rating(game.BlackElo)
+}
+
+func rating(elo *uint16) int {
+	if elo == nil {
+		return 0
 	}
-	return dateTime
+	return int(*elo)
+}
+
+// openingName ... lichess: [Opening "Sicilian Defense: Najdorf Variation"]
+// chess.com: [ECOUrl "https://www.chess.com/openings/Sicilian-Defense-Najdorf-Variation"]
+func openingName(gameMap map[string]string) string {
+	if gameMap["Opening"] != "" && gameMap["Opening"] != "?" {
+		return gameMap["Opening"]
+	}
+	if ecoURL := gameMap["ECOUrl"]; ecoURL != "" {
+		name := ecoURL[strings.LastIndex(ecoURL, "/")+1:]
+		return strings.ReplaceAll(name, "-", " ")
+	}
+	return ""
 }
 
 func createGameID(gameMap map[string]string) string {
-	return strings.ToLower(gameMap["Site"]) + ":" + gameMap["White"] + ":" + gameMap["Black"] + ":" + gameMap["UTCDate"] + ":" + gameMap["UTCTime"]
+	date, clock := gameMap["UTCDate"], gameMap["UTCTime"]
+	if date == "" {
+		date, clock = gameMap["Date"], gameMap["Time"]
+	}
+	return strings.ToLower(gameMap["Site"]) + ":" + gameMap["White"] + ":" + gameMap["Black"] + ":" + date + ":" + clock
 }
 
 // Reminder: last item of the pgn is "0-1" or "1-0" or "1/2-1/2" (for len(pgnElements) test)
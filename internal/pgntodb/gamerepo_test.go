@@ -0,0 +1,43 @@
+package pgntodb
+
+import "testing"
+
+func TestParseElo(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want int // 0: unknown (nil)
+	}{
+		{"1500", 1500},
+		{" 2850 ", 2850},
+		{"1", 1},
+		{"4000", 4000},
+		{"", 0},
+		{"?", 0},
+		{"-", 0},
+		{"0", 0},
+		{"-1500", 0},
+		{"1500?", 0},
+		{"4001", 0},
+		{"70000", 0}, // more than an uint16 holds
+		{"abc", 0},
+	}
+	for _, test := range tests {
+		elo := parseElo(test.tag)
+		switch {
+		case test.want == 0 && elo != nil:
+			t.Errorf("parseElo(%q) = %d, want unknown", test.tag, *elo)
+		case test.want != 0 && elo == nil:
+			t.Errorf("parseElo(%q) unknown, want %d", test.tag, test.want)
+		case test.want != 0 && int(*elo) != test.want:
+			t.Errorf("parseElo(%q) = %d, want %d", test.tag, *elo, test.want)
+		}
+	}
+}
+
+func TestRating(t *testing.T) {
+	elo := uint16(1850)
+	game := Game{WhiteElo: &elo}
+	if game.WhiteRating() != 1850 || game.BlackRating() != 0 {
+		t.Errorf("ratings %d and %d, want 1850 and 0", game.WhiteRating(), game.BlackRating())
+	}
+}
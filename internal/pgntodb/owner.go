@@ -0,0 +1,72 @@
+package pgntodb
+
+import (
+	"sync"
+
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+/*
+Owners of the games, for hosted instances serving several people (isolation of the web server): a game and a lastgame
+belong to the owner of the import (lichess.org:{username} or key:{sha256 of an API key}), none when empty.
+The web server imports for the owner of the request (LastGame.Owner), the commands for owner: of the config file,
+a synchronization for the owner of every lastgame.
+The id of an owned game starts with its owner: two owners may import the same game.
+*/
+
+// importOwner ... owner of the imports of the commands, instead of owner: of the config file while set
+var importOwner = struct {
+	sync.Mutex
+	owner *string
+}{}
+
+// Owner ... owner of the games imported without an owner of their own
+func Owner() string {
+	importOwner.Lock()
+	defer importOwner.Unlock()
+	if importOwner.owner != nil {
+		return *importOwner.owner
+	}
+	return viper.GetString("owner")
+}
+
+// SetOwner ... {owner} owns the games imported without an owner of their own until restore is called
+func SetOwner(owner string) (restore func()) {
+	importOwner.Lock()
+	defer importOwner.Unlock()
+	previous := importOwner.owner
+	importOwner.owner = &owner
+	return func() {
+		importOwner.Lock()
+		defer importOwner.Unlock()
+		importOwner.owner = previous
+	}
+}
+
+// OwnerQuery ... {query} restricted to the documents of {owner} (games or lastgames), unchanged for no owner
+func OwnerQuery(query bson.M, owner string) bson.M {
+	if owner == "" {
+		return query
+	}
+	if len(query) == 0 {
+		return bson.M{"owner": owner}
+	}
+	return bson.M{"$and": bson.A{query, bson.M{"owner": owner}}}
+}
+
+// ownedID ... id of a game of {owner}
+func ownedID(owner string, id string) string {
+	if owner == "" {
+		return id
+	}
+	return owner + "/" + id
+}
+
+// OwnerValue ... value of the owner field of the documents of {owner} (nil: documents without owner)
+func OwnerValue(owner string) interface{} {
+	if owner == "" {
+		return nil
+	}
+	return owner
+}
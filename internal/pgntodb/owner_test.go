@@ -0,0 +1,42 @@
+package pgntodb
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestOwnerQuery(t *testing.T) {
+	tests := []struct {
+		query bson.M
+		owner string
+		want  string
+	}{
+		{bson.M{"white": "alice"}, "", "map[white:alice]"},
+		{bson.M{}, "key:abc", "map[owner:key:abc]"},
+		{bson.M{"white": "alice"}, "key:abc", "map[$and:[map[white:alice] map[owner:key:abc]]]"},
+	}
+	for _, test := range tests {
+		if got := fmt.Sprint(OwnerQuery(test.query, test.owner)); got != test.want {
+			t.Errorf("OwnerQuery(%v, %q) = %s, want %s", test.query, test.owner, got, test.want)
+		}
+	}
+	if ownedID("", "abc") != "abc" || ownedID("key:abc", "abc") == ownedID("key:def", "abc") {
+		t.Errorf("ids of a game imported by two owners: %s and %s", ownedID("key:abc", "abc"), ownedID("key:def", "abc"))
+	}
+}
+
+func TestSetOwner(t *testing.T) {
+	viper.Set("owner", "config")
+	defer viper.Set("owner", "")
+	restore := SetOwner("lichess.org:alice")
+	if Owner() != "lichess.org:alice" {
+		t.Errorf("owner %q while set, want lichess.org:alice", Owner())
+	}
+	restore()
+	if Owner() != "config" {
+		t.Errorf("owner %q once restored, want the one of the config file", Owner())
+	}
+}
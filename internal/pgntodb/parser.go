@@ -2,22 +2,69 @@ package pgntodb
 
 import (
 	"bufio"
+	"fmt"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"io"
 	"os"
 	"strings"
 
-	"go.mongodb.org/mongo-driver/mongo"
+	"github.com/schollz/progressbar/v3"
 )
 
-func pgnFileToDB(f *os.File, db *mongo.Client, lastGame *LastGame) bool {
-	scanner := bufio.NewScanner(f)
-	return pgnToDB(scanner, db, lastGame)
+func pgnFileToDB(f *os.File, db *mongodb.Client, lastGame *LastGame, bar *progressbar.ProgressBar) (bool, error) {
+	reader := progressbar.NewReader(f, bar)
+	scanner := bufio.NewScanner(&reader)
+	return pgnToDB(scanner, db, lastGame, bar)
 }
 
-func pgnToDB(scanner *bufio.Scanner, db *mongo.Client, lastGame *LastGame) bool {
+func pgnToDB(scanner *bufio.Scanner, db *mongodb.Client, lastGame *LastGame, bar *progressbar.ProgressBar) (bool, error) {
+	complete, err := readGames(scanner, func(keyValues map[string]string) (bool, error) {
+		if totals.Games%1000 == 0 {
+			bar.Describe(fmt.Sprintf("Importing (%d games read, %d inserted)", totals.Games, totals.Inserted))
+		}
+		if !lastGame.DateTime.IsZero() {
+			dateTime, err := createDateTime(keyValues)
+			if err != nil {
+				return false, err
+			}
+			if lastGame.DateTime.Equal(dateTime) || lastGame.DateTime.After(dateTime) {
+				return false, nil
+			}
+		}
+		return true, pushGame(keyValues, db, lastGame)
+	})
+	if err != nil {
+		return false, err
+	}
+	return complete, flushGames(db, lastGame)
+}
+
+// ReadGames ... standard games of a pgn file (not stored in the database)
+func ReadGames(reader io.Reader) ([]Game, error) {
+	games := make([]Game, 0)
+	_, err := readGames(bufio.NewScanner(reader), func(keyValues map[string]string) (bool, error) {
+		game := Game{}
+		if err := mapToGame(keyValues, &game); err != nil {
+			return false, err
+		}
+		games = append(games, game)
+		return true, nil
+	})
+	return games, err
+}
+
+// readGames ... call {onGame} with the tags, the moves (PGN key) and the text as read (Raw key) of every standard game
+// of {scanner} (games from a position, variants and abandoned games are skipped)
+// stops early, returning false, when {onGame} returns false
+func readGames(scanner *bufio.Scanner, onGame func(keyValues map[string]string) (bool, error)) (bool, error) {
 	keyValues := make(map[string]string)
 	isSetup := false
+	var raw strings.Builder // lines of the game, from the Event tag
 	for i := 1; scanner.Scan(); i++ {
 		line := scanner.Text()
+		if raw.Len() > 0 {
+			raw.WriteString(line + "\n")
+		}
 		line = strings.Trim(line, " ")
 		if len(line) == 0 {
 			continue
@@ -28,6 +75,8 @@ func pgnToDB(scanner *bufio.Scanner, db *mongo.Client, lastGame *LastGame) bool
 			if key == "Event" {
 				keyValues = make(map[string]string)
 				isSetup = false
+				raw.Reset()
+				raw.WriteString(scanner.Text() + "\n")
 			}
 			if key == "FEN" {
 				isSetup = true
@@ -36,29 +85,33 @@ func pgnToDB(scanner *bufio.Scanner, db *mongo.Client, lastGame *LastGame) bool
 				keyValues[key] = value
 			}
 		case '0':
+			// abandoned game (0-1)
+			totals.Games++
+			totals.Skipped++
 		case '1':
+			totals.Games++
 			if isSetup == true {
+				totals.Skipped++
 				break
 			}
 			if val, ok := keyValues["Variant"]; ok {
 				if val != "Standard" {
+					totals.Skipped++
 					break
 				}
 			}
-			if !lastGame.DateTime.IsZero() &&
-				(lastGame.DateTime.Equal(createDateTime(keyValues)) ||
-					lastGame.DateTime.After(createDateTime(keyValues))) {
-				flushGames(db, lastGame)
-				return false
-			}
 
 			// If game was abandoned, pgn will be 0-1 or 1-0 (skip it)
 			if line != "0-1" && line != "1-0" {
 				keyValues["PGN"] = stripPgn(line)
-				goOn := pushGame(keyValues, db, lastGame)
-				if goOn == false {
-					return false
+				keyValues["Movetext"] = line
+				keyValues["Raw"] = raw.String()
+				next, err := onGame(keyValues)
+				if err != nil || !next {
+					return false, err
 				}
+			} else {
+				totals.Skipped++
 			}
 		default:
 			// not a valid char, skip
@@ -68,7 +121,10 @@ func pgnToDB(scanner *bufio.Scanner, db *mongo.Client, lastGame *LastGame) bool
 		}
 	}
 
-	return flushGames(db, lastGame)
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 // [Key "value"]
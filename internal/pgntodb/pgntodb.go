@@ -1,101 +1,156 @@
 package pgntodb
 
 import (
+	"bufio"
 	"context"
+	"fmt"
+	"io"
 	"io/ioutil"
-	"log"
 	"os"
 	"path"
+	pathfilepath "path/filepath"
 	"time"
 
-	"github.com/spf13/viper"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/progress"
+	"github.com/schollz/progressbar/v3"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 // Process ... process a single file or all the files of a folder
-func Process(filepath string, lastGame *LastGame) bool {
+// false when the last game of {lastGame} was found (older games are already in the database)
+func Process(filepath string, lastGame *LastGame) (bool, error) {
+	return ProcessFrom(context.Background(), filepath, "", lastGame)
+}
+
+// ProcessFrom ... Process, the games were read from {source} (provenance, the file itself when empty: downloads)
+// into the database of {ctx} (see mongodb.WithSettings)
+func ProcessFrom(ctx context.Context, filepath string, source string, lastGame *LastGame) (bool, error) {
 	goOn := true
 
 	// Connect to DB
-	client, err := mongo.NewClient(options.Client().ApplyURI(viper.GetString("mongo-url")))
-	if err != nil {
-		log.Fatal(err)
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
-	err = client.Connect(ctx)
+	client, err := mongodb.Connect(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return false, err
 	}
 	defer client.Disconnect(ctx)
 
-	// Ping MongoDB
-	if err = client.Ping(ctx, readpref.Primary()); err != nil {
-		log.Fatal("Cannot connect to DB " + viper.GetString("mongo-url"))
-	}
-
 	info, err := os.Stat(filepath)
-	if os.IsNotExist(err) {
-		log.Fatal("Cannot access " + filepath)
+	if err != nil {
+		return false, fmt.Errorf("Cannot access %s: %w", filepath, err)
 	}
 
 	if info.IsDir() {
 		fileinfos, err := ioutil.ReadDir(filepath)
 		if err != nil {
-			log.Fatal("Cannot list files in " + filepath)
+			return false, fmt.Errorf("Cannot list files in %s: %w", filepath, err)
 		}
 		for _, info := range fileinfos {
 			if !info.IsDir() {
 				log.Println(path.Join(filepath, info.Name()))
-				goOn = processFile(path.Join(filepath, info.Name()), client, lastGame)
-				if goOn == false {
+				lastGame.source = sourceOf(path.Join(filepath, info.Name()), source)
+				goOn, err = processFile(path.Join(filepath, info.Name()), client, lastGame)
+				if err != nil || goOn == false {
 					break
 				}
 			}
 		}
 	} else {
-		goOn = processFile(filepath, client, lastGame)
+		lastGame.source = sourceOf(filepath, source)
+		goOn, err = processFile(filepath, client, lastGame)
 	}
 
-	return goOn
+	return goOn, err
+}
+
+// ProcessReader ... import the games of {reader} (PGN pasted in the web page...), read from {source} (provenance):
+// ids of the games inserted and of the games already in the database, in the order of the PGN
+func ProcessReader(reader io.Reader, source string, lastGame *LastGame) ([]string, []string, error) {
+	// Connect to DB
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer client.Disconnect(ctx)
+
+	lastGame.source = source
+	lastGame.keepIDs = true
+	lastGame.inserted, lastGame.duplicates = nil, nil
+	_, err = pgnToDB(bufio.NewScanner(reader), client, lastGame, progressbar.DefaultSilent(-1))
+	return lastGame.inserted, lastGame.duplicates, err
 }
 
 // ProcessFile ... does everything
-func processFile(filepath string, client *mongo.Client, lastGame *LastGame) bool {
+func processFile(filepath string, client *mongodb.Client, lastGame *LastGame) (bool, error) {
 
 	// Open file
 	file, err := os.Open(filepath)
+	if err != nil {
+		return false, fmt.Errorf("Cannot open file %s: %w", filepath, err)
+	}
 	defer file.Close()
+	totals.Files++
 
-	if err != nil {
-		log.Fatal("Cannot open file " + filepath)
+	size := int64(-1)
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
 	}
+	bar := progress.Bytes(size, "Importing")
+	defer bar.Finish()
 
 	// Do the work
-	return pgnFileToDB(file, client, lastGame)
+	return pgnFileToDB(file, client, lastGame, bar)
 }
 
-// FindLastGame ... find last game (allowing prevention of duplicates)
-func FindLastGame(username string, site string) *LastGame {
+// FindLastGame ... find last game (allowing prevention of duplicates) in the database of {ctx}
+func FindLastGame(ctx context.Context, username string, site string) (*LastGame, error) {
 	// Connect to DB
-	client, err := mongo.NewClient(options.Client().ApplyURI(viper.GetString("mongo-url")))
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	defer client.Disconnect(ctx)
+
+	return findLastGame(username, site, client)
+}
+
+// TrackedUsers ... users whose games have been downloaded (lastgames collection)
+func TrackedUsers() ([]LastGame, error) {
+	// Connect to DB
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
-	err = client.Connect(ctx)
+	client, err := mongodb.Connect(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer client.Disconnect(ctx)
 
-	// Ping MongoDB
-	if err = client.Ping(ctx, readpref.Primary()); err != nil {
-		log.Fatal("Cannot connect to DB " + viper.GetString("mongo-url"))
+	cursor, err := mongodb.Collection(client, "lastgames").Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
 	}
 
-	return findLastGame(username, site, client)
+	var users []LastGame
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// sourceOf ... {source}, or the absolute path of {filepath}
+func sourceOf(filepath string, source string) string {
+	if source != "" {
+		return source
+	}
+	if absolute, err := pathfilepath.Abs(filepath); err == nil {
+		return absolute
+	}
+	return filepath
 }
@@ -0,0 +1,99 @@
+package pgntodb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+Provenance of the games: every game inserted by an import run (a batch: pgntodb, lichess, a synchronization...)
+records the batch, the command, the file or URL it was read from, when and by which version.
+Games imported before provenance was stored have none; a duplicate keeps the provenance of its first import.
+*/
+
+// Provenance ... how a game entered the database
+type Provenance struct {
+	Batch      string    `json:"batch" bson:"batch"`                       // import run, see NewBatch
+	Command    string    `json:"command" bson:"command"`                   // chess-explorer lichess, sync...
+	Source     string    `json:"source,omitempty" bson:"source,omitempty"` // file or URL
+	ImportedAt time.Time `json:"importedat" bson:"importedat"`
+	Version    string    `json:"version,omitempty" bson:"version,omitempty"` // of the importer
+}
+
+// NewBatch ... provenance of the games of a new import run of {command}
+// (given to the imports with LastGame.Provenance)
+func NewBatch(command string) *Provenance {
+	random := make([]byte, 3)
+	rand.Read(random)
+	versionTag := version.GitTag
+	if versionTag == "" {
+		versionTag = "dev"
+	}
+	return &Provenance{
+		Batch:   time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(random),
+		Command: command,
+		Version: versionTag,
+	}
+}
+
+// provenanceOf ... provenance of a game read now from {source}, nil without batch
+func provenanceOf(batch *Provenance, source string) *Provenance {
+	if batch == nil {
+		return nil
+	}
+	provenance := *batch
+	provenance.Source = source
+	provenance.ImportedAt = time.Now().UTC()
+	return &provenance
+}
+
+// Batch ... games inserted by an import run
+type Batch struct {
+	ID      string    `json:"batch" bson:"_id"`
+	Command string    `json:"command" bson:"command"`
+	Version string    `json:"version,omitempty" bson:"version"`
+	Games   int       `json:"games" bson:"games"`
+	First   time.Time `json:"first" bson:"first"` // import time of the first game
+	Last    time.Time `json:"last" bson:"last"`
+	Sources []string  `json:"sources,omitempty" bson:"sources"`
+}
+
+// Batches ... import runs of the games of the database, most recent first ({limit}: 0 for all)
+func Batches(ctx context.Context, client *mongodb.Client, limit int) ([]Batch, error) {
+	pipeline := []bson.M{
+		{"$match": bson.M{"provenance.batch": bson.M{"$exists": true}}},
+		{"$group": bson.M{
+			"_id":     "$provenance.batch",
+			"command": bson.M{"$first": "$provenance.command"},
+			"version": bson.M{"$first": "$provenance.version"},
+			"games":   bson.M{"$sum": 1},
+			"first":   bson.M{"$min": "$provenance.importedat"},
+			"last":    bson.M{"$max": "$provenance.importedat"},
+			"sources": bson.M{"$addToSet": "$provenance.source"},
+		}},
+		{"$sort": bson.M{"first": -1}},
+	}
+	if limit > 0 {
+		pipeline = append(pipeline, bson.M{"$limit": limit})
+	}
+	cursor, err := mongodb.Collection(client, "games").Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	batches := make([]Batch, 0)
+	if err = cursor.All(ctx, &batches); err != nil {
+		return nil, err
+	}
+	for _, batch := range batches {
+		sort.Strings(batch.Sources)
+	}
+	return batches, nil
+}
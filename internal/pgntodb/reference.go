@@ -0,0 +1,30 @@
+package pgntodb
+
+import (
+	"context"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+/*
+Reference database: a large collection of strong games (lichess elite, OTB mega databases) imported apart from the games
+of the players (pgntodb --reference), in the reference collection with indexes of its own, and queried as the comparison
+source of the explorer (/nextmoves with reference=true). Its games have no owner and no tracked user.
+*/
+
+// ReferenceCollection ... collection of the reference games
+const ReferenceCollection = "reference"
+
+// EnsureReferenceIndexes ... indexes of the reference collection: the first moves and the positions (next moves of a line or of a
+// position), the import batches
+func EnsureReferenceIndexes(ctx context.Context, client *mongodb.Client) error {
+	reference := mongodb.Collection(client, ReferenceCollection)
+	_, err := reference.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "m01", Value: 1}, {Key: "m02", Value: 1}, {Key: "m03", Value: 1}, {Key: "m04", Value: 1}, {Key: "m05", Value: 1}, {Key: "m06", Value: 1}}},
+		{Keys: bson.D{{Key: "hashes", Value: 1}}},
+		{Keys: bson.D{{Key: "provenance.batch", Value: 1}}},
+	})
+	return err
+}
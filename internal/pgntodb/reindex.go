@@ -0,0 +1,103 @@
+package pgntodb
+
+import (
+	"context"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/flutterbar/chess-explorer-go/internal/zobrist"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ReindexResult ... what reindex did
+type ReindexResult struct {
+	Games   int `json:"games"`   // games read
+	Updated int `json:"updated"` // games with new hashes or time controls
+	Invalid int `json:"invalid"` // games with an illegal move (hashes up to the move)
+}
+
+// EnsureIndexes ... indexes of the games collection used to find positions, import batches, speeds, links and tags
+func EnsureIndexes(ctx context.Context, client *mongodb.Client) error {
+	games := mongodb.Collection(client, "games")
+	_, err := games.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "hashes", Value: 1}}},
+		{Keys: bson.D{{Key: "provenance.batch", Value: 1}}},
+		{Keys: bson.D{{Key: "clock.speed", Value: 1}, {Key: "clock.base", Value: 1}}},
+		{Keys: bson.D{{Key: "link", Value: 1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}}},
+		{Keys: bson.D{{Key: "owner", Value: 1}}},
+	})
+	return err
+}
+
+// Reindex ... compute the Zobrist hashes and the structured time controls (clock) of the games imported before they were stored
+// ({all}: of every game)
+func Reindex(ctx context.Context, client *mongodb.Client, all bool) (*ReindexResult, error) {
+	if err := EnsureIndexes(ctx, client); err != nil {
+		return nil, err
+	}
+
+	games := mongodb.Collection(client, "games")
+	filter := bson.M{"$or": bson.A{
+		bson.M{"hashes": bson.M{"$exists": false}},
+		bson.M{"clock": bson.M{"$exists": false}, "timecontrol": bson.M{"$nin": bson.A{nil, "", "?"}}},
+	}}
+	if all {
+		filter = bson.M{}
+	}
+	cursor, err := games.Find(ctx, filter, options.Find().SetProjection(bson.M{"pgn": 1, "timecontrol": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := ReindexResult{}
+	updates := make([]mongo.WriteModel, 0)
+	flush := func() error {
+		if len(updates) == 0 {
+			return nil
+		}
+		bulkResult, err := games.BulkWrite(ctx, updates, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return err
+		}
+		result.Updated += int(bulkResult.ModifiedCount)
+		log.Println("Reindexed", result.Games, "games")
+		updates = updates[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var game Game
+		if err = cursor.Decode(&game); err != nil {
+			return nil, err
+		}
+		result.Games++
+		hashes, err := zobrist.Hashes(pgn.Moves(game.PGN))
+		if err != nil {
+			result.Invalid++
+			log.Warn(game.ID + ": " + err.Error())
+		}
+		updates = append(updates, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": game.ID}).
+			SetUpdate(bson.M{"$set": bson.M{"hashes": hashes, "clock": NormalizeTimeControl(game.TimeControl)}}))
+		if len(updates) > 999 {
+			if err = flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, err
+	}
+	if err = flush(); err != nil {
+		return nil, err
+	}
+	if result.Updated > 0 {
+		ForgetAllTrees(client)
+	}
+	return &result, nil
+}
@@ -0,0 +1,75 @@
+package pgntodb
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Clock ... time control of a game in a structured form (the TimeControl tag is stored as is)
+type Clock struct {
+	Base           int    `json:"base" bson:"base"`                                         // seconds, per move for correspondence (0: unknown)
+	Increment      int    `json:"increment,omitempty" bson:"increment,omitempty"`           // seconds per move
+	Correspondence bool   `json:"correspondence,omitempty" bson:"correspondence,omitempty"` // days per move ("-", 1/86400)
+	Speed          string `json:"speed" bson:"speed"`                                       // bullet, blitz, rapid, classical or correspondence
+}
+
+// NormalizeTimeControl ... structured form of a TimeControl tag, nil when the time control is unknown ("", "?")
+//
+//	chess.com and lichess.org: 600+5, 300 (no increment), 1/86400 (daily: seconds per move), - (correspondence)
+//	FIDE: 40/7200:3600 (first period of 40 moves in 7200 seconds)
+func NormalizeTimeControl(timeControl string) *Clock {
+	timeControl = strings.TrimSpace(timeControl)
+	switch timeControl {
+	case "", "?":
+		return nil
+	case "-":
+		return &Clock{Correspondence: true, Speed: "correspondence"}
+	}
+
+	// first period only: 40/7200:3600
+	period := strings.SplitN(timeControl, ":", 2)[0]
+	if parts := strings.SplitN(period, "/", 2); len(parts) == 2 {
+		moves, err := strconv.Atoi(parts[0])
+		seconds, secondsErr := strconv.Atoi(strings.SplitN(parts[1], "+", 2)[0])
+		if err != nil || secondsErr != nil || moves <= 0 || seconds <= 0 {
+			return nil
+		}
+		if moves == 1 {
+			// a time per move: days for correspondence games
+			return &Clock{Base: seconds, Correspondence: true, Speed: "correspondence"}
+		}
+		period = parts[1]
+	}
+
+	base, increment, ok := parseTimeControl(period)
+	if !ok {
+		return nil
+	}
+	return &Clock{Base: int(base), Increment: int(increment), Speed: speed(int(base), int(increment))}
+}
+
+// Speed ... bullet, blitz, rapid, classical or correspondence, from a time control (300+3),
+// correspondence when the time control is unknown
+func Speed(timeControl string) string {
+	if clock := NormalizeTimeControl(timeControl); clock != nil {
+		return clock.Speed
+	}
+	return "correspondence"
+}
+
+// Speeds ... categories of the time controls, fastest first
+var Speeds = []string{"bullet", "blitz", "rapid", "classical", "correspondence"}
+
+// speed ... as lichess.org: estimated duration = initial time + 40 x increment
+func speed(base int, increment int) string {
+	switch duration := base + 40*increment; {
+	case duration < 180:
+		return "bullet"
+	case duration < 480:
+		return "blitz"
+	case duration < 1500:
+		return "rapid"
+	default:
+		return "classical"
+	}
+}
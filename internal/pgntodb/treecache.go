@@ -0,0 +1,36 @@
+package pgntodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+/*
+The next moves computed in advance (treecache collection, see the warm command) are counts of the games: every change of
+the games (import, deletion, restore, tags, evaluations, reindex) removes the nodes computed on them.
+A node belongs to the owner of its filter (none: computed on the games of every owner).
+*/
+
+// ForgetTrees ... the games of {owner} changed: the nodes of the tree cache of {owner} and those computed on the games of
+// every owner are removed (a failure is logged)
+func ForgetTrees(client *mongodb.Client, owner string) {
+	forgetTrees(client, bson.M{"owner": bson.M{"$in": bson.A{nil, OwnerValue(owner)}}})
+}
+
+// ForgetAllTrees ... games of any owner changed: every node of the tree cache is removed (a failure is logged)
+func ForgetAllTrees(client *mongodb.Client) {
+	forgetTrees(client, bson.M{})
+}
+
+// forgetTrees ... remove the nodes of the tree cache matching {filter}
+func forgetTrees(client *mongodb.Client, filter bson.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := mongodb.Collection(client, "treecache").DeleteMany(ctx, filter); err != nil {
+		log.Warn("tree cache not cleared: " + err.Error())
+	}
+}
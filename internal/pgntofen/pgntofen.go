@@ -0,0 +1,140 @@
+package pgntofen
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/notnil/chess"
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+EPD opcodes written for each position:
+  id    game identifier (link if known)
+  c0    white
+  c1    black
+  c2    date
+  c3    result
+  c4    ECO
+  c5    ply
+  sm    move played in this position (if any), in SAN
+  hmvc  halfmove clock
+  fmvn  fullmove number
+*/
+
+// Options ... which positions to extract
+type Options struct {
+	Ply    int    // position after this ply (0: see Every and final position)
+	Every  bool   // position after every ply
+	Format string // "epd" or "fen"
+	Output string // file (standard output if empty)
+}
+
+// Process ... write positions of the games of {filepath}
+func Process(filepath string, options Options) {
+	if options.Format != "epd" && options.Format != "fen" {
+		log.Fatal("Unknown format: " + options.Format + " (expected epd or fen)")
+	}
+
+	// Open file
+	file, err := os.Open(filepath)
+	defer file.Close()
+
+	if err != nil {
+		log.Fatal("Cannot open file " + filepath)
+	}
+
+	var out io.Writer = os.Stdout
+	if options.Output != "" {
+		outFile, err := os.Create(options.Output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer outFile.Close()
+		out = outFile
+	}
+	writer := bufio.NewWriter(out)
+	defer writer.Flush()
+
+	reader := pgn.NewReader(file)
+	gameCounter, positionCounter := 0, 0
+	for {
+		game, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		gameCounter++
+
+		chessGame, err := pgn.Replay(game)
+		if err != nil {
+			log.Println("Game line " + strconv.Itoa(game.Line) + " skipped: " + err.Error())
+			continue
+		}
+
+		positions := chessGame.Positions()
+		moves := chessGame.Moves()
+		for ply := 1; ply < len(positions); ply++ {
+			switch {
+			case options.Every:
+			case options.Ply > 0 && ply != options.Ply:
+				continue
+			case options.Ply == 0 && ply != len(positions)-1:
+				continue
+			}
+
+			var nextMove *chess.Move
+			if ply < len(moves) {
+				nextMove = moves[ply]
+			}
+			line := positions[ply].String()
+			if options.Format == "epd" {
+				line = epdLine(game, positions[ply], nextMove, ply)
+			}
+			if _, err = writer.WriteString(line + "\n"); err != nil {
+				log.Fatal(err)
+			}
+			positionCounter++
+		}
+	}
+
+	log.Println("Games: " + strconv.Itoa(gameCounter) + ", positions: " + strconv.Itoa(positionCounter))
+}
+
+func epdLine(game *pgn.Game, position *chess.Position, nextMove *chess.Move, ply int) string {
+	fields := strings.Fields(position.String())
+
+	id := game.Get("Link")
+	if id == "" && strings.Contains(game.Get("Site"), "/") {
+		id = game.Get("Site")
+	}
+	if id == "" {
+		id = game.Get("White") + "-" + game.Get("Black") + " " + game.Get("Date")
+	}
+
+	opcodes := []string{
+		"id " + quote(id),
+		"c0 " + quote(game.Get("White")),
+		"c1 " + quote(game.Get("Black")),
+		"c2 " + quote(game.Get("Date")),
+		"c3 " + quote(game.Get("Result")),
+		"c4 " + quote(game.Get("ECO")),
+		"c5 " + quote(strconv.Itoa(ply)),
+	}
+	if nextMove != nil {
+		opcodes = append(opcodes, "sm "+chess.AlgebraicNotation{}.Encode(position, nextMove))
+	}
+	opcodes = append(opcodes, "hmvc "+fields[4], "fmvn "+fields[5])
+
+	return strings.Join(fields[0:4], " ") + " " + strings.Join(opcodes, "; ") + ";"
+}
+
+func quote(value string) string {
+	return "\"" + strings.ReplaceAll(value, "\"", "'") + "\""
+}
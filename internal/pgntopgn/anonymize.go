@@ -0,0 +1,173 @@
+package pgntopgn
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	log "github.com/sirupsen/logrus"
+)
+
+// tags that identify players or games
+var identifyingTags = []string{
+	"Link", "GameId", "Annotator", "WhiteTitle", "BlackTitle", "WhiteFideId", "BlackFideId",
+	"WhiteTeam", "BlackTeam", "WhiteUrl", "BlackUrl",
+}
+
+// tags holding ratings
+var ratingTags = []string{"WhiteElo", "BlackElo", "WhiteRatingDiff", "BlackRatingDiff"}
+
+// anonymizer ... strip or pseudonymize player names (in the tags and the comments), ratings and links
+type anonymizer struct {
+	mode        string // "strip" or "pseudonymize"
+	keepRatings bool
+	salt        []byte
+}
+
+func newAnonymizer(mode string, salt string, keepRatings bool) *anonymizer {
+	switch mode {
+	case "":
+		return nil
+	case "strip", "pseudonymize":
+	default:
+		log.Fatal("Unknown anonymize option: " + mode + " (expected strip or pseudonymize)")
+	}
+
+	anonymizer := anonymizer{mode: mode, keepRatings: keepRatings, salt: []byte(salt)}
+	if salt == "" {
+		// pseudonyms are consistent within this run only
+		anonymizer.salt = make([]byte, 32)
+		if _, err := rand.Read(anonymizer.salt); err != nil {
+			log.Fatal(err)
+		}
+	}
+	return &anonymizer
+}
+
+// pseudonym ... the same name always gives the same pseudonym (for a given salt)
+func (anonymizer *anonymizer) pseudonym(name string) string {
+	if anonymizer.mode == "strip" {
+		return "?"
+	}
+	mac := hmac.New(sha256.New, anonymizer.salt)
+	mac.Write([]byte(strings.ToLower(name)))
+	return "Player-" + hex.EncodeToString(mac.Sum(nil))[:10]
+}
+
+func (anonymizer *anonymizer) anonymize(game *pgn.Game) {
+	white := game.Get("White")
+	black := game.Get("Black")
+
+	for i := range game.Tags {
+		switch game.Tags[i].Key {
+		case "White", "Black":
+			if knownName(game.Tags[i].Value) {
+				game.Tags[i].Value = anonymizer.pseudonym(game.Tags[i].Value)
+			}
+		case "Site":
+			// https://lichess.org/abcd1234 gives lichess.org
+			site := strings.TrimPrefix(strings.TrimPrefix(game.Tags[i].Value, "https://"), "http://")
+			game.Tags[i].Value = strings.Split(site, "/")[0]
+		case "Termination":
+			// names can also appear there (chess.com: [Termination "Bob won by checkmate"])
+			for _, name := range []string{white, black} {
+				if knownName(name) {
+					game.Tags[i].Value = replaceWord(game.Tags[i].Value, name, anonymizer.pseudonym(name))
+				}
+			}
+		}
+	}
+
+	// comments too: {Bob missed Qxf7} or {https://lichess.org/study/abcd} (the names are replaced whatever their case)
+	game.Movetext = mapComments(game.Movetext, func(comment string) string {
+		comment = urlInComment.ReplaceAllStringFunc(comment, func(link string) string {
+			return strings.Split(strings.SplitN(link, "://", 2)[1], "/")[0]
+		})
+		for _, name := range []string{white, black} {
+			if knownName(name) {
+				comment = replaceWord(comment, name, anonymizer.pseudonym(name))
+			}
+		}
+		return comment
+	})
+
+	for _, key := range identifyingTags {
+		game.Delete(key)
+	}
+	if !anonymizer.keepRatings {
+		for _, key := range ratingTags {
+			game.Delete(key)
+		}
+	}
+}
+
+// knownName ... {name} is a player name, not the unknown marker of the PGN standard
+func knownName(name string) bool {
+	name = strings.TrimSpace(name)
+	return name != "" && name != "?" && name != "-"
+}
+
+var urlInComment = regexp.MustCompile(`https?://[^\s}]+`)
+
+// mapComments ... {movetext} with its comments ({...} and ; to the end of the line) replaced by {do}(comment)
+func mapComments(movetext string, do func(comment string) string) string {
+	var sb strings.Builder
+	for {
+		i := strings.IndexAny(movetext, "{;")
+		if i < 0 {
+			sb.WriteString(movetext)
+			return sb.String()
+		}
+		end := "}"
+		if movetext[i] == ';' {
+			end = "\n"
+		}
+		length := strings.Index(movetext[i+1:], end)
+		if length < 0 {
+			length = len(movetext) - i - 1
+		}
+		sb.WriteString(movetext[:i+1] + do(movetext[i+1:i+1+length]))
+		movetext = movetext[i+1+length:]
+	}
+}
+
+// replaceWord ... {text} with {word} replaced by {replacement} where it is a whole word (Bob, not Bobby), whatever its case
+func replaceWord(text string, word string, replacement string) string {
+	var sb strings.Builder
+	word = strings.ToLower(word)
+	for {
+		i := indexFold(text, word)
+		if i < 0 {
+			sb.WriteString(text)
+			return sb.String()
+		}
+		end := i + len(word)
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if (i == 0 || !isNameRune(before)) && (end == len(text) || !isNameRune(after)) {
+			sb.WriteString(text[:i] + replacement)
+		} else {
+			sb.WriteString(text[:end])
+		}
+		text = text[end:]
+	}
+}
+
+// indexFold ... index of {lowerWord} in {text} whatever the case of {text}, -1 when not found
+func indexFold(text string, lowerWord string) int {
+	if lower := strings.ToLower(text); len(lower) == len(text) {
+		return strings.Index(lower, lowerWord)
+	}
+	return strings.Index(text, lowerWord) // the lower case letters of text have another length: exact case only
+}
+
+// isNameRune ... a character of a username: letters, digits, - and _
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_'
+}
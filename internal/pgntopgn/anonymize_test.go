@@ -0,0 +1,46 @@
+package pgntopgn
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+)
+
+func TestAnonymize(t *testing.T) {
+	newGame := func() *pgn.Game {
+		return &pgn.Game{
+			Tags: []pgn.Tag{{Key: "White", Value: "Alice"}, {Key: "Black", Value: "Bob"}, {Key: "Site", Value: "https://lichess.org/abcd1234"},
+				{Key: "WhiteElo", Value: "1850"}, {Key: "Termination", Value: "Alice won by checkmate"}, {Key: "Link", Value: "https://lichess.org/abcd1234"}},
+			Movetext: "1. e4 { alice prepared this, see https://lichess.org/study/xyz } e5 2. Qh5 ; bob and Bobby\n2... Nc6 3. Bc4 Nf6 4. Qxf7# 1-0",
+		}
+	}
+
+	anonymizer := newAnonymizer("pseudonymize", "secret", false)
+	game := newGame()
+	anonymizer.anonymize(game)
+	alice, bob := anonymizer.pseudonym("Alice"), anonymizer.pseudonym("Bob")
+	if game.Get("White") != alice || game.Get("Black") != bob || alice == bob {
+		t.Errorf("players %s and %s, want %s and %s", game.Get("White"), game.Get("Black"), alice, bob)
+	}
+	if game.Get("Site") != "lichess.org" || game.Get("Link") != "" || game.Get("WhiteElo") != "" {
+		t.Errorf("site %q, link %q, elo %q: want lichess.org and no link or rating", game.Get("Site"), game.Get("Link"), game.Get("WhiteElo"))
+	}
+	if termination := game.Get("Termination"); termination != alice+" won by checkmate" {
+		t.Errorf("termination %q", termination)
+	}
+	want := "1. e4 { " + alice + " prepared this, see lichess.org } e5 2. Qh5 ; " + bob + " and Bobby\n2... Nc6 3. Bc4 Nf6 4. Qxf7# 1-0"
+	if game.Movetext != want {
+		t.Errorf("movetext\n%q, want\n%q", game.Movetext, want)
+	}
+
+	if again := anonymizer.pseudonym("alice"); again != alice {
+		t.Errorf("pseudonym of alice %s, want the one of Alice %s", again, alice)
+	}
+
+	game = newGame()
+	newAnonymizer("strip", "", true).anonymize(game)
+	if game.Get("White") != "?" || game.Get("WhiteElo") != "1850" || strings.Contains(strings.ToLower(game.Movetext), "alice") {
+		t.Errorf("strip: white %q, elo %q, movetext %q", game.Get("White"), game.Get("WhiteElo"), game.Movetext)
+	}
+}
@@ -0,0 +1,71 @@
+package pgntopgn
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/notnil/chess"
+	log "github.com/sirupsen/logrus"
+)
+
+// notation ... encoder for the --convert option
+func notation(name string) chess.Encoder {
+	switch name {
+	case "":
+		return nil
+	case "san":
+		return chess.AlgebraicNotation{}
+	case "lan":
+		return chess.LongAlgebraicNotation{}
+	case "uci":
+		return chess.UCINotation{}
+	default:
+		log.Fatal("Unknown notation: " + name + " (expected san, lan or uci)")
+	}
+	return nil
+}
+
+// convert ... replay the mainline and rewrite it with {encoder}
+// comments and variations are dropped
+func convert(game *pgn.Game, encoder chess.Encoder) error {
+	chessGame, err := pgn.NewChessGame(game)
+	if err != nil {
+		return err
+	}
+
+	// move number and side to move from the starting position
+	fields := strings.Fields(chessGame.Position().String())
+	moveNumber, _ := strconv.Atoi(fields[5])
+	whiteToMove := fields[1] == "w"
+
+	var sb strings.Builder
+	if !whiteToMove {
+		sb.WriteString(strconv.Itoa(moveNumber) + "... ")
+	}
+	for i, move := range pgn.Moves(game.Movetext) {
+		position := chessGame.Position()
+		m, err := pgn.DecodeMove(position, move)
+		if err == nil {
+			err = chessGame.Move(m)
+		}
+		if err != nil {
+			return &pgn.MoveError{Ply: i + 1, Move: move, FEN: position.String()}
+		}
+		if whiteToMove {
+			sb.WriteString(strconv.Itoa(moveNumber) + ". ")
+		} else {
+			moveNumber++
+		}
+		sb.WriteString(encoder.Encode(position, m) + " ")
+		whiteToMove = !whiteToMove
+	}
+
+	result := game.Get("Result")
+	if !pgn.IsResult(result) {
+		result = "*"
+	}
+	sb.WriteString(result)
+	game.Movetext = sb.String()
+	return nil
+}
@@ -0,0 +1,33 @@
+package pgntopgn
+
+import (
+	"testing"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		notation string
+		tags     []pgn.Tag
+		movetext string
+		want     string
+	}{
+		{"uci", nil, "1. e4 {best by test} e5 (1... c5) 2. Nf3 Nc6 3. O-O-O", ""}, // illegal castling: error
+		{"uci", []pgn.Tag{{Key: "Result", Value: "1-0"}}, "1. e4 {best by test} e5 (1... c5) 2. Nf3 Nc6 1-0", "1. e2e4 e7e5 2. g1f3 b8c6 1-0"},
+		{"lan", nil, "1. e4 e5 2. Nf3", "1. e2e4 e7e5 2. Ng1f3 *"},
+		{"san", []pgn.Tag{{Key: "FEN", Value: "4k3/8/8/8/8/8/4P3/4K3 b - - 0 30"}, {Key: "SetUp", Value: "1"}}, "30... Kd7 31. e2e4", "30... Kd7 31. e4 *"},
+	}
+	for _, test := range tests {
+		game := &pgn.Game{Tags: test.tags, Movetext: test.movetext}
+		err := convert(game, notation(test.notation))
+		switch {
+		case test.want == "" && err == nil:
+			t.Errorf("convert(%q) to %s = %q, want an error", test.movetext, test.notation, game.Movetext)
+		case test.want != "" && err != nil:
+			t.Errorf("convert(%q) to %s: %v", test.movetext, test.notation, err)
+		case test.want != "" && game.Movetext != test.want:
+			t.Errorf("convert(%q) to %s = %q, want %q", test.movetext, test.notation, game.Movetext, test.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package pgntopgn
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/flutterbar/chess-explorer-go/internal/pgnvalidate"
+	log "github.com/sirupsen/logrus"
+)
+
+// Seven Tag Roster, in the order required by the PGN standard
+var sevenTagRoster = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+// normalize ... fix common header problems so that ingestion and filtering behave consistently
+func normalize(game *pgn.Game) {
+	// UTF-8 cleanup (tags and movetext)
+	for i := range game.Tags {
+		game.Tags[i].Value = cleanText(game.Tags[i].Value)
+	}
+	game.Movetext = cleanMovetext(game.Movetext)
+
+	// Dates
+	for _, key := range []string{"Date", "UTCDate", "EventDate"} {
+		if value := game.Get(key); value != "" {
+			game.Set(key, normalizeDate(value))
+		}
+	}
+
+	// Sites
+	normalizeSite(game)
+
+	// Result: header and game termination marker must exist and agree
+	terminator := ""
+	moves := strings.Fields(game.Movetext)
+	if len(moves) > 0 && pgn.IsResult(moves[len(moves)-1]) {
+		terminator = moves[len(moves)-1]
+	}
+	result := game.Get("Result")
+	switch {
+	case !pgn.IsResult(result):
+		result = terminator
+		if result == "" {
+			result = "*"
+		}
+	case terminator != "" && terminator != result:
+		result = reconcileResult(game, result, terminator)
+	}
+	game.Set("Result", result)
+	if terminator != result {
+		movetext := strings.TrimSpace(game.Movetext)
+		game.Movetext = strings.TrimSpace(strings.TrimSpace(strings.TrimSuffix(movetext, terminator)) + " " + result)
+	}
+
+	// Seven Tag Roster first, with "?" when unknown
+	tags := make([]pgn.Tag, 0, len(game.Tags)+len(sevenTagRoster))
+	for _, key := range sevenTagRoster {
+		value := game.Get(key)
+		if value == "" {
+			value = "?"
+			if key == "Date" {
+				value = "????.??.??"
+			}
+		}
+		tags = append(tags, pgn.Tag{Key: key, Value: value})
+	}
+	for _, tag := range game.Tags {
+		if !isSevenTagRoster(tag.Key) && tag.Key != "" && tag.Value != "" {
+			tags = append(tags, tag)
+		}
+	}
+	game.Tags = tags
+}
+
+// reconcileResult ... the result of a game whose Result header {header} contradicts the termination marker {terminator}:
+// a decided result rather than *, the one allowed by the final position (checkmate, stalemate...), the header otherwise
+// (the result the sites write and the database imports)
+func reconcileResult(game *pgn.Game, header string, terminator string) string {
+	switch {
+	case header == "*":
+		return terminator
+	case terminator == "*":
+		return header
+	}
+	result := header
+	if chessGame, err := pgn.Replay(game); err == nil &&
+		pgnvalidate.ResultProblem(header, chessGame) != "" && pgnvalidate.ResultProblem(terminator, chessGame) == "" {
+		result = terminator
+	}
+	log.Warn("Game line " + strconv.Itoa(game.Line) + ": Result " + header + " and termination " + terminator + " disagree, " + result + " kept")
+	return result
+}
+
+// cleanText ... valid UTF-8 (invalid input is assumed to be Latin-1) without control characters or repeated spaces
+func cleanText(text string) string {
+	if !utf8.ValidString(text) {
+		runes := make([]rune, 0, len(text))
+		for i := 0; i < len(text); i++ {
+			runes = append(runes, rune(text[i]))
+		}
+		text = string(runes)
+	}
+	text = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\r' || r == '\n' {
+			return ' '
+		}
+		if r < ' ' || r == 0xFEFF {
+			return -1
+		}
+		return r
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// cleanMovetext ... cleanText of every line of a movetext, the line breaks kept: a ; comment ends with its line
+func cleanMovetext(movetext string) string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(movetext, "\n") {
+		if line = cleanText(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+var yearFirstDate = regexp.MustCompile(`^(\d{4}|\?{4})(?:[.\-/](\d{1,2}|\?{1,2})(?:[.\-/](\d{1,2}|\?{1,2}))?)?$`)
+var yearLastDate = regexp.MustCompile(`^(\d{1,2})[.\-/](\d{1,2})[.\-/](\d{4})$`)
+var compactDate = regexp.MustCompile(`^(\d{4})(\d{2})(\d{2})$`)
+
+// normalizeDate ... 2023-5-2, 2023/05/02, 02.05.2023, 20230502 give 2023.05.02 (unknown parts are ??)
+func normalizeDate(date string) string {
+	var year, month, day string
+	if parts := yearFirstDate.FindStringSubmatch(date); parts != nil {
+		year, month, day = parts[1], parts[2], parts[3]
+	} else if parts := compactDate.FindStringSubmatch(date); parts != nil {
+		year, month, day = parts[1], parts[2], parts[3]
+	} else if parts := yearLastDate.FindStringSubmatch(date); parts != nil {
+		year, month, day = parts[3], parts[2], parts[1] // day first (European order)
+	} else {
+		return date // leave what we do not understand
+	}
+	return year + "." + datePart(month) + "." + datePart(day)
+}
+
+func datePart(part string) string {
+	switch {
+	case part == "" || strings.HasPrefix(part, "?") || part == "0" || part == "00":
+		return "??"
+	case len(part) == 1:
+		return "0" + part
+	default:
+		return part
+	}
+}
+
+// normalizeSite ... one name per site (https://lichess.org/abcd1234 becomes lichess.org with a Link)
+func normalizeSite(game *pgn.Game) {
+	site := game.Get("Site")
+	lowerSite := strings.ToLower(site)
+	switch {
+	case strings.Contains(lowerSite, "lichess.org"):
+		if strings.Contains(lowerSite, "lichess.org/") && game.Get("Link") == "" {
+			game.Set("Link", site)
+		}
+		game.Set("Site", "lichess.org")
+	case strings.Contains(lowerSite, "chess.com"):
+		game.Set("Site", "Chess.com")
+	}
+}
+
+func isSevenTagRoster(key string) bool {
+	for _, str := range sevenTagRoster {
+		if key == str {
+			return true
+		}
+	}
+	return false
+}
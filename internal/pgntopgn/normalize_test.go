@@ -0,0 +1,69 @@
+package pgntopgn
+
+import (
+	"testing"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+)
+
+func TestNormalizeDate(t *testing.T) {
+	tests := []struct{ date, want string }{
+		{"2023-5-2", "2023.05.02"},
+		{"2023/05/02", "2023.05.02"},
+		{"02.05.2023", "2023.05.02"},
+		{"20230502", "2023.05.02"},
+		{"2023", "2023.??.??"},
+		{"2023.00.00", "2023.??.??"},
+		{"????.??.??", "????.??.??"},
+		{"yesterday", "yesterday"},
+	}
+	for _, test := range tests {
+		if got := normalizeDate(test.date); got != test.want {
+			t.Errorf("normalizeDate(%q) = %q, want %q", test.date, got, test.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	game := &pgn.Game{
+		Tags: []pgn.Tag{{Key: "ECO", Value: "C20"}, {Key: "White", Value: "Alice\t Smith"}, {Key: "Site", Value: "https://lichess.org/abcd1234"},
+			{Key: "Date", Value: "2023-5-2"}, {Key: "Black", Value: "Caf\xe9"}, {Key: "Result", Value: "?"}},
+		Movetext: "1. e4 e5  2. Qh5 Nc6 3. Bc4 Nf6 4. Qxf7# 1-0",
+	}
+	normalize(game)
+
+	want := []pgn.Tag{{Key: "Event", Value: "?"}, {Key: "Site", Value: "lichess.org"}, {Key: "Date", Value: "2023.05.02"},
+		{Key: "Round", Value: "?"}, {Key: "White", Value: "Alice Smith"}, {Key: "Black", Value: "Café"}, {Key: "Result", Value: "1-0"},
+		{Key: "ECO", Value: "C20"}, {Key: "Link", Value: "https://lichess.org/abcd1234"}}
+	if len(game.Tags) != len(want) {
+		t.Fatalf("tags %v, want %v", game.Tags, want)
+	}
+	for i := range want {
+		if game.Tags[i] != want[i] {
+			t.Errorf("tag %d: %v, want %v", i, game.Tags[i], want[i])
+		}
+	}
+	if game.Movetext != "1. e4 e5 2. Qh5 Nc6 3. Bc4 Nf6 4. Qxf7# 1-0" {
+		t.Errorf("movetext %q", game.Movetext)
+	}
+}
+
+func TestNormalizeResult(t *testing.T) {
+	tests := []struct {
+		result, movetext string
+		want             string // result header and termination marker
+	}{
+		{"1-0", "1. e4 e5", "1-0"},                                    // termination marker added
+		{"*", "1. e4 e5 0-1", "0-1"},                                  // a decided result rather than *
+		{"0-1", "1. e4 e5 2. Qh5 Nc6 3. Bc4 Nf6 4. Qxf7# 1-0", "1-0"}, // checkmate decides
+		{"1/2-1/2", "1. e4 e5 1-0", "1/2-1/2"},                        // the header otherwise
+	}
+	for _, test := range tests {
+		game := &pgn.Game{Tags: []pgn.Tag{{Key: "Result", Value: test.result}}, Movetext: test.movetext}
+		normalize(game)
+		if result, moves := game.Get("Result"), pgn.Moves(game.Movetext); result != test.want ||
+			game.Movetext[len(game.Movetext)-len(test.want):] != test.want {
+			t.Errorf("Result %s, %s: result %s, movetext %q (%d moves), want %s", test.result, test.movetext, result, game.Movetext, len(moves), test.want)
+		}
+	}
+}
@@ -2,22 +2,128 @@ package pgntopgn
 
 import (
 	"bufio"
-	"log"
+	"crypto/sha1"
+	"io"
+	"io/ioutil"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/notnil/chess"
+	log "github.com/sirupsen/logrus"
 )
 
 /*
 PGN parse is very similar to pgntodb package
 */
 
-// Process ... process a single file or all the files of a folder
-func Process(filepath string) {
-	processFile(filepath)
+// Options ... what to do with the games
+type Options struct {
+	Output    string // file where games are written (nothing written if empty)
+	Split     string // "", "month", "player" or "opening"
+	Dedupe    bool   // skip games already seen (same players, date and moves)
+	Normalize bool   // fix common header problems (dates, result, site names, UTF-8)
+	Convert   string // "", "san", "lan" or "uci": rewrite moves in this notation
+	Sample    int    // keep only this number of randomly chosen games (0: keep all)
+	Seed      int64  // random seed for Sample (0: a new sample every time)
+	Sort      bool   // sort games by date (oldest first)
+	Strip     string // what to remove from the movetext: comments, variations, nags, clocks or all (comma separated)
+
+	Anonymize     string // "", "strip" or "pseudonymize": player names, ratings and links
+	AnonymizeSalt string // same salt, same pseudonyms (random if empty)
+	KeepRatings   bool   // do not remove ratings when anonymizing
+}
+
+// processor ... state shared by all the input files
+type processor struct {
+	options  Options
+	output   *output
+	notation chess.Encoder            // conversion
+	sample   *reservoir               // random sample
+	pending  []*pgn.Game              // games kept in memory until the end (sort)
+	anonym   *anonymizer              // anonymization
+	strip    *stripOptions            // movetext cleanup
+	seen     map[[sha1.Size]byte]bool // games already written (dedupe)
+	skipped  int                      // duplicates
+	failed   int                      // games not converted, skipped: the output is in one notation
+}
+
+// Process ... process single files or all the files of folders
+func Process(filepaths []string, options Options) {
+	processor := processor{
+		options:  options,
+		output:   newOutput(options),
+		notation: notation(options.Convert),
+		strip:    newStripOptions(options.Strip),
+		anonym:   newAnonymizer(options.Anonymize, options.AnonymizeSalt, options.KeepRatings),
+		seen:     make(map[[sha1.Size]byte]bool),
+	}
+	if options.Sample > 0 {
+		seed := options.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		processor.sample = newReservoir(options.Sample, seed)
+	}
+
+	for _, filepath := range filepaths {
+		info, err := os.Stat(filepath)
+		if os.IsNotExist(err) {
+			log.Fatal("Cannot access " + filepath)
+		}
+
+		if info.IsDir() {
+			fileinfos, err := ioutil.ReadDir(filepath)
+			if err != nil {
+				log.Fatal("Cannot list files in " + filepath)
+			}
+			for _, info := range fileinfos {
+				if !info.IsDir() {
+					log.Println(path.Join(filepath, info.Name()))
+					processor.processFile(path.Join(filepath, info.Name()))
+				}
+			}
+		} else {
+			processor.processFile(filepath)
+		}
+	}
+
+	if options.Dedupe {
+		log.Println("Duplicates skipped: " + strconv.Itoa(processor.skipped))
+	}
+	if processor.notation != nil {
+		log.Println("Games not converted, skipped: " + strconv.Itoa(processor.failed))
+	}
+
+	// games kept in memory are written at the end
+	games := processor.pending
+	if processor.sample != nil {
+		games = processor.sample.sample()
+		log.Println("Sampled " + strconv.Itoa(len(games)) + " games out of " + strconv.Itoa(processor.sample.seen))
+	}
+	if options.Sort {
+		sort.SliceStable(games, func(i, j int) bool {
+			return dateKey(games[i]) < dateKey(games[j])
+		})
+	}
+	for _, game := range games {
+		if err := processor.output.write(game); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := processor.output.close(); err != nil {
+		log.Fatal(err)
+	}
 }
 
-func processFile(filepath string) {
+func (processor *processor) processFile(filepath string) {
 	// Open file
 	file, err := os.Open(filepath)
 	defer file.Close()
@@ -27,45 +133,70 @@ func processFile(filepath string) {
 	}
 
 	// Scan file
-	scanner := bufio.NewScanner(file)
+	reader := pgn.NewReader(file)
 
 	gameCounter := 0
 	elo1200to1300 := 0
-	inGame := false
-	keyValues := make(map[string]string)
-	for i := 1; scanner.Scan(); i++ {
-		line := scanner.Text()
-		line = strings.Trim(line, " ")
-		if len(line) == 0 {
-			continue
+	for {
+		game, err := reader.Next()
+		if err == io.EOF {
+			break
 		}
-		switch line[0] {
-		case '[':
-			if !inGame {
-				inGame = true
-			}
-			key, value := parseKeyValue(line)
-			if key != "" && value != "" {
-				keyValues[key] = value
-			}
-		case '0':
-		case '1':
-			// If game was abandoned, pgn will be 0-1 or 1-0 (skip it)
-			if line != "0-1" && line != "1-0" {
-				keyValues["PGN"] = stripPgn(line)
-			}
-			gameCounter++
-			if gameCounter%10000 == 0 {
-				log.Println("Scanned " + strconv.Itoa(gameCounter))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		gameCounter++
+		if gameCounter%10000 == 0 {
+			log.Println("Scanned " + strconv.Itoa(gameCounter))
+		}
+		whiteElo, _ := strconv.Atoi(game.Get("WhiteElo"))
+		blackElo, _ := strconv.Atoi(game.Get("BlackElo"))
+		if whiteElo >= 1200 && whiteElo < 1300 && blackElo >= 1200 && blackElo < 1300 {
+			elo1200to1300++
+		}
+
+		if processor.options.Normalize {
+			normalize(game)
+		}
+
+		if processor.strip != nil {
+			game.Movetext = strip(game.Movetext, processor.strip)
+		}
+
+		if processor.notation != nil {
+			if err = convert(game, processor.notation); err != nil {
+				log.Warn("Game line " + strconv.Itoa(game.Line) + " not converted, skipped: " + err.Error())
+				processor.failed++
+				continue
 			}
-			whiteElo, _ := strconv.Atoi(keyValues["WhiteElo"])
-			blackElo, _ := strconv.Atoi(keyValues["BlackElo"])
-			if whiteElo >= 1200 && whiteElo < 1300 && blackElo >= 1200 && blackElo < 1300 {
-				elo1200to1300++
+		}
+
+		if processor.options.Dedupe {
+			key := dedupeKey(game)
+			if processor.seen[key] {
+				processor.skipped++
+				continue
 			}
-			keyValues = make(map[string]string) // for next game
-		default:
-			// not a valid char, skip
+			processor.seen[key] = true
+		}
+
+		if processor.anonym != nil {
+			processor.anonym.anonymize(game)
+		}
+
+		if processor.sample != nil {
+			processor.sample.add(game)
+			continue
+		}
+
+		if processor.options.Sort {
+			processor.pending = append(processor.pending, game)
+			continue
+		}
+
+		if err = processor.output.write(game); err != nil {
+			log.Fatal(err)
 		}
 	}
 
@@ -74,45 +205,167 @@ func processFile(filepath string) {
 
 }
 
-// [Key "value"]
-func parseKeyValue(line string) (key string, value string) {
-	line = strings.Trim(line, "[] ")
-	if len(line) == 0 {
-		return "", ""
+// dateKey ... 2023.05.12 10:00:00 (unknown dates are sorted last as ? comes after digits)
+func dateKey(game *pgn.Game) string {
+	if date := game.Get("UTCDate"); date != "" {
+		return date + " " + game.Get("UTCTime")
 	}
-	split := strings.Split(line, " ")
-	if len(split) == 0 {
-		return "", ""
+	date := game.Get("Date")
+	if date == "" {
+		date = "????.??.??"
+	}
+	return date + " " + game.Get("Time")
+}
+
+// dedupeKey ... same players, same date and same moves make a duplicate
+func dedupeKey(game *pgn.Game) [sha1.Size]byte {
+	date := game.Get("UTCDate") + " " + game.Get("UTCTime")
+	if strings.TrimSpace(date) == "" {
+		date = game.Get("Date") + " " + game.Get("Time")
 	}
-	key = split[0]
+	key := strings.ToLower(game.Get("White")) + "\n" +
+		strings.ToLower(game.Get("Black")) + "\n" +
+		date + "\n" +
+		strings.Join(pgn.Moves(game.Movetext), " ")
+	return sha1.Sum([]byte(key))
+}
+
+// maximum number of files kept open when splitting (by player, there can be thousands)
+const maxOpenFiles = 200
+
+// output ... where games go, possibly split in several files
+type output struct {
+	options Options
+	files   map[string]*os.File
+	writers map[string]*bufio.Writer
+	created map[string]bool // files already created during this run (append to them)
+}
 
-	quotedValue := strings.Join(split[1:], " ")
-	value = strings.Trim(quotedValue, "\" ")
+func newOutput(options Options) *output {
+	switch options.Split {
+	case "", "month", "player", "opening":
+	default:
+		log.Fatal("Unknown split option: " + options.Split + " (expected month, player or opening)")
+	}
+	if options.Split != "" && options.Output == "" {
+		log.Fatal("Splitting requires an output file name")
+	}
+	return &output{
+		options: options,
+		files:   make(map[string]*os.File),
+		writers: make(map[string]*bufio.Writer),
+		created: make(map[string]bool),
+	}
+}
 
-	return key, value
+func (output *output) write(game *pgn.Game) error {
+	if output.options.Output == "" {
+		return nil
+	}
+	for _, fileName := range output.fileNames(game) {
+		writer, err := output.writer(fileName)
+		if err != nil {
+			return err
+		}
+		if err = pgn.Write(writer, game); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// lichess: 1. d4 Nf6 2. e3 d5
-// chess.com: 1. d4 {[%clk 0:29:56.7]} 1... d5 {[%clk 0:29:52.9]} 2. Bf4 {[%clk 0:29:52.9]} 2... Nf6 {[%clk 0:29:24.1]}
-func stripPgn(line string) (pgn string) {
-	split := strings.Split(line, " ")
-	i := 0 // output index
-	skip := false
-	for _, bit := range split {
-		if strings.HasPrefix(bit, "{") {
-			skip = true
-		}
-		if skip == false && !strings.HasSuffix(bit, "...") {
-			// copy and increment index
-			bit = strings.Replace(bit, "!", "", -1)
-			bit = strings.Replace(bit, "?", "", -1)
-			split[i] = bit
-			i++
-		}
-		if strings.HasSuffix(bit, "}") {
-			skip = false
-		}
-	}
-	pgn = strings.Join(split[:i], " ")
-	return pgn
+// fileNames ... files where {game} goes
+// games.pgn split by month gives games-2023-05.pgn
+func (output *output) fileNames(game *pgn.Game) []string {
+	var suffixes []string
+	switch output.options.Split {
+	case "month":
+		suffixes = append(suffixes, month(game))
+	case "player":
+		suffixes = append(suffixes, game.Get("White"), game.Get("Black"))
+	case "opening":
+		suffixes = append(suffixes, game.Get("ECO"))
+	default:
+		return []string{output.options.Output}
+	}
+
+	extension := filepath.Ext(output.options.Output)
+	base := strings.TrimSuffix(output.options.Output, extension)
+	if extension == "" {
+		extension = ".pgn"
+	}
+
+	fileNames := make([]string, 0, len(suffixes))
+	for _, suffix := range suffixes {
+		fileNames = append(fileNames, base+"-"+sanitize(suffix)+extension)
+	}
+	return fileNames
+}
+
+func (output *output) writer(fileName string) (*bufio.Writer, error) {
+	if writer, ok := output.writers[fileName]; ok {
+		return writer, nil
+	}
+
+	if len(output.files) >= maxOpenFiles {
+		if err := output.close(); err != nil {
+			return nil, err
+		}
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if output.created[fileName] {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(fileName, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if !output.created[fileName] {
+		log.Println("Writing " + fileName)
+	}
+	output.created[fileName] = true
+	output.files[fileName] = file
+	output.writers[fileName] = bufio.NewWriter(file)
+	return output.writers[fileName], nil
+}
+
+// close ... flush and close all open files
+func (output *output) close() error {
+	var ret error
+	for fileName, file := range output.files {
+		if err := output.writers[fileName].Flush(); err != nil && ret == nil {
+			ret = err
+		}
+		if err := file.Close(); err != nil && ret == nil {
+			ret = err
+		}
+	}
+	output.files = make(map[string]*os.File)
+	output.writers = make(map[string]*bufio.Writer)
+	return ret
+}
+
+// 2023.05.12 gives 2023-05
+func month(game *pgn.Game) string {
+	date := game.Get("UTCDate")
+	if date == "" {
+		date = game.Get("Date")
+	}
+	parts := strings.Split(date, ".")
+	if len(parts) < 2 || strings.Contains(parts[0]+parts[1], "?") {
+		return ""
+	}
+	return parts[0] + "-" + parts[1]
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+func sanitize(name string) string {
+	name = unsafeChars.ReplaceAllString(strings.TrimSpace(name), "_")
+	name = strings.Trim(name, "._")
+	if name == "" || name == "-" {
+		return "unknown"
+	}
+	return name
 }
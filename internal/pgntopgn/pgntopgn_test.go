@@ -0,0 +1,77 @@
+package pgntopgn
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+)
+
+func TestProcessDedupe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pgntopgn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	games := `[White "Alice"]
+[Black "Bob"]
+[Date "2023.05.02"]
+
+1. e4 e5 2. Nf3 1-0
+
+[White "alice"]
+[Black "BOB"]
+[Date "2023.05.02"]
+
+1. e4 {the same game, another case and a comment} e5 2. Nf3 1-0
+
+[White "Alice"]
+[Black "Bob"]
+[Date "2023.05.03"]
+
+1. e4 e5 2. Nf3 1-0
+
+[White "Alice"]
+[Black "Bob"]
+[Date "2023.05.02"]
+
+1. d4 d5 1-0
+`
+	input := filepath.Join(dir, "games.pgn")
+	if err = ioutil.WriteFile(input, []byte(games), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "deduped.pgn")
+	Process([]string{input}, Options{Output: output, Dedupe: true})
+
+	file, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader := pgn.NewReader(file)
+	var dates []string
+	for {
+		game, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		dates = append(dates, game.Get("Date")+" "+game.Movetext)
+	}
+	want := []string{"2023.05.02 1. e4 e5 2. Nf3 1-0", "2023.05.03 1. e4 e5 2. Nf3 1-0", "2023.05.02 1. d4 d5 1-0"}
+	if len(dates) != len(want) {
+		t.Fatalf("games written %q, want %q", dates, want)
+	}
+	for i := range want {
+		if dates[i] != want[i] {
+			t.Errorf("game %d: %q, want %q", i, dates[i], want[i])
+		}
+	}
+}
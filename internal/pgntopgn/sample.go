@@ -0,0 +1,52 @@
+package pgntopgn
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+)
+
+// reservoir ... uniform random sample of a stream of games (algorithm R)
+type reservoir struct {
+	size   int
+	seen   int
+	games  []*pgn.Game
+	order  []int // position of each kept game in the input (to write them in input order)
+	random *rand.Rand
+}
+
+func newReservoir(size int, seed int64) *reservoir {
+	return &reservoir{
+		size:   size,
+		games:  make([]*pgn.Game, 0, size),
+		order:  make([]int, 0, size),
+		random: rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (reservoir *reservoir) add(game *pgn.Game) {
+	reservoir.seen++
+	if len(reservoir.games) < reservoir.size {
+		reservoir.games = append(reservoir.games, game)
+		reservoir.order = append(reservoir.order, reservoir.seen)
+		return
+	}
+	if i := reservoir.random.Intn(reservoir.seen); i < reservoir.size {
+		reservoir.games[i] = game
+		reservoir.order[i] = reservoir.seen
+	}
+}
+
+// sample ... kept games in input order
+func (reservoir *reservoir) sample() []*pgn.Game {
+	sort.Sort(reservoir)
+	return reservoir.games
+}
+
+func (reservoir *reservoir) Len() int           { return len(reservoir.games) }
+func (reservoir *reservoir) Less(i, j int) bool { return reservoir.order[i] < reservoir.order[j] }
+func (reservoir *reservoir) Swap(i, j int) {
+	reservoir.games[i], reservoir.games[j] = reservoir.games[j], reservoir.games[i]
+	reservoir.order[i], reservoir.order[j] = reservoir.order[j], reservoir.order[i]
+}
@@ -0,0 +1,153 @@
+package pgntopgn
+
+import (
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// what can be stripped from the movetext
+type stripOptions struct {
+	comments   bool
+	variations bool
+	nags       bool
+	clocks     bool // [%clk 0:09:58] in comments
+}
+
+func newStripOptions(strip string) *stripOptions {
+	if strip == "" {
+		return nil
+	}
+	options := stripOptions{}
+	for _, item := range strings.Split(strip, ",") {
+		switch strings.TrimSpace(item) {
+		case "all":
+			options = stripOptions{comments: true, variations: true, nags: true, clocks: true}
+		case "comments":
+			options.comments = true
+		case "variations":
+			options.variations = true
+		case "nags":
+			options.nags = true
+		case "clocks":
+			options.clocks = true
+		default:
+			log.Fatal("Unknown strip option: " + item + " (expected comments, variations, nags, clocks or all)")
+		}
+	}
+	return &options
+}
+
+var clockCommand = regexp.MustCompile(`\[%(clk|emt)\s[^\]]*\]`)
+var blackMoveNumber = regexp.MustCompile(`^\d+\.\.\.$`)
+
+// strip ... remove comments, variations, NAGs and/or clocks from a movetext
+func strip(movetext string, options *stripOptions) string {
+	items := make([]string, 0)
+	afterBreak := true // a black move number is needed after a comment or a variation
+	var token strings.Builder
+
+	emit := func(item string, isBreak bool) {
+		if item == "" {
+			return
+		}
+		if blackMoveNumber.MatchString(item) && !afterBreak {
+			return
+		}
+		items = append(items, item)
+		afterBreak = isBreak
+	}
+	flushToken := func() {
+		item := token.String()
+		token.Reset()
+		if options.nags {
+			if strings.HasPrefix(item, "$") {
+				return
+			}
+			if trimmed := strings.TrimRight(item, "!?"); trimmed != "" {
+				item = trimmed
+			}
+		}
+		emit(item, false)
+	}
+
+	runes := []rune(movetext)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '{':
+			flushToken()
+			end := i + 1
+			for end < len(runes) && runes[end] != '}' {
+				end++
+			}
+			comment := string(runes[i+1 : min(end, len(runes))])
+			i = end
+			if options.comments {
+				continue
+			}
+			if options.clocks {
+				comment = clockCommand.ReplaceAllString(comment, "")
+			}
+			if comment = strings.Join(strings.Fields(comment), " "); comment != "" {
+				emit("{ "+comment+" }", true)
+			}
+		case '(':
+			flushToken()
+			depth, end := 1, i+1
+			for end < len(runes) && depth > 0 {
+				if runes[end] == '(' {
+					depth++
+				} else if runes[end] == ')' {
+					depth--
+				}
+				end++
+			}
+			variation := string(runes[i+1 : max(end-1, i+1)])
+			i = end - 1
+			if options.variations {
+				continue
+			}
+			emit("("+strings.TrimSpace(strip(variation, options))+")", true)
+		case ';':
+			// rest of line comment: written as a brace comment, the items are joined on one line
+			flushToken()
+			end := i + 1
+			for end < len(runes) && runes[end] != '\n' {
+				end++
+			}
+			comment := string(runes[i+1 : end])
+			i = end
+			if options.comments {
+				continue
+			}
+			if options.clocks {
+				comment = clockCommand.ReplaceAllString(comment, "")
+			}
+			if comment = strings.Join(strings.Fields(strings.ReplaceAll(comment, "}", "")), " "); comment != "" {
+				emit("{ "+comment+" }", true)
+			}
+		case ' ', '\t', '\n', '\r':
+			flushToken()
+		default:
+			token.WriteRune(c)
+		}
+	}
+	flushToken()
+
+	return strings.Join(items, " ")
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,19 @@
+package pgntopgn
+
+import "testing"
+
+func TestStrip(t *testing.T) {
+	movetext := "1. e4 { [%clk 0:09:58] good } e5 $1 (1... c5 2. Nf3) 2. Nf3 ; attack\n2... Nc6 1-0" // the ; comment is written as a { } one
+	tests := []struct{ strip, want string }{
+		{"comments", "1. e4 e5 $1 (1... c5 2. Nf3) 2. Nf3 Nc6 1-0"},
+		{"variations", "1. e4 { [%clk 0:09:58] good } e5 $1 2. Nf3 { attack } 2... Nc6 1-0"},
+		{"nags", "1. e4 { [%clk 0:09:58] good } e5 (1... c5 2. Nf3) 2. Nf3 { attack } 2... Nc6 1-0"},
+		{"clocks", "1. e4 { good } e5 $1 (1... c5 2. Nf3) 2. Nf3 { attack } 2... Nc6 1-0"},
+		{"all", "1. e4 e5 2. Nf3 Nc6 1-0"},
+	}
+	for _, test := range tests {
+		if got := strip(movetext, newStripOptions(test.strip)); got != test.want {
+			t.Errorf("strip %s:\n%q, want\n%q", test.strip, got, test.want)
+		}
+	}
+}
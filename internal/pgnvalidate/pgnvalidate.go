@@ -0,0 +1,115 @@
+package pgnvalidate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	log "github.com/sirupsen/logrus"
+)
+
+// Problem ... something wrong in a game
+type Problem struct {
+	Line    int
+	Message string
+}
+
+// Process ... replay every game of {filepath}, report problems and optionally write valid games to {output}
+func Process(filepath string, output string) {
+	// Open file
+	file, err := os.Open(filepath)
+	defer file.Close()
+
+	if err != nil {
+		log.Fatal("Cannot open file " + filepath)
+	}
+
+	var writer *bufio.Writer
+	if output != "" {
+		outFile, err := os.Create(output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer outFile.Close()
+		writer = bufio.NewWriter(outFile)
+		defer writer.Flush()
+	}
+
+	reader := pgn.NewReader(file)
+	gameCounter, invalidCounter := 0, 0
+	for {
+		game, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		gameCounter++
+
+		problems := Validate(game)
+		for _, problem := range problems {
+			fmt.Println(filepath + ":" + strconv.Itoa(problem.Line) + ": " + problem.Message)
+		}
+		if len(problems) > 0 {
+			invalidCounter++
+			continue
+		}
+
+		if writer != nil {
+			if err = pgn.Write(writer, game); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	log.Println("Games: " + strconv.Itoa(gameCounter) + ", invalid: " + strconv.Itoa(invalidCounter))
+}
+
+// Validate ... problems found in {game} (none if valid)
+func Validate(game *pgn.Game) []Problem {
+	problems := make([]Problem, 0)
+	moveLine := game.MoveLine
+	if moveLine == 0 {
+		moveLine = game.Line
+	}
+
+	// Truncated game
+	tokens := strings.Fields(game.Movetext)
+	if len(tokens) == 0 {
+		problems = append(problems, Problem{Line: game.Line, Message: "truncated game: no movetext"})
+		return problems
+	}
+	terminator := tokens[len(tokens)-1]
+	if !pgn.IsResult(terminator) {
+		problems = append(problems, Problem{Line: moveLine, Message: "truncated game: movetext does not end with a result"})
+		terminator = ""
+	}
+
+	// Result header
+	result := game.Get("Result")
+	switch {
+	case result == "":
+		problems = append(problems, Problem{Line: game.Line, Message: "missing Result header"})
+	case !pgn.IsResult(result):
+		problems = append(problems, Problem{Line: game.Line, Message: "invalid Result header " + result})
+	case terminator != "" && result != terminator:
+		problems = append(problems, Problem{Line: moveLine, Message: "Result header " + result + " does not match game termination " + terminator})
+	}
+
+	// Moves, and the result of the final position
+	chessGame, err := pgn.Replay(game)
+	if err != nil {
+		problems = append(problems, Problem{Line: moveLine, Message: err.Error()})
+	} else if pgn.IsResult(result) {
+		if problem := ResultProblem(result, chessGame); problem != "" {
+			problems = append(problems, Problem{Line: moveLine, Message: problem})
+		}
+	}
+
+	return problems
+}
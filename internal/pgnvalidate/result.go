@@ -0,0 +1,72 @@
+package pgnvalidate
+
+import (
+	"context"
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/flutterbar/chess-explorer-go/internal/server"
+	"github.com/notnil/chess"
+)
+
+// ResultProblem ... why {result} cannot end a game with the final position of {chessGame} ("" when it can)
+// only the endings decided by the rules are checked: resignations, time and agreements can give any result
+func ResultProblem(result string, chessGame *chess.Game) string {
+	if result == "*" {
+		return "" // unfinished
+	}
+	switch chessGame.Method() {
+	case chess.Checkmate:
+		expected, winner := "1-0", "white"
+		if chessGame.Position().Turn() == chess.White {
+			expected, winner = "0-1", "black"
+		}
+		if result != expected {
+			return "result " + result + " but " + winner + " checkmated (" + expected + ")"
+		}
+	case chess.Stalemate:
+		if result != "1/2-1/2" {
+			return "result " + result + " but the final position is a stalemate (1/2-1/2)"
+		}
+	case chess.InsufficientMaterial:
+		if result != "1/2-1/2" {
+			return "result " + result + " but neither side can checkmate in the final position (1/2-1/2)"
+		}
+	case chess.FivefoldRepetition:
+		if result != "1/2-1/2" {
+			return "result " + result + " but the final position was repeated five times (1/2-1/2)"
+		}
+	case chess.SeventyFiveMoveRule:
+		if result != "1/2-1/2" {
+			return "result " + result + " but 75 moves were played without capture or pawn move (1/2-1/2)"
+		}
+	}
+	return ""
+}
+
+// GameProblems ... problems of a game of the database
+type GameProblems struct {
+	ID       string   `json:"id"`
+	Link     string   `json:"link,omitempty"`
+	Result   string   `json:"result"`
+	Problems []string `json:"problems"`
+}
+
+// Database ... problems of the games of the database matching {filter}, number of games checked
+func Database(filter *server.GameFilter) ([]GameProblems, int, error) {
+	ret := make([]GameProblems, 0)
+	count, err := server.EachGame(context.Background(), filter, func(game *pgntodb.Game) error {
+		pgnGame := pgn.Game{Movetext: game.PGN}
+		pgnGame.Set("Result", game.Result)
+		problems := Validate(&pgnGame)
+		if len(problems) == 0 {
+			return nil
+		}
+		gameProblems := GameProblems{ID: game.ID, Link: game.Link, Result: game.Result}
+		for _, problem := range problems {
+			gameProblems.Problems = append(gameProblems.Problems, problem.Message)
+		}
+		ret = append(ret, gameProblems)
+		return nil
+	})
+	return ret, count, err
+}
@@ -0,0 +1,159 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/analyze"
+	"github.com/flutterbar/chess-explorer-go/internal/engine"
+	"github.com/flutterbar/chess-explorer-go/internal/evals"
+	"github.com/flutterbar/chess-explorer-go/internal/precompute"
+	"github.com/flutterbar/chess-explorer-go/internal/sync"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+/*
+Nightly pipeline: synchronization of the users, engine analysis of the new games, then the reports computed in advance,
+one step after the other under one lock (a second run started meanwhile stops at once), with one summary.
+*/
+
+// ErrLocked ... another pipeline is running
+var ErrLocked = errors.New("another pipeline is running")
+
+// Options ... how to run the pipeline
+type Options struct {
+	Lock        string        // lock file ("": in the temporary directory, one per database)
+	LockTimeout time.Duration // a lock older than this is left by a crashed run and taken over
+	MaxAnalyze  int64         // new games analysed at most (0: all)
+	NoAnalyze   bool
+	NoReports   bool
+}
+
+// Summary ... what the pipeline did
+type Summary struct {
+	Started  time.Time `json:"started"`
+	Duration string    `json:"duration"`
+	Sync     struct {
+		Users    int `json:"users"`
+		Inserted int `json:"inserted"`
+		Failed   int `json:"failed"`
+	} `json:"sync"`
+	Analysis *analyze.Result    `json:"analysis,omitempty"` // nil when skipped
+	Reports  *precompute.Result `json:"reports,omitempty"`  // nil when skipped
+	Errors   []string           `json:"errors,omitempty"`   // of the steps (a failed step does not stop the next ones)
+}
+
+// String ... one line summary
+func (summary Summary) String() string {
+	parts := []string{fmt.Sprintf("%d games imported for %d users", summary.Sync.Inserted, summary.Sync.Users)}
+	if summary.Analysis != nil {
+		parts = append(parts, fmt.Sprintf("%d games analysed (%d positions already evaluated)", summary.Analysis.Games, summary.Analysis.Cached))
+	}
+	if summary.Reports != nil {
+		parts = append(parts, fmt.Sprintf("%d reports computed", summary.Reports.Reports))
+	}
+	return strings.Join(parts, ", ") + " in " + summary.Duration
+}
+
+// Run ... synchronize, analyse the new games and compute the reports, the errors of the steps are in the summary
+// (and the first one is returned)
+func Run(ctx context.Context, options Options) (Summary, error) {
+	summary := Summary{Started: time.Now().UTC()}
+	unlock, err := lock(lockPath(options.Lock), options.LockTimeout)
+	if err != nil {
+		return summary, err
+	}
+	defer unlock()
+
+	var firstErr error
+	fail := func(step string, err error) {
+		log.Error(step + ": " + err.Error())
+		summary.Errors = append(summary.Errors, step+": "+err.Error())
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	// 1. synchronization
+	log.Info("Pipeline: synchronization")
+	results, err := sync.All(context.Background())
+	for _, result := range results {
+		summary.Sync.Users++
+		summary.Sync.Inserted += result.Inserted
+		if result.Error != "" {
+			summary.Sync.Failed++
+		}
+	}
+	if err != nil {
+		fail("sync", err)
+	}
+
+	// 2. engine analysis of the games imported by this run
+	settings := engine.SettingsFromConfig()
+	source, err := evals.Source()
+	switch {
+	case options.NoAnalyze:
+	case err != nil:
+		fail("analysis", err)
+	case source == evals.SourceEngine && settings.Path == "":
+		log.Info("Pipeline: no engine-path, analysis skipped")
+	default:
+		log.Info("Pipeline: analysis of the new games")
+		result, err := analyze.NewGames(ctx, summary.Started, options.MaxAnalyze, settings)
+		summary.Analysis = &result
+		if err != nil {
+			fail("analysis", err)
+		}
+	}
+
+	// 3. reports computed in advance
+	if !options.NoReports {
+		log.Info("Pipeline: reports")
+		result, err := precompute.Run(ctx)
+		summary.Reports = &result
+		if err != nil {
+			fail("reports", err)
+		}
+	}
+
+	summary.Duration = time.Since(summary.Started).Round(time.Second).String()
+	return summary, firstErr
+}
+
+// lockPath ... {path}, or the lock file of the database in the temporary directory
+func lockPath(path string) string {
+	if path != "" {
+		return path
+	}
+	return filepath.Join(os.TempDir(), "chess-explorer-pipeline-"+viper.GetString("mongo-db-name")+".lock")
+}
+
+// lock ... create the lock file {path} (with the process id), ErrLocked when it exists and is more recent than {timeout}
+func lock(path string, timeout time.Duration) (func(), error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if errors.Is(err, os.ErrExist) {
+		info, statErr := os.Stat(path)
+		if statErr != nil || timeout <= 0 || time.Since(info.ModTime()) < timeout {
+			return nil, fmt.Errorf("%w (lock file %s)", ErrLocked, path)
+		}
+		log.Warn("Taking over the lock file " + path + ", left by a run started " + info.ModTime().Format(time.RFC3339))
+		os.Remove(path)
+		file, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	}
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("%w (lock file %s)", ErrLocked, path)
+		}
+		return nil, err
+	}
+	file.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+	file.Close()
+	return func() { os.Remove(path) }, nil
+}
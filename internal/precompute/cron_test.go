@@ -0,0 +1,91 @@
+package precompute
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		expression string
+		valid      bool
+	}{
+		{"0 3 * * *", true},
+		{"*/15 * * * *", true},
+		{"0-30/10 8-18 * * 1-5", true},
+		{"0 0 1,15 * *", true},
+		{"5/15 * * * *", true},
+		{"  0   3 * *  0 ", true},
+		{"", false},
+		{"0 3 * *", false},
+		{"0 3 * * * *", false},
+		{"60 * * * *", false},
+		{"* 24 * * *", false},
+		{"* * 0 * *", false},
+		{"* * * 13 *", false},
+		{"* * * * 7", false},
+		{"5-1 * * * *", false},
+		{"*/0 * * * *", false},
+		{"a * * * *", false},
+		{"1-b * * * *", false},
+	}
+	for _, test := range tests {
+		_, err := ParseSchedule(test.expression)
+		if (err == nil) != test.valid {
+			t.Errorf("ParseSchedule(%q): error %v, valid %v", test.expression, err, test.valid)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	date := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04:05", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	tests := []struct {
+		expression string
+		after      string
+		next       string // "": none
+	}{
+		{"0 3 * * *", "2021-04-17 02:59:30", "2021-04-17 03:00:00"},
+		{"0 3 * * *", "2021-04-17 03:00:00", "2021-04-18 03:00:00"},
+		{"0 3 * * *", "2021-12-31 04:00:00", "2022-01-01 03:00:00"},
+		{"*/15 * * * *", "2021-04-17 10:14:59", "2021-04-17 10:15:00"},
+		{"*/15 * * * *", "2021-04-17 10:45:00", "2021-04-17 11:00:00"},
+		{"30 8 * * 1-5", "2021-04-16 09:00:00", "2021-04-19 08:30:00"}, // Friday: Monday
+		{"0 0 1 * *", "2021-01-31 12:00:00", "2021-02-01 00:00:00"},
+		{"0 0 29 2 *", "2021-03-01 00:00:00", "2024-02-29 00:00:00"},
+		{"0 0 13 * 5", "2021-04-10 00:00:00", "2021-04-13 00:00:00"}, // 13th or Friday: the 13th comes first
+		{"0 0 13 * 5", "2021-04-13 00:00:00", "2021-04-16 00:00:00"}, // then Friday 16th
+		{"0 0 31 2 *", "2021-01-01 00:00:00", ""},
+	}
+	for _, test := range tests {
+		schedule, err := ParseSchedule(test.expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next := schedule.Next(date(test.after))
+		want := time.Time{}
+		if test.next != "" {
+			want = date(test.next)
+		}
+		if !next.Equal(want) {
+			t.Errorf("%q after %s: %s, want %s", test.expression, test.after, next, want)
+		}
+	}
+}
+
+func TestNextLocation(t *testing.T) {
+	location := time.FixedZone("UTC+2", 2*60*60)
+	schedule, err := ParseSchedule("0 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := schedule.Next(time.Date(2021, 4, 17, 2, 0, 0, 0, location))
+	if want := time.Date(2021, 4, 17, 3, 0, 0, 0, location); !next.Equal(want) {
+		t.Errorf("next %s, want %s (in the zone of the time)", next, want)
+	}
+}
@@ -0,0 +1,146 @@
+package precompute
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/flutterbar/chess-explorer-go/internal/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+/*
+Heavy reports computed in advance for the players of the database (repertoire, results by speed, notable games),
+stored in the reports collection: the web page then gets them at once, with the time they were computed.
+Config file:
+  precompute-schedule: "0 3 * * *"          cron expression, in the time zone of the server (server: computed on schedule)
+  precompute-reports: [openings-white, ...] the reports to compute (default: all, see server.PrecomputedReports)
+  precompute-players: [lichess.org:me]      the players (default: the users downloaded and those of users:)
+*/
+
+// Result ... reports computed by a run
+type Result struct {
+	Players  int      `json:"players"`
+	Reports  int      `json:"reports"` // reports stored
+	Failed   []string `json:"failed,omitempty"`
+	Duration string   `json:"duration"`
+}
+
+// Run ... compute the reports of the players and store them
+func Run(ctx context.Context) (Result, error) {
+	start := time.Now()
+	result := Result{}
+	reports, err := Reports()
+	if err != nil {
+		return result, err
+	}
+	players, err := Players()
+	if err != nil {
+		return result, err
+	}
+	result.Players = len(players)
+
+	// Connect to DB
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(connectCtx)
+	if err != nil {
+		return result, err
+	}
+	defer client.Disconnect(context.Background())
+	games := mongodb.Collection(client, "games")
+	stored := mongodb.Collection(client, "reports")
+
+	var firstErr error
+	for _, player := range players {
+		for _, report := range reports {
+			data, err := server.ComputeReport(ctx, games, report, player)
+			if err == nil {
+				err = server.StoreReport(ctx, stored, report, player, data)
+			}
+			if err != nil {
+				log.Warn("report " + report + " of " + player + ": " + err.Error())
+				result.Failed = append(result.Failed, report+"/"+player)
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			result.Reports++
+		}
+	}
+	result.Duration = time.Since(start).Round(time.Second).String()
+	if firstErr != nil {
+		return result, fmt.Errorf("%d reports not computed, first error: %w", len(result.Failed), firstErr)
+	}
+	return result, nil
+}
+
+// Reports ... the reports to compute (precompute-reports, all by default)
+func Reports() ([]string, error) {
+	if !viper.IsSet("precompute-reports") {
+		return server.PrecomputedReports, nil
+	}
+	reports := viper.GetStringSlice("precompute-reports")
+	for _, report := range reports {
+		known := false
+		for _, precomputed := range server.PrecomputedReports {
+			known = known || report == precomputed
+		}
+		if !known {
+			return nil, fmt.Errorf("invalid precompute-reports %q: %s", report, strings.Join(server.PrecomputedReports, ", "))
+		}
+	}
+	return reports, nil
+}
+
+// Players ... the players whose reports are computed (precompute-players, by default the users downloaded and those of users:)
+func Players() ([]string, error) {
+	if viper.IsSet("precompute-players") {
+		return viper.GetStringSlice("precompute-players"), nil
+	}
+	tracked, err := pgntodb.TrackedUsers()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	players := make([]string, 0, len(tracked))
+	add := func(player string) {
+		if key := strings.ToLower(player); !seen[key] {
+			seen[key] = true
+			players = append(players, player)
+		}
+	}
+	for _, user := range tracked {
+		add(user.Site + ":" + user.Username)
+	}
+	for _, user := range viper.GetStringSlice("users") {
+		if strings.Contains(user, ":") {
+			add(user)
+		}
+	}
+	return players, nil
+}
+
+// Every ... compute the reports at the times of {schedule} (cron expression), forever (errors are logged)
+func Every(schedule *Schedule) {
+	for {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Error("precompute-schedule has no next time")
+			return
+		}
+		log.Info("Next report precomputation at " + next.Format(time.RFC3339))
+		time.Sleep(time.Until(next))
+
+		result, err := Run(context.Background())
+		log.Info(fmt.Sprintf("%d reports computed for %d players in %s", result.Reports, result.Players, result.Duration))
+		if err != nil {
+			log.Error(err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package progress
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+/*
+Progress bars on the standard error, only when it is a terminal (and not with --no-progress or --quiet)
+*/
+
+// Enabled ... progress bars are displayed
+func Enabled() bool {
+	return !viper.GetBool("no-progress") && !viper.GetBool("quiet") && term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// Bytes ... progress of a download or of a file being read ({max} -1 if unknown)
+func Bytes(max int64, description string) *progressbar.ProgressBar {
+	return newBar(max, description, progressbar.OptionShowBytes(true))
+}
+
+// Count ... progress of {max} items ({max} -1 if unknown)
+func Count(max int64, description string) *progressbar.ProgressBar {
+	return newBar(max, description, progressbar.OptionShowIts())
+}
+
+func newBar(max int64, description string, option progressbar.Option) *progressbar.ProgressBar {
+	options := []progressbar.Option{
+		progressbar.OptionSetDescription(description),
+		progressbar.OptionSetWidth(20),
+		progressbar.OptionThrottle(100 * time.Millisecond),
+		progressbar.OptionShowCount(),
+		progressbar.OptionSetPredictTime(true),
+		progressbar.OptionSpinnerType(14),
+		option,
+	}
+	if Enabled() {
+		options = append(options,
+			progressbar.OptionSetWriter(os.Stderr),
+			progressbar.OptionOnCompletion(func() {
+				fmt.Fprintln(os.Stderr)
+			}))
+	} else {
+		options = append(options, progressbar.OptionSetWriter(ioutil.Discard))
+	}
+	bar := progressbar.NewOptions64(max, options...)
+	bar.RenderBlank()
+	return bar
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+)
+
+// blunderLoss ... centipawns lost by a blunder (as the ?? of pgnannotate)
+const blunderLoss = 300
+
+// OpeningAccuracy ... engine-measured play of a player in an opening, games with evaluations
+type OpeningAccuracy struct {
+	Name     string  `json:"name"` // opening name, or the ECO code for games imported without one
+	ECO      string  `json:"eco,omitempty"`
+	Games    int     `json:"games"`
+	Moves    int     `json:"moves"`    // moves of the player
+	ACPL     float64 `json:"acpl"`     // average centipawn loss per move
+	Blunders float64 `json:"blunders"` // per game
+	Score    float64 `json:"score"`    // points per game (win 1, draw 0.5)
+	lost     int
+	blunders int
+	points   float64
+}
+
+// AccuracyReport ... openings of a player by accuracy, most accurate first
+type AccuracyReport struct {
+	White []OpeningAccuracy `json:"white"`
+	Black []OpeningAccuracy `json:"black"`
+}
+
+func accuracyHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "accuracyHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type accuracyResponse struct {
+		Error string          `json:"error"`
+		Data  *AccuracyReport `json:"data"`
+	}
+
+	r.ParseForm()
+	player := strings.TrimSpace(r.Form.Get("player"))
+	filter := NewGameFilter(r.Form)
+	var errs ValidationError
+	if player == "" {
+		errs.add("player", "player is missing: /report/accuracy?player={username}")
+	}
+	color := errs.colorParam(r.Form, "color")
+	minGames := errs.intParam(r.Form, "mingames", 3, 1, 1000000)
+	if badRequest(w, filter, errs...) {
+		return
+	}
+
+	report, err := ReportAccuracy(player, color, minGames, filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(accuracyResponse{Data: report})
+}
+
+// ReportAccuracy ... openings of {player} (username or site:username) with {color} ("": both) by average centipawn loss,
+// in the games of {gameFilter} with evaluations (its white and black are ignored), openings of less than {minGames} games left out
+func ReportAccuracy(player string, color string, minGames int, gameFilter *GameFilter) (*AccuracyReport, error) {
+	report := AccuracyReport{White: []OpeningAccuracy{}, Black: []OpeningAccuracy{}}
+	for _, side := range []string{"white", "black"} {
+		if color != "" && color != side {
+			continue
+		}
+		filter := *gameFilter
+		filter.white, filter.black = "", ""
+		if side == "white" {
+			filter.white = player
+		} else {
+			filter.black = player
+		}
+
+		isWhite := side == "white"
+		openings := map[string]*OpeningAccuracy{}
+		_, err := EachGame(context.Background(), &filter, func(game *pgntodb.Game) error {
+			if len(game.Evals) == 0 {
+				return nil
+			}
+			plies := len(pgn.Moves(game.PGN))
+			if plies < minAccuracyPlies || len(game.Evals) < plies*9/10 {
+				return nil
+			}
+			lost, moves, blunders := playerLosses(game.Evals, isWhite)
+			if moves == 0 {
+				return nil
+			}
+
+			name := game.Opening
+			if name == "" {
+				name = game.ECO
+			}
+			if name == "" {
+				name = "Unknown"
+			}
+			opening, ok := openings[name]
+			if !ok {
+				opening = &OpeningAccuracy{Name: name, ECO: game.ECO}
+				openings[name] = opening
+			}
+			opening.Games++
+			opening.Moves += moves
+			opening.lost += lost
+			opening.blunders += blunders
+			switch {
+			case game.Result == "1/2-1/2":
+				opening.points += 0.5
+			case (game.Result == "1-0") == isWhite:
+				opening.points++
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		ret := make([]OpeningAccuracy, 0, len(openings))
+		for _, opening := range openings {
+			if opening.Games < minGames {
+				continue
+			}
+			opening.ACPL = float64(opening.lost) / float64(opening.Moves)
+			opening.Blunders = float64(opening.blunders) / float64(opening.Games)
+			opening.Score = opening.points / float64(opening.Games)
+			ret = append(ret, *opening)
+		}
+		sort.Slice(ret, func(i, j int) bool {
+			if ret[i].ACPL != ret[j].ACPL {
+				return ret[i].ACPL < ret[j].ACPL
+			}
+			return ret[i].Games > ret[j].Games
+		})
+		if isWhite {
+			report.White = ret
+		} else {
+			report.Black = ret
+		}
+	}
+	return &report, nil
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/anki"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	log "github.com/sirupsen/logrus"
+)
+
+func ankiHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "ankiHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	filter := gameFilterFromRequest(r)
+	var errs ValidationError
+	options := anki.Options{Color: errs.colorParam(r.Form, "color")}
+	options.Depth = errs.intParam(r.Form, "depth", 0, 1, 100)
+	options.MinGames = errs.intParam(r.Form, "mingames", 0, 1, 1000000)
+	if badRequest(w, filter, errs...) {
+		return
+	}
+
+	// cards are built before anything is sent: errors get a status
+	var deck strings.Builder
+	if _, err := AnkiDeck(&deck, filter, options); err != nil {
+		log.Error(err)
+		status := http.StatusBadRequest
+		if errors.Is(err, mongodb.ErrUnavailable) {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"chess-explorer-anki.csv\"")
+	io.WriteString(w, deck.String())
+}
+
+// AnkiDeck ... write the repertoire cards of the games matching {filter} (see package anki), number of cards
+// the color is the filtered player's side when only white or only black is filtered
+func AnkiDeck(w io.Writer, filter *GameFilter, options anki.Options) (int, error) {
+	if options.Color == "" {
+		switch {
+		case filter.white != "" && filter.black == "":
+			options.Color = "white"
+		case filter.black != "" && filter.white == "":
+			options.Color = "black"
+		default:
+			return 0, errors.New("Repertoire color needed (white or black) when both or no players are filtered")
+		}
+	}
+
+	deck, err := anki.New(filter.pgn, options)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = EachGame(context.Background(), filter, func(game *pgntodb.Game) error {
+		deck.Add(game.PGN, game.Result, game.ECO)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return deck.Write(w)
+}
@@ -0,0 +1,315 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/httpclient"
+	"github.com/flutterbar/chess-explorer-go/internal/sync"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+/*
+Login with lichess.org (OAuth 2 authorization code with PKCE, no client secret to register):
+  /login            redirects to lichess.org
+  /oauth/callback   lichess.org redirects here: the username is read from https://lichess.org/api/account
+  /logout           ends the session
+  /me               the logged in user, {"data": {"enabled": true, "site": "lichess.org", "username": "..."}}
+
+Settings:
+  server-url          public URL of the server, for the redirection (default http://localhost:{server-port})
+  lichess-client-id   name of the application shown by lichess.org (default chess-explorer)
+
+The games of a user are downloaded when they log in, and are then part of the synchronizations.
+The access token (scope study:write, to create study chapters) and the sessions are kept in memory:
+users log in again after a restart of the server.
+*/
+
+const sessionCookie = "chess-explorer-session"
+
+// sessionDuration ... a login lasts 30 days
+const sessionDuration = 30 * 24 * time.Hour
+
+// loginDuration ... time to accept the access on lichess.org
+const loginDuration = 10 * time.Minute
+
+type session struct {
+	username string
+	token    string // lichess.org access token
+	expires  time.Time
+}
+
+type pendingLogin struct {
+	verifier string
+	expires  time.Time
+}
+
+var sessions = map[string]session{}
+var pendingLogins = map[string]pendingLogin{} // by OAuth state
+var sessionsLock gosync.Mutex
+
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	if demo != nil {
+		http.Error(w, "Login is not available in demo mode", http.StatusNotFound)
+		return
+	}
+
+	state := randomString()
+	verifier := randomString()
+	sessionsLock.Lock()
+	removeExpired()
+	pendingLogins[state] = pendingLogin{verifier: verifier, expires: time.Now().Add(loginDuration)}
+	sessionsLock.Unlock()
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID()},
+		"redirect_uri":          {redirectURI()},
+		"code_challenge_method": {"S256"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"state":                 {state},
+		"scope":                 {"study:write"},
+	}
+	http.Redirect(w, r, "https://lichess.org/oauth?"+query.Encode(), http.StatusFound)
+}
+
+func oauthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if errorCode := r.FormValue("error"); errorCode != "" {
+		// access denied on lichess.org
+		log.Warn("Login refused: " + errorCode)
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	state := r.FormValue("state")
+	sessionsLock.Lock()
+	login, found := pendingLogins[state]
+	delete(pendingLogins, state)
+	sessionsLock.Unlock()
+	if !found || time.Now().After(login.expires) {
+		http.Error(w, "Login expired, please try again", http.StatusBadRequest)
+		return
+	}
+
+	token, err := accessToken(r.FormValue("code"), login.verifier)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "Login failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	username, err := lichessUsername(token)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "Login failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	sessionID := randomString()
+	sessionsLock.Lock()
+	sessions[sessionID] = session{username: username, token: token, expires: time.Now().Add(sessionDuration)}
+	sessionsLock.Unlock()
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    sessionID,
+		Path:     "/",
+		MaxAge:   int(sessionDuration.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(serverURL(), "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	log.Info(username + " logged in")
+
+	// with isolation, the games of the user belong to the user (see requestOwner)
+	owner := ""
+	if isolation() {
+		owner = "lichess.org:" + username
+	}
+	// read-only: the games of the user are not downloaded
+	if !readOnly() {
+		go func() {
+			if _, err := sync.User("lichess.org", username, owner); err != nil {
+				log.Error(username + " (lichess.org): " + err.Error())
+			}
+		}()
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		sessionsLock.Lock()
+		delete(sessions, cookie.Value)
+		sessionsLock.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func meHandler(w http.ResponseWriter, r *http.Request) {
+	type me struct {
+		Enabled  bool   `json:"enabled"`  // login available (not in demo mode)
+		ReadOnly bool   `json:"readOnly"` // the database cannot be changed (read-only)
+		Site     string `json:"site,omitempty"`
+		Username string `json:"username,omitempty"`
+	}
+	type meResponse struct {
+		Error string `json:"error"`
+		Data  me     `json:"data"`
+	}
+
+	response := meResponse{Data: me{Enabled: demo == nil, ReadOnly: readOnly()}}
+	if username := loggedInUser(r); username != "" {
+		response.Data.Site = "lichess.org"
+		response.Data.Username = username
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// loggedInUser ... lichess.org username of the session of {r}, "" when not logged in
+func loggedInUser(r *http.Request) string {
+	return currentSession(r).username
+}
+
+// loggedInToken ... lichess.org access token of the session of {r}, "" when not logged in
+func loggedInToken(r *http.Request) string {
+	return currentSession(r).token
+}
+
+func currentSession(r *http.Request) session {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return session{}
+	}
+	sessionsLock.Lock()
+	defer sessionsLock.Unlock()
+	current, found := sessions[cookie.Value]
+	if !found || time.Now().After(current.expires) {
+		return session{}
+	}
+	return current
+}
+
+// removeExpired ... forget expired sessions and logins (sessionsLock held)
+func removeExpired() {
+	now := time.Now()
+	for id, session := range sessions {
+		if now.After(session.expires) {
+			delete(sessions, id)
+		}
+	}
+	for state, login := range pendingLogins {
+		if now.After(login.expires) {
+			delete(pendingLogins, state)
+		}
+	}
+}
+
+// accessToken ... exchange the authorization {code} for an access token
+// https://lichess.org/api#tag/OAuth/operation/apiToken
+func accessToken(code string, verifier string) (string, error) {
+	client, err := httpclient.New()
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"code_verifier": {verifier},
+		"redirect_uri":  {redirectURI()},
+		"client_id":     {clientID()},
+	}
+	req, err := http.NewRequest("POST", "https://lichess.org/api/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &url.Error{Op: "Post", URL: req.URL.String(), Err: errors.New(resp.Status)}
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("no access token from lichess.org")
+	}
+	return token.AccessToken, nil
+}
+
+// lichessUsername ... username of the owner of {token}
+func lichessUsername(token string) (string, error) {
+	client, err := httpclient.New()
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("GET", "https://lichess.org/api/account", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &url.Error{Op: "Get", URL: req.URL.String(), Err: errors.New(resp.Status)}
+	}
+
+	var account struct {
+		Username string `json:"username"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&account); err != nil {
+		return "", err
+	}
+	if account.Username == "" {
+		return "", errors.New("no username from lichess.org")
+	}
+	return account.Username, nil
+}
+
+func serverURL() string {
+	if serverURL := viper.GetString("server-url"); serverURL != "" {
+		return strings.TrimSuffix(serverURL, "/")
+	}
+	return "http://localhost:" + strconv.Itoa(viper.GetInt("server-port"))
+}
+
+func redirectURI() string {
+	return serverURL() + "/oauth/callback"
+}
+
+func clientID() string {
+	if clientID := viper.GetString("lichess-client-id"); clientID != "" {
+		return clientID
+	}
+	return "chess-explorer"
+}
+
+// randomString ... 32 random bytes, URL safe
+func randomString() string {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
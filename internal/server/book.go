@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/notnil/chess"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+/*
+Opening book from the games of a profile (the filter: white, black, speed, from...), for engines and GUIs:
+  GET /book?fen={FEN}&white=lichess.org:me&weight=games    a book move in the position, and the candidates
+The same book answers UCI on the standard input with the book command (see internal/book).
+*/
+
+// Book weightings: how a book move is chosen among the moves of the games
+const (
+	BookWeightGames = "games" // in proportion to the games (the repertoire as played)
+	BookWeightScore = "score" // in proportion to the points scored with the move by the side to move
+	BookWeightBest  = "best"  // always the most played move
+)
+
+// BookWeightings ... valid weight parameters
+var BookWeightings = []string{BookWeightGames, BookWeightScore, BookWeightBest}
+
+// BookMove ... move of the book in a position
+type BookMove struct {
+	Move   string  `json:"move"` // SAN
+	UCI    string  `json:"uci"`
+	Games  int     `json:"games"`
+	Score  float64 `json:"score"`  // points per game of the side to move (win 1, draw 0.5)
+	Weight float64 `json:"weight"` // probability to be chosen
+}
+
+// BookAnswer ... the move chosen (nil: out of book) and the candidates, most likely first
+type BookAnswer struct {
+	Move       *BookMove  `json:"move"`
+	Candidates []BookMove `json:"candidates"`
+}
+
+func bookHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "bookHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type bookResponse struct {
+		Error string      `json:"error"`
+		Data  *BookAnswer `json:"data"`
+	}
+
+	r.ParseForm()
+	filter := NewGameFilter(r.Form)
+	var errs ValidationError
+	weighting := errs.bookWeightParam(r.Form.Get("weight"))
+	minGames := errs.intParam(r.Form, "mingames", 1, 1, 1000000)
+	if badRequest(w, filter, errs...) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	store, err := openStore(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer store.close(ctx)
+
+	candidates, err := bookCandidates(ctx, store, filter, weighting, minGames)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	answer := BookAnswer{Move: ChooseBookMove(candidates, nil), Candidates: candidates}
+	json.NewEncoder(w).Encode(bookResponse{Data: &answer})
+}
+
+// bookWeightParam ... weighting of the book ("": games)
+func (errs *ValidationError) bookWeightParam(weight string) string {
+	weight = strings.ToLower(strings.TrimSpace(weight))
+	if weight == "" {
+		return BookWeightGames
+	}
+	for _, valid := range BookWeightings {
+		if weight == valid {
+			return weight
+		}
+	}
+	errs.add("weight", "invalid weight %q: %s", weight, strings.Join(BookWeightings, ", "))
+	return ""
+}
+
+// BookCandidates ... moves of the games of {filter} (the book) in its line or position, played in {minGames} games at least,
+// with their probability for {weighting}, most likely first ({games} is nil in demo mode)
+func BookCandidates(ctx context.Context, games *mongo.Collection, filter *GameFilter, weighting string, minGames int) ([]BookMove, error) {
+	return bookCandidates(ctx, storeOf(games), filter, weighting, minGames)
+}
+
+// bookCandidates ... BookCandidates of the games of {store}
+func bookCandidates(ctx context.Context, store gameStore, filter *GameFilter, weighting string, minGames int) ([]BookMove, error) {
+	nextmoves, _, err := store.nextMoves(ctx, filter, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	position := filter.boardPosition()
+	whiteToMove := position.Turn() == chess.White
+	candidates := make([]BookMove, 0, len(nextmoves))
+	total := 0.0
+	for _, nextmove := range nextmoves {
+		if int(nextmove.Total) < minGames {
+			continue
+		}
+		move, err := pgn.DecodeMove(position, nextmove.Move)
+		if err != nil {
+			continue // End: games ending in the position
+		}
+		wins, losses := nextmove.White, nextmove.Black
+		if !whiteToMove {
+			wins, losses = losses, wins
+		}
+		candidate := BookMove{
+			Move:  nextmove.Move,
+			UCI:   chess.UCINotation{}.Encode(position, move),
+			Games: int(nextmove.Total),
+			Score: (float64(wins) + float64(nextmove.Total-wins-losses)/2) / float64(nextmove.Total),
+		}
+		switch weighting {
+		case BookWeightScore:
+			candidate.Weight = candidate.Score * float64(candidate.Games)
+		default:
+			candidate.Weight = float64(candidate.Games)
+		}
+		total += candidate.Weight
+		candidates = append(candidates, candidate)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Weight != candidates[j].Weight {
+			return candidates[i].Weight > candidates[j].Weight
+		}
+		return candidates[i].Games > candidates[j].Games
+	})
+	for i := range candidates {
+		switch {
+		case weighting == BookWeightBest && i == 0:
+			candidates[i].Weight = 1
+		case weighting == BookWeightBest || total == 0:
+			candidates[i].Weight = 0
+		default:
+			candidates[i].Weight /= total
+		}
+	}
+	return candidates, nil
+}
+
+// ChooseBookMove ... a candidate at random by weight ({random} nil: crypto/rand, as the trainer), nil without candidate of some weight
+func ChooseBookMove(candidates []BookMove, random *rand.Rand) *BookMove {
+	draw := float64(randomInt(1<<53)) / (1 << 53)
+	if random != nil {
+		draw = random.Float64()
+	}
+	for i := range candidates {
+		if draw < candidates[i].Weight {
+			return &candidates[i]
+		}
+		draw -= candidates[i].Weight
+	}
+	// rounding: the last candidate of some weight
+	for i := len(candidates) - 1; i >= 0; i-- {
+		if candidates[i].Weight > 0 {
+			return &candidates[i]
+		}
+	}
+	return nil
+}
+
+// String ... book move for the logs: e4 (120 games, 0.55, 40%)
+func (move BookMove) String() string {
+	return fmt.Sprintf("%s (%d games, %.2f, %.0f%%)", move.Move, move.Games, move.Score, move.Weight*100)
+}
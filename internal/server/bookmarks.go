@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+Bookmarks: positions saved with the filter of the web page, to resume a study session, in the bookmarks collection.
+They belong to the user logged in with lichess, or to the API key of the request (X-Api-Key header or key parameter,
+any secret string of 16 characters or more chosen by the client: the web page keeps one in the browser).
+  GET    /bookmarks               the bookmarks of the user, most recent first
+  POST   /bookmarks               a new bookmark: name, pgn, fen and the filter (white, black, from...)
+  DELETE /bookmarks?id={id}
+*/
+
+// minAPIKeyLength ... characters of an API key at least
+const minAPIKeyLength = 16
+
+// maxBookmarks ... bookmarks of a user at most
+const maxBookmarks = 1000
+
+// filterParams ... parameters of the filter form of the web page (pgn and fen aside)
+var filterParams = []string{"white", "black", "timecontrol", "simplifyTimecontrol", "from", "to", "tz",
+	"minelo", "maxelo", "unknownelo", "site", "result", "speed", "batch", "tag"}
+
+// Bookmark ... a position and the filter it was explored with
+type Bookmark struct {
+	ID      string            `json:"id" bson:"_id"`
+	Owner   string            `json:"-" bson:"owner"` // lichess.org:{username} or key:{sha256 of the API key}
+	Name    string            `json:"name,omitempty" bson:"name,omitempty"`
+	PGN     string            `json:"pgn,omitempty" bson:"pgn,omitempty"`
+	FEN     string            `json:"fen" bson:"fen"`
+	Filter  map[string]string `json:"filter,omitempty" bson:"filter,omitempty"` // filterParams
+	Created time.Time         `json:"created" bson:"created"`
+}
+
+func bookmarksHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "bookmarksHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "X-Api-Key")
+
+	type bookmarksResponse struct {
+		Error string     `json:"error"`
+		Data  []Bookmark `json:"data"`
+	}
+
+	if r.Method == http.MethodOptions {
+		return // preflight of the X-Api-Key header
+	}
+	r.ParseForm()
+	owner, err := requestOwner(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		writeError(w, err)
+		return
+	}
+
+	var errs ValidationError
+	var bookmark Bookmark
+	id := strings.TrimSpace(r.Form.Get("id"))
+	switch r.Method {
+	case http.MethodDelete:
+		if id == "" {
+			errs.add("id", "id is missing: the bookmark to delete")
+		}
+	case http.MethodPost:
+		filter := NewGameFilter(r.Form)
+		if badRequest(w, filter) {
+			return
+		}
+		position := filter.boardPosition()
+		bookmark = Bookmark{
+			ID:      primitive.NewObjectID().Hex(),
+			Owner:   owner,
+			Name:    strings.TrimSpace(r.Form.Get("name")),
+			PGN:     filter.pgn,
+			FEN:     position.String(),
+			Filter:  filterContext(r.Form),
+			Created: time.Now().UTC(),
+		}
+		if len(bookmark.Name) > 200 {
+			errs.add("name", "a name has 200 characters at most")
+		}
+	}
+	if badRequest(w, &GameFilter{}, errs...) {
+		return
+	}
+
+	// Connect to DB
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer client.Disconnect(ctx)
+
+	bookmarks := mongodb.Collection(client, "bookmarks")
+	response := bookmarksResponse{Data: []Bookmark{}}
+	switch r.Method {
+	case http.MethodDelete:
+		err = DeleteBookmark(ctx, bookmarks, owner, id)
+	case http.MethodPost:
+		if err = AddBookmark(ctx, bookmarks, bookmark); err == nil {
+			response.Data = append(response.Data, bookmark)
+		}
+	default:
+		response.Data, err = Bookmarks(ctx, bookmarks, owner)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// requestOwner ... user of {r}: logged in with lichess, or its API key
+func requestOwner(r *http.Request) (string, error) {
+	if username := loggedInUser(r); username != "" {
+		return "lichess.org:" + username, nil
+	}
+	key := strings.TrimSpace(r.Header.Get("X-Api-Key"))
+	if key == "" {
+		key = strings.TrimSpace(r.Form.Get("key"))
+	}
+	if key == "" {
+		return "", errors.New("Log in with lichess or send an API key (X-Api-Key header)")
+	}
+	if len(key) < minAPIKeyLength {
+		return "", fmt.Errorf("An API key has %d characters at least", minAPIKeyLength)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "key:" + hex.EncodeToString(sum[:]), nil
+}
+
+// filterContext ... the values of the filter form among {values}
+func filterContext(values url.Values) map[string]string {
+	filter := map[string]string{}
+	for _, param := range filterParams {
+		if value := strings.TrimSpace(values.Get(param)); value != "" {
+			filter[param] = value
+		}
+	}
+	return filter
+}
+
+// AddBookmark ... store {bookmark} (maxBookmarks per owner)
+func AddBookmark(ctx context.Context, bookmarks *mongo.Collection, bookmark Bookmark) error {
+	count, err := bookmarks.CountDocuments(ctx, bson.M{"owner": bookmark.Owner})
+	if err != nil {
+		return err
+	}
+	if count >= maxBookmarks {
+		return errors.New("Too many bookmarks: delete some first")
+	}
+	if _, err = bookmarks.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "owner", Value: 1}, {Key: "created", Value: -1}},
+	}); err != nil {
+		return err
+	}
+	_, err = bookmarks.InsertOne(ctx, bookmark)
+	return err
+}
+
+// Bookmarks ... bookmarks of {owner}, most recent first
+func Bookmarks(ctx context.Context, bookmarks *mongo.Collection, owner string) ([]Bookmark, error) {
+	cursor, err := bookmarks.Find(ctx, bson.M{"owner": owner}, options.Find().SetSort(bson.M{"created": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	list := make([]Bookmark, 0)
+	if err = cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// DeleteBookmark ... remove the bookmark {id} of {owner}
+func DeleteBookmark(ctx context.Context, bookmarks *mongo.Collection, owner string, id string) error {
+	result, err := bookmarks.DeleteOne(ctx, bson.M{"_id": id, "owner": owner})
+	if err == nil && result.DeletedCount == 0 {
+		return errors.New("Bookmark not found: " + id)
+	}
+	return err
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+)
+
+// ClockBlunders ... blunders of a player at a time control, and how many with little time on the clock
+type ClockBlunders struct {
+	TimeControl       string  `json:"timeControl"` // 180+2
+	Games             int     `json:"games"`       // games with evaluations and clocks
+	Moves             int     `json:"moves"`
+	Blunders          int     `json:"blunders"`          // moves losing blunderLoss centipawns at least
+	LowClockMoves     int     `json:"lowClockMoves"`     // moves played with less than the threshold on the clock
+	LowClockBlunders  int     `json:"lowClockBlunders"`  // blunders played with less than the threshold on the clock
+	LowClockShare     float64 `json:"lowClockShare"`     // lowClockBlunders / blunders: the blunders due to time trouble
+	BlunderRate       float64 `json:"blunderRate"`       // blunders per move with more time
+	LowClockRate      float64 `json:"lowClockRate"`      // blunders per move with less time
+	LostAfterLowClock int     `json:"lostAfterLowClock"` // games lost with a blunder on a low clock
+}
+
+// ClockBlundersReport ... blunders by clock of a player, by time control (most played first)
+type ClockBlundersReport struct {
+	Seconds      int             `json:"seconds"` // threshold: time trouble below
+	Overall      ClockBlunders   `json:"overall"`
+	TimeControls []ClockBlunders `json:"timeControls"`
+}
+
+func clockBlundersHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "clockBlundersHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type clockBlundersResponse struct {
+		Error string               `json:"error"`
+		Data  *ClockBlundersReport `json:"data"`
+	}
+
+	r.ParseForm()
+	player := strings.TrimSpace(r.Form.Get("player"))
+	filter := NewGameFilter(r.Form)
+	var errs ValidationError
+	if player == "" {
+		errs.add("player", "player is missing: /report/clock-blunders?player={username}")
+	}
+	seconds := errs.intParam(r.Form, "seconds", 30, 1, 3600)
+	if badRequest(w, filter, errs...) {
+		return
+	}
+
+	report, err := ReportClockBlunders(player, seconds, filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(clockBlundersResponse{Data: report})
+}
+
+// ReportClockBlunders ... blunders of {player} (username or site:username) in the games of {gameFilter} with evaluations
+// and clocks, and those played with less than {seconds} on the clock, by time control (its white and black are ignored)
+func ReportClockBlunders(player string, seconds int, gameFilter *GameFilter) (*ClockBlundersReport, error) {
+	overall := ClockBlunders{TimeControl: "all"}
+	timeControls := map[string]*ClockBlunders{}
+	for _, side := range []string{"white", "black"} {
+		filter := *gameFilter
+		filter.white, filter.black = "", ""
+		if side == "white" {
+			filter.white = player
+		} else {
+			filter.black = player
+		}
+
+		isWhite := side == "white"
+		_, err := EachGame(context.Background(), &filter, func(game *pgntodb.Game) error {
+			clocks := pgntodb.ClocksBefore(game.Times, game.TimeControl)
+			if len(game.Evals) == 0 || clocks == nil {
+				return nil
+			}
+			byTimeControl, ok := timeControls[game.TimeControl]
+			if !ok {
+				byTimeControl = &ClockBlunders{TimeControl: game.TimeControl}
+				timeControls[game.TimeControl] = byTimeControl
+			}
+			lost := game.Result == "0-1" && isWhite || game.Result == "1-0" && !isWhite
+			lowClockBlunder := false
+			overall.Games++
+			byTimeControl.Games++
+
+			before := 0 // starting position
+			for ply, after := range game.Evals {
+				if (ply%2 == 0) == isWhite && ply < len(clocks) {
+					loss := before - after
+					if !isWhite {
+						loss = after - before
+					}
+					lowClock := clocks[ply] < float64(seconds)
+					for _, blunders := range []*ClockBlunders{&overall, byTimeControl} {
+						blunders.Moves++
+						if lowClock {
+							blunders.LowClockMoves++
+						}
+						if loss >= blunderLoss {
+							blunders.Blunders++
+							if lowClock {
+								blunders.LowClockBlunders++
+							}
+						}
+					}
+					lowClockBlunder = lowClockBlunder || (lowClock && loss >= blunderLoss)
+				}
+				before = after
+			}
+			if lost && lowClockBlunder {
+				overall.LostAfterLowClock++
+				byTimeControl.LostAfterLowClock++
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report := ClockBlundersReport{Seconds: seconds, Overall: overall.withRates(), TimeControls: []ClockBlunders{}}
+	for _, blunders := range timeControls {
+		report.TimeControls = append(report.TimeControls, blunders.withRates())
+	}
+	sort.Slice(report.TimeControls, func(i, j int) bool {
+		if report.TimeControls[i].Games != report.TimeControls[j].Games {
+			return report.TimeControls[i].Games > report.TimeControls[j].Games
+		}
+		return report.TimeControls[i].TimeControl < report.TimeControls[j].TimeControl
+	})
+	return &report, nil
+}
+
+// withRates ... {blunders} with its share and rates
+func (blunders ClockBlunders) withRates() ClockBlunders {
+	if blunders.Blunders > 0 {
+		blunders.LowClockShare = float64(blunders.LowClockBlunders) / float64(blunders.Blunders)
+	}
+	if moves := blunders.Moves - blunders.LowClockMoves; moves > 0 {
+		blunders.BlunderRate = float64(blunders.Blunders-blunders.LowClockBlunders) / float64(moves)
+	}
+	if blunders.LowClockMoves > 0 {
+		blunders.LowClockRate = float64(blunders.LowClockBlunders) / float64(blunders.LowClockMoves)
+	}
+	return blunders
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+)
+
+// Conversion ... how a player converts winning positions and saves lost ones, games with evaluations
+type Conversion struct {
+	Speed      string  `json:"speed"`      // bullet, blitz, rapid, classical, correspondence, or all
+	Games      int     `json:"games"`      // finished games with evaluations
+	Winning    int     `json:"winning"`    // games where the player was winning at some point
+	Converted  int     `json:"converted"`  // of them, won
+	Conversion float64 `json:"conversion"` // converted / winning (0 without winning game)
+	Losing     int     `json:"losing"`     // games where the player was lost at some point
+	Saved      int     `json:"saved"`      // of them, drawn or won
+	Defense    float64 `json:"defense"`    // saved / losing (0 without losing game)
+}
+
+// ConversionReport ... conversion and defense of a player, overall and by speed (fastest first)
+type ConversionReport struct {
+	Threshold int          `json:"threshold"` // centipawns: winning above, lost below the opposite
+	Overall   Conversion   `json:"overall"`
+	BySpeed   []Conversion `json:"bySpeed"`
+}
+
+func conversionHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "conversionHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type conversionResponse struct {
+		Error string            `json:"error"`
+		Data  *ConversionReport `json:"data"`
+	}
+
+	r.ParseForm()
+	player := strings.TrimSpace(r.Form.Get("player"))
+	filter := NewGameFilter(r.Form)
+	var errs ValidationError
+	if player == "" {
+		errs.add("player", "player is missing: /report/conversion?player={username}")
+	}
+	threshold := errs.intParam(r.Form, "threshold", 200, 50, 1000)
+	if badRequest(w, filter, errs...) {
+		return
+	}
+
+	report, err := ReportConversion(player, threshold, filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(conversionResponse{Data: report})
+}
+
+// ReportConversion ... how often {player} (username or site:username) won the games of {gameFilter} where the evaluation
+// was above {threshold} centipawns for them at some point, and did not lose those where it was below -{threshold}
+// (its white and black are ignored)
+func ReportConversion(player string, threshold int, gameFilter *GameFilter) (*ConversionReport, error) {
+	overall := Conversion{Speed: "all"}
+	speeds := map[string]*Conversion{}
+	for _, side := range []string{"white", "black"} {
+		filter := *gameFilter
+		filter.white, filter.black = "", ""
+		if side == "white" {
+			filter.white = player
+		} else {
+			filter.black = player
+		}
+
+		isWhite := side == "white"
+		_, err := EachGame(context.Background(), &filter, func(game *pgntodb.Game) error {
+			if len(game.Evals) == 0 || (game.Result != "1-0" && game.Result != "0-1" && game.Result != "1/2-1/2") {
+				return nil
+			}
+			best, worst := 0, 0 // player's point of view
+			for _, eval := range game.Evals {
+				if !isWhite {
+					eval = -eval
+				}
+				if eval > best {
+					best = eval
+				}
+				if eval < worst {
+					worst = eval
+				}
+			}
+			won := game.Result != "1/2-1/2" && (game.Result == "1-0") == isWhite
+			lost := game.Result != "1/2-1/2" && !won
+
+			speed := pgntodb.Speed(game.TimeControl)
+			bySpeed, ok := speeds[speed]
+			if !ok {
+				bySpeed = &Conversion{Speed: speed}
+				speeds[speed] = bySpeed
+			}
+			for _, conversion := range []*Conversion{&overall, bySpeed} {
+				conversion.Games++
+				if best > threshold {
+					conversion.Winning++
+					if won {
+						conversion.Converted++
+					}
+				}
+				if worst < -threshold {
+					conversion.Losing++
+					if !lost {
+						conversion.Saved++
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report := ConversionReport{Threshold: threshold, Overall: overall.withRates(), BySpeed: []Conversion{}}
+	for _, speed := range pgntodb.Speeds {
+		if conversion, ok := speeds[speed]; ok {
+			report.BySpeed = append(report.BySpeed, conversion.withRates())
+		}
+	}
+	return &report, nil
+}
+
+// withRates ... {conversion} with its conversion and defense rates
+func (conversion Conversion) withRates() Conversion {
+	if conversion.Winning > 0 {
+		conversion.Conversion = float64(conversion.Converted) / float64(conversion.Winning)
+	}
+	if conversion.Losing > 0 {
+		conversion.Defense = float64(conversion.Saved) / float64(conversion.Losing)
+	}
+	return conversion
+}
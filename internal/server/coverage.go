@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/flutterbar/chess-explorer-go/internal/zobrist"
+	"github.com/notnil/chess"
+)
+
+/*
+Repertoire coverage: the popular replies of the opponents in a reference collection (master games imported with a batch
+or a tag, a lichess explorer snapshot...), and whether the games of the player (or their notes) cover them.
+  GET /report/coverage?white=lichess.org:me&reference=batch%3Dmasters&pgn=1. e4
+The player's filter (white or black: the color of the repertoire) and the reference filter (reference: a query string
+of filter values) start from the same line or position. Along the reference tree, the lines follow the moves of the
+player found in both, and every reply of the opponent played in minShare of the reference games at least is a line to
+cover: covered when the player has games with the reply, or notes in the position after it.
+*/
+
+// CoveredReply ... popular reply of the opponents in the reference, and what the player has after it
+type CoveredReply struct {
+	Line           string  `json:"line"`  // from the first position, with the reply (SAN, without move numbers)
+	Reply          string  `json:"reply"` // SAN
+	ReferenceGames int     `json:"referenceGames"`
+	Share          float64 `json:"share"` // of the reference games in the position before the reply
+	Games          int     `json:"games"` // of the player after the reply
+	Notes          int     `json:"notes"` // in the position after the reply
+	Covered        bool    `json:"covered"`
+}
+
+// CoverageReport ... coverage of the repertoire of a player against a reference
+type CoverageReport struct {
+	Color    string         `json:"color"`    // of the player
+	Replies  int            `json:"replies"`  // popular replies of the opponents
+	Covered  int            `json:"covered"`  // of them, covered by games or notes
+	Coverage float64        `json:"coverage"` // covered / replies
+	Weighted float64        `json:"weighted"` // coverage weighted by the reference games of the replies
+	Lines    []CoveredReply `json:"lines"`    // in the order of the tree, most played first
+}
+
+func coverageHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "coverageHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type coverageResponse struct {
+		Error string          `json:"error"`
+		Data  *CoverageReport `json:"data"`
+	}
+
+	r.ParseForm()
+	filter := NewGameFilter(r.Form)
+	var errs ValidationError
+	depth := errs.intParam(r.Form, "depth", 10, 1, maxTreeDepth())
+	minGames := errs.intParam(r.Form, "mingames", 5, 1, 1000000)
+	minShare := float64(errs.intParam(r.Form, "minshare", 5, 1, 100)) / 100
+	color := ""
+	switch {
+	case filter.white != "" && filter.black == "":
+		color = "white"
+	case filter.black != "" && filter.white == "":
+		color = "black"
+	default:
+		errs.add("white", "white or black (not both): the player whose repertoire is checked")
+	}
+	referenceValues, err := url.ParseQuery(r.Form.Get("reference"))
+	if err != nil || len(referenceValues) == 0 {
+		errs.add("reference", "reference is missing or invalid: the filter of the reference games as a query string (batch=masters)")
+	}
+	if badRequest(w, filter, errs...) {
+		return
+	}
+	referenceValues.Set("pgn", r.Form.Get("pgn"))
+	referenceValues.Set("fen", r.Form.Get("fen"))
+	referenceValues.Set(ownerParam, requestGamesOwner(r))
+	reference := NewGameFilter(referenceValues)
+	if badRequest(w, reference) {
+		return
+	}
+
+	report, err := ReportCoverage(filter, reference, color, depth, minGames, minShare)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(coverageResponse{Data: report})
+}
+
+// ReportCoverage ... coverage of the games of {filter} (the player has {color}) against the games of {reference},
+// {depth} plies from the line or position of the filters, replies of {minGames} reference games and {minShare} at least
+func ReportCoverage(filter *GameFilter, reference *GameFilter, color string, depth int, minGames int, minShare float64) (*CoverageReport, error) {
+	referenceTree, err := OpeningTree(context.Background(), reference, depth, minGames)
+	if err != nil {
+		return nil, err
+	}
+	playerTree, err := OpeningTree(context.Background(), filter, depth, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// notes of the positions (none in demo mode)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	store, err := openStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer store.close(ctx)
+	notesCount := func(position *chess.Position) int {
+		found, _ := store.positionNotes(ctx, filter.owner, zobrist.Hash(position))
+		return len(found)
+	}
+
+	report := CoverageReport{Color: color, Lines: []CoveredReply{}}
+	weightedTotal, weightedCovered := 0, 0
+	var walk func(referenceNode *TreeNode, playerNode *TreeNode, position *chess.Position, line []string)
+	walk = func(referenceNode *TreeNode, playerNode *TreeNode, position *chess.Position, line []string) {
+		playerToMove := (position.Turn() == chess.White) == (color == "white")
+		for _, referenceChild := range referenceNode.Children {
+			move, err := pgn.DecodeMove(position, referenceChild.Move)
+			if err != nil {
+				continue
+			}
+			next := position.Update(move)
+			childLine := append(append([]string{}, line...), referenceChild.Move)
+			playerChild := treeChild(playerNode, referenceChild.Move)
+
+			if playerToMove {
+				// the lines of the player only
+				if playerChild != nil {
+					walk(referenceChild, playerChild, next, childLine)
+				}
+				continue
+			}
+			share := float64(referenceChild.Total) / float64(referenceNode.Total)
+			if share < minShare {
+				continue
+			}
+			reply := CoveredReply{Line: strings.Join(childLine, " "), Reply: referenceChild.Move, ReferenceGames: int(referenceChild.Total), Share: share}
+			if playerChild != nil {
+				reply.Games = int(playerChild.Total)
+			}
+			reply.Notes = notesCount(next)
+			reply.Covered = reply.Games > 0 || reply.Notes > 0
+			report.Lines = append(report.Lines, reply)
+			report.Replies++
+			weightedTotal += reply.ReferenceGames
+			if reply.Covered {
+				report.Covered++
+				weightedCovered += reply.ReferenceGames
+				if playerChild != nil {
+					walk(referenceChild, playerChild, next, childLine)
+				}
+			}
+		}
+	}
+	walk(referenceTree, playerTree, filter.boardPosition(), []string{})
+
+	if report.Replies > 0 {
+		report.Coverage = float64(report.Covered) / float64(report.Replies)
+		report.Weighted = float64(weightedCovered) / float64(weightedTotal)
+	}
+	return &report, nil
+}
+
+// treeChild ... child of {node} for {move}, nil when there is none (or {node} is nil)
+func treeChild(node *TreeNode, move string) *TreeNode {
+	if node == nil {
+		return nil
+	}
+	for _, child := range node.Children {
+		if child.Move == move {
+			return child
+		}
+	}
+	return nil
+}
@@ -0,0 +1,422 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/evals"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/flutterbar/chess-explorer-go/internal/winmodel"
+)
+
+/*
+Demo mode (chess-explorer server --demo): games are kept in memory, no database needed
+The filters of the web page are applied in Go, as bsonFromGameFilter does for MongoDB. The demo has no owners, notes
+or stored teams, and cannot be changed.
+*/
+
+// demo ... games of the demo mode (nil: games are in the database)
+var demo *memoryStore
+
+// UseDemo ... serve {games} of {player} from memory instead of the database
+func UseDemo(games []pgntodb.Game, player string) {
+	if games == nil {
+		demo = nil
+		return
+	}
+	demo = &memoryStore{all: games, player: player}
+}
+
+// demoKey ... key of the demo games of a context
+type demoKey struct{}
+
+// WithDemo ... {ctx} reading {games} of {player} from memory instead of the database (the library, one demo per store)
+func WithDemo(ctx context.Context, games []pgntodb.Game, player string) context.Context {
+	return context.WithValue(ctx, demoKey{}, &memoryStore{all: games, player: player})
+}
+
+// demoOf ... the demo games of {ctx}, else the demo of the server (nil: games are in the database)
+func demoOf(ctx context.Context) *memoryStore {
+	if store, ok := ctx.Value(demoKey{}).(*memoryStore); ok {
+		return store
+	}
+	return demo
+}
+
+// memoryStore ... games of the demo mode
+type memoryStore struct {
+	all    []pgntodb.Game
+	player string // whose games are in the demo (shown as the tracked user)
+}
+
+// databaseOnly ... {handler} of {features} (plural), refused in demo mode
+func databaseOnly(features string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if demo == nil {
+			handler(w, r)
+			return
+		}
+		// allow cross origin
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		writeError(w, errors.New(features+" are not available in demo mode"))
+	}
+}
+
+func (store *memoryStore) close(ctx context.Context) {}
+
+// matching ... games matching {filter}, oldest first
+func (store *memoryStore) matching(filter *GameFilter) []pgntodb.Game {
+	matching := make([]pgntodb.Game, 0)
+	for _, game := range store.all {
+		if matchesFilter(filter, &game) {
+			matching = append(matching, game)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		return matching[i].DateTime.Before(matching[j].DateTime)
+	})
+	return matching
+}
+
+func (store *memoryStore) nextMoves(ctx context.Context, filter *GameFilter, diagnostics *Diagnostics) ([]NextMove, bool, error) {
+	if filter.invalid != nil {
+		return nil, false, filter.invalid
+	}
+	matching := store.matching(filter)
+
+	nextmoves := countNextMoves(filter, matching, winmodel.Default())
+	for iNextMove := range nextmoves {
+		setTotals(&nextmoves[iNextMove])
+		if nextmoves[iNextMove].Total == 1 {
+			nextmoves[iNextMove].Game = nextmoves[iNextMove].tmpGame
+			setEnding(&nextmoves[iNextMove])
+		}
+	}
+	sort.SliceStable(nextmoves, func(i, j int) bool {
+		return nextmoves[i].Total > nextmoves[j].Total
+	})
+
+	for _, game := range filter.loneGames(matching) {
+		nextmoves = append(nextmoves, loneGameMove(game))
+	}
+	return nextmoves, false, nil
+}
+
+// referenceNextMoves ... none: the demo has no reference games
+func (store *memoryStore) referenceNextMoves(ctx context.Context, values url.Values) ([]NextMove, error) {
+	return nil, nil
+}
+
+func (store *memoryStore) eachGame(ctx context.Context, filter *GameFilter, do func(game *pgntodb.Game) error) (int, error) {
+	count := 0
+	for _, game := range store.matching(filter) {
+		if err := do(&game); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (store *memoryStore) game(ctx context.Context, owner string, gameID string, links []string) (pgntodb.Game, error) {
+	for _, game := range store.all {
+		if (gameID != "" && game.ID == gameID) || (gameID == "" && inLinks(game.Link, links)) {
+			return game, nil
+		}
+	}
+	return pgntodb.Game{}, errGameNotFound
+}
+
+func (store *memoryStore) games(ctx context.Context, owner string, ids []string) ([]pgntodb.Game, error) {
+	found := make([]pgntodb.Game, 0)
+	for _, gameID := range ids {
+		if game, err := store.game(ctx, owner, gameID, nil); err == nil {
+			found = append(found, game)
+		}
+	}
+	return found, nil
+}
+
+func (store *memoryStore) latestGames(ctx context.Context, players []string, owner string, limit int) ([]pgntodb.Game, error) {
+	games := make([]pgntodb.Game, 0)
+	for _, game := range store.all {
+		for _, player := range players {
+			if followedUser(player, game.Site, game.White) || followedUser(player, game.Site, game.Black) {
+				games = append(games, game)
+				break
+			}
+		}
+	}
+	sort.Slice(games, func(i, j int) bool {
+		return games[i].DateTime.After(games[j].DateTime)
+	})
+	if len(games) > limit {
+		games = games[:limit]
+	}
+	return games, nil
+}
+
+func (store *memoryStore) gamesReaching(ctx context.Context, filter *GameFilter, hash int64, starting bool) ([]pgntodb.Game, error) {
+	games := make([]pgntodb.Game, 0)
+	for _, game := range store.matching(filter) {
+		reached := starting
+		for _, gameHash := range game.Hashes {
+			reached = reached || gameHash == hash
+		}
+		if reached {
+			games = append(games, game)
+		}
+	}
+	return games, nil
+}
+
+// eachSimilarCandidate ... all the games of {filter}: the ranking leaves out those without a shared position
+func (store *memoryStore) eachSimilarCandidate(ctx context.Context, game *pgntodb.Game, filter *GameFilter, reference bool, do func(candidate *pgntodb.Game) error) error {
+	for _, candidate := range store.matching(filter) {
+		if err := do(&candidate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (store *memoryStore) report(ctx context.Context, filter *GameFilter) (report, error) {
+	report := report{TotalGames: int64(len(store.all))}
+
+	if filter.white == "" && filter.black == "" {
+		sites := map[string]int{}
+		played := map[string]bool{} // sites of the games of the player
+		for _, game := range store.all {
+			sites[game.Site]++
+			played[game.Site] = played[game.Site] || game.White == store.player || game.Black == store.player
+		}
+		report.Sites = sortedResults(sites)
+		// the player is tracked on the sites of their games (as lastgames in the database)
+		report.Users = make([]userResult, 0)
+		for _, site := range report.Sites {
+			if played[site.Name] {
+				report.Users = append(report.Users, userResult{SiteName: site.Name, Name: store.player, Count: 0})
+			}
+		}
+	}
+
+	timeControls := map[string]int{}
+	for _, game := range store.matching(filter) {
+		timeControls[game.TimeControl]++
+	}
+	report.TimeControls = sortedResults(timeControls)
+	return report, nil
+}
+
+func (store *memoryStore) openings(ctx context.Context, filter *GameFilter) ([]OpeningSlice, error) {
+	if filter.invalid != nil {
+		return nil, filter.invalid
+	}
+	counts := map[string]int{}
+	ecos := map[string]string{}
+	for _, game := range store.matching(filter) {
+		counts[game.Opening]++
+		if ecos[game.Opening] == "" {
+			ecos[game.Opening] = game.ECO
+		}
+	}
+	return openingSlices(counts, ecos), nil
+}
+
+// precomputed ... none: the reports of the demo are computed on request
+func (store *memoryStore) precomputed(ctx context.Context, name string, player string, into interface{}) *time.Time {
+	return nil
+}
+
+func (store *memoryStore) sites(ctx context.Context, owner string) ([]string, error) {
+	found := map[string]bool{}
+	sites := make([]string, 0)
+	for _, game := range store.all {
+		if !found[game.Site] {
+			found[game.Site] = true
+			sites = append(sites, game.Site)
+		}
+	}
+	return sites, nil
+}
+
+func (store *memoryStore) tags(ctx context.Context, owner string) ([]TagCount, error) {
+	counts := map[string]int{}
+	for _, game := range store.all {
+		for _, tag := range game.Tags {
+			counts[tag]++
+		}
+	}
+	tags := make([]TagCount, 0, len(counts))
+	for tag, games := range counts {
+		tags = append(tags, TagCount{Tag: tag, Games: games})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Games != tags[j].Games {
+			return tags[i].Games > tags[j].Games
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags, nil
+}
+
+func (store *memoryStore) tagGames(ctx context.Context, owner string, ids []string, add []string, remove []string) (int64, int64, error) {
+	return 0, 0, errors.New("Tags cannot be changed in demo mode")
+}
+
+// teams ... the teams of the config file only
+func (store *memoryStore) teams(ctx context.Context, owner string) ([]Team, error) {
+	return mergeTeams(nil), nil
+}
+
+func (store *memoryStore) saveTeam(ctx context.Context, team Team) (Team, error) {
+	return team, errors.New("Teams are read-only in demo mode")
+}
+
+func (store *memoryStore) deleteTeam(ctx context.Context, owner string, name string) error {
+	return errors.New("Teams are read-only in demo mode")
+}
+
+func (store *memoryStore) gameNotes(ctx context.Context, owner string, gameID string) ([]Note, error) {
+	return nil, nil
+}
+
+func (store *memoryStore) positionNotes(ctx context.Context, owner string, hash int64) ([]Note, error) {
+	return nil, nil
+}
+
+// cachedEvaluate ... {uncached}: the evaluations are not stored in demo mode
+func (store *memoryStore) cachedEvaluate(source string, minDepth int, uncached evals.Evaluate) evals.Evaluate {
+	return uncached
+}
+
+// sortedResults ... {counts} most frequent first
+func sortedResults(counts map[string]int) []result {
+	results := make([]result, 0, len(counts))
+	for name, count := range counts {
+		results = append(results, result{Name: name, Count: count})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Count != results[j].Count {
+			return results[i].Count > results[j].Count
+		}
+		return results[i].Name < results[j].Name
+	})
+	return results
+}
+
+// matchesFilter ... {game} matches {filter} (same rules as bsonFromGameFilter)
+func matchesFilter(filter *GameFilter, game *pgntodb.Game) bool {
+	if !matchesAny(filter.timecontrol, func(timeControl string) bool {
+		if filter.simplifyTimecontrol != "true" {
+			return game.TimeControl == timeControl
+		}
+		clock, gameClock := pgntodb.NormalizeTimeControl(timeControl), pgntodb.NormalizeTimeControl(game.TimeControl)
+		switch {
+		case clock == nil || gameClock == nil:
+			return game.TimeControl == timeControl
+		case clock.Correspondence:
+			return gameClock.Correspondence
+		}
+		return !gameClock.Correspondence && gameClock.Base == clock.Base
+	}) {
+		return false
+	}
+
+	included, excluded := filter.sites()
+	if !matchesAny(strings.Join(included, ","), func(site string) bool {
+		return game.Site == site
+	}) {
+		return false
+	}
+	for _, site := range excluded {
+		if game.Site == site {
+			return false
+		}
+	}
+
+	if !matchesAny(filter.speed, func(speed string) bool {
+		return game.Clock != nil && game.Clock.Speed == speed
+	}) {
+		return false
+	}
+
+	if !matchesAny(filter.batch, func(batch string) bool {
+		return game.Provenance != nil && game.Provenance.Batch == batch
+	}) {
+		return false
+	}
+
+	if !matchesAny(filter.tag, func(tag string) bool {
+		for _, gameTag := range game.Tags {
+			if gameTag == tag {
+				return true
+			}
+		}
+		return false
+	}) {
+		return false
+	}
+
+	if !filter.matchesElo(game) {
+		return false
+	}
+
+	fromDate, toDate := filter.dateRange()
+	if !fromDate.IsZero() && game.DateTime.Before(fromDate) {
+		return false
+	}
+	if !toDate.IsZero() && !game.DateTime.Before(toDate) {
+		return false
+	}
+
+	if result := filter.gameResult(); result != "" && game.Result != result {
+		return false
+	}
+
+	if !matchesAny(expandTeams(filter.owner, filter.white), func(user string) bool {
+		return matchesUser(user, game.Site, game.White)
+	}) {
+		return false
+	}
+	if !matchesAny(expandTeams(filter.owner, filter.black), func(user string) bool {
+		return matchesUser(user, game.Site, game.Black)
+	}) {
+		return false
+	}
+
+	if filter.fen != "" {
+		ply, _ := filter.nextMove(game)
+		return ply >= 0
+	}
+	return strings.HasPrefix(game.PGN, filter.pgn)
+}
+
+// matchesAny ... {values} (comma separated) is empty or one of them matches
+func matchesAny(values string, matches func(value string) bool) bool {
+	empty := true
+	for _, value := range strings.Split(values, ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		empty = false
+		if matches(value) {
+			return true
+		}
+	}
+	return empty
+}
+
+// matchesUser ... {user} (username or site:username, c:username, l:username) is {name} on {site}
+func matchesUser(user string, site string, name string) bool {
+	splitUser := strings.Split(user, ":")
+	if len(splitUser) > 1 {
+		return convertSite(splitUser[0]) == site && splitUser[1] == name
+	}
+	return splitUser[0] == name
+}
@@ -0,0 +1,32 @@
+package server
+
+import "time"
+
+// Diagnostics ... how the next moves were computed (debug=true), to tune a deployment
+type Diagnostics struct {
+	Aggregation string  `json:"aggregation"` // mongo (aggregation pipeline) or algorithmic (games read and counted)
+	Reason      string  `json:"reason"`      // why this path ran: requested, or chosen from the line length or the position
+	Scanned     int64   `json:"scanned"`     // documents matching the filter, read by the algorithmic path
+	Lookups     int     `json:"lookups"`     // extra queries for the games of the moves played once (mongo path)
+	Stages      []Stage `json:"stages"`
+	TotalMs     float64 `json:"totalms"`
+}
+
+// Stage ... time spent in a step of the next moves
+type Stage struct {
+	Name string  `json:"name"`
+	Ms   float64 `json:"ms"`
+}
+
+// stage ... record the step {name} started at {start} (no-op without diagnostics)
+func (diagnostics *Diagnostics) stage(name string, start time.Time) {
+	if diagnostics == nil {
+		return
+	}
+	diagnostics.Stages = append(diagnostics.Stages, Stage{Name: name, Ms: milliseconds(time.Since(start))})
+}
+
+// milliseconds ... {duration} in milliseconds, rounded to the microsecond
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+Diff of two repertoires: the opening trees of two filters (my 2022 games and my 2024 games, me and a teammate)
+from the same line or position.
+  GET /report/diff?a=white%3Dlichess.org:me%26to%3D2022-12-31&b=white%3Dlichess.org:me%26from%3D2024-01-01&color=white
+a and b are the filters as query strings, pgn or fen the first position of both.
+*/
+
+// Statuses of the lines of a repertoire diff
+const (
+	LineAdded     = "added"     // played in b only
+	LineAbandoned = "abandoned" // played in a only
+	LineChanged   = "changed"   // played in both, with another score
+)
+
+// LineDiff ... a line of two repertoires
+type LineDiff struct {
+	Line   string   `json:"line"` // from the first position (SAN, without move numbers)
+	Status string   `json:"status"`
+	GamesA int      `json:"gamesA"`
+	GamesB int      `json:"gamesB"`
+	ScoreA *float64 `json:"scoreA,omitempty"` // points per game of color (win 1, draw 0.5), nil without games
+	ScoreB *float64 `json:"scoreB,omitempty"`
+	Change float64  `json:"change,omitempty"` // scoreB - scoreA
+}
+
+// RepertoireDiff ... lines added and abandoned (where the trees part), and the common lines whose score changed
+type RepertoireDiff struct {
+	Color     string     `json:"color"` // scores of this side
+	Added     []LineDiff `json:"added"`
+	Abandoned []LineDiff `json:"abandoned"`
+	Changed   []LineDiff `json:"changed"` // biggest change first
+}
+
+func diffHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "diffHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type diffResponse struct {
+		Error string          `json:"error"`
+		Data  *RepertoireDiff `json:"data"`
+	}
+
+	r.ParseForm()
+	var errs ValidationError
+	color := errs.colorParam(r.Form, "color")
+	depth := errs.intParam(r.Form, "depth", 8, 1, maxTreeDepth())
+	minGames := errs.intParam(r.Form, "mingames", 3, 1, 1000000)
+	minChange := float64(errs.intParam(r.Form, "minchange", 10, 0, 100)) / 100
+	filters := make([]*GameFilter, 0, 2)
+	for _, name := range []string{"a", "b"} {
+		values, err := url.ParseQuery(r.Form.Get(name))
+		if err != nil || len(values) == 0 {
+			errs.add(name, "%s is missing or invalid: the filter of the games as a query string (white=lichess.org:me&to=2022-12-31)", name)
+			continue
+		}
+		values.Set("pgn", r.Form.Get("pgn"))
+		values.Set("fen", r.Form.Get("fen"))
+		values.Set(ownerParam, requestGamesOwner(r))
+		filters = append(filters, NewGameFilter(values))
+	}
+	if len(errs) > 0 {
+		badRequest(w, &GameFilter{}, errs...)
+		return
+	}
+	if badRequest(w, filters[0]) || badRequest(w, filters[1]) {
+		return
+	}
+
+	diff, err := DiffRepertoires(filters[0], filters[1], color, depth, minGames, minChange)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(diffResponse{Data: diff})
+}
+
+// DiffRepertoires ... lines of the games of {b} and not of {a} (added), of {a} and not of {b} (abandoned), and common lines
+// whose score of {color} ("": white) changed by {minChange} at least, {depth} plies deep, lines of {minGames} games at least
+func DiffRepertoires(a *GameFilter, b *GameFilter, color string, depth int, minGames int, minChange float64) (*RepertoireDiff, error) {
+	if color == "" {
+		color = "white"
+	}
+	treeA, err := OpeningTree(context.Background(), a, depth, minGames)
+	if err != nil {
+		return nil, err
+	}
+	treeB, err := OpeningTree(context.Background(), b, depth, minGames)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := RepertoireDiff{Color: color, Added: []LineDiff{}, Abandoned: []LineDiff{}, Changed: []LineDiff{}}
+	var walk func(nodeA *TreeNode, nodeB *TreeNode, line []string)
+	walk = func(nodeA *TreeNode, nodeB *TreeNode, line []string) {
+		for _, childA := range nodeA.Children {
+			childLine := append(append([]string{}, line...), childA.Move)
+			childB := treeChild(nodeB, childA.Move)
+			if childB == nil {
+				diff.Abandoned = append(diff.Abandoned, LineDiff{Line: strings.Join(childLine, " "), Status: LineAbandoned,
+					GamesA: int(childA.Total), ScoreA: childA.score(color)})
+				continue
+			}
+			lineDiff := LineDiff{Line: strings.Join(childLine, " "), Status: LineChanged,
+				GamesA: int(childA.Total), GamesB: int(childB.Total), ScoreA: childA.score(color), ScoreB: childB.score(color)}
+			lineDiff.Change = *lineDiff.ScoreB - *lineDiff.ScoreA
+			if math.Abs(lineDiff.Change) >= minChange && lineDiff.Change != 0 {
+				diff.Changed = append(diff.Changed, lineDiff)
+			}
+			walk(childA, childB, childLine)
+		}
+		for _, childB := range nodeB.Children {
+			if treeChild(nodeA, childB.Move) == nil {
+				diff.Added = append(diff.Added, LineDiff{Line: strings.Join(append(append([]string{}, line...), childB.Move), " "), Status: LineAdded,
+					GamesB: int(childB.Total), ScoreB: childB.score(color)})
+			}
+		}
+	}
+	walk(treeA, treeB, []string{})
+
+	sort.SliceStable(diff.Changed, func(i, j int) bool {
+		return math.Abs(diff.Changed[i].Change) > math.Abs(diff.Changed[j].Change)
+	})
+	return &diff, nil
+}
+
+// score ... points per game of {color} in the games of the node, nil without games
+func (node *TreeNode) score(color string) *float64 {
+	if node.Total == 0 {
+		return nil
+	}
+	wins := node.White
+	if color == "black" {
+		wins = node.Black
+	}
+	score := (float64(wins) + float64(node.Draw)/2) / float64(node.Total)
+	return &score
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/notnil/chess"
+)
+
+// Termination reasons of NextMove
+const (
+	EndCheckmate            = "checkmate"
+	EndResignation          = "resignation"
+	EndTime                 = "time"
+	EndAbandoned            = "abandoned"
+	EndStalemate            = "stalemate"
+	EndRepetition           = "repetition"
+	EndInsufficientMaterial = "insufficient material"
+	EndTimeVsInsufficient   = "time vs insufficient material"
+	EndFiftyMoves           = "50 moves"
+	EndAgreement            = "agreement"
+)
+
+// setEnding ... why the game of {nextMove} ended and its final position (lone games and single games)
+func setEnding(nextMove *NextMove) {
+	if nextMove.Game.PGN == "" {
+		return
+	}
+	nextMove.Termination, nextMove.FinalFEN = ending(&nextMove.Game)
+}
+
+// ending ... termination reason (see End constants, "" if unknown) and final FEN of {game}
+// from the final position, then the Termination tag
+// lichess: [Termination "Normal"], [Termination "Time forfeit"]; chess.com: [Termination "bob won by resignation"]
+func ending(game *pgntodb.Game) (string, string) {
+	chessGame := chess.NewGame()
+	for _, move := range pgn.Moves(game.PGN) {
+		m, err := pgn.DecodeMove(chessGame.Position(), move)
+		if err == nil {
+			err = chessGame.Move(m)
+		}
+		if err != nil {
+			return terminationReason(game.Termination, game.Result), ""
+		}
+	}
+	fen := chessGame.Position().String()
+
+	switch chessGame.Method() {
+	case chess.Checkmate:
+		return EndCheckmate, fen
+	case chess.Stalemate:
+		return EndStalemate, fen
+	case chess.InsufficientMaterial:
+		if !strings.Contains(strings.ToLower(game.Termination), "time") {
+			return EndInsufficientMaterial, fen
+		}
+	case chess.FivefoldRepetition:
+		return EndRepetition, fen
+	case chess.SeventyFiveMoveRule:
+		return EndFiftyMoves, fen
+	}
+
+	reason := terminationReason(game.Termination, game.Result)
+	if game.Result == "1/2-1/2" && (reason == "" || reason == EndAgreement) {
+		// lichess writes Normal for agreements and repetitions
+		for _, method := range chessGame.EligibleDraws() {
+			switch method {
+			case chess.ThreefoldRepetition:
+				return EndRepetition, fen
+			case chess.FiftyMoveRule:
+				return EndFiftyMoves, fen
+			}
+		}
+	}
+	return reason, fen
+}
+
+// terminationReason ... reason from the Termination tag
+func terminationReason(termination string, result string) string {
+	termination = strings.ToLower(termination)
+	switch {
+	case strings.Contains(termination, "insufficient") && strings.Contains(termination, "time"):
+		return EndTimeVsInsufficient
+	case strings.Contains(termination, "insufficient"):
+		return EndInsufficientMaterial
+	case strings.Contains(termination, "time"):
+		return EndTime
+	case strings.Contains(termination, "abandon"):
+		return EndAbandoned
+	case strings.Contains(termination, "resign"):
+		return EndResignation
+	case strings.Contains(termination, "checkmate"):
+		return EndCheckmate
+	case strings.Contains(termination, "stalemate"):
+		return EndStalemate
+	case strings.Contains(termination, "repetition"):
+		return EndRepetition
+	case strings.Contains(termination, "50") || strings.Contains(termination, "fifty"):
+		return EndFiftyMoves
+	case strings.Contains(termination, "agreement"):
+		return EndAgreement
+	case termination == "normal" && (result == "1-0" || result == "0-1"):
+		// not a checkmate (found in the final position)
+		return EndResignation
+	case termination == "normal" && result == "1/2-1/2":
+		return EndAgreement
+	}
+	return ""
+}
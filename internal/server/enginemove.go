@@ -0,0 +1,206 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/engine"
+	"github.com/flutterbar/chess-explorer-go/internal/evals"
+	"github.com/flutterbar/chess-explorer-go/internal/lichess"
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/notnil/chess"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// EngineMove ... preferred move of the engine in the position of the explorer (objective assessment, not from the games)
+type EngineMove struct {
+	Move  string `json:"move"` // SAN
+	Eval  string `json:"eval"` // white's point of view, lichess format: 0.35 or #-3
+	Depth int    `json:"depth"`
+}
+
+// engineCacheSize ... positions whose engine move is kept in memory at most (least recently used dropped first)
+const engineCacheSize = 10000
+
+// engineMoves ... engine moves of the positions evaluated (FEN), nil when there is none (no cloud evaluation),
+// and the positions being evaluated; a failed evaluation is not kept: the position is evaluated again when requested
+var engineMoves = struct {
+	sync.Mutex
+	moves   map[string]*list.Element // of order, value: engineEntry
+	order   *list.List               // most recently used first
+	pending map[string]bool
+}{moves: map[string]*list.Element{}, order: list.New(), pending: map[string]bool{}}
+
+// engineEntry ... engine move of a position in the cache
+type engineEntry struct {
+	fen  string
+	move *EngineMove
+}
+
+// engineBusy ... one evaluation at a time: the explorer requests never wait for it
+var engineBusy = make(chan struct{}, 1)
+
+// serverEngine ... engine of the server, started by the first evaluation and kept for the next ones (one engine:
+// one evaluation at a time), started again when the engine settings change or after a failure
+var serverEngine struct {
+	sync.Mutex
+	pool     *engine.Pool
+	settings engine.Settings
+}
+
+// engineMoveOf ... engine move after the line (or in the position) of {filter} when it was evaluated, nil without engine-path
+// setting (eval-source: cloud, nil when lichess.org has no evaluation of the position), when the engine fails (logged), or
+// while the position is evaluated in the background: the response does not wait for the engine, the next one has its move
+func engineMoveOf(filter *GameFilter) *EngineMove {
+	source, err := evals.Source()
+	if err != nil || (source == evals.SourceEngine && viper.GetString("engine-path") == "") || filter.invalid != nil {
+		return nil
+	}
+
+	position := filter.position
+	if position == nil {
+		position = chess.NewGame().Position()
+		for _, move := range filter.pgnMoves {
+			m, err := pgn.DecodeMove(position, move)
+			if err != nil {
+				return nil
+			}
+			position = position.Update(m)
+		}
+	}
+	if position.Status() != chess.NoMethod {
+		return nil // checkmate or stalemate
+	}
+
+	fen := position.String()
+	engineMoves.Lock()
+	defer engineMoves.Unlock()
+	if element, ok := engineMoves.moves[fen]; ok {
+		engineMoves.order.MoveToFront(element)
+		return element.Value.(engineEntry).move
+	}
+	if engineMoves.pending[fen] {
+		return nil
+	}
+	select {
+	case engineBusy <- struct{}{}:
+	default:
+		return nil // the engine evaluates another position: this one is evaluated when it is requested again
+	}
+	engineMoves.pending[fen] = true
+	go func() {
+		defer func() { <-engineBusy }()
+		move, err := computeEngineMove(position, source)
+		storeEngineMove(fen, move, err)
+	}()
+	return nil
+}
+
+// computeEngineMove ... engine move of {position}, nil when there is none
+func computeEngineMove(position *chess.Position, source string) (*EngineMove, error) {
+	eval, found, err := evaluate(position, source)
+	if err != nil || !found {
+		return nil, err
+	}
+	m, err := chess.UCINotation{}.Decode(position, eval.BestMove)
+	if err != nil {
+		return nil, errors.New("Engine move " + eval.BestMove + ": " + err.Error())
+	}
+	return &EngineMove{Move: chess.AlgebraicNotation{}.Encode(position, m), Eval: eval.String(), Depth: eval.Depth}, nil
+}
+
+// storeEngineMove ... {move} of the position {fen} in the cache, the least recently used position dropped when it is full;
+// on {err} (logged) nothing is kept: the position is evaluated again when it is requested again
+func storeEngineMove(fen string, move *EngineMove, err error) {
+	engineMoves.Lock()
+	defer engineMoves.Unlock()
+	delete(engineMoves.pending, fen)
+	if err != nil {
+		log.Warn(err)
+		return
+	}
+	engineMoves.moves[fen] = engineMoves.order.PushFront(engineEntry{fen: fen, move: move})
+	if engineMoves.order.Len() > engineCacheSize {
+		oldest := engineMoves.order.Back()
+		engineMoves.order.Remove(oldest)
+		delete(engineMoves.moves, oldest.Value.(engineEntry).fen)
+	}
+}
+
+// evaluate ... evaluation of {position} by the engine or the cloud of lichess.org ({source}), false when the cloud has none,
+// through the evals collection (except in demo mode: no database)
+func evaluate(position *chess.Position, source string) (engine.Eval, bool, error) {
+	settings := engine.SettingsFromConfig()
+	uncached := func(ctx context.Context, position *chess.Position) (engine.Eval, bool, error) {
+		if source == evals.SourceCloud {
+			cloud, err := lichess.NewCloudEval()
+			if err != nil {
+				return engine.Eval{}, false, err
+			}
+			return cloud.Evaluate(position.String())
+		}
+		pool, err := enginePool(settings)
+		if err != nil {
+			return engine.Eval{}, false, err
+		}
+		var eval engine.Eval
+		err = pool.Do(func(uci *engine.Engine) (err error) {
+			if err = uci.NewGame(); err == nil {
+				eval, err = uci.Evaluate(position.String())
+			}
+			return err
+		})
+		if err != nil {
+			dropEnginePool(pool)
+		}
+		return eval, err == nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	store, err := openStore(ctx)
+	if err != nil {
+		return engine.Eval{}, false, err
+	}
+	defer store.close(ctx)
+	minDepth := 0
+	if source == evals.SourceEngine {
+		minDepth = evals.MinDepth(settings)
+	}
+	return store.cachedEvaluate(source, minDepth, uncached)(ctx, position)
+}
+
+// enginePool ... the engine of the server with {settings}, started when there is none or the settings changed
+func enginePool(settings engine.Settings) (*engine.Pool, error) {
+	settings.Instances = 1
+	serverEngine.Lock()
+	defer serverEngine.Unlock()
+	if serverEngine.pool != nil && serverEngine.settings == settings {
+		return serverEngine.pool, nil
+	}
+	if serverEngine.pool != nil {
+		serverEngine.pool.Close()
+		serverEngine.pool = nil
+	}
+	pool, err := engine.NewPool(settings)
+	if err != nil {
+		return nil, err
+	}
+	serverEngine.pool, serverEngine.settings = pool, settings
+	return pool, nil
+}
+
+// dropEnginePool ... stop {pool} after a failure (the pool gives up an engine which cannot run): the next evaluation
+// starts a new engine
+func dropEnginePool(pool *engine.Pool) {
+	serverEngine.Lock()
+	defer serverEngine.Unlock()
+	if serverEngine.pool == pool {
+		serverEngine.pool = nil
+	}
+	pool.Close()
+}
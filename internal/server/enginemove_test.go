@@ -0,0 +1,31 @@
+package server
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStoreEngineMoveKeepsNoFailure(t *testing.T) {
+	fen := "8/8/8/8/8/8/8/K1k5 w - - 0 1"
+	engineMoves.Lock()
+	engineMoves.pending[fen] = true
+	engineMoves.Unlock()
+
+	storeEngineMove(fen, nil, errors.New("engine crashed"))
+	engineMoves.Lock()
+	_, cached := engineMoves.moves[fen]
+	pending := engineMoves.pending[fen]
+	engineMoves.Unlock()
+	if cached || pending {
+		t.Fatalf("failed evaluation: cached %t, pending %t, want neither", cached, pending)
+	}
+
+	move := &EngineMove{Move: "Kb2", Eval: "0.00", Depth: 20}
+	storeEngineMove(fen, move, nil)
+	engineMoves.Lock()
+	element, cached := engineMoves.moves[fen]
+	engineMoves.Unlock()
+	if !cached || element.Value.(engineEntry).move != move {
+		t.Errorf("evaluated position not cached")
+	}
+}
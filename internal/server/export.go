@@ -0,0 +1,146 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	log "github.com/sirupsen/logrus"
+)
+
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "exportHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	filter := gameFilterFromRequest(r)
+	if badRequest(w, filter) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-chess-pgn")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"chess-explorer.pgn\"")
+	limit := maxExportGames()
+	if limit > 0 {
+		w.Header().Set("X-Export-Limit", strconv.Itoa(limit))
+	}
+	if _, err := exportGames(context.Background(), w, filter, limit); err != nil {
+		// too late for an error status if games were already sent
+		log.Error(err)
+		if errors.Is(err, mongodb.ErrUnavailable) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		}
+	}
+}
+
+// Export ... write the games matching {filter} as PGN (oldest first)
+func Export(ctx context.Context, w io.Writer, filter *GameFilter) (int, error) {
+	return exportGames(ctx, w, filter, 0)
+}
+
+// errExportLimit ... stops EachGame when an export reaches its limit
+var errExportLimit = errors.New("export limit reached")
+
+// exportGames ... Export, {limit} games at most (0: no limit)
+func exportGames(ctx context.Context, w io.Writer, filter *GameFilter, limit int) (int, error) {
+	writer := bufio.NewWriter(w)
+	written := 0
+	count, err := EachGame(ctx, filter, func(game *pgntodb.Game) error {
+		if limit > 0 && written == limit {
+			return errExportLimit
+		}
+		written++
+		return pgn.Write(writer, gameToPgn(game))
+	})
+	if errors.Is(err, errExportLimit) {
+		log.Warn("export stopped after " + strconv.Itoa(count) + " games (max-export-games)")
+		err = nil
+	}
+	if err != nil {
+		return count, err
+	}
+	return count, writer.Flush()
+}
+
+// EachGame ... call {do} for every game matching {filter} (oldest first) in the games of {ctx} (see openStore),
+// stops at the first error
+func EachGame(ctx context.Context, filter *GameFilter, do func(game *pgntodb.Game) error) (int, error) {
+	if filter.invalid != nil {
+		return 0, filter.invalid
+	}
+
+	// Connect to DB
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	store, err := openStore(connectCtx)
+	if err != nil {
+		return 0, err
+	}
+	defer store.close(context.Background())
+
+	// the export is not limited in time
+	return store.eachGame(ctx, filter, do)
+}
+
+// gameToPgn ... rebuild PGN headers from the stored fields
+func gameToPgn(game *pgntodb.Game) *pgn.Game {
+	ret := pgn.Game{Movetext: game.PGN}
+
+	site := game.Site
+	if game.Site == "lichess.org" && game.Link != "" {
+		site = game.Link // lichess style
+	} else if game.Site == "chess.com" {
+		site = "Chess.com"
+	}
+	date := game.DateTime.UTC().Format("2006.01.02")
+
+	ret.Set("Event", "?")
+	ret.Set("Site", site)
+	ret.Set("Date", date)
+	ret.Set("Round", "?")
+	ret.Set("White", game.White)
+	ret.Set("Black", game.Black)
+	ret.Set("Result", game.Result)
+	ret.Set("UTCDate", date)
+	ret.Set("UTCTime", game.DateTime.UTC().Format("15:04:05"))
+	if game.WhiteRating() != 0 {
+		ret.Set("WhiteElo", strconv.Itoa(game.WhiteRating()))
+	}
+	if game.BlackRating() != 0 {
+		ret.Set("BlackElo", strconv.Itoa(game.BlackRating()))
+	}
+	if game.TimeControl != "" {
+		ret.Set("TimeControl", game.TimeControl)
+	}
+	if game.ECO != "" {
+		ret.Set("ECO", game.ECO)
+	}
+	if game.Opening != "" {
+		ret.Set("Opening", game.Opening)
+	}
+	if game.Link != "" && site != game.Link {
+		ret.Set("Link", game.Link)
+	}
+	if !pgn.IsResult(lastToken(game.PGN)) {
+		ret.Movetext = strings.TrimSpace(ret.Movetext + " " + game.Result)
+	}
+
+	return &ret
+}
+
+func lastToken(text string) string {
+	tokens := strings.Fields(text)
+	if len(tokens) == 0 {
+		return ""
+	}
+	return tokens[len(tokens)-1]
+}
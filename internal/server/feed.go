@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+Atom feed of the latest games of players, for feed readers:
+  /feed.atom?player=lichess.org:alice,chess.com:bob,carol   (username, lichess.org:username or chess.com:username)
+  &limit=50  entries, 200 at most
+*/
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  string      `xml:"author>name"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+func feedHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "feedHandler")
+
+	players := make([]string, 0)
+	for _, player := range strings.Split(r.FormValue("player"), ",") {
+		if player = strings.TrimSpace(player); player != "" {
+			players = append(players, player)
+		}
+	}
+	if len(players) == 0 {
+		http.Error(w, "player is missing: /feed.atom?player=lichess.org:username", http.StatusBadRequest)
+		return
+	}
+	var errs ValidationError
+	limit := errs.intParam(r.Form, "limit", 50, 1, 200)
+	if len(errs) > 0 {
+		http.Error(w, errs.Error(), http.StatusBadRequest)
+		return
+	}
+
+	games, err := latestGames(players, requestGamesOwner(r), limit)
+	if err != nil {
+		log.Error(err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, mongodb.ErrUnavailable) {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	self := serverURL() + r.URL.RequestURI()
+	feed := atomFeed{
+		Title:   "Chess games of " + strings.Join(players, ", "),
+		ID:      self,
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Author:  "chess-explorer",
+		Link:    atomLink{Href: self, Rel: "self"},
+		Entries: make([]atomEntry, 0, len(games)),
+	}
+	if len(games) > 0 {
+		feed.Updated = games[0].DateTime.UTC().Format(time.RFC3339)
+	}
+	for _, game := range games {
+		feed.Entries = append(feed.Entries, feedEntry(game, players))
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err = encoder.Encode(feed); err != nil {
+		log.Error(err)
+	}
+}
+
+// latestGames ... the {limit} most recent games of {players} among the games of {owner} ("": all the games), most recent first
+func latestGames(players []string, owner string, limit int) ([]pgntodb.Game, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	store, err := openStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer store.close(ctx)
+	return store.latestGames(ctx, players, owner, limit)
+}
+
+// eloText ... rating of a player, ? when unknown
+func eloText(elo int) string {
+	if elo == 0 {
+		return "?"
+	}
+	return strconv.Itoa(elo)
+}
+
+// feedEntry ... {game} told from the side of the followed player (white if both are followed)
+func feedEntry(game pgntodb.Game, players []string) atomEntry {
+	player, opponent := game.White, game.Black
+	playerElo, opponentElo := game.WhiteRating(), game.BlackRating()
+	playerWins := "1-0"
+	followedWhite := false
+	for _, followed := range players {
+		if followedUser(followed, game.Site, game.White) {
+			followedWhite = true
+		}
+	}
+	if !followedWhite {
+		player, opponent = game.Black, game.White
+		playerElo, opponentElo = game.BlackRating(), game.WhiteRating()
+		playerWins = "0-1"
+	}
+
+	outcome := "lost against"
+	switch game.Result {
+	case playerWins:
+		outcome = "won against"
+	case "1/2-1/2":
+		outcome = "drew with"
+	}
+	title := fmt.Sprintf("%s (%s) %s %s (%s)", player, eloText(playerElo), outcome, opponent, eloText(opponentElo))
+	if game.Opening != "" {
+		title += " (" + game.Opening + ")"
+	}
+
+	summary := fmt.Sprintf("%s (%s) - %s (%s) %s on %s, time control %s",
+		game.White, eloText(game.WhiteRating()), game.Black, eloText(game.BlackRating()), game.Result, game.Site, game.TimeControl)
+	if game.ECO != "" {
+		summary += ", " + game.ECO
+	}
+	if game.Opening != "" {
+		summary += " " + game.Opening
+	}
+	id := "urn:chess-explorer:game:" + game.ID
+	link := game.Link
+	if link == "" {
+		link = serverURL() + "/game?gameId=" + url.QueryEscape(game.ID)
+	}
+	return atomEntry{
+		Title:   title,
+		ID:      id,
+		Updated: game.DateTime.UTC().Format(time.RFC3339),
+		Link:    atomLink{Href: link, Rel: "alternate"},
+		Summary: summary,
+	}
+}
+
+// followedUser ... {followed} (username or site:username) is {name} on {site}, case insensitive
+func followedUser(followed string, site string, name string) bool {
+	split := strings.SplitN(followed, ":", 2)
+	if len(split) == 2 {
+		return convertSite(split[0]) == site && strings.EqualFold(split[1], name)
+	}
+	return strings.EqualFold(followed, name)
+}
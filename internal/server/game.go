@@ -3,17 +3,17 @@ package server
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
 	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
-	"github.com/spf13/viper"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 func gameHandler(w http.ResponseWriter, r *http.Request) {
@@ -21,6 +21,7 @@ func gameHandler(w http.ResponseWriter, r *http.Request) {
 	type gameResponse struct {
 		Error string       `json:"error"`
 		Data  pgntodb.Game `json:"data"`
+		Notes []Note       `json:"notes,omitempty"`
 	}
 
 	defer timeTrack(time.Now(), "gameHandler")
@@ -29,37 +30,201 @@ func gameHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	gameID := strings.TrimSpace(r.FormValue("gameId"))
+	link := strings.TrimSpace(r.FormValue("link"))
+	links := gameLinks(link)
+	if gameID == "" && link != "" && len(links) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, errors.New("Not a game URL: "+link))
+		return
+	}
 
 	// Connect to DB
-	client, err := mongo.NewClient(options.Client().ApplyURI(viper.GetString("mongo-url")))
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	store, err := openStore(ctx)
 	if err != nil {
-		log.Fatal(err)
+		writeError(w, err)
+		return
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer store.close(ctx)
+
+	game, err := store.game(ctx, requestGamesOwner(r), gameID, links)
+	if errors.Is(err, errGameNotFound) {
+		notFound := gameID
+		if gameID == "" {
+			notFound = link
+		}
+		w.WriteHeader(http.StatusNotFound)
+		writeError(w, errors.New("Game not found: "+notFound))
+		return
+	} else if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response := gameResponse{}
+	response.Data = game
+	response.Notes = notesOf(store.gameNotes(ctx, requestGamesOwner(r), game.ID))
+	json.NewEncoder(w).Encode(response)
+
+}
+
+// gamePgnHandler ... the game {gameId} as it was read, for download: tags, comments, clocks and evals
+// (games imported with an older version or with raw-pgn: false: the PGN of the stored fields)
+func gamePgnHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "gamePgnHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	gameID := strings.TrimSpace(r.FormValue("gameId"))
+	if gameID == "" {
+		badRequest(w, &GameFilter{}, FieldError{Field: "gameId", Message: "gameId is missing: /game/pgn?gameId={id}"})
+		return
+	}
+
+	game, err := findGame(gameID, requestGamesOwner(r))
+	if err != nil {
+		if errors.Is(err, errGameNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			err = errors.New("Game not found: " + gameID)
+		}
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-chess-pgn")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"chess-explorer-game.pgn\"")
+	if game.Raw != "" {
+		io.WriteString(w, game.Raw)
+		return
+	}
+	pgn.Write(w, gameToPgn(&game))
+}
+
+// findGame ... the game {gameID} of {owner} ("": any owner), errGameNotFound when there is none
+func findGame(gameID string, owner string) (pgntodb.Game, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	err = client.Connect(ctx)
+	store, err := openStore(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return pgntodb.Game{}, err
 	}
-	defer client.Disconnect(ctx)
+	defer store.close(ctx)
+	return store.game(ctx, owner, gameID, nil)
+}
 
-	// Ping MongoDB
-	if err = client.Ping(ctx, readpref.Primary()); err != nil {
-		log.Fatal("Cannot connect to DB " + viper.GetString("mongo-url"))
+// gameLinks ... links a game of the URL {link} can be stored with (nil when {link} is not a game URL):
+// lichess.org/{id} whatever the player's side, the move or the analysis (lichess.org/abcd1234wxyz/black#12),
+// the live and daily games of chess.com with both URL formats (chess.com/game/live/{id}, chess.com/live/game/{id})
+func gameLinks(link string) []string {
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" {
+		return nil
+	}
+	host := strings.TrimPrefix(strings.ToLower(parsed.Host), "www.")
+	path := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	switch host {
+	case "lichess.org":
+		if len(path[0]) < 8 {
+			return nil
+		}
+		return []string{"https://lichess.org/" + path[0][:8]}
+	case "chess.com":
+		// game/live/{id}, live/game/{id}, game/daily/{id}, daily/game/{id}, live#g={id}
+		id, kinds := path[len(path)-1], []string{"live", "daily"}
+		if strings.HasPrefix(parsed.Fragment, "g=") {
+			id = strings.TrimPrefix(parsed.Fragment, "g=")
+		}
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+			return nil
+		}
+		for _, part := range path {
+			if part == "live" || part == "daily" {
+				kinds = []string{part}
+			}
+		}
+		links := make([]string, 0, 4)
+		for _, kind := range kinds {
+			links = append(links, "https://www.chess.com/game/"+kind+"/"+id, "https://www.chess.com/"+kind+"/game/"+id)
+		}
+		return links
 	}
+	return []string{strings.TrimSuffix(link, "/")}
+}
 
-	games := client.Database(viper.GetString("mongo-db-name")).Collection("games")
+// inLinks ... {link} is one of {links}
+func inLinks(link string, links []string) bool {
+	for _, candidate := range links {
+		if link == candidate {
+			return true
+		}
+	}
+	return false
+}
 
-	result := games.FindOne(ctx, bson.M{"_id": gameID})
+// maxGamesByIDs ... games of one /games/byIds request at most
+const maxGamesByIDs = 200
 
-	var game pgntodb.Game
+// gamesByIDsHandler ... the games of a list of IDs in one response (id=...&id=...), in the order of the list
+func gamesByIDsHandler(w http.ResponseWriter, r *http.Request) {
 
-	if result != nil {
-		result.Decode(&game)
+	type gamesResponse struct {
+		Error   string         `json:"error"`
+		Data    []pgntodb.Game `json:"data"`
+		Missing []string       `json:"missing,omitempty"` // IDs without a game
 	}
 
-	response := gameResponse{}
-	response.Data = game
-	json.NewEncoder(w).Encode(response)
+	defer timeTrack(time.Now(), "gamesByIDsHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, err)
+		return
+	}
+	gameIDs := make([]string, 0)
+	for _, gameID := range r.Form["id"] {
+		if gameID = strings.TrimSpace(gameID); gameID != "" {
+			gameIDs = append(gameIDs, gameID)
+		}
+	}
+	if len(gameIDs) > maxGamesByIDs {
+		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, fmt.Errorf("%d game IDs: %d at most", len(gameIDs), maxGamesByIDs))
+		return
+	}
+
+	// Connect to DB
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	store, err := openStore(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer store.close(ctx)
 
+	found, err := store.games(ctx, requestGamesOwner(r), gameIDs)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	byID := make(map[string]pgntodb.Game, len(found))
+	for _, game := range found {
+		byID[game.ID] = game
+	}
+	response := gamesResponse{Data: make([]pgntodb.Game, 0, len(gameIDs))}
+	for _, gameID := range gameIDs {
+		if game, ok := byID[gameID]; ok {
+			response.Data = append(response.Data, game)
+		} else {
+			response.Missing = append(response.Missing, gameID)
+		}
+	}
+	json.NewEncoder(w).Encode(response)
 }
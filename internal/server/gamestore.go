@@ -0,0 +1,348 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/evals"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+Games of the handlers: the database (mongoStore), or the games of the demo mode kept in memory (memoryStore, see
+demo.go). The handlers open the store of the server and read it the same way in both modes; the features needing the
+database alone (notes, bookmarks, share links, imports...) are refused in demo mode by databaseOnly.
+*/
+
+// gameStore ... games, teams and notes read by the handlers
+type gameStore interface {
+	// close ... release the connection
+	close(ctx context.Context)
+	// nextMoves ... NextMoves of {filter} recording the steps in {diagnostics} (when not nil), true when truncated
+	nextMoves(ctx context.Context, filter *GameFilter, diagnostics *Diagnostics) ([]NextMove, bool, error)
+	// referenceNextMoves ... next moves of the reference games in the line or position of {values}
+	referenceNextMoves(ctx context.Context, values url.Values) ([]NextMove, error)
+	// eachGame ... call {do} for every game of {filter} (oldest first), stops at the first error
+	eachGame(ctx context.Context, filter *GameFilter, do func(game *pgntodb.Game) error) (int, error)
+	// game ... the game {gameID} of {owner}, or the game of one of {links} when {gameID} is "" (errGameNotFound)
+	game(ctx context.Context, owner string, gameID string, links []string) (pgntodb.Game, error)
+	// games ... the games {ids} of {owner} found, in any order
+	games(ctx context.Context, owner string, ids []string) ([]pgntodb.Game, error)
+	// latestGames ... the {limit} most recent games of {players} among the games of {owner}, most recent first
+	latestGames(ctx context.Context, players []string, owner string, limit int) ([]pgntodb.Game, error)
+	// gamesReaching ... games of {filter} reaching the position {hash} (all of them: the starting position)
+	gamesReaching(ctx context.Context, filter *GameFilter, hash int64, starting bool) ([]pgntodb.Game, error)
+	// eachSimilarCandidate ... call {do} for every game of {filter} sharing a position of {game} from the ply
+	// similarFrom, stops at the first error
+	// (the games of the reference with {reference})
+	eachSimilarCandidate(ctx context.Context, game *pgntodb.Game, filter *GameFilter, reference bool, do func(candidate *pgntodb.Game) error) error
+	// report ... games of the players and dates of {filter} by site, user and time control
+	report(ctx context.Context, filter *GameFilter) (report, error)
+	// openings ... OpeningDistribution of {filter}
+	openings(ctx context.Context, filter *GameFilter) ([]OpeningSlice, error)
+	// precomputed ... decode the report {name} of {player} computed in advance into {into}, and when (nil: not computed)
+	precomputed(ctx context.Context, name string, player string, into interface{}) *time.Time
+	// sites ... the sites of the games of {owner}
+	sites(ctx context.Context, owner string) ([]string, error)
+	// tags ... Tags of the games of {owner}
+	tags(ctx context.Context, owner string) ([]TagCount, error)
+	// tagGames ... TagGames
+	tagGames(ctx context.Context, owner string, ids []string, add []string, remove []string) (int64, int64, error)
+	// teams ... Teams of {owner}
+	teams(ctx context.Context, owner string) ([]Team, error)
+	// saveTeam ... SaveTeam
+	saveTeam(ctx context.Context, team Team) (Team, error)
+	// deleteTeam ... DeleteTeam
+	deleteTeam(ctx context.Context, owner string, name string) error
+	// gameNotes ... GameNotes
+	gameNotes(ctx context.Context, owner string, gameID string) ([]Note, error)
+	// positionNotes ... PositionNotes
+	positionNotes(ctx context.Context, owner string, hash int64) ([]Note, error)
+	// cachedEvaluate ... {uncached} with the evaluations of {source} stored (see evals.Cache)
+	cachedEvaluate(source string, minDepth int, uncached evals.Evaluate) evals.Evaluate
+}
+
+// errGameNotFound ... no game with the ID or the link
+var errGameNotFound = errors.New("game not found")
+
+// openStore ... the games of the server: in memory in demo mode, else in the database
+func openStore(ctx context.Context) (gameStore, error) {
+	if store := demoOf(ctx); store != nil {
+		return store, nil
+	}
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &mongoStore{client: client, disconnect: true}, nil
+}
+
+// storeOf ... the games of the server read with the connection of {games} (nil in demo mode)
+func storeOf(games *mongo.Collection) gameStore {
+	if demo != nil {
+		return demo
+	}
+	return &mongoStore{client: mongodb.ClientOf(games)}
+}
+
+// mongoStore ... games in the database
+type mongoStore struct {
+	client     *mongodb.Client
+	disconnect bool // the connection was opened by openStore
+}
+
+func (store *mongoStore) close(ctx context.Context) {
+	if store.disconnect {
+		store.client.Disconnect(ctx)
+	}
+}
+
+func (store *mongoStore) collection(name string) *mongo.Collection {
+	return mongodb.Collection(store.client, name)
+}
+
+func (store *mongoStore) nextMoves(ctx context.Context, filter *GameFilter, diagnostics *Diagnostics) ([]NextMove, bool, error) {
+	// the top of the tree computed in advance (see warm)
+	if nextmoves, truncated, cached := cachedNextMoves(ctx, store.client, filter); cached {
+		return nextmoves, truncated, nil
+	}
+	return nextMoves(ctx, store.collection("games"), filter, diagnostics)
+}
+
+func (store *mongoStore) referenceNextMoves(ctx context.Context, values url.Values) ([]NextMove, error) {
+	return referenceNextMoves(ctx, store.client, values)
+}
+
+func (store *mongoStore) eachGame(ctx context.Context, filter *GameFilter, do func(game *pgntodb.Game) error) (int, error) {
+	// the whole line is a prefix of the games to export (no next move needed)
+	filter.mongoAggregation = false
+	findOptions := options.Find().SetSort(map[string]int{"datetime": 1}).SetProjection(pgntodb.WithoutRaw)
+	cursor, err := store.collection("games").Find(ctx, bsonFromGameFilter(filter), findOptions)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	count := 0
+	for cursor.Next(ctx) {
+		var game pgntodb.Game
+		if err = cursor.Decode(&game); err != nil {
+			return count, err
+		}
+		if err = do(&game); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, cursor.Err()
+}
+
+func (store *mongoStore) game(ctx context.Context, owner string, gameID string, links []string) (pgntodb.Game, error) {
+	query := bson.M{"_id": gameID}
+	if gameID == "" {
+		query = bson.M{"link": bson.M{"$in": links}}
+	}
+	var game pgntodb.Game
+	err := store.collection("games").FindOne(ctx, pgntodb.OwnerQuery(query, owner)).Decode(&game)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = errGameNotFound
+	}
+	return game, err
+}
+
+func (store *mongoStore) games(ctx context.Context, owner string, ids []string) ([]pgntodb.Game, error) {
+	found := make([]pgntodb.Game, 0)
+	if len(ids) == 0 {
+		return found, nil
+	}
+	cursor, err := store.collection("games").Find(ctx, pgntodb.OwnerQuery(bson.M{"_id": bson.M{"$in": ids}}, owner))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	err = cursor.All(ctx, &found)
+	return found, err
+}
+
+func (store *mongoStore) latestGames(ctx context.Context, players []string, owner string, limit int) ([]pgntodb.Game, error) {
+	or := bson.A{}
+	for _, player := range players {
+		split := strings.SplitN(player, ":", 2)
+		if len(split) == 2 {
+			site := convertSite(split[0])
+			or = append(or, bson.M{"site": site, "white": split[1]}, bson.M{"site": site, "black": split[1]})
+		} else {
+			or = append(or, bson.M{"white": player}, bson.M{"black": player})
+		}
+	}
+	// case insensitive usernames
+	collation := options.Collation{Locale: "en", Strength: 2}
+	findOptions := options.Find().SetSort(bson.M{"datetime": -1}).SetLimit(int64(limit)).SetCollation(&collation)
+	cursor, err := store.collection("games").Find(ctx, pgntodb.OwnerQuery(bson.M{"$or": or}, owner), findOptions)
+	if err != nil {
+		return nil, err
+	}
+	games := make([]pgntodb.Game, 0)
+	err = cursor.All(ctx, &games)
+	return games, err
+}
+
+func (store *mongoStore) gamesReaching(ctx context.Context, filter *GameFilter, hash int64, starting bool) ([]pgntodb.Game, error) {
+	query := bsonFromGameFilter(filter)
+	if !starting {
+		query = bson.M{"$and": bson.A{query, bson.M{"hashes": hash}}}
+	}
+	findOptions := options.Find().
+		SetSort(bson.M{"datetime": -1}).
+		SetLimit(maxModelGames).
+		SetProjection(bson.M{"pgn": 1, "hashes": 1, "result": 1})
+	cursor, err := store.collection("games").Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	var games []pgntodb.Game
+	err = cursor.All(ctx, &games)
+	return games, err
+}
+
+func (store *mongoStore) eachSimilarCandidate(ctx context.Context, game *pgntodb.Game, filter *GameFilter, reference bool, do func(candidate *pgntodb.Game) error) error {
+	if len(game.Hashes) == 0 {
+		return errors.New("Game " + game.ID + " has no position hashes: run reindex")
+	}
+	from := similarFrom - 1
+	if from >= len(game.Hashes) {
+		from = len(game.Hashes) - 1
+	}
+	hashes := bson.A{}
+	for _, hash := range game.Hashes[from:] {
+		hashes = append(hashes, hash)
+	}
+
+	collection := store.collection("games")
+	if reference {
+		collection = store.collection(pgntodb.ReferenceCollection)
+	}
+	filter.mongoAggregation = false
+	query := bson.M{"$and": bson.A{bsonFromGameFilter(filter), bson.M{"hashes": bson.M{"$in": hashes}}, bson.M{"_id": bson.M{"$ne": game.ID}}}}
+	// only what a SimilarGame needs: the candidates are streamed, not loaded together
+	projection := bson.M{"white": 1, "black": 1, "whiteelo": 1, "blackelo": 1, "result": 1, "datetime": 1, "link": 1, "pgn": 1, "hashes": 1}
+	findOptions := options.Find().SetProjection(projection)
+	if limit := maxSearchGames(); limit > 0 {
+		findOptions.SetLimit(limit)
+	}
+	cursor, err := collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var candidate pgntodb.Game
+		if err = cursor.Decode(&candidate); err != nil {
+			return err
+		}
+		if err = do(&candidate); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (store *mongoStore) report(ctx context.Context, filter *GameFilter) (report, error) {
+	games := store.collection("games")
+	lastgames := store.collection("lastgames")
+
+	// Total games
+	totalGames, err := games.CountDocuments(ctx, pgntodb.OwnerQuery(bson.M{}, filter.owner))
+	if err != nil {
+		return report{}, err
+	}
+	report := report{}
+	report.TotalGames = totalGames
+
+	if filter.black == "" && filter.white == "" {
+		err = reportSites(ctx, games, filter.owner, &report)
+		if err == nil {
+			err = reportUsers(ctx, games, lastgames, filter.owner, &report)
+		}
+		if err == nil {
+			err = reportTimeControls(ctx, filter, games, &report)
+		}
+	} else {
+		err = reportTimeControls(ctx, filter, games, &report)
+		if err == nil && (filter.white == "" || filter.black == "") {
+			player, color := filter.white, "white"
+			if player == "" {
+				player, color = filter.black, "black"
+			}
+			if filter.from == "" && filter.to == "" {
+				report.OpeningsComputedAt = findReportInto(ctx, store.client, "openings-"+color, player, &report.Openings)
+			}
+			if report.OpeningsComputedAt == nil {
+				report.Openings, err = ReportOpenings(ctx, games, player, color, filter)
+			}
+		}
+	}
+	return report, err
+}
+
+func (store *mongoStore) openings(ctx context.Context, filter *GameFilter) ([]OpeningSlice, error) {
+	return OpeningDistribution(ctx, store.collection("games"), filter)
+}
+
+func (store *mongoStore) precomputed(ctx context.Context, name string, player string, into interface{}) *time.Time {
+	return findReportInto(ctx, store.client, name, player, into)
+}
+
+func (store *mongoStore) sites(ctx context.Context, owner string) ([]string, error) {
+	values, err := store.collection("games").Distinct(ctx, "site", pgntodb.OwnerQuery(bson.M{}, owner))
+	if err != nil {
+		return nil, err
+	}
+	sites := make([]string, 0, len(values))
+	for _, value := range values {
+		if site, ok := value.(string); ok {
+			sites = append(sites, site)
+		}
+	}
+	return sites, nil
+}
+
+func (store *mongoStore) tags(ctx context.Context, owner string) ([]TagCount, error) {
+	return Tags(ctx, store.collection("games"), owner)
+}
+
+func (store *mongoStore) tagGames(ctx context.Context, owner string, ids []string, add []string, remove []string) (int64, int64, error) {
+	return TagGames(ctx, store.collection("games"), owner, ids, add, remove)
+}
+
+func (store *mongoStore) teams(ctx context.Context, owner string) ([]Team, error) {
+	return Teams(ctx, store.collection("teams"), owner)
+}
+
+func (store *mongoStore) saveTeam(ctx context.Context, team Team) (Team, error) {
+	return SaveTeam(ctx, store.collection("teams"), team)
+}
+
+func (store *mongoStore) deleteTeam(ctx context.Context, owner string, name string) error {
+	return DeleteTeam(ctx, store.collection("teams"), owner, name)
+}
+
+func (store *mongoStore) gameNotes(ctx context.Context, owner string, gameID string) ([]Note, error) {
+	return GameNotes(ctx, store.collection("notes"), owner, gameID)
+}
+
+func (store *mongoStore) positionNotes(ctx context.Context, owner string, hash int64) ([]Note, error) {
+	return PositionNotes(ctx, store.collection("notes"), owner, hash)
+}
+
+func (store *mongoStore) cachedEvaluate(source string, minDepth int, uncached evals.Evaluate) evals.Evaluate {
+	return evals.NewCache(store.client).Cached(source, minDepth, uncached)
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxImportBytes ... size of the PGN posted to /import/pgn at most
+const maxImportBytes = 1 << 20
+
+// importSource ... provenance of the games posted to /import/pgn
+const importSource = "/import/pgn"
+
+// importPGNHandler ... POST /import/pgn with the PGN text as the body (one game or more, pasted in the web page...):
+// the games go through the pgntodb import and the ids of the new games are returned
+func importPGNHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "importPGNHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type importResult struct {
+		IDs        []string        `json:"ids"`        // games inserted
+		Duplicates []string        `json:"duplicates"` // games already in the database
+		Summary    pgntodb.Summary `json:"summary"`
+	}
+	type importResponse struct {
+		Error string        `json:"error"`
+		Data  *importResult `json:"data"`
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		writeError(w, errors.New("POST the PGN text to /import/pgn"))
+		return
+	}
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+	defer body.Close()
+
+	source := importSource
+	if username := loggedInUser(r); username != "" {
+		source += " (lichess.org:" + username + ")"
+	}
+	lastGame := &pgntodb.LastGame{Provenance: pgntodb.NewBatch("server " + importSource), Owner: requestGamesOwner(r)}
+	unlock := pgntodb.LockImport() // after the running synchronization (server --with-sync) or import
+	before := pgntodb.Totals()
+	ids, duplicates, err := pgntodb.ProcessReader(body, source, lastGame)
+	summary := pgntodb.Totals().Minus(before)
+	unlock()
+	if err != nil {
+		if strings.Contains(err.Error(), "request body too large") {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		}
+		writeError(w, err)
+		return
+	}
+	if summary.Games-summary.Skipped == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, errors.New("No standard game found in the PGN (games from a position and variants are skipped)"))
+		return
+	}
+	log.Printf("%d games imported from %s, %d already in the database", len(ids), source, len(duplicates))
+	forgetSites() // the site of the games may be new
+
+	if ids == nil {
+		ids = []string{}
+	}
+	if duplicates == nil {
+		duplicates = []string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(importResponse{Data: &importResult{IDs: ids, Duplicates: duplicates, Summary: summary}})
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// maxSearchGames ... games replayed by a search for FEN at most (max-search-games, 0: no limit)
+func maxSearchGames() int64 {
+	if viper.IsSet("max-search-games") {
+		return viper.GetInt64("max-search-games")
+	}
+	return 100000
+}
+
+// maxExportGames ... games of an export of the web server at most (max-export-games, 0: no limit)
+func maxExportGames() int {
+	if viper.IsSet("max-export-games") {
+		return viper.GetInt("max-export-games")
+	}
+	return 50000
+}
+
+// maxTreeDepth ... plies of an opening tree at most (max-tree-depth)
+func maxTreeDepth() int {
+	if viper.IsSet("max-tree-depth") {
+		return viper.GetInt("max-tree-depth")
+	}
+	return 40
+}
+
+// maxHeavyQueries ... heavy queries (exports, reports, searches) running at the same time for a client IP at most
+// (max-heavy-queries, 0: no limit)
+func maxHeavyQueries() int {
+	if viper.IsSet("max-heavy-queries") {
+		return viper.GetInt("max-heavy-queries")
+	}
+	return 2
+}
+
+// heavyQueries ... heavy queries running by client IP
+var heavyQueries = struct {
+	sync.Mutex
+	running map[string]int
+}{running: map[string]int{}}
+
+// acquireHeavy ... take a heavy query slot for the client of {r}, false when the client already runs max-heavy-queries queries;
+// call release when the query is over
+func acquireHeavy(r *http.Request) (release func(), ok bool) {
+	limit := maxHeavyQueries()
+	if limit <= 0 {
+		return func() {}, true
+	}
+	ip := clientIP(r)
+
+	heavyQueries.Lock()
+	defer heavyQueries.Unlock()
+	if heavyQueries.running[ip] >= limit {
+		return nil, false
+	}
+	heavyQueries.running[ip]++
+	return func() {
+		heavyQueries.Lock()
+		defer heavyQueries.Unlock()
+		if heavyQueries.running[ip]--; heavyQueries.running[ip] <= 0 {
+			delete(heavyQueries.running, ip)
+		}
+	}, true
+}
+
+// heavy ... {handler} runs max-heavy-queries times at the same time for a client IP at most, 429 Too Many Requests otherwise
+func heavy(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		release, ok := acquireHeavy(r)
+		if !ok {
+			tooManyRequests(w, r)
+			return
+		}
+		defer release()
+		handler(w, r)
+	}
+}
+
+// tooManyRequests ... 429 when the client already runs max-heavy-queries queries
+func tooManyRequests(w http.ResponseWriter, r *http.Request) {
+	log.Warn("too many heavy queries from " + clientIP(r) + ": " + r.URL.Path)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", "5")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{Error: "too many queries running: wait for the previous ones to finish"})
+}
+
+// clientIP ... IP address of the client of {r} (the address of the proxy when there is one)
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
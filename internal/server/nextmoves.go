@@ -4,20 +4,28 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"math"
 	"net/http"
+	"net/url"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
 	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/flutterbar/chess-explorer-go/internal/sites"
+	"github.com/flutterbar/chess-explorer-go/internal/winmodel"
+	"github.com/flutterbar/chess-explorer-go/internal/zobrist"
+	"github.com/notnil/chess"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 // GameFilter ... represents the filter form from the UI
@@ -27,13 +35,81 @@ type GameFilter struct {
 	black               string
 	timecontrol         string
 	simplifyTimecontrol string
-	from                string
-	to                  string
-	minelo              string
-	maxelo              string
+	from                string         // YYYY-MM-DD, first day in location
+	to                  string         // YYYY-MM-DD, last day in location
+	location            *time.Location // time zone of the dates (tz), nil for UTC
+	minelo              int            // 0 when not set
+	maxelo              int            // 0 when not set
+	unknownElo          string         // include or exclude the games with an unknown rating (see eloBounds)
 	site                string
+	result              string // 1-0, 0-1, 1/2-1/2, or win, loss, draw (see gameResult)
+	batch               string // import batch(es), comma separated (provenance)
+	speed               string // bullet, blitz, rapid, classical, correspondence, comma separated (structured time control)
+	tag                 string // tag(s) set by the user, comma separated
+	owner               string // games of this owner only (isolation, see owner.go), "" for all the games
 	pgnMoves            []string
 	mongoAggregation    bool
+	aggregation         string // mongo or algorithmic to force the path of the next moves ("": mongo below 20 moves)
+	debug               bool   // diagnostics of the next moves in the response
+	// position to explore instead of pgn, any move order (games with its Zobrist hash, see zobrist)
+	fen      string
+	position *chess.Position
+	hash     int64
+	// invalid values of the request (ValidationError: pgn, fen, minelo, maxelo, unknownelo, from, to, tz...): bad request
+	invalid error
+}
+
+// Result ... number of games ending with {Result} after a move
+type Result struct {
+	Result string `json:"result,omitempty"`
+	Sum    uint32 `json:"sum,omitempty"`
+}
+
+// NextMove ... a move played in the position, with the results of the games
+type NextMove struct {
+	move01  string `bson:"m01,omitempty"`
+	move02  string `bson:"m02,omitempty"`
+	move03  string `bson:"m03,omitempty"`
+	move04  string `bson:"m04,omitempty"`
+	move05  string `bson:"m05,omitempty"`
+	move06  string `bson:"m06,omitempty"`
+	move07  string `bson:"m07,omitempty"`
+	move08  string `bson:"m08,omitempty"`
+	move09  string `bson:"m09,omitempty"`
+	move10  string `bson:"m10,omitempty"`
+	move11  string `bson:"m11,omitempty"`
+	move12  string `bson:"m12,omitempty"`
+	move13  string `bson:"m13,omitempty"`
+	move14  string `bson:"m14,omitempty"`
+	move15  string `bson:"m15,omitempty"`
+	move16  string `bson:"m16,omitempty"`
+	move17  string `bson:"m17,omitempty"`
+	move18  string `bson:"m18,omitempty"`
+	move19  string `bson:"m19,omitempty"`
+	move20  string `bson:"m20,omitempty"`
+	tmpGame pgntodb.Game
+	// Only the fields below go in the response
+	Results []Result     `json:"results"`
+	Move    string       `json:"move"`
+	White   uint32       `json:"white"`
+	Draw    uint32       `json:"draw"`
+	Black   uint32       `json:"black"`
+	Total   uint32       `json:"total"`
+	Game    pgntodb.Game `json:"game,omitempty"`    // when Total = 1
+	AvgTime float64      `json:"avgtime,omitempty"` // average seconds spent on the move, in the games with clocks
+	AvgEval *float64     `json:"avgeval,omitempty"` // average evaluation after the move in pawns (white's point of view), in the games with evaluations
+	// expected score of white (win probability model: ratings, evaluation, time control, see winmodel), in the games with both ratings
+	ExpectedScore *float64 `json:"expectedscore,omitempty"`
+	// when Total = 1: why the game ended (checkmate, resignation, time... see ending.go) and its final position
+	Termination string `json:"termination,omitempty"`
+	FinalFEN    string `json:"finalfen,omitempty"`
+	// sums and number of games with clocks and evaluations
+	TimeSum   float64 `json:"-" bson:"timesum"`
+	Timed     uint32  `json:"-" bson:"timed"`
+	EvalSum   float64 `json:"-" bson:"evalsum"`
+	Evaluated uint32  `json:"-" bson:"evaluated"`
+	ExpSum    float64 `json:"-" bson:"expsum"`
+	Expected  uint32  `json:"-" bson:"expected"`
 }
 
 func nextMovesHandler(w http.ResponseWriter, r *http.Request) {
@@ -43,49 +119,18 @@ func nextMovesHandler(w http.ResponseWriter, r *http.Request) {
 	// allow cross origin
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	type Result struct {
-		Result string `json:"result,omitempty"`
-		Sum    uint32 `json:"sum,omitempty"`
-	}
-	type NextMove struct {
-		move01  string `bson:"m01,omitempty"`
-		move02  string `bson:"m02,omitempty"`
-		move03  string `bson:"m03,omitempty"`
-		move04  string `bson:"m04,omitempty"`
-		move05  string `bson:"m05,omitempty"`
-		move06  string `bson:"m06,omitempty"`
-		move07  string `bson:"m07,omitempty"`
-		move08  string `bson:"m08,omitempty"`
-		move09  string `bson:"m09,omitempty"`
-		move10  string `bson:"m10,omitempty"`
-		move11  string `bson:"m11,omitempty"`
-		move12  string `bson:"m12,omitempty"`
-		move13  string `bson:"m13,omitempty"`
-		move14  string `bson:"m14,omitempty"`
-		move15  string `bson:"m15,omitempty"`
-		move16  string `bson:"m16,omitempty"`
-		move17  string `bson:"m17,omitempty"`
-		move18  string `bson:"m18,omitempty"`
-		move19  string `bson:"m19,omitempty"`
-		move20  string `bson:"m20,omitempty"`
-		tmpGame pgntodb.Game
-		// Only the fields below go in the response
-		Results []Result     `json:"results"`
-		Move    string       `json:"move"`
-		White   uint32       `json:"white"`
-		Draw    uint32       `json:"draw"`
-		Black   uint32       `json:"black"`
-		Total   uint32       `json:"total"`
-		Game    pgntodb.Game `json:"game,omitempty"` // when Total = 1
-	}
-
 	type nextMovesResponse struct {
-		Error string     `json:"error"`
-		Data  []NextMove `json:"data"`
+		Error  string       `json:"error"`
+		Data   []NextMove   `json:"data"`
+		Engine *EngineMove  `json:"engine,omitempty"` // not from the games
+		Debug  *Diagnostics `json:"debug,omitempty"`  // debug=true
+		// the algorithmic path stopped at max-scanned-games: the counts are from the first games only
+		Truncated bool   `json:"truncated,omitempty"`
+		Notes     []Note `json:"notes,omitempty"` // of the position, whatever the move order
+		// reference=true: the moves of the reference games in the line or position (see pgntodb.ReferenceCollection)
+		Reference []NextMove `json:"reference,omitempty"`
 	}
 
-	var nextmoves []NextMove
-
 	switch r.Method {
 	case "POST":
 		// Call ParseForm() to parse the raw query and update r.PostForm and r.Form.
@@ -98,31 +143,88 @@ func nextMovesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Connect to DB
-	client, err := mongo.NewClient(options.Client().ApplyURI(viper.GetString("mongo-url")))
-	if err != nil {
-		log.Fatal(err)
+	filter := gameFilterFromRequest(r)
+	if badRequest(w, filter) {
+		return
 	}
+	// Connect to DB
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	err = client.Connect(ctx)
+	store, err := openStore(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer store.close(ctx)
+
+	var diagnostics *Diagnostics
+	if filter.debug {
+		diagnostics = &Diagnostics{}
+	}
+	nextmoves, truncated, err := store.nextMoves(ctx, filter, diagnostics)
 	if err != nil {
-		log.Fatal(err)
+		writeError(w, err)
+		return
 	}
-	defer client.Disconnect(ctx)
 
-	// Ping MongoDB
-	if err = client.Ping(ctx, readpref.Primary()); err != nil {
-		log.Fatal("Cannot connect to DB " + viper.GetString("mongo-url"))
+	var reference []NextMove
+	if r.Form.Get("reference") == "true" {
+		if reference, err = store.referenceNextMoves(ctx, r.Form); err != nil {
+			writeError(w, err)
+			return
+		}
 	}
 
-	games := client.Database(viper.GetString("mongo-db-name")).Collection("games")
+	// send the response
+	response := nextMovesResponse{}
+	response.Reference = reference
+	response.Data = nextmoves
+	if response.Data == nil {
+		response.Data = []NextMove{} // no game
+	}
+	response.Engine = engineMoveOf(filter)
+	response.Debug = diagnostics
+	response.Truncated = truncated
+	response.Notes = notesOf(store.positionNotes(ctx, filter.owner, zobrist.Hash(filter.boardPosition())))
+	json.NewEncoder(w).Encode(response)
+}
 
-	// create game filter
-	filter := gameFilterFromRequest(r)
+// NextMoves ... moves played after the opening of {filter} with their results, most played first
+// games ending right after the opening come last (Move "End")
+// (the algorithmic path counts the first max-scanned-games games only)
+func NextMoves(ctx context.Context, games *mongo.Collection, filter *GameFilter) ([]NextMove, error) {
+	nextmoves, _, err := nextMoves(ctx, games, filter, nil)
+	return nextmoves, err
+}
+
+// nextMoves ... NextMoves, recording the path, the documents scanned and the time of each step in {diagnostics} (when not nil),
+// true when the algorithmic path stopped at max-scanned-games
+func nextMoves(ctx context.Context, games *mongo.Collection, filter *GameFilter, diagnostics *Diagnostics) ([]NextMove, bool, error) {
+	if filter.invalid != nil {
+		return nil, false, filter.invalid
+	}
+	if diagnostics != nil {
+		defer func(start time.Time) { diagnostics.TotalMs = milliseconds(time.Since(start)) }(time.Now())
+		diagnostics.Aggregation, diagnostics.Reason = filter.aggregationPath()
+	}
+	var nextmoves []NextMove
+	var loneGames []pgntodb.Game
+	truncated := false
 	gameFilterBson := bsonFromGameFilter(filter)
 
 	if filter.mongoAggregation {
+		if diagnostics != nil {
+			// only counted when debugging: the pipeline does not tell how many documents it matched
+			start := time.Now()
+			scanned, err := games.CountDocuments(ctx, gameFilterBson)
+			if err != nil {
+				return nil, false, err
+			}
+			diagnostics.Scanned = scanned
+			diagnostics.stage("count", start)
+		}
+
+		start := time.Now()
 		pipeline := make([]bson.M, 0)
 		pipeline = append(pipeline, bson.M{"$match": gameFilterBson})
 
@@ -130,148 +232,350 @@ func nextMovesHandler(w http.ResponseWriter, r *http.Request) {
 		fieldNum := len(filter.pgnMoves) + 1
 		moveField := buildMoveFieldName(fieldNum)
 
+		// thinking time and evaluation of the move, missing for games without clocks or evaluations
+		moveTime := bson.M{"$arrayElemAt": bson.A{"$times", fieldNum - 1}}
+		moveEval := bson.M{"$arrayElemAt": bson.A{"$evals", fieldNum - 1}}
+		// expected score, null for games without both ratings
+		model, err := winmodel.Current(ctx, mongodb.ClientOf(games))
+		if err != nil {
+			return nil, false, err
+		}
+		moveExpected := model.Expression(fieldNum - 1)
+		diagnostics.stage("winmodel", start)
 		groupStage := bson.M{
 			"$group": bson.M{
-				"_id":    bson.M{moveField: "$" + moveField, "result": "$result"},
-				"total":  bson.M{"$sum": 1},
-				"result": bson.M{"$push": "$result"},
+				"_id":       bson.M{moveField: "$" + moveField, "result": "$result"},
+				"total":     bson.M{"$sum": 1},
+				"result":    bson.M{"$push": "$result"},
+				"timesum":   bson.M{"$sum": moveTime},
+				"timed":     bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{moveTime, nil}}, 1, 0}}},
+				"evalsum":   bson.M{"$sum": moveEval},
+				"evaluated": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{moveEval, nil}}, 1, 0}}},
+				"expsum":    bson.M{"$sum": moveExpected},
+				"expected":  bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{moveExpected, nil}}, 1, 0}}},
 			},
 		}
 		pipeline = append(pipeline, groupStage)
 
 		subGroupStage := bson.M{
 			"$group": bson.M{
-				"_id":     bson.M{moveField: "$_id." + moveField},
-				"results": bson.M{"$addToSet": bson.M{"result": "$_id.result", "sum": "$total"}},
+				"_id":       bson.M{moveField: "$_id." + moveField},
+				"results":   bson.M{"$addToSet": bson.M{"result": "$_id.result", "sum": "$total"}},
+				"timesum":   bson.M{"$sum": "$timesum"},
+				"timed":     bson.M{"$sum": "$timed"},
+				"evalsum":   bson.M{"$sum": "$evalsum"},
+				"evaluated": bson.M{"$sum": "$evaluated"},
+				"expsum":    bson.M{"$sum": "$expsum"},
+				"expected":  bson.M{"$sum": "$expected"},
 			},
 		}
 		pipeline = append(pipeline, subGroupStage)
 
 		projectStage := bson.M{
 			"$project": bson.M{
-				"_id":     false,
-				"move":    "$_id." + moveField,
-				"results": "$results",
+				"_id":       false,
+				"move":      "$_id." + moveField,
+				"results":   "$results",
+				"timesum":   "$timesum",
+				"timed":     "$timed",
+				"evalsum":   "$evalsum",
+				"evaluated": "$evaluated",
+				"expsum":    "$expsum",
+				"expected":  "$expected",
 			},
 		}
 		pipeline = append(pipeline, projectStage)
 
+		start = time.Now()
 		aggregateCursor, err := games.Aggregate(ctx, pipeline)
 		if err != nil {
-			log.Fatal(err)
+			return nil, false, err
 		}
 
 		defer aggregateCursor.Close(ctx)
 
 		if err = aggregateCursor.All(ctx, &nextmoves); err != nil {
-			log.Fatal(err)
+			return nil, false, err
 		}
+		diagnostics.stage("aggregate", start)
 	} else {
-		// algorythmic aggregation
-		cursor, err := games.Find(ctx, gameFilterBson)
-		defer cursor.Close(ctx)
+		// algorythmic aggregation: the games are counted while they are read, up to max-scanned-games
+		start := time.Now()
+		model, err := winmodel.Current(ctx, mongodb.ClientOf(games))
 		if err != nil {
-			log.Fatal(err)
+			return nil, false, err
 		}
-
-		var resultGames []pgntodb.Game
-		err = cursor.All(ctx, &resultGames)
+		diagnostics.stage("winmodel", start)
+
+		start = time.Now()
+		findOptions := options.Find().SetProjection(pgntodb.WithoutRaw)
+		limit := maxScannedGames()
+		if limit > 0 {
+			// one more game tells that the results are truncated
+			findOptions.SetLimit(limit + 1)
+		}
+		cursor, err := games.Find(ctx, gameFilterBson, findOptions)
 		if err != nil {
-			log.Fatal(err)
+			return nil, false, err
 		}
+		defer cursor.Close(ctx)
 
-		filterPgn := strings.Split(filter.pgn, " ")
-		for _, game := range resultGames {
-			gamePgn := strings.Split(game.PGN, " ")
-			gamePgn = gamePgn[0 : len(gamePgn)-1] // remove last bit which is the result
-			nextmove := ""
-			if len(gamePgn) > len(filterPgn) {
-				if strings.HasSuffix(gamePgn[len(filterPgn)], ".") {
-					nextmove = gamePgn[len(filterPgn)+1]
-				} else {
-					nextmove = gamePgn[len(filterPgn)]
-				}
+		counter := newMoveCounter(filter, model)
+		var scanned int64
+		for cursor.Next(ctx) {
+			if limit > 0 && scanned == limit {
+				truncated = true
+				break
 			}
-			if nextmove != "" {
-				foundNextMove := -1
-				for iNextMove := range nextmoves {
-					if nextmoves[iNextMove].Move == nextmove {
-						foundNextMove = iNextMove
-						break
-					}
-				}
-				if foundNextMove == -1 {
-					nextmoves = append(nextmoves, NextMove{Move: nextmove, Results: make([]Result, 0), tmpGame: game})
-					foundNextMove = len(nextmoves) - 1
-				}
-				foundResult := -1
-				for iResult := range nextmoves[foundNextMove].Results {
-					if nextmoves[foundNextMove].Results[iResult].Result == game.Result {
-						foundResult = iResult
-						nextmoves[foundNextMove].Results[iResult].Sum = nextmoves[foundNextMove].Results[iResult].Sum + 1
-						break
-					}
-				}
-				if foundResult == -1 {
-					nextmoves[foundNextMove].Results = append(nextmoves[foundNextMove].Results, Result{Result: game.Result, Sum: 1})
-				}
+			var game pgntodb.Game
+			if err = cursor.Decode(&game); err != nil {
+				return nil, false, err
 			}
+			counter.add(&game)
+			scanned++
+		}
+		if err = cursor.Err(); err != nil {
+			return nil, false, err
+		}
+		nextmoves, loneGames = counter.nextmoves, counter.loneGames
+		diagnostics.stage("scan", start)
+		if diagnostics != nil {
+			diagnostics.Scanned = scanned
 		}
-
 	}
 
 	// add a total
+	start := time.Now()
 	for iNextMove := range nextmoves {
-		for _, y := range nextmoves[iNextMove].Results {
-			if y.Result == "1-0" {
-				nextmoves[iNextMove].White = y.Sum
-			} else if y.Result == "0-1" {
-				nextmoves[iNextMove].Black = y.Sum
-			} else {
-				nextmoves[iNextMove].Draw = y.Sum
-			}
-		}
-
-		nextmoves[iNextMove].Total = nextmoves[iNextMove].White + nextmoves[iNextMove].Draw + nextmoves[iNextMove].Black
+		setTotals(&nextmoves[iNextMove])
 
 		if nextmoves[iNextMove].Total == 1 {
 			if filter.mongoAggregation {
 				// get link for moves pgn + move
 				// Note: this slows down the results if there are a lot of single games
-				game := getGame(ctx, games, filter.pgnMoves, nextmoves[iNextMove].Move, gameFilterBson)
+				game, err := getGame(ctx, games, filter.pgnMoves, nextmoves[iNextMove].Move, gameFilterBson)
+				if err != nil {
+					return nil, false, err
+				}
+				if diagnostics != nil {
+					diagnostics.Lookups++
+				}
 				if game != nil {
 					nextmoves[iNextMove].Game = *game
 				}
 			} else {
 				nextmoves[iNextMove].Game = nextmoves[iNextMove].tmpGame
 			}
+			setEnding(&nextmoves[iNextMove])
 		}
 	}
 
+	diagnostics.stage("totals", start)
+
 	// sort by counts
 	sort.Slice(nextmoves, func(i, j int) bool {
 		return nextmoves[i].Total > nextmoves[j].Total
 	})
 
 	// look for lone games (opening == full game) and append them to response
-	loneGames := getLoneGames(ctx, games, filter.pgn, gameFilterBson)
+	// (counted with the next moves by the algorithmic path)
+	start = time.Now()
+	if filter.mongoAggregation {
+		var err error
+		loneGames, err = getLoneGames(ctx, games, filter.pgn, gameFilterBson)
+		if err != nil {
+			return nil, false, err
+		}
+	}
 	for _, loneGame := range loneGames {
-		item := NextMove{Move: "End", Game: loneGame, Total: 1}
-		switch loneGame.Result {
-		case "1-0":
-			item.White = 1
-		case "0-1":
-			item.Black = 1
-		default:
-			item.Draw = 1
+		nextmoves = append(nextmoves, loneGameMove(loneGame))
+	}
+	diagnostics.stage("lonegames", start)
+
+	return nextmoves, truncated, nil
+}
+
+// maxScannedGames ... games read by the algorithmic path at most (max-scanned-games, 0: no limit)
+func maxScannedGames() int64 {
+	if viper.IsSet("max-scanned-games") {
+		return viper.GetInt64("max-scanned-games")
+	}
+	return 200000
+}
+
+// aggregationPath ... path of the next moves of the filter (mongo or algorithmic) and why
+func (filter *GameFilter) aggregationPath() (string, string) {
+	path := "algorithmic"
+	if filter.mongoAggregation {
+		path = "mongo"
+	}
+	switch {
+	case filter.aggregation != "":
+		return path, "requested"
+	case filter.fen != "":
+		return path, "position (fen): games found by their position hashes"
+	case filter.mongoAggregation:
+		return path, "line under 20 moves"
+	}
+	return path, "line of 20 moves or more: no move field to group on"
+}
+
+// countNextMoves ... moves played after the line or the position of {filter} in {games} (algorythmic aggregation), expected scores from {model}
+func countNextMoves(filter *GameFilter, games []pgntodb.Game, model winmodel.Model) []NextMove {
+	counter := newMoveCounter(filter, model)
+	for i := range games {
+		counter.add(&games[i])
+	}
+	return counter.nextmoves
+}
+
+// moveCounter ... next moves of the games added one by one, so the games do not have to be kept in memory
+type moveCounter struct {
+	filter    *GameFilter
+	model     winmodel.Model
+	moves     map[string]int // index of a move in nextmoves
+	nextmoves []NextMove
+	loneGames []pgntodb.Game // games ending in the position of the filter
+}
+
+func newMoveCounter(filter *GameFilter, model winmodel.Model) *moveCounter {
+	return &moveCounter{filter: filter, model: model, moves: map[string]int{}}
+}
+
+// add ... count the next move of {game} (the game of the move is kept while it is the only one)
+func (counter *moveCounter) add(game *pgntodb.Game) {
+	ply, nextmove := counter.filter.nextMove(game)
+	if nextmove == "" {
+		if ply >= 0 {
+			counter.loneGames = append(counter.loneGames, *game)
+		}
+		return
+	}
+	foundNextMove, ok := counter.moves[nextmove]
+	if !ok {
+		counter.nextmoves = append(counter.nextmoves, NextMove{Move: nextmove, Results: make([]Result, 0), tmpGame: *game})
+		foundNextMove = len(counter.nextmoves) - 1
+		counter.moves[nextmove] = foundNextMove
+	} else {
+		counter.nextmoves[foundNextMove].tmpGame = pgntodb.Game{}
+	}
+	item := &counter.nextmoves[foundNextMove]
+	foundResult := false
+	for iResult := range item.Results {
+		if item.Results[iResult].Result == game.Result {
+			item.Results[iResult].Sum++
+			foundResult = true
+			break
 		}
-		nextmoves = append(nextmoves, item)
 	}
+	if !foundResult {
+		item.Results = append(item.Results, Result{Result: game.Result, Sum: 1})
+	}
+	if ply < len(game.Times) {
+		item.TimeSum += game.Times[ply]
+		item.Timed++
+	}
+	if ply < len(game.Evals) {
+		item.EvalSum += float64(game.Evals[ply])
+		item.Evaluated++
+	}
+	if expected, ok := counter.model.Expected(game, ply); ok {
+		item.ExpSum += expected
+		item.Expected++
+	}
+}
 
-	// send the response
-	response := nextMovesResponse{}
-	response.Data = nextmoves
-	json.NewEncoder(w).Encode(response)
+// nextMove ... index and SAN of the move played after the line or the position of {filter} in {game}
+// ("" when the game ends there, -1 when the game does not reach the position)
+func (filter *GameFilter) nextMove(game *pgntodb.Game) (int, string) {
+	if filter.fen != "" {
+		// first time the position is reached, whatever the move order
+		ply := -1
+		for i, hash := range game.Hashes {
+			if hash == filter.hash {
+				ply = i + 1
+				break
+			}
+		}
+		if ply == -1 {
+			return -1, ""
+		}
+		if moves := pgn.Moves(game.PGN); ply < len(moves) {
+			return ply, moves[ply]
+		}
+		return ply, ""
+	}
+
+	filterPgn := strings.Split(filter.pgn, " ")
+	ply := 0 // index of the next move
+	for _, bit := range filterPgn {
+		if bit != "" && !strings.HasSuffix(bit, ".") {
+			ply++
+		}
+	}
+	gamePgn := strings.Split(game.PGN, " ")
+	gamePgn = gamePgn[0 : len(gamePgn)-1] // remove last bit which is the result
+	nextmove := ""
+	if len(gamePgn) > len(filterPgn) {
+		if strings.HasSuffix(gamePgn[len(filterPgn)], ".") {
+			nextmove = gamePgn[len(filterPgn)+1]
+		} else {
+			nextmove = gamePgn[len(filterPgn)]
+		}
+	}
+	return ply, nextmove
+}
+
+// loneGames ... games of {games} ending in the position of {filter}
+func (filter *GameFilter) loneGames(games []pgntodb.Game) []pgntodb.Game {
+	loneGames := make([]pgntodb.Game, 0)
+	for _, game := range games {
+		if ply, nextmove := filter.nextMove(&game); ply >= 0 && nextmove == "" {
+			loneGames = append(loneGames, game)
+		}
+	}
+	return loneGames
+}
+
+// setTotals ... White, Draw, Black and Total from the Results of {nextMove}
+func setTotals(nextMove *NextMove) {
+	for _, y := range nextMove.Results {
+		if y.Result == "1-0" {
+			nextMove.White = y.Sum
+		} else if y.Result == "0-1" {
+			nextMove.Black = y.Sum
+		} else {
+			nextMove.Draw = y.Sum
+		}
+	}
+	nextMove.Total = nextMove.White + nextMove.Draw + nextMove.Black
+	if nextMove.Timed > 0 {
+		nextMove.AvgTime = math.Round(10*nextMove.TimeSum/float64(nextMove.Timed)) / 10
+	}
+	if nextMove.Evaluated > 0 {
+		// centipawns to pawns
+		avgEval := math.Round(nextMove.EvalSum/float64(nextMove.Evaluated)) / 100
+		nextMove.AvgEval = &avgEval
+	}
+	if nextMove.Expected > 0 {
+		expectedScore := math.Round(1000*nextMove.ExpSum/float64(nextMove.Expected)) / 1000
+		nextMove.ExpectedScore = &expectedScore
+	}
+}
+
+// loneGameMove ... a game ending right after the opening
+func loneGameMove(game pgntodb.Game) NextMove {
+	item := NextMove{Move: "End", Game: game, Total: 1}
+	setEnding(&item)
+	switch game.Result {
+	case "1-0":
+		item.White = 1
+	case "0-1":
+		item.Black = 1
+	default:
+		item.Draw = 1
+	}
+	return item
 }
 
 func buildMoveFieldName(fieldNum int) (moveField string) {
@@ -283,7 +587,7 @@ func buildMoveFieldName(fieldNum int) (moveField string) {
 	return moveField
 }
 
-func getLoneGames(ctx context.Context, games *mongo.Collection, pgn string, gameFilterBson bson.M) (loneGames []pgntodb.Game) {
+func getLoneGames(ctx context.Context, games *mongo.Collection, pgn string, gameFilterBson bson.M) ([]pgntodb.Game, error) {
 	var andClause []bson.M
 	andClause = append(andClause, gameFilterBson)
 	orQuery := []bson.M{}
@@ -293,21 +597,17 @@ func getLoneGames(ctx context.Context, games *mongo.Collection, pgn string, game
 	andClause = append(andClause, bson.M{"$or": orQuery})
 
 	cursor, err := games.Find(ctx, bson.M{"$and": andClause})
-	defer cursor.Close(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	defer cursor.Close(ctx)
 
 	var resultGames []pgntodb.Game
 	err = cursor.All(ctx, &resultGames)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	return resultGames
+	return resultGames, err
 }
 
-func getGame(ctx context.Context, games *mongo.Collection, pgnMoves []string, move string, gameFilterBson bson.M) (game *pgntodb.Game) {
+func getGame(ctx context.Context, games *mongo.Collection, pgnMoves []string, move string, gameFilterBson bson.M) (*pgntodb.Game, error) {
 	var andClause []bson.M
 
 	andClause = append(andClause, gameFilterBson)
@@ -318,22 +618,21 @@ func getGame(ctx context.Context, games *mongo.Collection, pgnMoves []string, mo
 	andClause = append(andClause, bson.M{buildMoveFieldName(len(pgnMoves) + 1): move})
 
 	cursor, err := games.Find(ctx, bson.M{"$and": andClause})
-	defer cursor.Close(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	defer cursor.Close(ctx)
 
 	var resultGames []pgntodb.Game
 	err = cursor.All(ctx, &resultGames)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	var ret *pgntodb.Game
 	if len(resultGames) != 0 {
-		return &resultGames[0]
+		return &resultGames[0], nil
 	}
-	return ret
+	return nil, nil
 }
 
 func bsonFromGameFilter(filter *GameFilter) bson.M {
@@ -345,18 +644,14 @@ func bsonFromGameFilter(filter *GameFilter) bson.M {
 	for _, timeControl := range timeControls {
 		if strings.TrimSpace(timeControl) != "" {
 			if filter.simplifyTimecontrol == "true" {
-				orQuery := []bson.M{}
-				if timeControl == "-" {
-					exactQuery := bson.M{"timecontrol": "-"}
-					looseQuery := bson.M{"timecontrol": bson.M{"$regex": "/"}}
-					orQuery = append(orQuery, exactQuery, looseQuery)
+				// same base time whatever the increment, any correspondence game for "-" (structured time control: see reindex)
+				if clock := pgntodb.NormalizeTimeControl(timeControl); clock == nil {
+					timeControlBson = append(timeControlBson, bson.M{"timecontrol": strings.TrimSpace(timeControl)})
+				} else if clock.Correspondence {
+					timeControlBson = append(timeControlBson, bson.M{"clock.correspondence": true})
 				} else {
-					timecontrolParts := strings.Split(strings.TrimSpace(timeControl), "+")
-					exactQuery := bson.M{"timecontrol": timecontrolParts[0]}
-					looseQuery := bson.M{"timecontrol": bson.M{"$regex": "^" + regexp.QuoteMeta(timecontrolParts[0]+"+")}}
-					orQuery = append(orQuery, exactQuery, looseQuery)
+					timeControlBson = append(timeControlBson, bson.M{"clock.base": clock.Base, "clock.correspondence": bson.M{"$ne": true}})
 				}
-				timeControlBson = append(timeControlBson, bson.M{"$or": orQuery})
 			} else {
 				timeControlBson = append(timeControlBson, bson.M{"timecontrol": strings.TrimSpace(timeControl)})
 			}
@@ -365,61 +660,45 @@ func bsonFromGameFilter(filter *GameFilter) bson.M {
 
 	// Site filter
 	siteBson := make([]bson.M, 0)
-	sites := strings.Split(filter.site, ",")
-	for _, site := range sites {
-		if strings.TrimSpace(site) != "" {
-			siteBson = append(siteBson, bson.M{"site": strings.TrimSpace(site)})
-		}
+	includedSites, excludedSites := filter.sites()
+	for _, site := range includedSites {
+		siteBson = append(siteBson, bson.M{"site": site})
 	}
 
-	// ELO filter
+	// ELO filter: the rating of each player is in the bounds, or unknown (null, or 0 for older games) when included
 	eloBson := make([]bson.M, 0)
-
-	if filter.minelo != "" {
-		minelo, _ := strconv.Atoi(filter.minelo)
-		eloBson = append(eloBson, bson.M{
-			"whiteelo": bson.M{"$gte": minelo},
-			"blackelo": bson.M{"$gte": minelo},
-		})
-	}
-
-	if filter.maxelo != "" {
-		maxelo, _ := strconv.Atoi(filter.maxelo)
-		eloBson = append(eloBson, bson.M{
-			"whiteelo": bson.M{"$lte": maxelo},
-			"blackelo": bson.M{"$lte": maxelo},
-		})
+	if bounds, include := filter.eloBounds(); bounds != nil {
+		for _, side := range []string{"whiteelo", "blackelo"} {
+			if include {
+				eloBson = append(eloBson, bson.M{"$or": bson.A{
+					bson.M{side: bounds},
+					bson.M{side: bson.M{"$in": bson.A{nil, 0}}},
+				}})
+			} else {
+				eloBson = append(eloBson, bson.M{side: bounds})
+			}
+		}
 	}
 
 	// date filter
 	dateBson := make([]bson.M, 0)
-	if filter.from != "" {
-		fromDate, error := time.Parse(time.RFC3339, filter.from+"T00:00:00+00:00")
-		if error != nil {
-			log.Print("datetime error " + filter.from)
-		} else {
-			dateBson = append(dateBson, bson.M{
-				"datetime": bson.M{"$gte": fromDate},
-			})
-		}
+	fromDate, toDate := filter.dateRange()
+	if !fromDate.IsZero() {
+		dateBson = append(dateBson, bson.M{
+			"datetime": bson.M{"$gte": fromDate},
+		})
 	}
-
-	if filter.to != "" {
-		toDate, error := time.Parse(time.RFC3339, filter.to+"T23:59:59+00:00")
-		if error != nil {
-			log.Print("datetime error " + filter.to)
-		} else {
-			dateBson = append(dateBson, bson.M{
-				"datetime": bson.M{"$lte": toDate},
-			})
-		}
+	if !toDate.IsZero() {
+		dateBson = append(dateBson, bson.M{
+			"datetime": bson.M{"$lt": toDate},
+		})
 	}
 
 	// user filter
 	whiteBson := make([]bson.M, 0)
 
 	// example: c:fred, l:john, alfredo
-	whiteUsers := strings.Split(filter.white, ",")
+	whiteUsers := strings.Split(expandTeams(filter.owner, filter.white), ",")
 	for _, user := range whiteUsers {
 		if strings.TrimSpace(user) == "" {
 			break
@@ -435,7 +714,7 @@ func bsonFromGameFilter(filter *GameFilter) bson.M {
 
 	blackBson := make([]bson.M, 0)
 
-	blackUsers := strings.Split(filter.black, ",")
+	blackUsers := strings.Split(expandTeams(filter.owner, filter.black), ",")
 	for _, user := range blackUsers {
 		if strings.TrimSpace(user) == "" {
 			break
@@ -449,6 +728,12 @@ func bsonFromGameFilter(filter *GameFilter) bson.M {
 		}
 	}
 
+	// result filter
+	resultBson := make([]bson.M, 0)
+	if result := filter.gameResult(); result != "" {
+		resultBson = append(resultBson, bson.M{"result": result})
+	}
+
 	movesBson := make([]bson.M, 0)
 
 	if filter.mongoAggregation {
@@ -465,10 +750,12 @@ func bsonFromGameFilter(filter *GameFilter) bson.M {
 
 		// make sure next move exists
 		movesBson = append(movesBson, bson.M{moveField: bson.M{"$exists": true, "$ne": ""}})
+	} else if filter.fen != "" {
+		movesBson = append(movesBson, bson.M{"hashes": filter.hash})
 	} else {
 		if filter.pgn != "" {
 			quotedPgn := regexp.QuoteMeta(filter.pgn)
-			movesBson = append(movesBson, bson.M{"pgn": bson.M{"$regex": quotedPgn}})
+			movesBson = append(movesBson, bson.M{"pgn": bson.M{"$regex": "^" + quotedPgn}})
 		}
 	}
 
@@ -490,6 +777,24 @@ func bsonFromGameFilter(filter *GameFilter) bson.M {
 	default:
 		finalBson = append(finalBson, bson.M{"$or": siteBson})
 	}
+	if len(excludedSites) > 0 {
+		finalBson = append(finalBson, bson.M{"site": bson.M{"$nin": excludedSites}})
+	}
+
+	// speed filter
+	if speeds := filter.speeds(); len(speeds) > 0 {
+		finalBson = append(finalBson, bson.M{"clock.speed": bson.M{"$in": speeds}})
+	}
+
+	// import batch filter
+	if batches := filter.batches(); len(batches) > 0 {
+		finalBson = append(finalBson, bson.M{"provenance.batch": bson.M{"$in": batches}})
+	}
+
+	// tag filter
+	if tags := filter.tags(); len(tags) > 0 {
+		finalBson = append(finalBson, bson.M{"tags": bson.M{"$in": tags}})
+	}
 
 	switch len(eloBson) {
 	case 0:
@@ -523,6 +828,10 @@ func bsonFromGameFilter(filter *GameFilter) bson.M {
 		finalBson = append(finalBson, bson.M{"$or": blackBson})
 	}
 
+	if len(resultBson) == 1 {
+		finalBson = append(finalBson, resultBson[0])
+	}
+
 	switch len(movesBson) {
 	case 0:
 	case 1:
@@ -531,6 +840,11 @@ func bsonFromGameFilter(filter *GameFilter) bson.M {
 		finalBson = append(finalBson, bson.M{"$and": movesBson})
 	}
 
+	// owner filter (isolation)
+	if filter.owner != "" {
+		finalBson = append(finalBson, bson.M{"owner": filter.owner})
+	}
+
 	// wrap up
 	switch len(finalBson) {
 	case 0:
@@ -543,30 +857,353 @@ func bsonFromGameFilter(filter *GameFilter) bson.M {
 	return ret
 }
 
+// setInvalid ... {field} of the request is invalid (filter.invalid is a ValidationError)
+func (filter *GameFilter) setInvalid(field string, err error) {
+	errs, _ := filter.invalid.(ValidationError)
+	errs.add(field, "%s", err.Error())
+	filter.invalid = errs
+}
+
+// tags ... tags of the filter (comma separated), games with one of them
+func (filter *GameFilter) tags() []string {
+	tags := make([]string, 0)
+	for _, tag := range strings.Split(filter.tag, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// batches ... import batches of the filter (comma separated)
+func (filter *GameFilter) batches() []string {
+	batches := make([]string, 0)
+	for _, batch := range strings.Split(filter.batch, ",") {
+		if batch = strings.TrimSpace(batch); batch != "" {
+			batches = append(batches, batch)
+		}
+	}
+	return batches
+}
+
+// speeds ... speeds of the filter (comma separated)
+func (filter *GameFilter) speeds() []string {
+	speeds := make([]string, 0)
+	for _, speed := range strings.Split(filter.speed, ",") {
+		if speed = strings.TrimSpace(speed); speed != "" {
+			speeds = append(speeds, speed)
+		}
+	}
+	return speeds
+}
+
+// sites ... sites of the filter (comma separated), and the excluded ones (-iccf.com)
+func (filter *GameFilter) sites() ([]string, []string) {
+	included, excluded := make([]string, 0), make([]string, 0)
+	for _, site := range strings.Split(filter.site, ",") {
+		site = strings.TrimSpace(site)
+		if strings.HasPrefix(site, "-") {
+			if site = strings.TrimSpace(site[1:]); site != "" {
+				excluded = append(excluded, site)
+			}
+		} else if site != "" {
+			included = append(included, site)
+		}
+	}
+	return included, excluded
+}
+
+// setDates ... dates of the filter form: {from} and {to} (YYYY-MM-DD) are days in the time zone {tz} (UTC when empty)
+func (filter *GameFilter) setDates(from string, to string, tz string) {
+	filter.from, filter.to = strings.TrimSpace(from), strings.TrimSpace(to)
+	location, err := parseTimeZone(strings.TrimSpace(tz))
+	if err != nil {
+		filter.setInvalid("tz", err)
+	}
+	filter.location = location
+	for _, date := range []struct{ name, value string }{{"from", filter.from}, {"to", filter.to}} {
+		if date.value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date.value); err != nil {
+			filter.setInvalid(date.name, fmt.Errorf("invalid %s %q: a date is YYYY-MM-DD", date.name, date.value))
+		}
+	}
+	if fromDate, toDate := filter.dateRange(); !fromDate.IsZero() && !toDate.IsZero() && !fromDate.Before(toDate) {
+		filter.setInvalid("to", fmt.Errorf("from %s is after to %s", filter.from, filter.to))
+	}
+}
+
+// dateRange ... start of the day {from} and end of the day {to} (exclusive: start of the next day) in the time zone
+// of the filter, zero when not set
+func (filter *GameFilter) dateRange() (time.Time, time.Time) {
+	location := filter.location
+	if location == nil {
+		location = time.UTC
+	}
+	var fromDate, toDate time.Time
+	if day, err := time.ParseInLocation("2006-01-02", filter.from, location); err == nil {
+		fromDate = day
+	}
+	if day, err := time.ParseInLocation("2006-01-02", filter.to, location); err == nil {
+		toDate = day.AddDate(0, 0, 1)
+	}
+	return fromDate, toDate
+}
+
+// parseTimeZone ... IANA time zone (Europe/Paris), UTC offset (+02:00, -0530, 02:00) or "" for UTC
+func parseTimeZone(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.UTC, nil
+	}
+	if offset := timeZoneOffset.FindStringSubmatch(tz); offset != nil {
+		hours, _ := strconv.Atoi(offset[2])
+		minutes, _ := strconv.Atoi(offset[3])
+		seconds := (hours*60 + minutes) * 60
+		sign := offset[1]
+		if sign == "-" {
+			seconds = -seconds
+		} else {
+			sign = "+" // tz=+02:00 in a query string is " 02:00" (+ decodes to a space)
+		}
+		if hours > 14 || minutes > 59 {
+			return time.UTC, fmt.Errorf("invalid tz %q: UTC offset out of range", tz)
+		}
+		return time.FixedZone("UTC"+sign+offset[2]+":"+offset[3], seconds), nil
+	}
+	location, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.UTC, fmt.Errorf("invalid tz %q: an IANA time zone (Europe/Paris) or a UTC offset (+02:00)", tz)
+	}
+	return location, nil
+}
+
+var timeZoneOffset = regexp.MustCompile(`^([+-]?)(\d{2}):?(\d{2})$`)
+
+// eloBounds ... bounds of the rating of both players ($gt 0: known rating, $gte minelo, $lte maxelo), nil when no rating filter,
+// and whether the games with an unknown rating are included: excluded by default when minelo or maxelo is set,
+// unknownelo=exclude alone keeps the rated games
+func (filter *GameFilter) eloBounds() (bson.M, bool) {
+	if filter.minelo == 0 && filter.maxelo == 0 && filter.unknownElo != "exclude" {
+		return nil, true
+	}
+	bounds := bson.M{"$gt": 0}
+	if filter.minelo > 0 {
+		bounds["$gte"] = filter.minelo
+	}
+	if filter.maxelo > 0 {
+		bounds["$lte"] = filter.maxelo
+	}
+	return bounds, filter.unknownElo == "include"
+}
+
+// isSpeed ... {speed} is a category of time controls (see pgntodb.Speeds)
+func isSpeed(speed string) bool {
+	for _, known := range pgntodb.Speeds {
+		if speed == known {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesElo ... the ratings of {game} pass the rating filter (see eloBounds)
+func (filter *GameFilter) matchesElo(game *pgntodb.Game) bool {
+	bounds, include := filter.eloBounds()
+	if bounds == nil {
+		return true
+	}
+	for _, elo := range []int{game.WhiteRating(), game.BlackRating()} {
+		switch {
+		case elo == 0 && include:
+		case elo == 0:
+			return false
+		case filter.minelo > 0 && elo < filter.minelo, filter.maxelo > 0 && elo > filter.maxelo:
+			return false
+		}
+	}
+	return true
+}
+
+// gameResult ... result of the games of the filter: win, loss and draw are from the side of the white player(s),
+// or of the black player(s) when only black is set ("" for any result)
+func (filter *GameFilter) gameResult() string {
+	switch filter.result {
+	case "1-0", "0-1", "1/2-1/2":
+		return filter.result
+	case "draw":
+		return "1/2-1/2"
+	case "win", "loss":
+		playerIsWhite := filter.white != "" || filter.black == ""
+		if (filter.result == "win") == playerIsWhite {
+			return "1-0"
+		}
+		return "0-1"
+	}
+	return ""
+}
+
+// convertSite ... site of the prefix of a user: c (chess.com), l (lichess.org) or the site itself (iccf.com)
 func convertSite(shortName string) string {
-	ret := ""
-	switch shortName {
+	switch shortName = strings.ToLower(strings.TrimSpace(shortName)); shortName {
 	case "c":
-		ret = "chess.com"
+		return "chess.com"
 	case "l":
-		ret = "lichess.org"
-	default:
+		return "lichess.org"
 	}
-	return ret
+	return shortName
+}
+
+// sitesCache ... sites of the games of every owner ("": no owner), read again after teamsRefresh
+var sitesCache = struct {
+	sync.Mutex
+	sites  map[string][]string
+	loaded map[string]time.Time
+}{}
+
+// knownSite ... {site} is a registered site (lichess.org...) or the site of games of {owner} (imported PGN files),
+// true when the sites of the games cannot be read
+func knownSite(owner string, site string) bool {
+	if _, ok := sites.Get(site); ok {
+		return true
+	}
+	sitesCache.Lock()
+	defer sitesCache.Unlock()
+	if sitesCache.sites == nil {
+		sitesCache.sites, sitesCache.loaded = map[string][]string{}, map[string]time.Time{}
+	}
+	if _, ok := sitesCache.sites[owner]; !ok || time.Since(sitesCache.loaded[owner]) > teamsRefresh {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		var stored []string // nil: unknown
+		store, err := openStore(ctx)
+		if err == nil {
+			defer store.close(ctx)
+			stored, err = store.sites(ctx, owner)
+		}
+		if err != nil {
+			log.Warn("Cannot read the sites of the games: " + err.Error())
+		}
+		sitesCache.sites[owner], sitesCache.loaded[owner] = stored, time.Now()
+	}
+	if sitesCache.sites[owner] == nil {
+		return true
+	}
+	for _, stored := range sitesCache.sites[owner] {
+		if stored == site {
+			return true
+		}
+	}
+	return false
+}
+
+// forgetSites ... the sites of the games are read again on next use
+func forgetSites() {
+	sitesCache.Lock()
+	defer sitesCache.Unlock()
+	sitesCache.sites = nil
+}
+
+// siteNames ... the sites of the messages of the site filters
+func siteNames() string {
+	return strings.Join(sites.Names(), ", ")
 }
 
 func gameFilterFromRequest(r *http.Request) *GameFilter {
+	r.ParseForm()
+	return NewGameFilter(r.Form)
+}
+
+// NewGameFilter ... game filter from the values of the filter form (pgn, white, black, timecontrol, from, to ...)
+func NewGameFilter(values url.Values) *GameFilter {
 	filter := GameFilter{
-		pgn:                 strings.TrimSpace(r.FormValue("pgn")),
-		white:               strings.TrimSpace(r.FormValue("white")),
-		black:               strings.TrimSpace(r.FormValue("black")),
-		timecontrol:         strings.TrimSpace(r.FormValue("timecontrol")),
-		simplifyTimecontrol: strings.TrimSpace(r.FormValue("simplifyTimecontrol")),
-		from:                strings.TrimSpace(r.FormValue("from")),
-		to:                  strings.TrimSpace(r.FormValue("to")),
-		minelo:              strings.TrimSpace(r.FormValue("minelo")),
-		maxelo:              strings.TrimSpace(r.FormValue("maxelo")),
-		site:                strings.ToLower(strings.TrimSpace(r.FormValue("site"))),
+		pgn:                 strings.TrimSpace(values.Get("pgn")),
+		white:               strings.TrimSpace(values.Get("white")),
+		black:               strings.TrimSpace(values.Get("black")),
+		timecontrol:         strings.TrimSpace(values.Get("timecontrol")),
+		simplifyTimecontrol: strings.TrimSpace(values.Get("simplifyTimecontrol")),
+		unknownElo:          strings.ToLower(strings.TrimSpace(values.Get("unknownelo"))),
+		site:                strings.ToLower(strings.TrimSpace(values.Get("site"))),
+		result:              strings.ToLower(strings.TrimSpace(values.Get("result"))),
+		fen:                 strings.TrimSpace(values.Get("fen")),
+		batch:               strings.TrimSpace(values.Get("batch")),
+		speed:               strings.ToLower(strings.TrimSpace(values.Get("speed"))),
+		tag:                 strings.TrimSpace(values.Get("tag")),
+		owner:               values.Get(ownerParam),
+	}
+
+	filter.setDates(values.Get("from"), values.Get("to"), values.Get("tz"))
+
+	// teams: team:{name} among the players, site of the players: {site}:username
+	for _, side := range []struct{ name, users string }{{"white", filter.white}, {"black", filter.black}} {
+		for _, user := range strings.Split(side.users, ",") {
+			user = strings.TrimSpace(user)
+			if strings.HasPrefix(user, teamPrefix) {
+				if len(teamPlayers(filter.owner, strings.TrimPrefix(user, teamPrefix))) == 0 {
+					filter.setInvalid(side.name, fmt.Errorf("unknown team %q: the teams of the config file (teams:) or of /teams", strings.TrimPrefix(user, teamPrefix)))
+				}
+			} else if split := strings.SplitN(user, ":", 2); len(split) == 2 && !knownSite(filter.owner, convertSite(split[0])) {
+				filter.setInvalid(side.name, fmt.Errorf("unknown site %q in %s: c, l, %s or a site of the games", split[0], user, siteNames()))
+			}
+		}
+	}
+
+	// sites: registered or of the games (imported PGN files)
+	includedSites, excludedSites := filter.sites()
+	for _, site := range append(includedSites, excludedSites...) {
+		if !knownSite(filter.owner, site) {
+			filter.setInvalid("site", fmt.Errorf("unknown site %q: %s or a site of the games", site, siteNames()))
+		}
+	}
+
+	switch filter.result {
+	case "", "win", "loss", "draw", "1-0", "0-1", "1/2-1/2":
+	default:
+		filter.setInvalid("result", fmt.Errorf("invalid result %q: win, loss, draw, 1-0, 0-1 or 1/2-1/2", filter.result))
+	}
+
+	// ratings: positive integers, min <= max
+	for _, bound := range []struct {
+		name  string
+		value *int
+	}{{"minelo", &filter.minelo}, {"maxelo", &filter.maxelo}} {
+		value := strings.TrimSpace(values.Get(bound.name))
+		if value == "" {
+			continue
+		}
+		elo, err := strconv.Atoi(value)
+		if err != nil || elo <= 0 {
+			filter.setInvalid(bound.name, fmt.Errorf("invalid %s %q: a rating is a positive integer", bound.name, value))
+			continue
+		}
+		*bound.value = elo
+	}
+	if filter.minelo > 0 && filter.maxelo > 0 && filter.minelo > filter.maxelo {
+		filter.setInvalid("maxelo", fmt.Errorf("minelo %d is higher than maxelo %d", filter.minelo, filter.maxelo))
+	}
+	switch filter.unknownElo {
+	case "", "include", "exclude":
+	default:
+		filter.setInvalid("unknownelo", fmt.Errorf("invalid unknownelo %q: include or exclude", filter.unknownElo))
+	}
+	for _, speed := range filter.speeds() {
+		if !isSpeed(speed) {
+			filter.setInvalid("speed", fmt.Errorf("invalid speed %q: %s", speed, strings.Join(pgntodb.Speeds, ", ")))
+		}
+	}
+
+	// a position replaces the line (the starting position is the empty line: no hash before the first move)
+	if filter.fen != "" {
+		option, err := chess.FEN(filter.fen)
+		if err != nil {
+			filter.setInvalid("fen", fmt.Errorf("invalid FEN %s: %v", filter.fen, err))
+		} else if position := chess.NewGame(option).Position(); position.Hash() == chess.StartingPosition().Hash() {
+			filter.fen = ""
+		} else {
+			filter.position = position
+			filter.hash = zobrist.Hash(position)
+			filter.pgn = ""
+		}
 	}
 
 	// Process input pgn (remove "1." etc)
@@ -583,12 +1220,32 @@ func gameFilterFromRequest(r *http.Request) *GameFilter {
 		}
 	}
 	filter.pgnMoves = filter.pgnMoves[:i]
+	if err := checkLine(filter.pgnMoves); err != nil {
+		filter.setInvalid("pgn", err)
+	}
 
-	if len(filter.pgnMoves) < 20 {
+	if len(filter.pgnMoves) < 20 && filter.fen == "" {
 		filter.mongoAggregation = true
 	} else {
 		filter.mongoAggregation = false
 	}
 
+	// the path of the next moves can be forced, the pipeline groups on the move fields (m01 to m20) of the line
+	filter.aggregation = strings.ToLower(strings.TrimSpace(values.Get("aggregation")))
+	switch filter.aggregation {
+	case "", "auto":
+		filter.aggregation = ""
+	case "algorithmic":
+		filter.mongoAggregation = false
+	case "mongo":
+		if filter.fen != "" || len(filter.pgnMoves) >= 20 {
+			filter.setInvalid("aggregation", fmt.Errorf("aggregation mongo needs a line (pgn) of less than 20 moves"))
+		}
+		filter.mongoAggregation = true
+	default:
+		filter.setInvalid("aggregation", fmt.Errorf("invalid aggregation %q: mongo, algorithmic or auto", filter.aggregation))
+	}
+	filter.debug, _ = strconv.ParseBool(strings.TrimSpace(values.Get("debug")))
+
 	return &filter
 }
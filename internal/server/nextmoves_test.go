@@ -0,0 +1,50 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeZone(t *testing.T) {
+	tests := []struct {
+		tz     string
+		name   string // of the location, "": an error
+		offset int    // seconds east of UTC on 2021-04-17
+	}{
+		{"", "UTC", 0},
+		{"Europe/Paris", "Europe/Paris", 2 * 3600},
+		{"America/New_York", "America/New_York", -4 * 3600},
+		{"+02:00", "UTC+02:00", 2 * 3600},
+		{"02:00", "UTC+02:00", 2 * 3600}, // + of the query string decoded to a space, then trimmed
+		{"+0530", "UTC+05:30", 5*3600 + 30*60},
+		{"-05:30", "UTC-05:30", -(5*3600 + 30*60)},
+		{"-0000", "UTC-00:00", 0},
+		{"+14:00", "UTC+14:00", 14 * 3600},
+		{"+15:00", "", 0},
+		{"+02:60", "", 0},
+		{"+2:00", "", 0},
+		{"Mars/Olympus", "", 0},
+	}
+	for _, test := range tests {
+		location, err := parseTimeZone(test.tz)
+		if test.name == "" {
+			if err == nil {
+				t.Errorf("parseTimeZone(%q) = %s, want an error", test.tz, location)
+			}
+			if location != time.UTC {
+				t.Errorf("parseTimeZone(%q) = %s on error, want UTC", test.tz, location)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseTimeZone(%q): %v", test.tz, err)
+			continue
+		}
+		if location.String() != test.name {
+			t.Errorf("parseTimeZone(%q) = %s, want %s", test.tz, location, test.name)
+		}
+		if _, offset := time.Date(2021, 4, 17, 12, 0, 0, 0, location).Zone(); offset != test.offset {
+			t.Errorf("parseTimeZone(%q): offset %d, want %d", test.tz, offset, test.offset)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/notnil/chess"
+)
+
+// Notable game categories
+const (
+	NotableUpset          = "upset"
+	NotableLongest        = "longest"
+	NotableFastestWin     = "fastest-win"
+	NotableAccuracy       = "accuracy"
+	NotableQueenSacrifice = "queen-sacrifice"
+)
+
+// NotableGame ... a game standing out among the games of a player, and why
+type NotableGame struct {
+	Category string       `json:"category"`
+	Criteria string       `json:"criteria"` // how the game was chosen
+	Detail   string       `json:"detail"`   // the figures of this game
+	Game     pgntodb.Game `json:"game"`
+}
+
+// games with evaluations for most of their moves, long enough for an accuracy
+const minAccuracyPlies = 20
+
+// NotableGames ... the games of {player} (username or site:username) standing out in the games of {gameFilter}
+// (its white and black are ignored), one per category found
+func NotableGames(player string, gameFilter *GameFilter) ([]NotableGame, error) {
+	var upset, longest, fastest, accurate, sacrifice *NotableGame
+	bestGap, mostPlies, fewestPlies, lowestLoss := 0, 0, 0, 0.0
+
+	for _, side := range []string{"white", "black"} {
+		filter := *gameFilter
+		filter.white, filter.black = "", ""
+		if side == "white" {
+			filter.white = player
+		} else {
+			filter.black = player
+		}
+
+		_, err := EachGame(context.Background(), &filter, func(game *pgntodb.Game) error {
+			isWhite := side == "white"
+			won := (game.Result == "1-0") == isWhite && game.Result != "1/2-1/2"
+			plies := len(pgn.Moves(game.PGN))
+			playerElo, opponentElo := game.WhiteRating(), game.BlackRating()
+			if !isWhite {
+				playerElo, opponentElo = opponentElo, playerElo
+			}
+
+			if won && playerElo > 0 && opponentElo-playerElo > bestGap {
+				bestGap = opponentElo - playerElo
+				upset = &NotableGame{Category: NotableUpset, Criteria: "win against the highest rated opponent compared to the player's rating",
+					Detail: fmt.Sprintf("%d won against %d (+%d)", playerElo, opponentElo, bestGap), Game: *game}
+			}
+			if plies > mostPlies {
+				mostPlies = plies
+				longest = &NotableGame{Category: NotableLongest, Criteria: "most moves",
+					Detail: fmt.Sprintf("%d moves, %s", (plies+1)/2, game.Result), Game: *game}
+			}
+			if won && (fewestPlies == 0 || plies < fewestPlies) {
+				fewestPlies = plies
+				fastest = &NotableGame{Category: NotableFastestWin, Criteria: "win in the fewest moves",
+					Detail: fmt.Sprintf("won in %d moves", (plies+1)/2), Game: *game}
+			}
+			if loss, ok := averageLoss(game.Evals, plies, isWhite); ok && (accurate == nil || loss < lowestLoss) {
+				lowestLoss = loss
+				accurate = &NotableGame{Category: NotableAccuracy,
+					Criteria: fmt.Sprintf("lowest average centipawn loss of the player, games with engine evaluations (%d moves at least)", minAccuracyPlies/2),
+					Detail:   fmt.Sprintf("%.0f centipawns lost per move", loss), Game: *game}
+			}
+			if won && sacrifice == nil {
+				if move, ok := queenSacrifice(game.PGN, isWhite); ok {
+					sacrifice = &NotableGame{Category: NotableQueenSacrifice,
+						Criteria: "won after giving the queen: taken on the next move, without taking the opponent's queen back",
+						Detail:   "queen given with " + move, Game: *game}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ret := make([]NotableGame, 0)
+	for _, notable := range []*NotableGame{upset, longest, fastest, accurate, sacrifice} {
+		if notable != nil {
+			ret = append(ret, *notable)
+		}
+	}
+	return ret, nil
+}
+
+// averageLoss ... centipawns lost per move by the player, from the evaluations after every move
+// false when the game is short or most moves have no evaluation
+func averageLoss(evals []int, plies int, isWhite bool) (float64, bool) {
+	if plies < minAccuracyPlies || len(evals) < plies*9/10 {
+		return 0, false
+	}
+	total, moves, _ := playerLosses(evals, isWhite)
+	if moves == 0 {
+		return 0, false
+	}
+	return float64(total) / float64(moves), true
+}
+
+// playerLosses ... centipawns lost by the player in all, moves of the player and blunders (blunderLoss lost at least),
+// from the evaluations after every move
+func playerLosses(evals []int, isWhite bool) (total int, moves int, blunders int) {
+	before := 0 // starting position
+	for ply, after := range evals {
+		if (ply%2 == 0) == isWhite {
+			loss := before - after
+			if !isWhite {
+				loss = after - before
+			}
+			if loss > 0 {
+				total += loss
+			}
+			if loss >= blunderLoss {
+				blunders++
+			}
+			moves++
+		}
+		before = after
+	}
+	return total, moves, blunders
+}
+
+// queenSacrifice ... move of the player giving the queen: taken on the next move,
+// and the player does not take the opponent's queen (not a trade)
+func queenSacrifice(movetext string, isWhite bool) (string, bool) {
+	playerQueen, opponentQueen := chess.WhiteQueen, chess.BlackQueen
+	if !isWhite {
+		playerQueen, opponentQueen = chess.BlackQueen, chess.WhiteQueen
+	}
+
+	moves := pgn.Moves(movetext)
+	captured := make([]chess.Piece, 0, len(moves)) // piece taken by every move
+	position := chess.NewGame().Position()
+	for _, move := range moves {
+		m, err := pgn.DecodeMove(position, move)
+		if err != nil {
+			return "", false
+		}
+		captured = append(captured, position.Board().Piece(m.S2()))
+		position = position.Update(m)
+	}
+
+	for ply := 0; ply+1 < len(moves); ply++ {
+		if (ply%2 == 0) != isWhite || captured[ply] == opponentQueen || captured[ply+1] != playerQueen {
+			continue
+		}
+		if ply+2 < len(moves) && captured[ply+2] == opponentQueen {
+			continue // queens traded
+		}
+		return pgn.MoveNumber(ply+1) + " " + moves[ply], true
+	}
+	return "", false
+}
+
+// notableHandler ... /report/notable?player={username}, with the other filters of the web page
+func notableHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "notableHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type notableResponse struct {
+		Error      string        `json:"error"`
+		Data       []NotableGame `json:"data"`
+		ComputedAt *time.Time    `json:"computedAt,omitempty"` // computed in advance
+	}
+
+	r.ParseForm()
+	player := strings.TrimSpace(r.Form.Get("player"))
+	var errs ValidationError
+	if player == "" {
+		errs.add("player", "player is missing: /report/notable?player={username}")
+	}
+	filter := NewGameFilter(r.Form)
+	if badRequest(w, filter, errs...) {
+		return
+	}
+
+	// the games of the player without other filter: computed in advance when precompute-schedule is set
+	if filter.pgn == "" && filter.fen == "" && len(filterContext(r.Form)) == 0 {
+		if notables, computedAt := precomputedNotable(player); computedAt != nil {
+			json.NewEncoder(w).Encode(notableResponse{Data: notables, ComputedAt: computedAt})
+			return
+		}
+	}
+	notables, err := NotableGames(player, filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(notableResponse{Data: notables})
+}
+
+// precomputedNotable ... notable games of {player} computed in advance, and when (nil when they were not)
+func precomputedNotable(player string) ([]NotableGame, *time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	store, err := openStore(ctx)
+	if err != nil {
+		return nil, nil
+	}
+	defer store.close(ctx)
+
+	var notables []NotableGame
+	computedAt := store.precomputed(ctx, ReportNotable, player, &notables)
+	return notables, computedAt
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/flutterbar/chess-explorer-go/internal/zobrist"
+	"github.com/notnil/chess"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+Notes: free text attached to a game or to a position (its Zobrist hash: whatever the move order), in the notes collection.
+  GET    /notes?gameId={id} or /notes?pgn={line} or /notes?fen={FEN}   the notes of the game or of the position
+  POST   /notes with text and gameId, pgn or fen                       a new note
+  POST   /notes with id and text                                       the note is changed
+  DELETE /notes?id={id}
+The notes of the position are also in the /nextmoves responses, those of the game in the /game responses.
+*/
+
+// maxNoteLength ... characters of a note at most
+const maxNoteLength = 10000
+
+// Note ... free text about a game or a position
+type Note struct {
+	ID      string    `json:"id" bson:"_id"`
+	GameID  string    `json:"gameId,omitempty" bson:"gameid,omitempty"`
+	Hash    int64     `json:"hash,string,omitempty" bson:"hash,omitempty"` // position (see zobrist), a string in JSON: too big for javascript numbers
+	FEN     string    `json:"fen,omitempty" bson:"fen,omitempty"`          // of the position, to read the notes without the explorer
+	Text    string    `json:"text" bson:"text"`
+	Created time.Time `json:"created" bson:"created"`
+	Updated time.Time `json:"updated" bson:"updated"`
+	Owner   string    `json:"-" bson:"owner,omitempty"` // of the request which added it (isolation, see owner.go)
+}
+
+func notesHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "notesHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type notesResponse struct {
+		Error string `json:"error"`
+		Data  []Note `json:"data"`
+	}
+
+	r.ParseForm()
+	var errs ValidationError
+	id := strings.TrimSpace(r.Form.Get("id"))
+	gameID := strings.TrimSpace(r.Form.Get("gameId"))
+	text := strings.TrimSpace(r.Form.Get("text"))
+	switch r.Method {
+	case http.MethodDelete:
+		if id == "" {
+			errs.add("id", "id is missing: the note to delete")
+		}
+	case http.MethodPost:
+		if text == "" {
+			errs.add("text", "text is missing: the note")
+		} else if len(text) > maxNoteLength {
+			errs.add("text", "a note has %d characters at most", maxNoteLength)
+		}
+	}
+
+	// the game or the position of a new note, or of the notes to read
+	var position *chess.Position
+	if id == "" && gameID == "" {
+		filter := NewGameFilter(r.Form)
+		if badRequest(w, filter, errs...) {
+			return
+		}
+		position = filter.boardPosition()
+	} else if badRequest(w, &GameFilter{}, errs...) {
+		return
+	}
+
+	// Connect to DB
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer client.Disconnect(ctx)
+
+	notes := mongodb.Collection(client, "notes")
+	owner := requestGamesOwner(r)
+	var note Note
+	switch {
+	case r.Method == http.MethodDelete:
+		err = DeleteNote(ctx, notes, owner, id)
+	case r.Method == http.MethodPost && id != "":
+		note, err = UpdateNote(ctx, notes, owner, id, text)
+	case r.Method == http.MethodPost:
+		note, err = AddNote(ctx, notes, owner, gameID, position, text)
+	default:
+		var list []Note
+		if gameID != "" {
+			list, err = GameNotes(ctx, notes, owner, gameID)
+		} else {
+			list, err = PositionNotes(ctx, notes, owner, zobrist.Hash(position))
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(notesResponse{Data: list})
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	response := notesResponse{Data: []Note{}}
+	if note.ID != "" {
+		response.Data = append(response.Data, note)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// boardPosition ... position of the board of the filter: its FEN, or after its line
+func (filter *GameFilter) boardPosition() *chess.Position {
+	if filter.position != nil {
+		return filter.position
+	}
+	position := chess.StartingPosition()
+	for _, move := range filter.pgnMoves {
+		// the moves were checked by NewGameFilter
+		m, err := pgn.DecodeMove(position, move)
+		if err != nil {
+			break
+		}
+		position = position.Update(m)
+	}
+	return position
+}
+
+// AddNote ... a new note of {owner} ("": no owner) about the game {gameID} or, without game, about {position}
+func AddNote(ctx context.Context, notes *mongo.Collection, owner string, gameID string, position *chess.Position, text string) (Note, error) {
+	now := time.Now().UTC()
+	note := Note{ID: primitive.NewObjectID().Hex(), GameID: gameID, Text: text, Created: now, Updated: now, Owner: owner}
+	if gameID == "" {
+		note.Hash = zobrist.Hash(position)
+		note.FEN = position.String()
+	}
+	if err := ensureNoteIndexes(ctx, notes); err != nil {
+		return note, err
+	}
+	_, err := notes.InsertOne(ctx, note)
+	return note, err
+}
+
+// UpdateNote ... replace the text of the note {id} of {owner} ("": any owner)
+func UpdateNote(ctx context.Context, notes *mongo.Collection, owner string, id string, text string) (Note, error) {
+	var note Note
+	err := notes.FindOneAndUpdate(ctx, pgntodb.OwnerQuery(bson.M{"_id": id}, owner),
+		bson.M{"$set": bson.M{"text": text, "updated": time.Now().UTC()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&note)
+	if err == mongo.ErrNoDocuments {
+		return note, errors.New("Note not found: " + id)
+	}
+	return note, err
+}
+
+// DeleteNote ... remove the note {id} of {owner} ("": any owner)
+func DeleteNote(ctx context.Context, notes *mongo.Collection, owner string, id string) error {
+	result, err := notes.DeleteOne(ctx, pgntodb.OwnerQuery(bson.M{"_id": id}, owner))
+	if err == nil && result.DeletedCount == 0 {
+		return errors.New("Note not found: " + id)
+	}
+	return err
+}
+
+// GameNotes ... notes of {owner} ("": every note) of the game {gameID}, oldest first
+func GameNotes(ctx context.Context, notes *mongo.Collection, owner string, gameID string) ([]Note, error) {
+	return findNotes(ctx, notes, pgntodb.OwnerQuery(bson.M{"gameid": gameID}, owner))
+}
+
+// PositionNotes ... notes of {owner} ("": every note) of the position of Zobrist hash {hash}, oldest first
+func PositionNotes(ctx context.Context, notes *mongo.Collection, owner string, hash int64) ([]Note, error) {
+	return findNotes(ctx, notes, pgntodb.OwnerQuery(bson.M{"hash": hash}, owner))
+}
+
+func findNotes(ctx context.Context, notes *mongo.Collection, query bson.M) ([]Note, error) {
+	cursor, err := notes.Find(ctx, query, options.Find().SetSort(bson.M{"created": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	list := make([]Note, 0)
+	if err = cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ensureNoteIndexes ... indexes of the notes by game and by position
+func ensureNoteIndexes(ctx context.Context, notes *mongo.Collection) error {
+	_, err := notes.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "gameid", Value: 1}}},
+		{Keys: bson.D{{Key: "hash", Value: 1}}},
+	})
+	return err
+}
+
+// notesOf ... notes returned with a response: an error is logged, the response is sent without the notes
+func notesOf(notes []Note, err error) []Note {
+	if err != nil {
+		log.Warn("notes: " + err.Error())
+		return nil
+	}
+	return notes
+}
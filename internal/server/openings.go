@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Opening ... games of a player in an opening, results from the player's side
+type Opening struct {
+	Name   string  `json:"name"` // opening name, or the first moves for games imported without one
+	ECO    string  `json:"eco,omitempty"`
+	Games  int     `json:"games"`
+	Wins   int     `json:"wins"`
+	Draws  int     `json:"draws"`
+	Losses int     `json:"losses"`
+	Score  float64 `json:"score"` // points per game (win 1, draw 0.5)
+}
+
+// ReportOpenings ... openings of {player} (username or site:username) with {color} (white, black or both)
+// in the games of {gameFilter} (its white and black are ignored), most played first
+func ReportOpenings(ctx context.Context, games *mongo.Collection, player string, color string, gameFilter *GameFilter) ([]Opening, error) {
+	if gameFilter.invalid != nil {
+		return nil, gameFilter.invalid
+	}
+	openings := map[string]*Opening{}
+	for _, side := range []string{"white", "black"} {
+		if color != side && color != "both" {
+			continue
+		}
+		filter := *gameFilter
+		filter.white, filter.black = "", ""
+		if side == "white" {
+			filter.white = player
+		} else {
+			filter.black = player
+		}
+		if err := reportOpenings(ctx, games, &filter, side, openings); err != nil {
+			return nil, err
+		}
+	}
+
+	ret := make([]Opening, 0, len(openings))
+	for _, opening := range openings {
+		opening.Score = (float64(opening.Wins) + float64(opening.Draws)/2) / float64(opening.Games)
+		ret = append(ret, *opening)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Games != ret[j].Games {
+			return ret[i].Games > ret[j].Games
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return ret, nil
+}
+
+// reportOpenings ... add the games of {gameFilter} to {openings}, {side} is the player's color
+func reportOpenings(ctx context.Context, games *mongo.Collection, gameFilter *GameFilter, side string, openings map[string]*Opening) error {
+	pipeline := make([]bson.M, 0)
+	pipeline = append(pipeline, bson.M{"$match": bsonFromGameFilter(gameFilter)})
+
+	// games imported before the opening was stored: first 2 moves
+	firstMoves := bson.M{"$concat": bson.A{"1. ", "$m01", " ", "$m02", " 2. ", "$m03", " ", "$m04"}}
+	count := func(result string) bson.M {
+		return bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$result", result}}, 1, 0}}}
+	}
+	groupStage := bson.M{
+		"$group": bson.M{
+			"_id":   bson.M{"$ifNull": bson.A{"$opening", bson.M{"$ifNull": bson.A{firstMoves, "Unknown"}}}},
+			"eco":   bson.M{"$first": "$eco"},
+			"games": bson.M{"$sum": 1},
+			"white": count("1-0"),
+			"black": count("0-1"),
+		},
+	}
+	pipeline = append(pipeline, groupStage)
+
+	aggregateCursor, err := games.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer aggregateCursor.Close(ctx)
+
+	var results []struct {
+		Name  string `bson:"_id"`
+		ECO   string `bson:"eco"`
+		Games int    `bson:"games"`
+		White int    `bson:"white"`
+		Black int    `bson:"black"`
+	}
+	if err = aggregateCursor.All(ctx, &results); err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		opening, ok := openings[result.Name]
+		if !ok {
+			opening = &Opening{Name: result.Name, ECO: result.ECO}
+			openings[result.Name] = opening
+		}
+		wins, losses := result.White, result.Black
+		if side == "black" {
+			wins, losses = losses, wins
+		}
+		opening.Games += result.Games
+		opening.Wins += wins
+		opening.Losses += losses
+		opening.Draws += result.Games - result.White - result.Black
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/flutterbar/chess-explorer-go/internal/zobrist"
+	"github.com/notnil/chess"
+)
+
+/*
+Opponent model, for bots preparing against a known opponent (anti-book moves):
+  GET /opponents/model?opponent=lichess.org:{username}&fen={FEN}    (or pgn={line}, or hash={Zobrist hash of the notes})
+the replies of the opponent in the position, in their games of the database (with the other filters), with their probability.
+*/
+
+// maxModelGames ... most recent games of the opponent in the position read at most
+const maxModelGames = 1000
+
+// OpponentReply ... move of an opponent in a position
+type OpponentReply struct {
+	Move        string  `json:"move"` // SAN
+	UCI         string  `json:"uci"`
+	Games       int     `json:"games"`
+	Probability float64 `json:"probability"` // games / games of the opponent in the position
+	Score       float64 `json:"score"`       // points per game of the opponent after the move (win 1, draw 0.5)
+	points      float64
+}
+
+// OpponentModel ... replies of an opponent in a position, most played first
+type OpponentModel struct {
+	Opponent string          `json:"opponent"`
+	Hash     int64           `json:"hash,string"` // of the position, a string in JSON: too big for javascript numbers
+	Games    int             `json:"games"`       // games of the opponent to move in the position
+	Replies  []OpponentReply `json:"replies"`
+}
+
+func opponentModelHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "opponentModelHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type opponentModelResponse struct {
+		Error string         `json:"error"`
+		Data  *OpponentModel `json:"data"`
+	}
+
+	r.ParseForm()
+	opponent := strings.TrimSpace(r.Form.Get("opponent"))
+	filter := NewGameFilter(r.Form)
+	var errs ValidationError
+	if opponent == "" {
+		errs.add("opponent", "opponent is missing: /opponents/model?opponent={username}&fen={FEN}")
+	}
+	var hash int64
+	var position *chess.Position // nil: position known by its hash only
+	if value := strings.TrimSpace(r.Form.Get("hash")); value != "" {
+		var err error
+		if hash, err = strconv.ParseInt(value, 10, 64); err != nil {
+			errs.add("hash", "invalid hash %q: the Zobrist hash of the position, as in the notes", value)
+		}
+	} else {
+		position = filter.boardPosition()
+		hash = zobrist.Hash(position)
+	}
+	if badRequest(w, filter, errs...) {
+		return
+	}
+
+	model, err := ModelOpponent(opponent, hash, position, filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(opponentModelResponse{Data: model})
+}
+
+// ModelOpponent ... replies of {opponent} (username or site:username) in the position of {hash} ({position}: nil when unknown),
+// in the games of {gameFilter} (its line, position, white and black are ignored), maxModelGames most recent at most
+func ModelOpponent(opponent string, hash int64, position *chess.Position, gameFilter *GameFilter) (*OpponentModel, error) {
+	if gameFilter.invalid != nil {
+		return nil, gameFilter.invalid
+	}
+	starting := position != nil && position.Hash() == chess.StartingPosition().Hash()
+	model := OpponentModel{Opponent: opponent, Hash: hash, Replies: []OpponentReply{}}
+	replies := map[string]*OpponentReply{}
+
+	for _, side := range []string{"white", "black"} {
+		if position != nil && (position.Turn() == chess.White) != (side == "white") {
+			continue // the opponent is not to move
+		}
+		filter := *gameFilter
+		filter.white, filter.black = "", ""
+		filter.pgn, filter.pgnMoves, filter.fen, filter.position = "", nil, "", nil
+		filter.mongoAggregation = false
+		if side == "white" {
+			filter.white = opponent
+		} else {
+			filter.black = opponent
+		}
+
+		games, err := modelGames(&filter, hash, starting)
+		if err != nil {
+			return nil, err
+		}
+		for _, game := range games {
+			ply := 0 // of the reply
+			if !starting {
+				ply = -1
+				for i, gameHash := range game.Hashes {
+					if gameHash == hash {
+						ply = i + 1
+						break
+					}
+				}
+			}
+			moves := pgn.Moves(game.PGN)
+			if ply < 0 || ply >= len(moves) || (ply%2 == 0) != (side == "white") {
+				continue // the game ends there, or the other side is to move
+			}
+			reply, ok := replies[moves[ply]]
+			if !ok {
+				uci, err := uciMove(moves[:ply+1])
+				if err != nil {
+					continue
+				}
+				reply = &OpponentReply{Move: moves[ply], UCI: uci}
+				replies[moves[ply]] = reply
+			}
+			reply.Games++
+			model.Games++
+			switch {
+			case game.Result == "1/2-1/2":
+				reply.points += 0.5
+			case (game.Result == "1-0") == (side == "white"):
+				reply.points++
+			}
+		}
+	}
+
+	for _, reply := range replies {
+		reply.Probability = float64(reply.Games) / float64(model.Games)
+		reply.Score = reply.points / float64(reply.Games)
+		model.Replies = append(model.Replies, *reply)
+	}
+	sort.Slice(model.Replies, func(i, j int) bool {
+		if model.Replies[i].Games != model.Replies[j].Games {
+			return model.Replies[i].Games > model.Replies[j].Games
+		}
+		return model.Replies[i].Move < model.Replies[j].Move
+	})
+	return &model, nil
+}
+
+// modelGames ... most recent games of {filter} reaching the position {hash} (all of them: the starting position)
+func modelGames(filter *GameFilter, hash int64, starting bool) ([]pgntodb.Game, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	store, err := openStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer store.close(ctx)
+	return store.gamesReaching(ctx, filter, hash, starting)
+}
+
+// uciMove ... UCI notation of the last move of {moves} (SAN from the starting position)
+func uciMove(moves []string) (string, error) {
+	position := chess.StartingPosition()
+	for i, san := range moves {
+		move, err := pgn.DecodeMove(position, san)
+		if err != nil {
+			return "", err
+		}
+		if i == len(moves)-1 {
+			return chess.UCINotation{}.Encode(position, move), nil
+		}
+		position = position.Update(move)
+	}
+	return "", errors.New("no move")
+}
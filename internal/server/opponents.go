@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+)
+
+// OpponentMove ... a move played by an opponent in the position
+type OpponentMove struct {
+	Move  string `json:"move"`
+	Games int    `json:"games"`
+}
+
+// OpponentStats ... games of an opponent in the position, results from the opponent's side
+type OpponentStats struct {
+	Opponent string         `json:"opponent"`
+	Site     string         `json:"site"`
+	Games    int            `json:"games"`
+	Wins     int            `json:"wins"`
+	Draws    int            `json:"draws"`
+	Losses   int            `json:"losses"`
+	Score    float64        `json:"score"` // points per game of the opponent (win 1, draw 0.5)
+	Moves    []OpponentMove `json:"moves"` // next moves (of both sides), most played first, empty when the games ended here
+}
+
+// Opponents ... opponents of the player(s) of {filter} (white, or black when white is empty) in the games reaching its line,
+// with their scores and continuations, most frequent opponent first
+func Opponents(ctx context.Context, filter *GameFilter) ([]OpponentStats, error) {
+	if (filter.white == "") == (filter.black == "") {
+		return nil, errors.New("Set the player(s) of one side only: the opponents play the other side")
+	}
+	opponentIsBlack := filter.white != ""
+
+	type key struct{ site, opponent string }
+	opponents := map[key]*OpponentStats{}
+	moves := map[key]map[string]int{}
+	_, err := EachGame(ctx, filter, func(game *pgntodb.Game) error {
+		opponent := game.Black
+		if !opponentIsBlack {
+			opponent = game.White
+		}
+		k := key{game.Site, opponent}
+		stats, ok := opponents[k]
+		if !ok {
+			stats = &OpponentStats{Opponent: opponent, Site: game.Site}
+			opponents[k] = stats
+			moves[k] = map[string]int{}
+		}
+		stats.Games++
+		switch {
+		case game.Result == "1/2-1/2":
+			stats.Draws++
+		case (game.Result == "0-1") == opponentIsBlack:
+			stats.Wins++
+		default:
+			stats.Losses++
+		}
+		if _, move := filter.nextMove(game); move != "" {
+			moves[k][move]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]OpponentStats, 0, len(opponents))
+	for k, stats := range opponents {
+		stats.Score = (float64(stats.Wins) + float64(stats.Draws)/2) / float64(stats.Games)
+		stats.Moves = make([]OpponentMove, 0, len(moves[k]))
+		for move, games := range moves[k] {
+			stats.Moves = append(stats.Moves, OpponentMove{Move: move, Games: games})
+		}
+		sort.Slice(stats.Moves, func(i, j int) bool {
+			if stats.Moves[i].Games != stats.Moves[j].Games {
+				return stats.Moves[i].Games > stats.Moves[j].Games
+			}
+			return stats.Moves[i].Move < stats.Moves[j].Move
+		})
+		ret = append(ret, *stats)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Games != ret[j].Games {
+			return ret[i].Games > ret[j].Games
+		}
+		return ret[i].Opponent < ret[j].Opponent
+	})
+	return ret, nil
+}
+
+// opponentsHandler ... /opponents with the filter of /nextmoves
+func opponentsHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "opponentsHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type opponentsResponse struct {
+		Error string          `json:"error"`
+		Data  []OpponentStats `json:"data"`
+	}
+
+	filter := gameFilterFromRequest(r)
+	if badRequest(w, filter) {
+		return
+	}
+	opponents, err := Opponents(context.Background(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(opponentsResponse{Data: opponents})
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+/*
+Per-user isolation, for hosted instances serving several people (isolation: true in the config file): every request but
+the web page and the login is authenticated as the bookmarks (lichess login or API key) and reaches the games and
+lastgames of its owner only. The games imported with /import/pgn belong to the owner of the request; the commands
+import for owner: of the config file (see pgntodb.Owner).
+The owner travels in the owner form value of the request, set by isolate: the value sent by the client is ignored.
+*/
+
+// ownerParam ... form value of the owner of the request
+const ownerParam = "owner"
+
+// openPaths ... paths reached without owner: the login
+var openPaths = map[string]bool{"/login": true, "/oauth/callback": true, "/logout": true, "/me": true}
+
+// isolation ... the requests reach the games of their owner only (isolation)
+func isolation() bool {
+	return viper.GetBool("isolation")
+}
+
+// isolate ... {mux} with the owner of every request in its form (isolation), 401 for a request without owner;
+// the web page (static files of /) and the login are open
+func isolate(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern == "/" || openPaths[r.URL.Path] {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		if r.URL.Path == "/import/pgn" {
+			r.Form = r.URL.Query() // the body is the PGN text, not a form
+		} else {
+			r.ParseForm()
+		}
+		r.Form.Del(ownerParam)
+		if !isolation() {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "X-Api-Key")
+		if r.Method == http.MethodOptions {
+			return // preflight of the X-Api-Key header
+		}
+		owner, err := requestOwner(r)
+		if err != nil {
+			log.Warn("request without owner from " + clientIP(r) + ": " + r.URL.Path)
+			w.WriteHeader(http.StatusUnauthorized)
+			writeError(w, err)
+			return
+		}
+		r.Form.Set(ownerParam, owner)
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// requestGamesOwner ... owner of the games the request reaches, "" for all the games (no isolation)
+func requestGamesOwner(r *http.Request) string {
+	return r.Form.Get(ownerParam)
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestIsolate(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/report", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, requestGamesOwner(r))
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "login")
+	})
+	handler := isolate(mux)
+	request := func(path string, apiKey string) (int, string) {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if apiKey != "" {
+			r.Header.Set("X-Api-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code, strings.TrimSpace(w.Body.String())
+	}
+
+	defer viper.Set("isolation", false)
+	viper.Set("isolation", false)
+	if code, owner := request("/report?owner=key:someone", ""); code != http.StatusOK || owner != "" {
+		t.Errorf("without isolation: %d, owner %q, want 200 and all the games", code, owner)
+	}
+
+	viper.Set("isolation", true)
+	if code, _ := request("/report", ""); code != http.StatusUnauthorized {
+		t.Errorf("isolation without API key: %d, want 401", code)
+	}
+	if code, _ := request("/report", "short"); code != http.StatusUnauthorized {
+		t.Errorf("isolation with a short API key: %d, want 401", code)
+	}
+	if code, body := request("/login", ""); code != http.StatusOK || body != "login" {
+		t.Errorf("login with isolation: %d %q, want it open", code, body)
+	}
+	_, alice := request("/report", "alice-0123456789abcdef")
+	_, aliceAgain := request("/report?owner="+url.QueryEscape("key:bob"), "alice-0123456789abcdef")
+	_, bob := request("/report", "bob-0123456789abcdef")
+	if !strings.HasPrefix(alice, "key:") || alice != aliceAgain || alice == bob {
+		t.Errorf("owners %q, %q (owner sent by the client) and %q: want the same key owner twice, another one", alice, aliceAgain, bob)
+	}
+}
+
+func TestGameFilterOfOwner(t *testing.T) {
+	filter := NewGameFilter(url.Values{ownerParam: {"key:alice"}, "white": {"alice"}})
+	query := fmt.Sprint(bsonFromGameFilter(filter))
+	if !strings.Contains(query, "owner:key:alice") {
+		t.Errorf("query of an owner %s: no owner condition", query)
+	}
+	if query := fmt.Sprint(bsonFromGameFilter(NewGameFilter(url.Values{"white": {"alice"}}))); strings.Contains(query, "owner") {
+		t.Errorf("query without owner %s: owner condition", query)
+	}
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Reports computed in advance for a player, on all their games (see precompute), stored in the reports collection
+const (
+	ReportOpeningsWhite = "openings-white" // repertoire with white (ReportOpenings)
+	ReportOpeningsBlack = "openings-black" // repertoire with black
+	ReportSpeedResults  = "results"        // results by speed (ReportResults)
+	ReportNotable       = "notable"        // games standing out (NotableGames)
+)
+
+// PrecomputedReports ... the reports which can be computed in advance
+var PrecomputedReports = []string{ReportOpeningsWhite, ReportOpeningsBlack, ReportSpeedResults, ReportNotable}
+
+// PrecomputedReport ... a report of a player computed in advance
+type PrecomputedReport struct {
+	ID         string          `json:"-" bson:"_id"` // {report}/{player}
+	Report     string          `json:"report" bson:"report"`
+	Player     string          `json:"player" bson:"player"`
+	ComputedAt time.Time       `json:"computedAt" bson:"computedat"`
+	Data       json.RawMessage `json:"data" bson:"-"`
+	JSON       string          `json:"-" bson:"json"` // Data as stored
+}
+
+// ComputeReport ... the report {report} of {player} (username or site:username) on all their games
+func ComputeReport(ctx context.Context, games *mongo.Collection, report string, player string) (interface{}, error) {
+	filter := NewGameFilter(url.Values{})
+	switch report {
+	case ReportOpeningsWhite:
+		return ReportOpenings(ctx, games, player, "white", filter)
+	case ReportOpeningsBlack:
+		return ReportOpenings(ctx, games, player, "black", filter)
+	case ReportSpeedResults:
+		return ReportResults(ctx, games, player, filter)
+	case ReportNotable:
+		return NotableGames(player, filter)
+	}
+	return nil, fmt.Errorf("unknown report %q: %s", report, strings.Join(PrecomputedReports, ", "))
+}
+
+// StoreReport ... {data}, the report {report} of {player} computed now, replaces the previous one
+func StoreReport(ctx context.Context, reports *mongo.Collection, report string, player string, data interface{}) error {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	stored := PrecomputedReport{
+		ID:         reportID(report, player),
+		Report:     report,
+		Player:     player,
+		ComputedAt: time.Now().UTC(),
+		JSON:       string(content),
+	}
+	_, err = reports.ReplaceOne(ctx, bson.M{"_id": stored.ID}, stored, options.Replace().SetUpsert(true))
+	return err
+}
+
+// FindReport ... the report {report} of {player} computed in advance, nil when it was not
+func FindReport(ctx context.Context, reports *mongo.Collection, report string, player string) (*PrecomputedReport, error) {
+	var stored PrecomputedReport
+	err := reports.FindOne(ctx, bson.M{"_id": reportID(report, player)}).Decode(&stored)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	stored.Data = json.RawMessage(stored.JSON)
+	return &stored, nil
+}
+
+// findReportInto ... decode the report {report} of {player} computed in advance into {data}: when it was computed, nil when it was not
+// (errors are logged: the report is then computed again), nil with isolation: the reports computed in advance cover every game
+func findReportInto(ctx context.Context, client *mongodb.Client, report string, player string, data interface{}) *time.Time {
+	if isolation() {
+		return nil
+	}
+	stored, err := FindReport(ctx, mongodb.Collection(client, "reports"), report, player)
+	if err == nil && stored != nil {
+		err = json.Unmarshal(stored.Data, data)
+	}
+	if err != nil {
+		log.Warn("precomputed report " + reportID(report, player) + ": " + err.Error())
+		return nil
+	}
+	if stored == nil {
+		return nil
+	}
+	return &stored.ComputedAt
+}
+
+// reportID ... key of a report of a player (usernames are not case sensitive)
+func reportID(report string, player string) string {
+	return report + "/" + strings.ToLower(strings.TrimSpace(player))
+}
+
+// precomputedHandler ... /reports/precomputed?report={report}&player={username}: a report computed in advance, with its time
+func precomputedHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "precomputedHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type precomputedResponse struct {
+		Error string             `json:"error"`
+		Data  *PrecomputedReport `json:"data"`
+	}
+
+	r.ParseForm()
+	var errs ValidationError
+	report := strings.TrimSpace(r.Form.Get("report"))
+	player := strings.TrimSpace(r.Form.Get("player"))
+	if !isPrecomputedReport(report) {
+		errs.add("report", "invalid report %q: %s", report, strings.Join(PrecomputedReports, ", "))
+	}
+	if player == "" {
+		errs.add("player", "player is missing: /reports/precomputed?report={report}&player={username}")
+	}
+	if badRequest(w, &GameFilter{}, errs...) {
+		return
+	}
+	if isolation() {
+		writeError(w, errors.New("Precomputed reports are not available with isolation: they cover every game"))
+		return
+	}
+
+	// Connect to DB
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer client.Disconnect(ctx)
+
+	stored, err := FindReport(ctx, mongodb.Collection(client, "reports"), report, player)
+	if err == nil && stored == nil {
+		err = errors.New("Report " + report + " of " + player + " not computed yet (see precompute-schedule)")
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(precomputedResponse{Data: stored})
+}
+
+func isPrecomputedReport(report string) bool {
+	for _, known := range PrecomputedReports {
+		if report == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/spf13/viper"
+)
+
+/*
+Read-only mode, for a public reference instance of a curated database (server --read-only, or read-only: true in the
+config file): the requests changing the database are refused with 403 (upload of games, tags, notes, bookmarks, share
+links, teams) and a lichess login does not download the games of the user. Everything else (explorer, reports, exports)
+works as usual.
+*/
+
+// mutations ... methods changing the database, by path (nil: every method)
+var mutations = map[string][]string{
+	"/import/pgn": nil,
+	"/games/tags": {http.MethodPost},
+	"/notes":      {http.MethodPost, http.MethodDelete},
+	"/bookmarks":  {http.MethodPost, http.MethodDelete},
+	"/views":      {http.MethodPost},
+	"/teams":      {http.MethodPost, http.MethodDelete},
+}
+
+// readOnly ... the database is not changed by the requests (read-only)
+func readOnly() bool {
+	return viper.GetBool("read-only")
+}
+
+// mutating ... {r} changes the database
+func mutating(r *http.Request) bool {
+	methods, ok := mutations[r.URL.Path]
+	if !ok {
+		return false
+	}
+	if methods == nil {
+		return true
+	}
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	return false
+}
+
+// guardReadOnly ... {handler} refusing the requests changing the database with 403 (read-only)
+func guardReadOnly(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !readOnly() || !mutating(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		// allow cross origin
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusForbidden)
+		writeError(w, errors.New("This server is read-only"))
+	})
+}
@@ -0,0 +1,17 @@
+package server
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+)
+
+// referenceNextMoves ... moves of the reference games (pgntodb --reference) in the line or position of {values},
+// whatever the other values of the filter (players, dates...): the comparison source of the explorer
+func referenceNextMoves(ctx context.Context, client *mongodb.Client, values url.Values) ([]NextMove, error) {
+	filter := NewGameFilter(url.Values{"pgn": {values.Get("pgn")}, "fen": {values.Get("fen")}, "aggregation": {values.Get("aggregation")}})
+	moves, _, err := nextMoves(ctx, mongodb.Collection(client, pgntodb.ReferenceCollection), filter, nil)
+	return moves, err
+}
@@ -3,17 +3,13 @@ package server
 import (
 	"context"
 	"encoding/json"
-	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
-	"github.com/spf13/viper"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 type result struct {
@@ -33,6 +29,9 @@ type report struct {
 	Users        []userResult
 	UsersAsWhite []result
 	TimeControls []result
+	Openings     []Opening `json:"openings,omitempty"` // when only white or only black is filtered
+	// time of the openings computed in advance (no dates filtered, see precompute), nil when they were computed now
+	OpeningsComputedAt *time.Time `json:"openingsComputedAt,omitempty"`
 }
 
 type reportResponse struct {
@@ -40,8 +39,6 @@ type reportResponse struct {
 	Data  report `json:"data"`
 }
 
-var filter GameFilter
-
 func reportHandler(w http.ResponseWriter, r *http.Request) {
 
 	defer timeTrack(time.Now(), "reportHandler")
@@ -49,69 +46,50 @@ func reportHandler(w http.ResponseWriter, r *http.Request) {
 	// allow cross origin
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	filter.white = strings.TrimSpace(r.FormValue("white"))
-	filter.black = strings.TrimSpace(r.FormValue("black"))
-	filter.from = strings.TrimSpace(r.FormValue("from"))
-	filter.to = strings.TrimSpace(r.FormValue("to"))
-
-	response := reportResponse{}
+	// the players, dates and owner of the request only
+	filter := &GameFilter{
+		white: strings.TrimSpace(r.FormValue("white")),
+		black: strings.TrimSpace(r.FormValue("black")),
+		owner: requestGamesOwner(r),
+	}
+	filter.setDates(r.FormValue("from"), r.FormValue("to"), r.FormValue("tz"))
+	if badRequest(w, filter) {
+		return
+	}
 
 	// Connect to DB
-	client, err := mongo.NewClient(options.Client().ApplyURI(viper.GetString("mongo-url")))
-	if err != nil {
-		log.Fatal(err)
-	}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	err = client.Connect(ctx)
+	store, err := openStore(ctx)
 	if err != nil {
-		log.Fatal(err)
-	}
-	defer client.Disconnect(ctx)
-
-	// Ping MongoDB
-	if err = client.Ping(ctx, readpref.Primary()); err != nil {
-		log.Fatal("Cannot connect to DB " + viper.GetString("mongo-url"))
+		writeError(w, err)
+		return
 	}
+	defer store.close(ctx)
 
-	games := client.Database(viper.GetString("mongo-db-name")).Collection("games")
-	lastgames := client.Database(viper.GetString("mongo-db-name")).Collection("lastgames")
-
-	// Total games
-	totalGames, error := games.CountDocuments(ctx, bson.M{})
-	if error != nil {
-		log.Fatal(error)
-	}
-	report := report{}
-	report.TotalGames = totalGames
-
-	if filter.black == "" && filter.white == "" {
-		//reportGames(ctx, games, &report)
-		reportSites(ctx, games, &report)
-		reportUsers(ctx, games, lastgames, &report)
-		//reportUsersAsWhite(ctx, games, &report)
-		reportTimeControls(ctx, &filter, games, &report)
-	} else {
-		reportTimeControls(ctx, &filter, games, &report)
+	report, err := store.report(ctx, filter)
+	if err != nil {
+		writeError(w, err)
+		return
 	}
 
 	// send the response
-	response.Data = report
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(reportResponse{Data: report})
 }
 
 // Games
-func reportGames(ctx context.Context, games *mongo.Collection, report *report) {
+func reportGames(ctx context.Context, games *mongo.Collection, report *report) error {
 	totalGames, error := games.CountDocuments(ctx, bson.M{})
 	if error != nil {
-		log.Fatal(error)
+		return error
 	}
 	report.TotalGames = totalGames
+	return nil
 }
 
 // Sites
-func reportSites(ctx context.Context, games *mongo.Collection, report *report) {
-	filter := bson.M{"$match": bson.M{}}
+func reportSites(ctx context.Context, games *mongo.Collection, owner string, report *report) error {
+	filter := bson.M{"$match": pgntodb.OwnerQuery(bson.M{}, owner)}
 	pipeline := make([]bson.M, 0)
 	pipeline = append(pipeline, filter)
 
@@ -141,38 +119,40 @@ func reportSites(ctx context.Context, games *mongo.Collection, report *report) {
 
 	aggregateCursor, err := games.Aggregate(ctx, pipeline)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	defer aggregateCursor.Close(ctx)
 
 	var siteResults []result
 	if err = aggregateCursor.All(ctx, &siteResults); err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	report.Sites = siteResults
+	return nil
 }
 
 // Users
-func reportUsers(ctx context.Context, games *mongo.Collection, lastgames *mongo.Collection, report *report) {
-	cursor, err := lastgames.Find(ctx, bson.M{})
+func reportUsers(ctx context.Context, games *mongo.Collection, lastgames *mongo.Collection, owner string, report *report) error {
+	cursor, err := lastgames.Find(ctx, pgntodb.OwnerQuery(bson.M{}, owner))
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	var results []pgntodb.LastGame
 	if err = cursor.All(ctx, &results); err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	report.Users = make([]userResult, 0)
 	for _, aUser := range results {
 		report.Users = append(report.Users, userResult{SiteName: aUser.Site, Name: aUser.Username, Count: 0})
 	}
+	return nil
 }
 
 // Users as white
-func reportUsersAsWhite(ctx context.Context, games *mongo.Collection, report *report) {
+func reportUsersAsWhite(ctx context.Context, games *mongo.Collection, report *report) error {
 	filter := bson.M{"$match": bson.M{}}
 	pipeline := make([]bson.M, 0)
 	pipeline = append(pipeline, filter)
@@ -208,21 +188,22 @@ func reportUsersAsWhite(ctx context.Context, games *mongo.Collection, report *re
 
 	aggregateCursor, err := games.Aggregate(ctx, pipeline)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	defer aggregateCursor.Close(ctx)
 
 	var usersAsWhiteResult []result
 	if err = aggregateCursor.All(ctx, &usersAsWhiteResult); err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	report.UsersAsWhite = usersAsWhiteResult
+	return nil
 }
 
 // Time controls
-func reportTimeControls(ctx context.Context, gameFilter *GameFilter, games *mongo.Collection, report *report) {
+func reportTimeControls(ctx context.Context, gameFilter *GameFilter, games *mongo.Collection, report *report) error {
 	filter := bson.M{"$match": bsonFromGameFilter(gameFilter)}
 	pipeline := make([]bson.M, 0)
 	pipeline = append(pipeline, filter)
@@ -253,15 +234,16 @@ func reportTimeControls(ctx context.Context, gameFilter *GameFilter, games *mong
 
 	aggregateCursor, err := games.Aggregate(ctx, pipeline)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	defer aggregateCursor.Close(ctx)
 
 	var timeControlResults []result
 	if err = aggregateCursor.All(ctx, &timeControlResults); err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	report.TimeControls = timeControlResults
+	return nil
 }
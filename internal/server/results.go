@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"sort"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SpeedResults ... games of a player at a speed, results from the player's side
+type SpeedResults struct {
+	Speed  string  `json:"speed"` // bullet, blitz, rapid, classical or correspondence
+	Games  int     `json:"games"`
+	Wins   int     `json:"wins"`
+	Draws  int     `json:"draws"`
+	Losses int     `json:"losses"`
+	Score  float64 `json:"score"` // points per game (win 1, draw 0.5)
+}
+
+// ReportResults ... results of {player} (username or site:username) by speed in the games of {gameFilter}
+// (its white and black are ignored), most played first
+func ReportResults(ctx context.Context, games *mongo.Collection, player string, gameFilter *GameFilter) ([]SpeedResults, error) {
+	if gameFilter.invalid != nil {
+		return nil, gameFilter.invalid
+	}
+	speeds := map[string]*SpeedResults{}
+	for _, side := range []string{"white", "black"} {
+		filter := *gameFilter
+		filter.white, filter.black = "", ""
+		if side == "white" {
+			filter.white = player
+		} else {
+			filter.black = player
+		}
+
+		pipeline := []bson.M{
+			{"$match": bsonFromGameFilter(&filter)},
+			{"$group": bson.M{
+				"_id":   bson.M{"timecontrol": "$timecontrol", "result": "$result"},
+				"games": bson.M{"$sum": 1},
+			}},
+		}
+		cursor, err := games.Aggregate(ctx, pipeline)
+		if err != nil {
+			return nil, err
+		}
+		var results []struct {
+			ID struct {
+				TimeControl string `bson:"timecontrol"`
+				Result      string `bson:"result"`
+			} `bson:"_id"`
+			Games int `bson:"games"`
+		}
+		err = cursor.All(ctx, &results)
+		cursor.Close(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, result := range results {
+			if result.ID.Result != "1-0" && result.ID.Result != "0-1" && result.ID.Result != "1/2-1/2" {
+				continue // unfinished game
+			}
+			speed := pgntodb.Speed(result.ID.TimeControl)
+			speedResults, ok := speeds[speed]
+			if !ok {
+				speedResults = &SpeedResults{Speed: speed}
+				speeds[speed] = speedResults
+			}
+			speedResults.Games += result.Games
+			switch {
+			case result.ID.Result == "1/2-1/2":
+				speedResults.Draws += result.Games
+			case (result.ID.Result == "1-0") == (side == "white"):
+				speedResults.Wins += result.Games
+			default:
+				speedResults.Losses += result.Games
+			}
+		}
+	}
+
+	ret := make([]SpeedResults, 0, len(speeds))
+	for _, speedResults := range speeds {
+		speedResults.Score = (float64(speedResults.Wins) + float64(speedResults.Draws)/2) / float64(speedResults.Games)
+		ret = append(ret, *speedResults)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Games != ret[j].Games {
+			return ret[i].Games > ret[j].Games
+		}
+		return ret[i].Speed < ret[j].Speed
+	})
+	return ret, nil
+}
@@ -3,19 +3,17 @@ package server
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
 	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
 	"github.com/notnil/chess"
-	"github.com/spf13/viper"
+	log "github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 type searchFENResult struct {
@@ -41,20 +39,65 @@ func searchFentHandler(w http.ResponseWriter, r *http.Request) {
 	// allow cross origin
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	// create game filter
-	filter := gameFilterFromRequest(r)
+	var errs ValidationError
+	fen := strings.TrimSpace(r.FormValue("fen"))
+	if fen == "" {
+		errs.add("fen", "fen is missing: the position to search")
+	} else if position := errs.fenParam(r.Form, "fen"); position != nil {
+		// compared with the positions of the games
+		fen = position.String()
+	}
+	maxMoves := errs.intParam(r.Form, "maxMoves", 0, 0, 1000) // 0: all the moves
+
+	// create game filter (the fen searched is not the position filter of /nextmoves: games imported before the hashes are searched too)
+	r.Form.Del("fen")
+	filter := NewGameFilter(r.Form)
+	if badRequest(w, filter, errs...) {
+		return
+	}
 	gameFilterBson := bsonFromGameFilter(filter)
 
-	fen := strings.TrimSpace(r.FormValue("fen"))
-	maxMoves, _ := strconv.Atoi(r.FormValue("maxMoves"))
+	// the slot is held until the background job is over
+	release, ok := acquireHeavy(r)
+	if !ok {
+		tooManyRequests(w, r)
+		return
+	}
 
-	go searchFEN(fen, maxMoves, gameFilterBson) // launch background job and return immediately
+	// launch background job and return immediately
+	go func() {
+		defer release()
+		if err := searchFEN(fen, maxMoves, gameFilterBson); err != nil {
+			log.Error("Search for FEN " + fen + " failed: " + err.Error())
+		}
+	}()
 }
 
-func searchFEN(fen string, maxMoves int, gameFilterBson primitive.M) {
+func searchFEN(fen string, maxMoves int, gameFilterBson primitive.M) error {
 	log.Println("Searching for FEN: " + fen)
 	log.Println("Maximum", maxMoves, "moves per games")
 
+	// Connect to DB
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(ctx)
+
+	gamesCollection := mongodb.Collection(client, "games")
+
+	findOptions := options.Find().SetProjection(pgntodb.WithoutRaw)
+	limit := maxSearchGames()
+	if limit > 0 {
+		findOptions.SetLimit(limit)
+	}
+	cur, err := gamesCollection.Find(ctx, gameFilterBson, findOptions)
+	if err != nil {
+		return err
+	}
+
 	// start a ticker
 	ticker := time.NewTicker(15000 * time.Millisecond)
 	tickerChannel := make(chan bool)
@@ -97,45 +140,20 @@ func searchFEN(fen string, maxMoves int, gameFilterBson primitive.M) {
 		}
 	}()
 
-	// Connect to DB
-	client, err := mongo.NewClient(options.Client().ApplyURI(viper.GetString("mongo-url")))
-	if err != nil {
-		log.Fatal(err)
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
-	err = client.Connect(ctx)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer client.Disconnect(ctx)
-
-	// Ping MongoDB
-	if err = client.Ping(ctx, readpref.Primary()); err != nil {
-		log.Fatal("Cannot connect to DB " + viper.GetString("mongo-url"))
-	}
-
-	gamesCollection := client.Database(viper.GetString("mongo-db-name")).Collection("games")
-
-	cur, error := gamesCollection.Find(ctx, gameFilterBson)
-	if error != nil {
-		log.Fatal(err)
-	}
-
 	concurrency := 20
 	concurrencyChannel := make(chan bool, concurrency)
 
 	count := 0
 	for cur.Next(context.TODO()) {
 		var gameHolder pgntodb.Game
-		err := cur.Decode(&gameHolder)
+		if err := cur.Decode(&gameHolder); err != nil {
+			log.Warn(err)
+			continue
+		}
 
 		concurrencyChannel <- true // take a slot
 		go replay(gameHolder, fen, maxMoves, concurrencyChannel, logChannel)
 
-		if err != nil {
-			log.Fatal(err)
-		}
 		count++
 	}
 
@@ -145,6 +163,9 @@ func searchFEN(fen string, maxMoves int, gameFilterBson primitive.M) {
 	}
 
 	log.Printf("replayed " + strconv.Itoa(count) + " games")
+	if limit > 0 && int64(count) >= limit {
+		log.Warn("search stopped after " + strconv.Itoa(count) + " games (max-search-games)")
+	}
 
 	// stop the ticker
 	ticker.Stop()
@@ -152,6 +173,8 @@ func searchFEN(fen string, maxMoves int, gameFilterBson primitive.M) {
 
 	// dump the logs
 	logChannel <- nil
+
+	return cur.Err()
 }
 
 func replay(game pgntodb.Game, fen string, maxMoves int, concurrencyChannel chan bool, logChannel chan *searchFENResult) {
@@ -1,8 +1,9 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"os/exec"
 	"runtime"
@@ -10,23 +11,59 @@ import (
 	"time"
 
 	"github.com/flutterbar/chess-explorer-go/internal/embed"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
 // Start ... start a web server
-func Start() {
+func Start() error {
 
 	fs := http.FileServer(http.FS(embed.StaticFiles))
 	http.Handle("/", fs)
 
-	http.HandleFunc("/nextmoves", nextMovesHandler)
+	http.HandleFunc("/nextmoves", heavy(nextMovesHandler))
 	http.HandleFunc("/game", gameHandler)
+	http.HandleFunc("/game/pgn", gamePgnHandler)
+	http.HandleFunc("/games/byIds", gamesByIDsHandler)
+	http.HandleFunc("/games/tags", tagsHandler)
+	http.HandleFunc("/games/similar", heavy(similarHandler))
+	http.HandleFunc("/notes", databaseOnly("Notes", notesHandler))
+	http.HandleFunc("/bookmarks", databaseOnly("Bookmarks", bookmarksHandler))
+	http.HandleFunc("/views", databaseOnly("Share links", viewsHandler))
+	http.HandleFunc("/teams", teamsHandler)
 	http.HandleFunc("/report", reportHandler)
-	http.HandleFunc("/searchfen", searchFentHandler)
+	http.HandleFunc("/searchfen", heavy(databaseOnly("Searches for FEN", searchFentHandler)))
+	http.HandleFunc("/import/pgn", databaseOnly("Imports", importPGNHandler))
+	http.HandleFunc("/export", heavy(exportHandler))
+	http.HandleFunc("/export/tree", heavy(treeHandler))
+	http.HandleFunc("/anki", heavy(ankiHandler))
+	http.HandleFunc("/study", heavy(studyHandler))
+	http.HandleFunc("/feed.atom", feedHandler)
+	http.HandleFunc("/opponents", heavy(opponentsHandler))
+	http.HandleFunc("/opponents/model", opponentModelHandler)
+	http.HandleFunc("/book", bookHandler)
+	http.HandleFunc("/train/guess", trainGuessHandler)
+	http.HandleFunc("/report/notable", heavy(notableHandler))
+	http.HandleFunc("/report/sunburst", heavy(sunburstHandler))
+	http.HandleFunc("/report/accuracy", heavy(accuracyHandler))
+	http.HandleFunc("/report/conversion", heavy(conversionHandler))
+	http.HandleFunc("/report/clock-blunders", heavy(clockBlundersHandler))
+	http.HandleFunc("/report/coverage", heavy(coverageHandler))
+	http.HandleFunc("/report/diff", heavy(diffHandler))
+	http.HandleFunc("/stats/line", heavy(lineTrendHandler))
+	http.HandleFunc("/reports/precomputed", databaseOnly("Precomputed reports", precomputedHandler))
 
+	http.HandleFunc("/login", loginHandler)
+	http.HandleFunc("/oauth/callback", oauthCallbackHandler)
+	http.HandleFunc("/logout", logoutHandler)
+	http.HandleFunc("/me", meHandler)
+
+	if readOnly() {
+		log.Info("Read-only mode: the requests changing the database are refused")
+	}
 	port := viper.GetInt("server-port")
 	if port == 0 {
-		log.Fatal("server-port does not have a valid integer value")
+		return errors.New("server-port does not have a valid integer value")
 	}
 	log.Println("Server is listening on port " + strconv.Itoa(port))
 
@@ -34,7 +71,32 @@ func Start() {
 	if browser {
 		openbrowser("http://localhost:" + strconv.Itoa(port))
 	}
-	http.ListenAndServe(":"+strconv.Itoa(port), nil)
+	return http.ListenAndServe(":"+strconv.Itoa(port), guardReadOnly(isolate(http.DefaultServeMux)))
+}
+
+// writeError ... the UI shows the error field of the response (the status stays 200 as the UI expects)
+func writeError(w http.ResponseWriter, err error) {
+	log.Error(err)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{Error: err.Error()})
+}
+
+// badRequest ... 400 with the invalid values of {filter} and the other parameters of the request ({more}),
+// false when they are valid
+func badRequest(w http.ResponseWriter, filter *GameFilter, more ...FieldError) bool {
+	errs, _ := filter.invalid.(ValidationError)
+	errs = append(errs, more...)
+	if len(errs) == 0 {
+		return false
+	}
+	log.Warn(errs)
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(struct {
+		Error  string          `json:"error"`
+		Errors ValidationError `json:"errors"` // field by field
+	}{Error: errs.Error(), Errors: errs})
+	return true
 }
 
 func openbrowser(url string) {
@@ -51,11 +113,11 @@ func openbrowser(url string) {
 		err = fmt.Errorf("unsupported platform")
 	}
 	if err != nil {
-		log.Fatal(err)
+		log.Warn("Cannot start a browser: " + err.Error())
 	}
 }
 
+// timeTrack ... request durations (only shown with --verbose)
 func timeTrack(start time.Time, name string) {
-	//elapsed := time.Since(start)
-	//log.Printf("%s took %s", name, elapsed)
+	log.Debugf("%s took %s", name, time.Since(start))
 }
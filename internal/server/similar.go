@@ -0,0 +1,242 @@
+package server
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+)
+
+/*
+Similar games: the games sharing the longest move prefix with a game, or the most positions (transpositions included),
+to find the model games which went on further in the same line.
+  GET /games/similar?gameId={id}&by=prefix&limit=20
+  GET /games/similar?gameId={id}&by=positions&minelo=2400&reference=true
+The other values of the filter (players, elo, dates...) restrict the similar games; reference=true searches the reference
+games (pgntodb --reference). Games imported before the position hashes were stored are not found (see reindex).
+*/
+
+// similarFrom ... the positions before this ply are in too many games to find similar games
+const similarFrom = 8
+
+// Orders of the similar games
+const (
+	SimilarByPrefix    = "prefix"    // longest shared move prefix first, then the longest games
+	SimilarByPositions = "positions" // most shared positions first
+)
+
+// SimilarGame ... a game similar to another
+type SimilarGame struct {
+	ID              string    `json:"id"`
+	White           string    `json:"white"`
+	Black           string    `json:"black"`
+	WhiteElo        int       `json:"whiteElo,omitempty"`
+	BlackElo        int       `json:"blackElo,omitempty"`
+	Result          string    `json:"result"`
+	DateTime        time.Time `json:"datetime"`
+	Link            string    `json:"link,omitempty"`
+	Plies           int       `json:"plies"`
+	SharedPlies     int       `json:"sharedPlies"`     // moves played in the same order from the start
+	SharedPositions int       `json:"sharedPositions"` // positions of both games, whatever the move order
+	Continuation    string    `json:"continuation"`    // next moves after the shared plies (SAN, 10 plies at most)
+}
+
+func similarHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "similarHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type similarResponse struct {
+		Error string        `json:"error"`
+		Data  []SimilarGame `json:"data"`
+	}
+
+	r.ParseForm()
+	var errs ValidationError
+	gameID := strings.TrimSpace(r.Form.Get("gameId"))
+	if gameID == "" {
+		errs.add("gameId", "gameId is missing: the game to find similar games of")
+	}
+	by := strings.TrimSpace(r.Form.Get("by"))
+	switch by {
+	case "":
+		by = SimilarByPrefix
+	case SimilarByPrefix, SimilarByPositions:
+	default:
+		errs.add("by", "invalid by %q: %s or %s", by, SimilarByPrefix, SimilarByPositions)
+	}
+	limit := errs.intParam(r.Form, "limit", 20, 1, 500)
+	reference := r.Form.Get("reference") == "true"
+	for _, name := range []string{"gameId", "by", "limit", "reference", "pgn", "fen"} {
+		r.Form.Del(name)
+	}
+	if reference {
+		r.Form.Del(ownerParam) // the reference games have no owner
+	}
+	filter := NewGameFilter(r.Form)
+	if len(errs) > 0 {
+		badRequest(w, filter, errs...)
+		return
+	}
+	if badRequest(w, filter) {
+		return
+	}
+
+	// Connect to DB
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	store, err := openStore(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer store.close(ctx)
+
+	game, err := store.game(ctx, requestGamesOwner(r), gameID, nil)
+	if errors.Is(err, errGameNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		err = errors.New("Game not found: " + gameID)
+	}
+	ranking := newSimilarRanking(&game, by, limit)
+	if err == nil {
+		err = store.eachSimilarCandidate(ctx, &game, filter, reference, func(candidate *pgntodb.Game) error {
+			ranking.add(candidate)
+			return nil
+		})
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(similarResponse{Data: ranking.games()})
+}
+
+// SimilarGames ... the {limit} games of {candidates} most similar to {game}, ordered {by} (SimilarByPrefix or
+// SimilarByPositions); candidates without a shared position are left out
+func SimilarGames(game *pgntodb.Game, candidates []pgntodb.Game, by string, limit int) []SimilarGame {
+	ranking := newSimilarRanking(game, by, limit)
+	for i := range candidates {
+		ranking.add(&candidates[i])
+	}
+	return ranking.games()
+}
+
+// similarRanking ... the {limit} most similar games of the candidates added so far, in a heap with the least similar
+// on top: the candidates are streamed, only {limit} of them are kept
+type similarRanking struct {
+	moves     []string
+	positions map[int64]bool
+	id        string
+	by        string
+	limit     int
+	added     int // arrival order of the candidates, the first one wins a tie
+	heap      []rankedGame
+}
+
+type rankedGame struct {
+	SimilarGame
+	order int
+}
+
+func newSimilarRanking(game *pgntodb.Game, by string, limit int) *similarRanking {
+	positions := map[int64]bool{}
+	for _, hash := range game.Hashes {
+		positions[hash] = true
+	}
+	return &similarRanking{moves: pgn.Moves(game.PGN), positions: positions, id: game.ID, by: by, limit: limit}
+}
+
+// add ... rank {candidate}, dropping the least similar game when there are more than {limit}
+func (ranking *similarRanking) add(candidate *pgntodb.Game) {
+	if candidate.ID == ranking.id || ranking.limit <= 0 {
+		return
+	}
+	shared := 0
+	for _, hash := range candidate.Hashes {
+		if ranking.positions[hash] {
+			shared++
+		}
+	}
+	if shared == 0 {
+		return
+	}
+	candidateMoves := pgn.Moves(candidate.PGN)
+	prefix := 0
+	for prefix < len(ranking.moves) && prefix < len(candidateMoves) && ranking.moves[prefix] == candidateMoves[prefix] {
+		prefix++
+	}
+	continuation := candidateMoves[prefix:]
+	if len(continuation) > 10 {
+		continuation = continuation[:10]
+	}
+	ranked := rankedGame{order: ranking.added, SimilarGame: SimilarGame{
+		ID: candidate.ID, White: candidate.White, Black: candidate.Black,
+		WhiteElo: candidate.WhiteRating(), BlackElo: candidate.BlackRating(),
+		Result: candidate.Result, DateTime: candidate.DateTime, Link: candidate.Link,
+		Plies: len(candidateMoves), SharedPlies: prefix, SharedPositions: shared,
+		Continuation: strings.Join(continuation, " "),
+	}}
+	ranking.added++
+
+	if len(ranking.heap) < ranking.limit {
+		heap.Push(ranking, ranked)
+	} else if ranking.before(ranked, ranking.heap[0]) {
+		ranking.heap[0] = ranked
+		heap.Fix(ranking, 0)
+	}
+}
+
+// games ... the ranked games, the most similar first
+func (ranking *similarRanking) games() []SimilarGame {
+	ranked := append([]rankedGame(nil), ranking.heap...)
+	sort.Slice(ranked, func(i, j int) bool { return ranking.before(ranked[i], ranked[j]) })
+	similar := make([]SimilarGame, len(ranked))
+	for i := range ranked {
+		similar[i] = ranked[i].SimilarGame
+	}
+	return similar
+}
+
+// before ... {a} is more similar than {b}
+func (ranking *similarRanking) before(a, b rankedGame) bool {
+	if ranking.by == SimilarByPositions && a.SharedPositions != b.SharedPositions {
+		return a.SharedPositions > b.SharedPositions
+	}
+	if a.SharedPlies != b.SharedPlies {
+		return a.SharedPlies > b.SharedPlies
+	}
+	if a.Plies-a.SharedPlies != b.Plies-b.SharedPlies {
+		return a.Plies-a.SharedPlies > b.Plies-b.SharedPlies // went on further
+	}
+	if a.SharedPositions != b.SharedPositions {
+		return a.SharedPositions > b.SharedPositions
+	}
+	return a.order < b.order
+}
+
+// heap.Interface, the least similar game on top
+
+func (ranking *similarRanking) Len() int { return len(ranking.heap) }
+func (ranking *similarRanking) Less(i, j int) bool {
+	return ranking.before(ranking.heap[j], ranking.heap[i])
+}
+func (ranking *similarRanking) Swap(i, j int) {
+	ranking.heap[i], ranking.heap[j] = ranking.heap[j], ranking.heap[i]
+}
+func (ranking *similarRanking) Push(x interface{}) {
+	ranking.heap = append(ranking.heap, x.(rankedGame))
+}
+func (ranking *similarRanking) Pop() interface{} {
+	last := ranking.heap[len(ranking.heap)-1]
+	ranking.heap = ranking.heap[:len(ranking.heap)-1]
+	return last
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+)
+
+func TestSimilarGamesKeepsTheMostSimilar(t *testing.T) {
+	game := pgntodb.Game{ID: "game", PGN: "1. e4 e5 2. Nf3 Nc6 3. Bb5 a6", Hashes: []int64{1, 2, 3, 4, 5, 6}}
+	candidates := []pgntodb.Game{
+		{ID: "game", PGN: game.PGN, Hashes: game.Hashes},                                // the game itself
+		{ID: "none", PGN: "1. d4 d5", Hashes: []int64{7, 8}},                            // no shared position
+		{ID: "short", PGN: "1. e4 e5 2. Nf3 Nc6", Hashes: []int64{1, 2, 3, 4}},          // 4 shared plies
+		{ID: "long", PGN: "1. e4 e5 2. Nf3 Nc6 3. Bc4", Hashes: []int64{1, 2, 3, 4, 9}}, // went on further
+		{ID: "one", PGN: "1. e4 c5", Hashes: []int64{1, 10}},
+		{ID: "transposed", PGN: "1. Nf3 Nc6 2. e4 e5 3. Bb5 a6", Hashes: []int64{11, 2, 3, 4, 5, 6}},
+	}
+
+	tests := []struct {
+		by    string
+		limit int
+		want  string
+	}{
+		{SimilarByPrefix, 10, "[long short one transposed]"},
+		{SimilarByPrefix, 2, "[long short]"},
+		{SimilarByPositions, 10, "[transposed long short one]"},
+		{SimilarByPositions, 1, "[transposed]"},
+		{SimilarByPrefix, 0, "[]"},
+	}
+	for _, test := range tests {
+		var ids []string
+		for _, similar := range SimilarGames(&game, candidates, test.by, test.limit) {
+			ids = append(ids, similar.ID)
+		}
+		if got := fmt.Sprint(ids); got != test.want {
+			t.Errorf("SimilarGames by %s, limit %d = %s, want %s", test.by, test.limit, got, test.want)
+		}
+	}
+}
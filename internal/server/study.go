@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/lichess"
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+)
+
+// StudyChapter ... a chapter of a lichess.org study
+type StudyChapter struct {
+	Name string
+	PGN  string
+}
+
+// StudyChapters ... the line of {filter} with its {alternatives} most played next moves as variations,
+// then the {samples} most recent games of the line
+func StudyChapters(filter *GameFilter, alternatives int, samples int) ([]StudyChapter, error) {
+	nextmoves, err := Explore(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	moves := make([]NextMove, 0, len(nextmoves))
+	for _, nextmove := range nextmoves {
+		if nextmove.Move != "End" {
+			moves = append(moves, nextmove)
+		}
+	}
+	sort.SliceStable(moves, func(i, j int) bool {
+		return moves[i].Total > moves[j].Total
+	})
+	if len(moves) > alternatives+1 {
+		moves = moves[:alternatives+1]
+	}
+
+	name := "Start position"
+	if filter.pgn != "" {
+		name = filter.pgn
+	}
+	chapters := []StudyChapter{{Name: name, PGN: linePgn(filter.pgnMoves, moves)}}
+
+	recent := make([]pgntodb.Game, 0, samples)
+	if samples > 0 {
+		if _, err = EachGame(context.Background(), filter, func(game *pgntodb.Game) error {
+			// oldest first: keep the last ones
+			if len(recent) == samples {
+				recent = recent[1:]
+			}
+			recent = append(recent, *game)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	for i := len(recent) - 1; i >= 0; i-- {
+		var builder strings.Builder
+		if err = pgn.Write(&builder, gameToPgn(&recent[i])); err != nil {
+			return nil, err
+		}
+		chapters = append(chapters, StudyChapter{
+			Name: fmt.Sprintf("%s - %s, %s", recent[i].White, recent[i].Black, recent[i].DateTime.Format("2006-01-02")),
+			PGN:  builder.String(),
+		})
+	}
+	return chapters, nil
+}
+
+// ExportToStudy ... add the chapters of StudyChapters to the lichess.org {study} (URL or id), number of chapters
+func ExportToStudy(token string, study string, filter *GameFilter, alternatives int, samples int, orientation string) (int, error) {
+	studyID, err := lichess.StudyID(study)
+	if err != nil {
+		return 0, err
+	}
+	if orientation == "" {
+		orientation = "white"
+		if filter.black != "" && filter.white == "" {
+			orientation = "black"
+		}
+	}
+	chapters, err := StudyChapters(filter, alternatives, samples)
+	if err != nil {
+		return 0, err
+	}
+	for i, chapter := range chapters {
+		if err = lichess.ImportChapter(token, studyID, chapter.Name, chapter.PGN, orientation); err != nil {
+			return i, err
+		}
+	}
+	return len(chapters), nil
+}
+
+// linePgn ... {line} then the most played next move, the others as variations, with their results as comments
+func linePgn(line []string, nextmoves []NextMove) string {
+	var builder strings.Builder
+	builder.WriteString("[Event \"Chess explorer\"]\n[Result \"*\"]\n\n")
+	for i, move := range line {
+		if i%2 == 0 {
+			builder.WriteString(pgn.MoveNumber(i+1) + " ")
+		}
+		builder.WriteString(move + " ")
+	}
+	ply := len(line) + 1
+	for i, nextmove := range nextmoves {
+		if i == 1 {
+			builder.WriteString("(")
+		} else if i > 1 {
+			builder.WriteString(" (")
+		}
+		if i > 0 || ply%2 == 1 {
+			builder.WriteString(pgn.MoveNumber(ply) + " ")
+		}
+		builder.WriteString(nextmove.Move + " { " + resultsComment(nextmove) + " }")
+		if i > 0 {
+			builder.WriteString(")")
+		}
+		if i == 0 && len(nextmoves) > 1 {
+			builder.WriteString(" ")
+		}
+	}
+	builder.WriteString(" *\n")
+	return builder.String()
+}
+
+// resultsComment ... 120 games: white 45%, draws 10%, black 45%
+func resultsComment(nextmove NextMove) string {
+	total := float64(nextmove.Total)
+	if total == 0 {
+		return "no games"
+	}
+	return fmt.Sprintf("%d games: white %.0f%%, draws %.0f%%, black %.0f%%", nextmove.Total,
+		100*float64(nextmove.White)/total, 100*float64(nextmove.Draw)/total, 100*float64(nextmove.Black)/total)
+}
+
+// Explore ... next moves of {filter} in the games of {ctx} (see openStore)
+func Explore(ctx context.Context, filter *GameFilter) ([]NextMove, error) {
+	if filter.invalid != nil {
+		return nil, filter.invalid
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	store, err := openStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer store.close(ctx)
+	nextmoves, _, err := store.nextMoves(ctx, filter, nil)
+	return nextmoves, err
+}
+
+func studyHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "studyHandler")
+
+	type studyResult struct {
+		Chapters int    `json:"chapters"`
+		URL      string `json:"url"`
+	}
+	type studyResponse struct {
+		Error string      `json:"error"`
+		Data  studyResult `json:"data"`
+	}
+
+	if r.Method != "POST" {
+		writeError(w, errors.New("Only POST is supported"))
+		return
+	}
+	token := loggedInToken(r)
+	if token == "" {
+		writeError(w, errors.New("Log in with lichess to create study chapters"))
+		return
+	}
+
+	filter := gameFilterFromRequest(r)
+	var errs ValidationError
+	alternatives := errs.intParam(r.Form, "alternatives", 3, 0, 20)
+	samples := errs.intParam(r.Form, "samples", 5, 0, 50)
+	color := errs.colorParam(r.Form, "color")
+	study := strings.TrimSpace(r.FormValue("study"))
+	if _, err := lichess.StudyID(study); err != nil {
+		errs.add("study", "%s", err.Error())
+	}
+	if badRequest(w, filter, errs...) {
+		return
+	}
+	count, err := ExportToStudy(token, study, filter, alternatives, samples, color)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	studyID, _ := lichess.StudyID(study)
+	json.NewEncoder(w).Encode(studyResponse{Data: studyResult{Chapters: count, URL: "https://lichess.org/study/" + studyID}})
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// OpeningSlice ... a node of the opening distribution: the root (all games), an opening family (Sicilian Defense),
+// a variation (Najdorf Variation) or a sub-line (English Attack), as the ids, labels, parents and values of a sunburst
+// or a treemap (Plotly, Google Charts, ECharts, d3.stratify)
+type OpeningSlice struct {
+	ID     string `json:"id"`     // path of the node: "Sicilian Defense/Najdorf Variation"
+	Parent string `json:"parent"` // "" for the root
+	Label  string `json:"label"`
+	ECO    string `json:"eco,omitempty"` // first ECO code of the games of the node
+	Value  int    `json:"value"`         // games, those of the children included
+}
+
+// sunburstRoot ... id of the root node
+const sunburstRoot = "All games"
+
+func sunburstHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "sunburstHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type sunburstResponse struct {
+		Error string         `json:"error"`
+		Data  []OpeningSlice `json:"data"`
+	}
+
+	filter := gameFilterFromRequest(r)
+	if badRequest(w, filter) {
+		return
+	}
+
+	// Connect to DB
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	store, err := openStore(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer store.close(ctx)
+
+	slices, err := store.openings(ctx, filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sunburstResponse{Data: slices})
+}
+
+// OpeningDistribution ... games of {filter} by opening family, variation and sub-line, parents first and then most played first
+// (games imported without an opening name are "Unknown")
+func OpeningDistribution(ctx context.Context, games *mongo.Collection, filter *GameFilter) ([]OpeningSlice, error) {
+	if filter.invalid != nil {
+		return nil, filter.invalid
+	}
+	filter.mongoAggregation = false
+	pipeline := []bson.M{
+		{"$match": bsonFromGameFilter(filter)},
+		{"$group": bson.M{
+			"_id":   "$opening",
+			"eco":   bson.M{"$first": "$eco"},
+			"games": bson.M{"$sum": 1},
+		}},
+	}
+	cursor, err := games.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Opening string `bson:"_id"`
+		ECO     string `bson:"eco"`
+		Games   int    `bson:"games"`
+	}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	ecos := map[string]string{}
+	for _, result := range results {
+		counts[result.Opening] += result.Games
+		if ecos[result.Opening] == "" {
+			ecos[result.Opening] = result.ECO
+		}
+	}
+	return openingSlices(counts, ecos), nil
+}
+
+// openingSlices ... nodes of the games counted by opening name in {counts} ("Sicilian Defense: Najdorf Variation, English Attack"),
+// {ecos}: ECO code of the openings
+func openingSlices(counts map[string]int, ecos map[string]string) []OpeningSlice {
+	root := OpeningSlice{ID: sunburstRoot, Label: sunburstRoot}
+	nodes := map[string]*OpeningSlice{}
+	for opening, games := range counts {
+		root.Value += games
+		parent := sunburstRoot
+		for i, label := range openingLevels(opening) {
+			id := label
+			if i > 0 {
+				id = parent + "/" + label
+			}
+			node, ok := nodes[id]
+			if !ok {
+				node = &OpeningSlice{ID: id, Parent: parent, Label: label, ECO: ecos[opening]}
+				nodes[id] = node
+			}
+			node.Value += games
+			parent = id
+		}
+	}
+
+	slices := make([]OpeningSlice, 0, len(nodes)+1)
+	for _, node := range nodes {
+		slices = append(slices, *node)
+	}
+	sort.Slice(slices, func(i, j int) bool {
+		depthI, depthJ := strings.Count(slices[i].ID, "/"), strings.Count(slices[j].ID, "/")
+		if depthI != depthJ {
+			return depthI < depthJ
+		}
+		if slices[i].Value != slices[j].Value {
+			return slices[i].Value > slices[j].Value
+		}
+		return slices[i].ID < slices[j].ID
+	})
+	return append([]OpeningSlice{root}, slices...)
+}
+
+// openingLevels ... family, variation and sub-line of an opening name: "Sicilian Defense: Najdorf Variation, English Attack"
+func openingLevels(opening string) []string {
+	opening = strings.TrimSpace(opening)
+	if opening == "" {
+		return []string{"Unknown"}
+	}
+	levels := make([]string, 0, 3)
+	parts := strings.SplitN(opening, ":", 2)
+	levels = append(levels, strings.TrimSpace(parts[0]))
+	if len(parts) == 2 {
+		variation := strings.SplitN(parts[1], ",", 2)
+		if label := strings.TrimSpace(variation[0]); label != "" {
+			levels = append(levels, label)
+		}
+		if len(variation) == 2 && len(levels) == 2 {
+			if label := strings.TrimSpace(variation[1]); label != "" {
+				levels = append(levels, label)
+			}
+		}
+	}
+	return levels
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxTagLength ... characters of a tag at most
+const maxTagLength = 50
+
+// TagCount ... a tag and its games
+type TagCount struct {
+	Tag   string `json:"tag" bson:"_id"`
+	Games int    `json:"games" bson:"games"`
+}
+
+// tagsHandler ... GET /games/tags: the tags and their games;
+// POST /games/tags?id={game id}&add={tag}&remove={tag}: tag the games (id repeated, tags comma separated)
+func tagsHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "tagsHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type tagsResponse struct {
+		Error string     `json:"error"`
+		Data  []TagCount `json:"data"`
+	}
+	type tagGamesResponse struct {
+		Error string `json:"error"`
+		Data  struct {
+			Matched  int64 `json:"matched"`  // games found
+			Modified int64 `json:"modified"` // games whose tags changed
+		} `json:"data"`
+	}
+
+	r.ParseForm()
+	if r.Method != http.MethodPost {
+		// Connect to DB
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		store, err := openStore(ctx)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		defer store.close(ctx)
+
+		tags, err := store.tags(ctx, requestGamesOwner(r))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tagsResponse{Data: tags})
+		return
+	}
+
+	var errs ValidationError
+	ids := r.Form["id"]
+	if len(ids) == 0 {
+		errs.add("id", "id is missing: the game(s) to tag")
+	} else if len(ids) > maxGamesByIDs {
+		errs.add("id", "%d games at most", maxGamesByIDs)
+	}
+	add := errs.tagsParam(r.Form.Get("add"), "add")
+	remove := errs.tagsParam(r.Form.Get("remove"), "remove")
+	if len(add) == 0 && len(remove) == 0 {
+		errs.add("add", "add or remove is missing: the tag(s) of the games")
+	}
+	if badRequest(w, &GameFilter{}, errs...) {
+		return
+	}
+
+	// Connect to DB
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	store, err := openStore(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer store.close(ctx)
+
+	var response tagGamesResponse
+	response.Data.Matched, response.Data.Modified, err = store.tagGames(ctx, requestGamesOwner(r), ids, add, remove)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// TagGames ... add the tags {add} to the games {ids} of {owner} ("": any) and remove the tags {remove}: games found and games changed
+func TagGames(ctx context.Context, games *mongo.Collection, owner string, ids []string, add []string, remove []string) (int64, int64, error) {
+	filter := pgntodb.OwnerQuery(bson.M{"_id": bson.M{"$in": ids}}, owner)
+	var matched, modified int64
+	// one update per operator: a field cannot be the target of $addToSet and $pull at once
+	if len(add) > 0 {
+		result, err := games.UpdateMany(ctx, filter, bson.M{"$addToSet": bson.M{"tags": bson.M{"$each": add}}})
+		if err != nil {
+			return 0, 0, err
+		}
+		matched, modified = result.MatchedCount, result.ModifiedCount
+	}
+	if len(remove) > 0 {
+		result, err := games.UpdateMany(ctx, filter, bson.M{"$pull": bson.M{"tags": bson.M{"$in": remove}}})
+		if err != nil {
+			return 0, 0, err
+		}
+		matched = result.MatchedCount
+		if result.ModifiedCount > modified {
+			modified = result.ModifiedCount
+		}
+	}
+	if modified > 0 && owner == "" {
+		pgntodb.ForgetAllTrees(mongodb.ClientOf(games))
+	} else if modified > 0 {
+		pgntodb.ForgetTrees(mongodb.ClientOf(games), owner)
+	}
+	return matched, modified, nil
+}
+
+// Tags ... tags of the games of {owner} ("": all the games), most used first
+func Tags(ctx context.Context, games *mongo.Collection, owner string) ([]TagCount, error) {
+	cursor, err := games.Aggregate(ctx, []bson.M{
+		{"$match": pgntodb.OwnerQuery(bson.M{"tags": bson.M{"$exists": true}}, owner)},
+		{"$unwind": "$tags"},
+		{"$group": bson.M{"_id": "$tags", "games": bson.M{"$sum": 1}}},
+		{"$sort": bson.D{{Key: "games", Value: -1}, {Key: "_id", Value: 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tags := make([]TagCount, 0)
+	if err = cursor.All(ctx, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// tagsParam ... tags of the comma separated {value} of parameter {name} ("tournament prep, model game")
+func (errs *ValidationError) tagsParam(value string, name string) []string {
+	tags := make([]string, 0)
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if len(tag) > maxTagLength {
+			errs.add(name, "invalid tag %q: %d characters at most", tag, maxTagLength)
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
@@ -0,0 +1,287 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+Teams: a named set of players (a club, a team of a league), usable wherever a player is, as team:{name}:
+  white=team:club, black=team:club, player=team:club (reports), mixed with players (white=team:club,lichess.org:guest)
+The teams are in the config file (teams: club: [lichess.org:alice, chess.com:bob]) or in the teams collection:
+  GET    /teams                                  the teams, of the config file and of the database
+  POST   /teams?name={name}&players={players}    players comma separated (username or site:username)
+  DELETE /teams?name={name}
+A team of the config file hides the team of the database of the same name.
+With isolation, the teams of the database belong to the owner of the request (see owner.go): two owners may have a team of
+the same name.
+*/
+
+// teamPrefix ... prefix of a team where a player is expected
+const teamPrefix = "team:"
+
+// teamsRefresh ... the teams of the database are read again after this time
+const teamsRefresh = time.Minute
+
+// Team ... named set of players
+type Team struct {
+	ID      string    `json:"-" bson:"_id"`           // the name, after the owner when there is one (see teamID)
+	Name    string    `json:"name" bson:"name"`       // empty in the teams stored by older versions: the _id
+	Players []string  `json:"players" bson:"players"` // username or site:username
+	Config  bool      `json:"config" bson:"-"`        // of the config file, read-only
+	Updated time.Time `json:"updated,omitempty" bson:"updated"`
+	Owner   string    `json:"-" bson:"owner,omitempty"` // lichess.org:{username} or key:{sha256 of the API key}, none without isolation
+}
+
+// teamsCache ... teams of the database of every owner ("": no owner), by name
+var teamsCache = struct {
+	sync.Mutex
+	teams  map[string]map[string][]string
+	loaded map[string]time.Time
+}{}
+
+func teamsHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "teamsHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type teamsResponse struct {
+		Error string `json:"error"`
+		Data  []Team `json:"data"`
+	}
+
+	r.ParseForm()
+	var errs ValidationError
+	name := strings.TrimSpace(r.Form.Get("name"))
+	var players []string
+	switch r.Method {
+	case http.MethodPost, http.MethodDelete:
+		if err := checkTeamName(name); err != nil {
+			errs.add("name", "%v", err)
+		} else if _, ok := configTeams()[name]; ok {
+			errs.add("name", "team %s is defined in the config file", name)
+		}
+		if r.Method == http.MethodPost {
+			if players = teamMembers(r.Form.Get("players")); len(players) == 0 {
+				errs.add("players", "players is missing: usernames or site:usernames, comma separated")
+			}
+		}
+	}
+	if badRequest(w, &GameFilter{}, errs...) {
+		return
+	}
+
+	// Connect to DB
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	store, err := openStore(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer store.close(ctx)
+
+	owner := requestGamesOwner(r)
+	response := teamsResponse{Data: []Team{}}
+	switch r.Method {
+	case http.MethodPost:
+		team := Team{Name: name, Players: players, Owner: owner}
+		if team, err = store.saveTeam(ctx, team); err == nil {
+			response.Data = append(response.Data, team)
+		}
+	case http.MethodDelete:
+		err = store.deleteTeam(ctx, owner, name)
+	default:
+		response.Data, err = store.teams(ctx, owner)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Teams ... the teams of the config file and of the database of {owner} ("": the teams without owner), by name
+func Teams(ctx context.Context, teams *mongo.Collection, owner string) ([]Team, error) {
+	cursor, err := teams.Find(ctx, bson.M{"owner": pgntodb.OwnerValue(owner)})
+	if err != nil {
+		return nil, err
+	}
+	var stored []Team
+	if err = cursor.All(ctx, &stored); err != nil {
+		return nil, err
+	}
+	for i := range stored {
+		if stored[i].Name == "" {
+			stored[i].Name = stored[i].ID
+		}
+	}
+	return mergeTeams(stored), nil
+}
+
+// SaveTeam ... create or replace {team} of its owner in the database
+func SaveTeam(ctx context.Context, teams *mongo.Collection, team Team) (Team, error) {
+	if err := checkTeamName(team.Name); err != nil {
+		return team, err
+	}
+	if _, ok := configTeams()[team.Name]; ok {
+		return team, fmt.Errorf("team %s is defined in the config file", team.Name)
+	}
+	team.ID = teamID(team.Owner, team.Name)
+	team.Updated = time.Now().UTC()
+	_, err := teams.ReplaceOne(ctx, bson.M{"_id": team.ID}, team, options.Replace().SetUpsert(true))
+	forgetTeams()
+	return team, err
+}
+
+// DeleteTeam ... remove the team {name} of {owner} from the database
+func DeleteTeam(ctx context.Context, teams *mongo.Collection, owner string, name string) error {
+	result, err := teams.DeleteOne(ctx, bson.M{"_id": teamID(owner, name)})
+	forgetTeams()
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("Unknown team: " + name)
+	}
+	return nil
+}
+
+// teamID ... _id of the team {name} of {owner}: the name alone without owner, as stored by older versions
+func teamID(owner string, name string) string {
+	if owner == "" {
+		return name
+	}
+	return owner + "/" + name
+}
+
+// checkTeamName ... a team name is a word (letters, digits, - and _), as it is written in a comma separated list of players
+func checkTeamName(name string) error {
+	if name == "" {
+		return errors.New("name is missing: the name of the team")
+	}
+	if len(name) > 50 || strings.ContainsAny(name, ",: \t&=") {
+		return fmt.Errorf("invalid team name %q: 50 characters at most, without spaces, commas or colons", name)
+	}
+	return nil
+}
+
+// teamMembers ... players of a comma separated list, without blanks and duplicates
+func teamMembers(list string) []string {
+	players := []string{}
+	seen := map[string]bool{}
+	for _, player := range strings.Split(list, ",") {
+		if player = strings.TrimSpace(player); player != "" && !seen[player] {
+			seen[player] = true
+			players = append(players, player)
+		}
+	}
+	return players
+}
+
+// configTeams ... the teams of the config file (teams:), by name
+func configTeams() map[string][]string {
+	teams := map[string][]string{}
+	for name, players := range viper.GetStringMapStringSlice("teams") {
+		teams[name] = teamMembers(strings.Join(players, ","))
+	}
+	return teams
+}
+
+// mergeTeams ... the teams of the config file and the teams {stored} in the database they do not hide, by name
+func mergeTeams(stored []Team) []Team {
+	ret := []Team{}
+	config := configTeams()
+	for name, players := range config {
+		ret = append(ret, Team{Name: name, Players: players, Config: true})
+	}
+	for _, team := range stored {
+		if _, ok := config[team.Name]; !ok {
+			ret = append(ret, team)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+// teamPlayers ... the players of the team {name} of {owner}, of the config file first (lowercase names: viper keys),
+// nil for an unknown team
+func teamPlayers(owner string, name string) []string {
+	if players, ok := configTeams()[strings.ToLower(name)]; ok {
+		return players
+	}
+	teamsCache.Lock()
+	defer teamsCache.Unlock()
+	if teamsCache.teams == nil {
+		teamsCache.teams, teamsCache.loaded = map[string]map[string][]string{}, map[string]time.Time{}
+	}
+	if _, ok := teamsCache.teams[owner]; !ok || time.Since(teamsCache.loaded[owner]) > teamsRefresh {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		teams := map[string][]string{}
+		store, err := openStore(ctx)
+		if err == nil {
+			defer store.close(ctx)
+			var stored []Team
+			stored, err = store.teams(ctx, owner)
+			for _, team := range stored {
+				teams[team.Name] = team.Players
+			}
+		}
+		if err != nil {
+			log.Warn("teams of the database not read: " + err.Error())
+		}
+		teamsCache.teams[owner], teamsCache.loaded[owner] = teams, time.Now()
+	}
+	return teamsCache.teams[owner][name]
+}
+
+// forgetTeams ... the teams of the database are read again on next use
+func forgetTeams() {
+	teamsCache.Lock()
+	defer teamsCache.Unlock()
+	teamsCache.teams = nil
+}
+
+// expandTeams ... {users} (comma separated) with the players of the teams of {owner} (team:{name}) in place of the teams;
+// an unknown team matches no game
+func expandTeams(owner string, users string) string {
+	if !strings.Contains(users, teamPrefix) {
+		return users
+	}
+	expanded := []string{}
+	for _, user := range strings.Split(users, ",") {
+		user = strings.TrimSpace(user)
+		if user == "" {
+			continue
+		}
+		if !strings.HasPrefix(user, teamPrefix) {
+			expanded = append(expanded, user)
+			continue
+		}
+		players := teamPlayers(owner, strings.TrimPrefix(user, teamPrefix))
+		if len(players) == 0 {
+			log.Warn("unknown or empty team: " + user)
+			players = []string{"\x00" + user} // no such player
+		}
+		expanded = append(expanded, players...)
+	}
+	return strings.Join(expanded, ",")
+}
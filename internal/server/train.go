@@ -0,0 +1,314 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/notnil/chess"
+)
+
+/*
+Guess-the-move trainer: /train/guess
+
+	GET  ?player={username or site:username}[&eco=B9][&color=white|black]
+	     a random position of the player's games (any player of the database: yours, a master's), a few moves into the line
+	POST move={SAN or UCI}
+	     the guess is right when it is the move of the game or the best scoring move of the player in the position
+
+Streaks are kept on the server for the browser (cookie), as long as the server runs.
+*/
+
+const trainerCookie = "chess-explorer-trainer"
+
+// first and last plies of the positions
+const minTrainPly = 6
+const maxTrainPly = 30
+
+// TrainPosition ... position to guess (the player of the game is to move)
+type TrainPosition struct {
+	FEN     string `json:"fen"`
+	Line    string `json:"line"`   // moves so far, 1. e4 c5 2. Nf3
+	ToMove  string `json:"tomove"` // white or black
+	White   string `json:"white"`
+	Black   string `json:"black"`
+	Site    string `json:"site"`
+	Date    string `json:"date"`
+	ECO     string `json:"eco,omitempty"`
+	Opening string `json:"opening,omitempty"`
+}
+
+// TrainAnswer ... checked guess
+type TrainAnswer struct {
+	Correct    bool    `json:"correct"`
+	Guess      string  `json:"guess"`            // SAN
+	Played     string  `json:"played"`           // move of the game
+	Best       string  `json:"best"`             // best scoring move of the player in the position (2 games at least), else the move of the game
+	BestScore  float64 `json:"bestscore"`        // points per game of the best move
+	BestGames  uint32  `json:"bestgames"`        // games with the best move
+	Streak     int     `json:"streak"`           // right guesses in a row
+	BestStreak int     `json:"beststreak"`       // longest streak
+	Link       string  `json:"link,omitempty"`   // the game on its site
+	Result     string  `json:"result,omitempty"` // of the game
+}
+
+type puzzle struct {
+	player   string // filter of the player's side
+	color    string
+	moves    []string // the game, SAN
+	line     string   // moves before the position, with move numbers
+	ply      int      // index of the move to guess
+	position *chess.Position
+	game     pgntodb.Game
+	owner    string // of the games (isolation)
+}
+
+type trainee struct {
+	streak     int
+	bestStreak int
+	puzzle     *puzzle
+	seen       time.Time
+}
+
+var trainees = map[string]*trainee{}
+var traineesLock sync.Mutex
+
+func trainGuessHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "trainGuessHandler")
+
+	r.ParseForm()
+	id := trainerID(w, r)
+	switch r.Method {
+	case "GET":
+		position, err := newPuzzle(id, r.Form)
+		if errs, ok := err.(ValidationError); ok {
+			badRequest(w, &GameFilter{}, errs...)
+			return
+		} else if err != nil {
+			writeError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(struct {
+			Error string        `json:"error"`
+			Data  TrainPosition `json:"data"`
+		}{Data: *position})
+	case "POST":
+		answer, err := checkGuess(id, r.Form.Get("move"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(struct {
+			Error string      `json:"error"`
+			Data  TrainAnswer `json:"data"`
+		}{Data: *answer})
+	default:
+		writeError(w, errors.New("Only GET (new position) and POST (guess) are supported"))
+	}
+}
+
+// trainerID ... id of the browser (cookie, created when missing)
+func trainerID(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(trainerCookie); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	id := hex.EncodeToString(bytes)
+	http.SetCookie(w, &http.Cookie{Name: trainerCookie, Value: id, Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	return id
+}
+
+// newPuzzle ... pick a position of the games of the player of {values} for the trainee {id}
+func newPuzzle(id string, values url.Values) (*TrainPosition, error) {
+	var errs ValidationError
+	player := strings.TrimSpace(values.Get("player"))
+	if player == "" {
+		errs.add("player", "player is missing: /train/guess?player={username}")
+	}
+	eco := strings.ToUpper(strings.TrimSpace(values.Get("eco")))
+	color := errs.colorParam(values, "color")
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	// candidate games, the player's side
+	type candidate struct {
+		game  pgntodb.Game
+		color string
+	}
+	candidates := make([]candidate, 0)
+	for _, side := range []string{"white", "black"} {
+		if color != "" && color != side {
+			continue
+		}
+		filter := NewGameFilter(url.Values{side: {player}, ownerParam: {values.Get(ownerParam)}})
+		_, err := EachGame(context.Background(), filter, func(game *pgntodb.Game) error {
+			if strings.HasPrefix(game.ECO, eco) && len(strings.Fields(game.PGN)) > minTrainPly*3/2 {
+				candidates = append(candidates, candidate{*game, side})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New("No game of " + player + " with this opening (a few moves long at least)")
+	}
+
+	picked := candidates[randomInt(len(candidates))]
+	moves := pgn.Moves(picked.game.PGN)
+	last := len(moves) - 1 // a move to guess after the position
+	if last > maxTrainPly {
+		last = maxTrainPly
+	}
+	// plies of the player: even for white
+	plies := make([]int, 0)
+	for ply := minTrainPly; ply <= last; ply++ {
+		if (ply%2 == 0) == (picked.color == "white") {
+			plies = append(plies, ply)
+		}
+	}
+	if len(plies) == 0 {
+		return nil, errors.New("The game " + picked.game.ID + " is too short")
+	}
+	ply := plies[randomInt(len(plies))]
+
+	chessGame := chess.NewGame()
+	var line strings.Builder
+	for i, move := range moves[:ply] {
+		m, err := pgn.DecodeMove(chessGame.Position(), move)
+		if err == nil {
+			err = chessGame.Move(m)
+		}
+		if err != nil {
+			return nil, &pgn.MoveError{Ply: i + 1, Move: move, FEN: chessGame.Position().String()}
+		}
+		if i%2 == 0 {
+			if i > 0 {
+				line.WriteString(" ")
+			}
+			line.WriteString(pgn.MoveNumber(i+1) + " ")
+		} else {
+			line.WriteString(" ")
+		}
+		line.WriteString(move)
+	}
+
+	traineesLock.Lock()
+	defer traineesLock.Unlock()
+	forgetTrainees()
+	t, ok := trainees[id]
+	if !ok {
+		t = &trainee{}
+		trainees[id] = t
+	}
+	t.seen = time.Now()
+	t.puzzle = &puzzle{player: player, color: picked.color, moves: moves, line: line.String(), ply: ply, position: chessGame.Position(), game: picked.game, owner: values.Get(ownerParam)}
+
+	return &TrainPosition{
+		FEN:     chessGame.Position().String(),
+		Line:    line.String(),
+		ToMove:  picked.color,
+		White:   picked.game.White,
+		Black:   picked.game.Black,
+		Site:    picked.game.Site,
+		Date:    picked.game.DateTime.Format("2006-01-02"),
+		ECO:     picked.game.ECO,
+		Opening: picked.game.Opening,
+	}, nil
+}
+
+// checkGuess ... compare {guess} with the move of the game and the best scoring move of the player, update the streak
+func checkGuess(id string, guess string) (*TrainAnswer, error) {
+	traineesLock.Lock()
+	t, ok := trainees[id]
+	if !ok || t.puzzle == nil {
+		traineesLock.Unlock()
+		return nil, errors.New("No position to guess: GET /train/guess?player={username} first")
+	}
+	p := t.puzzle
+	traineesLock.Unlock()
+
+	m, err := pgn.DecodeMove(p.position, strings.TrimSpace(guess))
+	if err != nil {
+		return nil, errors.New("Not a legal move: " + guess)
+	}
+	answer := TrainAnswer{
+		Guess:  chess.AlgebraicNotation{}.Encode(p.position, m),
+		Played: p.moves[p.ply],
+		Link:   p.game.Link,
+		Result: p.game.Result,
+	}
+
+	// best scoring move of the player in this position
+	answer.Best = answer.Played
+	nextmoves, err := Explore(context.Background(), NewGameFilter(url.Values{p.color: {p.player}, "pgn": {p.line}, ownerParam: {p.owner}}))
+	if err != nil {
+		return nil, err
+	}
+	bestScore := -1.0
+	for _, nextmove := range nextmoves {
+		if nextmove.Move == "End" || nextmove.Total < 2 {
+			continue
+		}
+		score := (float64(nextmove.White) + float64(nextmove.Draw)/2) / float64(nextmove.Total)
+		if p.color == "black" {
+			score = (float64(nextmove.Black) + float64(nextmove.Draw)/2) / float64(nextmove.Total)
+		}
+		if score > bestScore {
+			bestScore = score
+			answer.Best, answer.BestScore, answer.BestGames = nextmove.Move, score, nextmove.Total
+		}
+	}
+
+	sameMove := func(a string, b string) bool {
+		return strings.TrimRight(a, "+#") == strings.TrimRight(b, "+#")
+	}
+	answer.Correct = sameMove(answer.Guess, answer.Played) || sameMove(answer.Guess, answer.Best)
+
+	traineesLock.Lock()
+	defer traineesLock.Unlock()
+	if answer.Correct {
+		t.streak++
+		if t.streak > t.bestStreak {
+			t.bestStreak = t.streak
+		}
+	} else {
+		t.streak = 0
+	}
+	t.puzzle = nil // one guess per position
+	t.seen = time.Now()
+	answer.Streak, answer.BestStreak = t.streak, t.bestStreak
+	return &answer, nil
+}
+
+// forgetTrainees ... remove the trainees without activity for a day (traineesLock held)
+func forgetTrainees() {
+	for id, t := range trainees {
+		if time.Since(t.seen) > 24*time.Hour {
+			delete(trainees, id)
+		}
+	}
+}
+
+// randomInt ... random number in [0, n)
+func randomInt(n int) int {
+	value, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0
+	}
+	return int(value.Int64())
+}
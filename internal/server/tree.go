@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+)
+
+// TreeNode ... a move of the opening tree with the results of its games (the root is the line or the position of the filter)
+type TreeNode struct {
+	Move     string      `json:"move,omitempty"`
+	White    uint32      `json:"white"`
+	Draw     uint32      `json:"draw"`
+	Black    uint32      `json:"black"`
+	Total    uint32      `json:"total"`
+	Children []*TreeNode `json:"children,omitempty"` // most played first
+	moves    map[string]*TreeNode
+}
+
+func treeHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "treeHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type treeResponse struct {
+		Error string    `json:"error"`
+		Data  *TreeNode `json:"data"`
+	}
+
+	filter := gameFilterFromRequest(r)
+	var errs ValidationError
+	depth := errs.intParam(r.Form, "depth", 8, 1, maxTreeDepth())
+	minGames := errs.intParam(r.Form, "mingames", 1, 1, 1000000)
+	if badRequest(w, filter, errs...) {
+		return
+	}
+
+	tree, err := OpeningTree(context.Background(), filter, depth, minGames)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(treeResponse{Data: tree})
+}
+
+// OpeningTree ... moves played after the line or the position of {filter}, {depth} plies deep (max-tree-depth at most),
+// moves played in less than {minGames} games left out
+func OpeningTree(ctx context.Context, filter *GameFilter, depth int, minGames int) (*TreeNode, error) {
+	if max := maxTreeDepth(); depth > max {
+		return nil, fmt.Errorf("depth %d: %d plies at most", depth, max)
+	}
+	root := newTreeNode("")
+	if _, err := EachGame(ctx, filter, func(game *pgntodb.Game) error {
+		ply, _ := filter.nextMove(game)
+		if ply < 0 {
+			return nil
+		}
+		root.add(game.Result)
+		node := root
+		moves := pgn.Moves(game.PGN)
+		for i := ply; i < len(moves) && i < ply+depth; i++ {
+			child, ok := node.moves[moves[i]]
+			if !ok {
+				child = newTreeNode(moves[i])
+				node.moves[moves[i]] = child
+				node.Children = append(node.Children, child)
+			}
+			child.add(game.Result)
+			node = child
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	root.prune(uint32(minGames))
+	return root, nil
+}
+
+func newTreeNode(move string) *TreeNode {
+	return &TreeNode{Move: move, moves: map[string]*TreeNode{}}
+}
+
+// add ... a game ending with {result} goes through the node
+func (node *TreeNode) add(result string) {
+	switch result {
+	case "1-0":
+		node.White++
+	case "0-1":
+		node.Black++
+	default:
+		node.Draw++
+	}
+	node.Total++
+}
+
+// prune ... remove the moves of less than {minGames} games, sort the others (most played first)
+func (node *TreeNode) prune(minGames uint32) {
+	children := node.Children[:0]
+	for _, child := range node.Children {
+		if child.Total >= minGames {
+			child.prune(minGames)
+			children = append(children, child)
+		}
+	}
+	node.Children = children
+	sort.SliceStable(node.Children, func(i, j int) bool {
+		return node.Children[i].Total > node.Children[j].Total
+	})
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+Cache of the next moves (treecache collection): the nodes of the top of the tree of a player, computed after a big import
+by chess-explorer warm, are returned at once by /nextmoves instead of being aggregated on cold caches.
+A node is valid until the games of its owner change (pgntodb.ForgetTrees: imports, deletions, tags, evaluations...)
+and for tree-cache-days days (config file, default 7, 0: the cache is not used).
+*/
+
+// CachedNode ... the next moves of a line or a position for a filter, computed in advance
+type CachedNode struct {
+	ID         string    `json:"-" bson:"_id"` // hash of the key
+	Key        string    `json:"key" bson:"key"`
+	Line       string    `json:"line" bson:"line"`
+	Games      int64     `json:"games" bson:"games"`       // games of the database when computed
+	Owner      string    `json:"-" bson:"owner,omitempty"` // of the filter, none: the games of every owner
+	Truncated  bool      `json:"truncated" bson:"truncated"`
+	ComputedAt time.Time `json:"computedAt" bson:"computedat"`
+	JSON       string    `json:"-" bson:"json"` // next moves as stored
+}
+
+// WarmResult ... nodes of a tree computed by Warm
+type WarmResult struct {
+	Nodes    int      `json:"nodes"`
+	Moves    int      `json:"moves"` // next moves stored, in all the nodes
+	Depth    int      `json:"depth"` // deepest line computed (plies)
+	Games    int64    `json:"games"` // games of the database
+	Failed   []string `json:"failed,omitempty"`
+	Duration string   `json:"duration"`
+}
+
+// treeCacheDays ... days a node of the cache stays valid (tree-cache-days)
+func treeCacheDays() int {
+	if viper.IsSet("tree-cache-days") {
+		return viper.GetInt("tree-cache-days")
+	}
+	return 7
+}
+
+// cacheKey ... the filter, line or position included, with its values normalized: two requests of the same next moves
+// have the same key, whatever the move numbers of the line and the order of the values
+func (filter *GameFilter) cacheKey() string {
+	location := ""
+	if filter.location != nil {
+		location = filter.location.String()
+	}
+	values := url.Values{
+		"white":               {filter.white},
+		"black":               {filter.black},
+		"timecontrol":         {filter.timecontrol},
+		"simplifyTimecontrol": {filter.simplifyTimecontrol},
+		"from":                {filter.from},
+		"to":                  {filter.to},
+		"tz":                  {location},
+		"minelo":              {strconv.Itoa(filter.minelo)},
+		"maxelo":              {strconv.Itoa(filter.maxelo)},
+		"unknownelo":          {filter.unknownElo},
+		"site":                {filter.site},
+		"result":              {filter.result},
+		"batch":               {filter.batch},
+		"speed":               {filter.speed},
+		"tag":                 {filter.tag},
+		"owner":               {filter.owner},
+		"aggregation":         {filter.aggregation},
+		"line":                {strings.Join(filter.pgnMoves, " ")},
+		"hash":                {strconv.FormatInt(filter.hash, 10)},
+	}
+	return values.Encode()
+}
+
+// cacheID ... _id of the node of {key}
+func cacheID(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// cachedNextMoves ... the next moves of {filter} computed in advance and still valid, false when there are none
+// (errors are logged: the next moves are then computed), never with debug: the diagnostics are of the computation
+func cachedNextMoves(ctx context.Context, client *mongodb.Client, filter *GameFilter) ([]NextMove, bool, bool) {
+	days := treeCacheDays()
+	if filter.debug || days <= 0 {
+		return nil, false, false
+	}
+	var node CachedNode
+	err := mongodb.Collection(client, "treecache").FindOne(ctx, bson.M{"_id": cacheID(filter.cacheKey())}).Decode(&node)
+	if err == mongo.ErrNoDocuments {
+		return nil, false, false
+	}
+	if err != nil {
+		log.Warn("tree cache: " + err.Error())
+		return nil, false, false
+	}
+	if time.Since(node.ComputedAt) > time.Duration(days)*24*time.Hour {
+		return nil, false, false
+	}
+	var nextmoves []NextMove
+	if err := json.Unmarshal([]byte(node.JSON), &nextmoves); err != nil {
+		log.Warn("tree cache: " + err.Error())
+		return nil, false, false
+	}
+	return nextmoves, node.Truncated, true
+}
+
+// storeNextMoves ... {nextmoves} of {filter} computed on {games} games now, replace the node of the cache
+func storeNextMoves(ctx context.Context, cache *mongo.Collection, filter *GameFilter, games int64, nextmoves []NextMove, truncated bool) error {
+	content, err := json.Marshal(nextmoves)
+	if err != nil {
+		return err
+	}
+	key := filter.cacheKey()
+	line := strings.Join(filter.pgnMoves, " ")
+	if filter.fen != "" {
+		line = filter.fen
+	}
+	node := CachedNode{
+		ID:         cacheID(key),
+		Key:        key,
+		Line:       line,
+		Games:      games,
+		Owner:      filter.owner,
+		Truncated:  truncated,
+		ComputedAt: time.Now().UTC(),
+		JSON:       string(content),
+	}
+	_, err = cache.ReplaceOne(ctx, bson.M{"_id": node.ID}, node, options.Replace().SetUpsert(true))
+	return err
+}
+
+// Warm ... compute the next moves of the games of {values} (filter, pgn or fen the first line) {depth} plies deep and
+// store them in the cache, following the moves played in {minGames} games at least, {progress} after every node
+// (may be nil)
+func Warm(values url.Values, depth int, minGames int, progress func(nodes int, line string)) (*WarmResult, error) {
+	if depth < 0 || depth > maxTreeDepth() {
+		return nil, fmt.Errorf("invalid depth: %d (from 0 to %d plies)", depth, maxTreeDepth())
+	}
+	start := time.Now()
+	root := NewGameFilter(values)
+	if root.invalid != nil {
+		return nil, root.invalid
+	}
+	if root.fen != "" && depth > 0 {
+		return nil, fmt.Errorf("the position (fen) is warmed alone: depth 0, or a line (pgn)")
+	}
+	if demo != nil {
+		return nil, fmt.Errorf("the cache is not available in demo mode")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Disconnect(ctx)
+	games := mongodb.Collection(client, "games")
+	cache := mongodb.Collection(client, "treecache")
+	count, err := games.EstimatedDocumentCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := WarmResult{Games: count}
+	// breadth first: the top of the tree is cached first, whatever stops the run
+	type node struct {
+		filter *GameFilter
+		ply    int
+	}
+	queue := []node{{filter: root}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		line := strings.Join(current.filter.pgnMoves, " ")
+		nextmoves, truncated, err := nextMoves(ctx, games, current.filter, nil)
+		if err == nil {
+			err = storeNextMoves(ctx, cache, current.filter, count, nextmoves, truncated)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return &result, err
+			}
+			result.Failed = append(result.Failed, line+": "+err.Error())
+			continue
+		}
+		result.Nodes++
+		result.Moves += len(nextmoves)
+		if current.ply > result.Depth {
+			result.Depth = current.ply
+		}
+		if progress != nil {
+			progress(result.Nodes, line)
+		}
+		if current.ply >= depth {
+			continue
+		}
+		for _, nextmove := range nextmoves {
+			if nextmove.Move == "End" || int(nextmove.Total) < minGames {
+				continue
+			}
+			child := url.Values{}
+			for key, value := range values {
+				child[key] = value
+			}
+			child.Set("pgn", strings.TrimSpace(line+" "+nextmove.Move))
+			queue = append(queue, node{filter: NewGameFilter(child), ply: current.ply + 1})
+		}
+	}
+	result.Duration = time.Since(start).Round(time.Millisecond).String()
+	return &result, nil
+}
+
+// ClearTreeCache ... remove every node of the cache: nodes removed
+func ClearTreeCache() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Disconnect(ctx)
+	deleted, err := mongodb.Collection(client, "treecache").DeleteMany(ctx, bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	return deleted.DeletedCount, nil
+}
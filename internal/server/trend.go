@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+)
+
+// MonthStats ... games of a player in a line during a month, results from the player's side
+type MonthStats struct {
+	Month  string  `json:"month"` // YYYY-MM, in the time zone of the filter
+	Games  int     `json:"games"`
+	Wins   int     `json:"wins"`
+	Draws  int     `json:"draws"`
+	Losses int     `json:"losses"`
+	Score  float64 `json:"score"` // points per game (win 1, draw 0.5)
+}
+
+// LineTrend ... games of {player} (username or site:username) reaching the line or the position of {gameFilter} by month,
+// oldest first (its white and black are ignored: the player has either color)
+func LineTrend(player string, gameFilter *GameFilter) ([]MonthStats, error) {
+	location := gameFilter.location
+	if location == nil {
+		location = time.UTC
+	}
+	months := map[string]*MonthStats{}
+	for _, side := range []string{"white", "black"} {
+		filter := *gameFilter
+		filter.white, filter.black = "", ""
+		if side == "white" {
+			filter.white = player
+		} else {
+			filter.black = player
+		}
+
+		_, err := EachGame(context.Background(), &filter, func(game *pgntodb.Game) error {
+			month := game.DateTime.In(location).Format("2006-01")
+			stats, ok := months[month]
+			if !ok {
+				stats = &MonthStats{Month: month}
+				months[month] = stats
+			}
+			stats.Games++
+			switch {
+			case game.Result == "1/2-1/2":
+				stats.Draws++
+			case (game.Result == "1-0") == (side == "white"):
+				stats.Wins++
+			default:
+				stats.Losses++
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ret := make([]MonthStats, 0, len(months))
+	for _, stats := range months {
+		stats.Score = (float64(stats.Wins) + float64(stats.Draws)/2) / float64(stats.Games)
+		ret = append(ret, *stats)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Month < ret[j].Month
+	})
+	return ret, nil
+}
+
+// lineTrendHandler ... /stats/line?pgn={line}&player={username}, with the other filters of the web page
+func lineTrendHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "lineTrendHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type lineTrendResponse struct {
+		Error string       `json:"error"`
+		Data  []MonthStats `json:"data"`
+	}
+
+	r.ParseForm()
+	player := strings.TrimSpace(r.Form.Get("player"))
+	var errs ValidationError
+	if player == "" {
+		errs.add("player", "player is missing: /stats/line?pgn={line}&player={username}")
+	}
+	filter := NewGameFilter(r.Form)
+	if badRequest(w, filter, errs...) {
+		return
+	}
+	months, err := LineTrend(player, filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(lineTrendResponse{Data: months})
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/notnil/chess"
+)
+
+// FieldError ... an invalid request parameter
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError ... the invalid parameters of a request (400 Bad Request, see badRequest)
+type ValidationError []FieldError
+
+func (errs ValidationError) Error() string {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// add ... {field} is invalid
+func (errs *ValidationError) add(field string, format string, args ...interface{}) {
+	*errs = append(*errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// intParam ... integer parameter {name} of {values} between {min} and {max}, {fallback} when it is missing
+func (errs *ValidationError) intParam(values url.Values, name string, fallback int, min int, max int) int {
+	value := strings.TrimSpace(values.Get(name))
+	if value == "" {
+		return fallback
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < min || number > max {
+		errs.add(name, "invalid %s %q: an integer from %d to %d", name, value, min, max)
+		return fallback
+	}
+	return number
+}
+
+// colorParam ... white or black parameter {name} of {values}, "" when it is missing
+func (errs *ValidationError) colorParam(values url.Values, name string) string {
+	color := strings.ToLower(strings.TrimSpace(values.Get(name)))
+	switch color {
+	case "", "white", "black":
+		return color
+	}
+	errs.add(name, "invalid %s %q: white or black", name, color)
+	return ""
+}
+
+// fenParam ... position of the FEN parameter {name} of {values}, nil when it is missing or invalid
+func (errs *ValidationError) fenParam(values url.Values, name string) *chess.Position {
+	fen := strings.TrimSpace(values.Get(name))
+	if fen == "" {
+		return nil
+	}
+	option, err := chess.FEN(fen)
+	if err != nil {
+		errs.add(name, "invalid FEN %s: %v", fen, err)
+		return nil
+	}
+	return chess.NewGame(option).Position()
+}
+
+// checkLine ... error when a move of the line {moves} (e4 c5: without the move numbers) is not legal from the starting position
+func checkLine(moves []string) error {
+	position := chess.StartingPosition()
+	for ply, move := range moves {
+		m, err := pgn.DecodeMove(position, move)
+		if err != nil {
+			return fmt.Errorf("invalid pgn: %s is not a legal move (%s)", move, pgn.MoveNumber(ply+1))
+		}
+		position = position.Update(m)
+	}
+	return nil
+}
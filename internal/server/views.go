@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+Share links: a view of the explorer (line or position and filter) is stored in the views collection under a short token,
+the page opens it with /?view={token}.
+  POST /views with pgn or fen and the filter (white, black, from...)   {"data": {"token": "..."}}, the same token for the same view
+  GET  /views?token={token}                                           the view, in the filter schema of this version
+A view records the version of the filter schema it was saved with (viewVersion): upgradeView brings old views up to date.
+*/
+
+// viewVersion ... version of the filter schema of the views saved now
+const viewVersion = 1
+
+// viewTokenLength ... characters of a token (base64url of the hash of the view)
+const viewTokenLength = 11
+
+// View ... a line or a position of the explorer with its filter
+type View struct {
+	Token   string            `json:"token" bson:"_id"`
+	Version int               `json:"version" bson:"version"`
+	PGN     string            `json:"pgn,omitempty" bson:"pgn,omitempty"`
+	FEN     string            `json:"fen,omitempty" bson:"fen,omitempty"`
+	Filter  map[string]string `json:"filter,omitempty" bson:"filter,omitempty"` // filterParams
+	Created time.Time         `json:"created" bson:"created"`
+}
+
+func viewsHandler(w http.ResponseWriter, r *http.Request) {
+
+	defer timeTrack(time.Now(), "viewsHandler")
+
+	// allow cross origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	type viewResponse struct {
+		Error string `json:"error"`
+		Data  *View  `json:"data"`
+	}
+
+	r.ParseForm()
+	var view View
+	token := strings.TrimSpace(r.Form.Get("token"))
+	if r.Method == http.MethodPost {
+		filter := NewGameFilter(r.Form)
+		if badRequest(w, filter) {
+			return
+		}
+		view = View{Version: viewVersion, PGN: filter.pgn, FEN: filter.fen, Filter: filterContext(r.Form)}
+	} else if token == "" {
+		badRequest(w, &GameFilter{}, FieldError{Field: "token", Message: "token is missing: /views?token={token}"})
+		return
+	}
+
+	// Connect to DB
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer client.Disconnect(ctx)
+
+	views := mongodb.Collection(client, "views")
+	if r.Method == http.MethodPost {
+		view, err = SaveView(ctx, views, view)
+	} else {
+		view, err = FindView(ctx, views, token)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(viewResponse{Data: &view})
+}
+
+// SaveView ... store {view} under its token (the view already stored when it was shared before)
+func SaveView(ctx context.Context, views *mongo.Collection, view View) (View, error) {
+	content, err := json.Marshal(struct {
+		PGN    string            `json:"pgn"`
+		FEN    string            `json:"fen"`
+		Filter map[string]string `json:"filter"` // keys sorted by json.Marshal
+	}{view.PGN, view.FEN, view.Filter})
+	if err != nil {
+		return view, err
+	}
+	sum := sha256.Sum256(content)
+	view.Token = base64.RawURLEncoding.EncodeToString(sum[:])[:viewTokenLength]
+	view.Created = time.Now().UTC()
+
+	_, err = views.UpdateOne(ctx, bson.M{"_id": view.Token}, bson.M{"$setOnInsert": view}, options.Update().SetUpsert(true))
+	return view, err
+}
+
+// FindView ... the view of {token}, upgraded to the filter schema of this version
+func FindView(ctx context.Context, views *mongo.Collection, token string) (View, error) {
+	var view View
+	err := views.FindOne(ctx, bson.M{"_id": token}).Decode(&view)
+	if err == mongo.ErrNoDocuments {
+		return view, errors.New("Unknown share link: " + token)
+	}
+	if err != nil {
+		return view, err
+	}
+	upgradeView(&view)
+	return view, nil
+}
+
+// upgradeView ... filter of a view saved by an older version in the schema of this version:
+// the parameters the filter does not have anymore are left out (a rename maps the old name to the new one here)
+func upgradeView(view *View) {
+	filter := map[string]string{}
+	for _, param := range filterParams {
+		if value, ok := view.Filter[param]; ok {
+			filter[param] = value
+		}
+	}
+	view.Filter = filter
+	view.Version = viewVersion
+}
@@ -0,0 +1,214 @@
+package sites
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/httpclient"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/flutterbar/chess-explorer-go/internal/progress"
+	log "github.com/sirupsen/logrus"
+)
+
+// Download ... download the recent games of {username} on {site} and import them in {batch} (provenance, may be nil),
+// the PGN is also appended to {keepPgn} if set (the database and the credentials of {ctx}, see mongodb.WithSettings)
+func Download(ctx context.Context, site string, username string, keepPgn string, batch *pgntodb.Provenance) (pgntodb.Summary, error) {
+	siteClient, found := Get(site)
+	if !found {
+		return pgntodb.Summary{}, UnknownSiteError(site)
+	}
+	before := pgntodb.Totals()
+
+	client, err := HTTPClient(siteClient.Name())
+	if err != nil {
+		return pgntodb.Summary{}, err
+	}
+
+	// Get most recent game from database to avoid downloading duplicates
+	lastGame, err := pgntodb.FindLastGame(ctx, username, siteClient.Name())
+	if err != nil {
+		return pgntodb.Summary{}, err
+	}
+	lastGame.Provenance = batch
+	if lastGame.DateTime.IsZero() {
+		log.Println("New user")
+	} else {
+		log.Println("Most recent game in database: " + lastGame.GameID)
+	}
+
+	archives, err := siteClient.ListArchives(ctx, client, username, lastGame.DateTime)
+	if err != nil {
+		return pgntodb.Summary{}, err
+	}
+
+	// Create the keep file if needed
+	var keepPgnFile *os.File
+	if keepPgn != "" {
+		keepPgnFile, err = os.OpenFile(keepPgn, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+		if err != nil {
+			return pgntodb.Summary{}, err
+		}
+		defer keepPgnFile.Close()
+	}
+
+	// Download archives most recent first (several at once with the concurrent-downloads setting)
+	// Store games in database, in the order of the archives
+	// Stop on first duplicate
+	downloads := startDownloads(ctx, siteClient, client, archives, lastGame, PolitenessOf(siteClient.Name()).ConcurrentDownloads)
+	defer downloads.stop()
+	for i := range archives {
+		goOn, err := downloads.importArchive(ctx, i, lastGame, keepPgnFile)
+		if err != nil {
+			return pgntodb.Totals().Minus(before), err
+		}
+		if !goOn {
+			break
+		}
+	}
+
+	return pgntodb.Totals().Minus(before), nil
+}
+
+// downloaded ... temporary file of an archive
+type downloaded struct {
+	path string
+	url  string // provenance of the games
+	err  error
+}
+
+// downloads ... archives downloaded ahead of their import
+type downloads struct {
+	results []chan downloaded // by archive
+	slots   chan struct{}     // an archive takes a slot until it is imported
+	done    chan struct{}     // closed by stop
+	mutex   sync.Mutex
+	stopped bool
+}
+
+// startDownloads ... download {archives} in the background, {concurrent} at a time
+func startDownloads(ctx context.Context, siteClient SiteClient, client *httpclient.Client, archives []Archive, lastGame *pgntodb.LastGame, concurrent int) *downloads {
+	d := &downloads{
+		results: make([]chan downloaded, len(archives)),
+		slots:   make(chan struct{}, concurrent),
+		done:    make(chan struct{}),
+	}
+	for i := range d.results {
+		d.results[i] = make(chan downloaded, 1)
+	}
+	since := lastGame.DateTime
+	go func() {
+		for i, archive := range archives {
+			select {
+			case d.slots <- struct{}{}:
+			case <-d.done:
+				return
+			}
+			go func(i int, archive Archive) {
+				path, err := downloadArchive(ctx, siteClient, client, archive, since)
+				d.mutex.Lock()
+				defer d.mutex.Unlock()
+				if d.stopped {
+					os.Remove(path) // not imported
+					return
+				}
+				d.results[i] <- downloaded{path: path, url: archive.URL, err: err}
+			}(i, archive)
+		}
+	}()
+	return d
+}
+
+// importArchive ... import the archive of index {i} once downloaded (and append it to {keepPgnFile})
+// false when the last game of {lastGame} was found
+func (d *downloads) importArchive(ctx context.Context, i int, lastGame *pgntodb.LastGame, keepPgnFile *os.File) (bool, error) {
+	result := <-d.results[i]
+	defer func() { <-d.slots }()
+	if result.path != "" {
+		defer os.Remove(result.path) // clean up
+	}
+	if result.err != nil {
+		return false, result.err
+	}
+
+	if keepPgnFile != nil {
+		file, err := os.Open(result.path)
+		if err != nil {
+			return false, err
+		}
+		_, err = io.Copy(keepPgnFile, file)
+		file.Close()
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// parse file
+	return pgntodb.ProcessFrom(ctx, result.path, result.url, lastGame)
+}
+
+// stop ... no more downloads, downloaded archives not imported are removed
+func (d *downloads) stop() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.stopped = true
+	close(d.done)
+	for _, results := range d.results {
+		select {
+		case result := <-results:
+			os.Remove(result.path)
+		default:
+		}
+	}
+}
+
+// downloadArchive ... download {archive} to a temporary file
+func downloadArchive(ctx context.Context, siteClient SiteClient, client *httpclient.Client, archive Archive, since time.Time) (string, error) {
+
+	// Random file name
+	tmpfile, err := ioutil.TempFile("", "download")
+	if err != nil {
+		return "", err
+	}
+	defer tmpfile.Close()
+
+	resp, err := siteClient.DownloadSince(ctx, client, archive, since)
+	if err != nil {
+		return tmpfile.Name(), err
+	}
+	defer resp.Body.Close()
+
+	// stream response (size unknown when the site streams the games)
+	bar := progress.Bytes(resp.ContentLength, archive.Description)
+	buf := make([]byte, 10000)
+
+	numBytesRead := 0
+	// Read the response body
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			numBytesRead += n
+			bar.Add(n)
+
+			if _, werr := tmpfile.Write(buf[0:n]); werr != nil {
+				return tmpfile.Name(), werr
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return tmpfile.Name(), fmt.Errorf("Error reading HTTP response: %w", err)
+		}
+	}
+
+	bar.Finish()
+
+	log.Println(numBytesRead, " bytes read")
+	return tmpfile.Name(), nil
+}
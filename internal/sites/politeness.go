@@ -0,0 +1,150 @@
+package sites
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/httpclient"
+	"github.com/flutterbar/chess-explorer-go/internal/version"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+/*
+Politeness settings (config file), so the sites do not ban the IP address of the explorer:
+
+	user-agent: "chess-explorer (me@example.com)"  # contact of the sites, all requests
+	requests-per-minute: 0                          # all sites, 0 for no limit
+	concurrent-downloads: 1                         # archives downloaded at once, per site
+	politeness:                                     # per site, over the values above
+	  - site: chess.com
+	    requests-per-minute: 30
+	    concurrent-downloads: 2
+	  - site: lichess.org
+	    user-agent: "chess-explorer (lichess.org/@/me)"
+
+The limits apply to the requests of the process (downloads, synchronizations, lichess.org studies),
+retries included.
+*/
+
+// Politeness ... limits of the requests to a site
+type Politeness struct {
+	Site                string `mapstructure:"site"`
+	RequestsPerMinute   int    `mapstructure:"requests-per-minute"`  // 0 for no limit
+	ConcurrentDownloads int    `mapstructure:"concurrent-downloads"` // archives downloaded at once (at least 1)
+	UserAgent           string `mapstructure:"user-agent"`
+}
+
+// PolitenessOf ... settings of {site}: politeness entry of the site over the settings of all sites and the defaults
+func PolitenessOf(site string) Politeness {
+	politeness := Politeness{
+		Site:                site,
+		RequestsPerMinute:   viper.GetInt("requests-per-minute"),
+		ConcurrentDownloads: 1,
+		UserAgent:           defaultUserAgent(),
+	}
+	if viper.IsSet("concurrent-downloads") {
+		politeness.ConcurrentDownloads = viper.GetInt("concurrent-downloads")
+	}
+	if userAgent := viper.GetString("user-agent"); userAgent != "" {
+		politeness.UserAgent = userAgent
+	}
+
+	var perSite []Politeness
+	if err := viper.UnmarshalKey("politeness", &perSite); err != nil {
+		log.Warn("Ignoring the politeness setting: " + err.Error())
+	}
+	for _, settings := range perSite {
+		if !strings.EqualFold(settings.Site, site) {
+			continue
+		}
+		if settings.RequestsPerMinute != 0 {
+			politeness.RequestsPerMinute = settings.RequestsPerMinute
+		}
+		if settings.ConcurrentDownloads != 0 {
+			politeness.ConcurrentDownloads = settings.ConcurrentDownloads
+		}
+		if settings.UserAgent != "" {
+			politeness.UserAgent = settings.UserAgent
+		}
+	}
+
+	if politeness.RequestsPerMinute < 0 {
+		politeness.RequestsPerMinute = 0
+	}
+	if politeness.ConcurrentDownloads < 1 {
+		politeness.ConcurrentDownloads = 1
+	}
+	return politeness
+}
+
+// defaultUserAgent ... name, version and repository of the explorer
+func defaultUserAgent() string {
+	tag := version.GitTag
+	if tag == "" {
+		tag = "dev"
+	}
+	return fmt.Sprintf("chess-explorer-go/%s (+https://github.com/flutterbar/chess-explorer-go)", tag)
+}
+
+// HTTPClient ... client of the requests to {site}, with its politeness settings
+func HTTPClient(site string) (*httpclient.Client, error) {
+	client, err := httpclient.New()
+	if err != nil {
+		return nil, err
+	}
+	politeness := PolitenessOf(site)
+	return client.Polite(politeness.UserAgent, limiterOf(site, politeness.RequestsPerMinute).wait), nil
+}
+
+// limiter ... spaces the requests to a site (shared by its clients)
+type limiter struct {
+	mutex    sync.Mutex
+	interval time.Duration // 0: no limit
+	next     time.Time     // earliest time of the next request
+}
+
+var (
+	limitersMutex sync.Mutex
+	limiters      = map[string]*limiter{}
+)
+
+// limiterOf ... limiter of {site} at {requestsPerMinute} (0 for no limit)
+func limiterOf(site string, requestsPerMinute int) *limiter {
+	limitersMutex.Lock()
+	defer limitersMutex.Unlock()
+	site = strings.ToLower(site)
+	if limiters[site] == nil {
+		limiters[site] = &limiter{}
+	}
+	limiter := limiters[site]
+	limiter.mutex.Lock()
+	limiter.interval = 0
+	if requestsPerMinute > 0 {
+		limiter.interval = time.Minute / time.Duration(requestsPerMinute)
+	}
+	limiter.mutex.Unlock()
+	return limiter
+}
+
+// wait ... until the next request to the site is allowed
+func (limiter *limiter) wait() {
+	limiter.mutex.Lock()
+	now := time.Now()
+	if limiter.interval == 0 {
+		limiter.mutex.Unlock()
+		return
+	}
+	start := limiter.next
+	if start.Before(now) {
+		start = now
+	}
+	limiter.next = start.Add(limiter.interval)
+	limiter.mutex.Unlock()
+	if delay := start.Sub(now); delay > 0 {
+		log.Debug(fmt.Sprintf("Waiting %s before the next request (politeness)", delay.Round(time.Millisecond)))
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,106 @@
+package sites
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/httpclient"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+)
+
+/*
+Sites where games are downloaded from (lichess.org, chess.com...)
+
+A site implements SiteClient and registers itself in the init function of its package:
+
+	func init() {
+		sites.Register(Client{})
+	}
+
+Download does the rest for every site: most recent game of the database, archives newer
+than it, streaming with a progress bar, the --keep file and the import (see download.go).
+The packages of the sites are imported by sync, so every command knows them.
+*/
+
+// Archive ... a download of a site: a monthly archive (chess.com), all the games since a date (lichess.org)
+type Archive struct {
+	URL         string
+	Description string // progress bar
+}
+
+// SiteClient ... a site where games are downloaded from
+type SiteClient interface {
+	// Name ... site of the games (Site tag once normalized): lichess.org
+	Name() string
+	// ListArchives ... archives of {username} with games played after {since} (zero: all the games), most recent first
+	// (ErrNoDownload when the games of the site are imported from files)
+	ListArchives(ctx context.Context, client *httpclient.Client, username string, since time.Time) ([]Archive, error)
+	// DownloadSince ... response of {archive} (PGN), games played after {since} when the site can filter them
+	DownloadSince(ctx context.Context, client *httpclient.Client, archive Archive, since time.Time) (*http.Response, error)
+	// Normalize ... fix the tags of a game of the site before the import (Site, Link...)
+	Normalize(gameMap map[string]string)
+}
+
+var (
+	registryMutex sync.RWMutex
+	registry      = map[string]SiteClient{}
+)
+
+// Register ... make {client} available to the downloads and the synchronizations
+func Register(client SiteClient) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if _, found := registry[client.Name()]; found {
+		panic("sites: " + client.Name() + " registered twice")
+	}
+	registry[client.Name()] = client
+	pgntodb.AddNormalizer(client.Normalize)
+}
+
+// Get ... the client of {site}
+func Get(site string) (SiteClient, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	client, found := registry[strings.ToLower(site)]
+	return client, found
+}
+
+// Names ... registered sites, sorted
+func Names() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ErrNoDownload ... the site has no download API: its games are imported from PGN files (errors.Is)
+var ErrNoDownload = errors.New("no download from this site: import its PGN files")
+
+// UnknownSiteError ... error of a site which is not registered
+func UnknownSiteError(site string) error {
+	return fmt.Errorf("Unknown site %s (expected %s)", site, strings.Join(Names(), " or "))
+}
+
+// Fetch ... send {req}, error if the status is not 200 (the caller closes the body)
+func Fetch(client *httpclient.Client, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &url.Error{Op: "Get", URL: req.URL.String(), Err: errors.New(resp.Status)}
+	}
+	return resp, nil
+}
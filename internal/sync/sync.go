@@ -2,67 +2,177 @@ package sync
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
-	"github.com/flutterbar/chess-explorer-go/internal/chesscom"
-	"github.com/flutterbar/chess-explorer-go/internal/lichess"
+	_ "github.com/flutterbar/chess-explorer-go/internal/chesscom" // sites
+	_ "github.com/flutterbar/chess-explorer-go/internal/iccf"     // sites
+	_ "github.com/flutterbar/chess-explorer-go/internal/lichess"  // sites
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/notify"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/flutterbar/chess-explorer-go/internal/sites"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 type user struct {
 	Site     string `json:"site,omitempty"`
 	Username string `json:"username,omitempty"`
+	Owner    string `json:"owner,omitempty"` // of the games, see pgntodb.Owner
+}
+
+// Result ... games imported for a user
+type Result struct {
+	Site     string `json:"site"`
+	Username string `json:"username"`
+	pgntodb.Summary
+	Error string `json:"error,omitempty"`
 }
 
 // All ... Download recent games for all users in database
-func All() {
-	// Connect to DB
-	client, err := mongo.NewClient(options.Client().ApplyURI(viper.GetString("mongo-url")))
+// a user who cannot be synchronized does not stop the others (the first error is returned at the end)
+// (the database and the credentials of {ctx}, see mongodb.WithSettings)
+func All(ctx context.Context) ([]Result, error) {
+	users, err := trackedUsers(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	users = append(users, newUsers(users)...)
+
+	// Call the right download command in a sequence
+	batch := pgntodb.NewBatch("sync")
+	results := make([]Result, 0, len(users))
+	var firstErr error
+	failed := 0
+	for i, user := range users {
+		log.Printf("Synchronizing %s (%s) %d/%d", user.Username, user.Site, i+1, len(users))
+		if _, found := sites.Get(user.Site); !found {
+			continue
+		}
+		result := Result{Site: user.Site, Username: user.Username}
+		result.Summary, err = download(ctx, user.Site, user.Username, user.Owner, batch)
+		if errors.Is(err, sites.ErrNoDownload) {
+			log.Println(user.Username + " (" + user.Site + "): " + err.Error())
+			continue
+		}
+		if err != nil {
+			log.Error(user.Username + " (" + user.Site + "): " + err.Error())
+			result.Error = err.Error()
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		results = append(results, result)
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	notifyResults(results)
+
+	if firstErr != nil {
+		return results, fmt.Errorf("%d users not synchronized, first error: %w", failed, firstErr)
+	}
+	return results, nil
+}
+
+// trackedUsers ... users already downloaded
+func trackedUsers(ctx context.Context) ([]user, error) {
+	// Connect to DB
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
-	err = client.Connect(ctx)
+	client, err := mongodb.Connect(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer client.Disconnect(ctx)
 
-	// Ping MongoDB
-	if err = client.Ping(ctx, readpref.Primary()); err != nil {
-		log.Fatal("Cannot connect to DB " + viper.GetString("mongo-url"))
-	}
-
-	// Gather names of users whose games we must not delete
-	lastgamesCollection := client.Database(viper.GetString("mongo-db-name")).Collection("lastgames")
-	findOptions := options.Find().SetProjection(bson.M{"site": 1, "username": 1})
+	lastgamesCollection := mongodb.Collection(client, "lastgames")
+	findOptions := options.Find().SetProjection(bson.M{"site": 1, "username": 1, "owner": 1})
 	cursor, err := lastgamesCollection.Find(ctx, bson.M{}, findOptions)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	var users []user
 	if err = cursor.All(ctx, &users); err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	return users, nil
+}
 
-	// Call the right download command in a sequence
-	for _, user := range users {
-		log.Println("Synchronizing", user.Username, " (", user.Site, ")")
-		switch user.Site {
-		case "lichess.org":
-			lichess.DownloadGames(user.Username, "")
-		case "chess.com":
-			chesscom.DownloadGames(user.Username, "")
-		default:
-			// Do nothing
+// download ... recent games of {username} on {site} for {owner} in {batch}, after the running download if any
+func download(ctx context.Context, site string, username string, owner string, batch *pgntodb.Provenance) (pgntodb.Summary, error) {
+	defer pgntodb.LockImport()()
+	defer pgntodb.SetOwner(owner)()
+	return sites.Download(ctx, site, username, "", batch)
+}
+
+// User ... download recent games of {username} on {site} for {owner} ("": owner: of the config file), then the user
+// is part of the synchronizations (safe to call while a synchronization is running)
+func User(site string, username string, owner string) (Result, error) {
+	if owner == "" {
+		owner = pgntodb.Owner()
+	}
+	result := Result{Site: site, Username: username}
+	var err error
+	result.Summary, err = download(context.Background(), site, username, owner, pgntodb.NewBatch("sync "+site+":"+username))
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result, err
+}
+
+// notifyResults ... chat notification of the synchronization (if configured)
+func notifyResults(results []Result) {
+	users := make([]notify.UserSync, len(results))
+	for i, result := range results {
+		users[i] = notify.UserSync{Site: result.Site, Username: result.Username, Inserted: result.Inserted, Error: result.Error}
+	}
+	notify.Sync(users)
+}
+
+// newUsers ... users of the config file (users: [lichess.org:username, chess.com:username]) not downloaded yet
+func newUsers(known []user) []user {
+	// the users of the config file belong to owner: of the config file
+	owner := pgntodb.Owner()
+	seen := make(map[string]bool)
+	for _, user := range known {
+		if user.Owner == owner {
+			seen[user.Site+":"+strings.ToLower(user.Username)] = true
 		}
 	}
 
+	users := make([]user, 0)
+	for _, siteUser := range viper.GetStringSlice("users") {
+		parts := strings.SplitN(siteUser, ":", 2)
+		if len(parts) != 2 {
+			log.Warn("Ignoring user " + siteUser + " (expected site:username, sites: " + strings.Join(sites.Names(), ", ") + ")")
+			continue
+		}
+		key := parts[0] + ":" + strings.ToLower(parts[1])
+		if !seen[key] {
+			seen[key] = true
+			users = append(users, user{Site: parts[0], Username: parts[1], Owner: owner})
+		}
+	}
+	return users
+}
+
+// Every ... synchronize all users now and then every {interval}, forever (errors are logged)
+func Every(interval time.Duration) {
+	for {
+		results, err := All(context.Background())
+		total := 0
+		for _, result := range results {
+			total += result.Inserted
+		}
+		log.Info(fmt.Sprintf("%d games imported for %d users, next synchronization in %s", total, len(results), interval))
+		if err != nil {
+			log.Error(err)
+		}
+		time.Sleep(interval)
+	}
 }
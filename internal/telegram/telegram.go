@@ -0,0 +1,253 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/httpclient"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/notify"
+	"github.com/flutterbar/chess-explorer-go/internal/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+/*
+Telegram bot answering questions about the games of the database (long polling, no public URL needed):
+  /prep {opponent}                     openings of an opponent as white and as black
+  /stats [speed] [last30d] [player]    results by speed (players of the users setting by default)
+
+Settings:
+  telegram-token          bot token (from @BotFather)
+  telegram-chat-id        chat of the synchronization summaries, also allowed to ask questions
+  telegram-allowed-chats  other chats allowed to ask questions
+
+Other chats get their chat id as answer, to be added to the settings.
+*/
+
+const help = `/prep {opponent} - openings of an opponent (username or lichess.org:username)
+/stats [bullet|blitz|rapid|classical] [last30d] [player] - results by speed`
+
+type update struct {
+	UpdateID int `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// Run ... answer the messages sent to the bot, forever (network errors are logged, Telegram refusing the token stops the bot)
+func Run() error {
+	token := viper.GetString("telegram-token")
+	if token == "" {
+		return errors.New("The telegram-token setting is missing (create a bot with @BotFather)")
+	}
+	client, err := httpclient.New()
+	if err != nil {
+		return err
+	}
+
+	log.Info("Telegram bot is waiting for messages")
+	offset := 0
+	for {
+		updates, err := getUpdates(client, token, offset)
+		var refused *refusedError
+		if errors.As(err, &refused) {
+			return err
+		} else if err != nil {
+			log.Warn(err.Error() + ", polling again in 1m")
+			time.Sleep(time.Minute)
+			continue
+		}
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			if update.Message == nil || !strings.HasPrefix(update.Message.Text, "/") {
+				continue
+			}
+			chatID := strconv.FormatInt(update.Message.Chat.ID, 10)
+			answer := Answer(chatID, update.Message.Text)
+			if err := notify.Telegram(chatID, answer); err != nil {
+				log.Warn("Telegram answer failed: " + err.Error())
+			}
+		}
+	}
+}
+
+// getUpdates ... messages after {offset}, waiting for them up to 25 seconds
+// https://core.telegram.org/bots/api#getupdates
+func getUpdates(client *httpclient.Client, token string, offset int) ([]update, error) {
+	query := url.Values{"timeout": {"25"}, "offset": {strconv.Itoa(offset)}, "allowed_updates": {`["message"]`}}
+	resp, err := client.Get("https://api.telegram.org/bot" + token + "/getUpdates?" + query.Encode())
+	if err != nil {
+		// the token is part of the URL
+		return nil, errors.New(strings.ReplaceAll(err.Error(), token, "****"))
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		OK          bool     `json:"ok"`
+		Description string   `json:"description"`
+		Result      []update `json:"result"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	if !response.OK || resp.StatusCode != http.StatusOK {
+		return nil, &refusedError{status: resp.Status, description: response.Description}
+	}
+	return response.Result, nil
+}
+
+// refusedError ... Telegram answered with an error (wrong token, bot used elsewhere)
+type refusedError struct {
+	status      string
+	description string
+}
+
+func (err *refusedError) Error() string {
+	return "Telegram refused the request: " + err.status + " " + err.description
+}
+
+// Answer ... the answer to the command {text} sent from the chat {chatID}
+func Answer(chatID string, text string) string {
+	if !allowed(chatID) {
+		return "This chat is not allowed to ask questions. Add its id " + chatID + " to telegram-allowed-chats in the config file."
+	}
+
+	fields := strings.Fields(text)
+	// /stats@my_bot in groups
+	command := strings.SplitN(fields[0], "@", 2)[0]
+	args := fields[1:]
+
+	var answer string
+	var err error
+	switch command {
+	case "/prep":
+		if len(args) != 1 {
+			return "Usage: /prep {opponent}"
+		}
+		answer, err = prep(args[0])
+	case "/stats":
+		answer, err = stats(args)
+	case "/start", "/help":
+		return help
+	default:
+		return "Unknown command " + command + "\n" + help
+	}
+	if err != nil {
+		log.Error(err)
+		return "Error: " + err.Error()
+	}
+	return answer
+}
+
+func allowed(chatID string) bool {
+	if chatID == viper.GetString("telegram-chat-id") {
+		return true
+	}
+	for _, allowedChat := range viper.GetStringSlice("telegram-allowed-chats") {
+		if chatID == allowedChat {
+			return true
+		}
+	}
+	return false
+}
+
+// prep ... most played openings of {opponent} as white and as black
+func prep(opponent string) (string, error) {
+	var builder strings.Builder
+	err := withGames(func(ctx context.Context, games *mongo.Collection) error {
+		for _, color := range []string{"white", "black"} {
+			openings, err := server.ReportOpenings(ctx, games, opponent, color, server.NewGameFilter(url.Values{}))
+			if err != nil {
+				return err
+			}
+			total := 0
+			for _, opening := range openings {
+				total += opening.Games
+			}
+			builder.WriteString(fmt.Sprintf("%s as %s: %d games\n", opponent, color, total))
+			for i, opening := range openings {
+				if i == 5 {
+					break
+				}
+				builder.WriteString(fmt.Sprintf("  %s: %d games, score %.0f%%\n", opening.Name, opening.Games, 100*opening.Score))
+			}
+		}
+		return nil
+	})
+	return strings.TrimSpace(builder.String()), err
+}
+
+var periodPattern = regexp.MustCompile(`^(?:last)?(\d+)d$`)
+
+// stats ... results by speed of players: [speed] [lastNd] [player]
+func stats(args []string) (string, error) {
+	speed := ""
+	values := url.Values{}
+	players := make([]string, 0)
+	for _, arg := range args {
+		switch lower := strings.ToLower(arg); {
+		case lower == "bullet" || lower == "blitz" || lower == "rapid" || lower == "classical" || lower == "correspondence":
+			speed = lower
+		case periodPattern.MatchString(lower):
+			days, _ := strconv.Atoi(periodPattern.FindStringSubmatch(lower)[1])
+			values.Set("from", time.Now().AddDate(0, 0, -days).Format("2006-01-02"))
+		default:
+			players = append(players, arg)
+		}
+	}
+	if len(players) == 0 {
+		players = viper.GetStringSlice("users")
+	}
+	if len(players) == 0 {
+		return "", errors.New("No player: /stats blitz last30d {player}, or set users in the config file")
+	}
+
+	var builder strings.Builder
+	err := withGames(func(ctx context.Context, games *mongo.Collection) error {
+		for _, player := range players {
+			results, err := server.ReportResults(ctx, games, player, server.NewGameFilter(values))
+			if err != nil {
+				return err
+			}
+			found := false
+			for _, result := range results {
+				if speed != "" && result.Speed != speed {
+					continue
+				}
+				found = true
+				builder.WriteString(fmt.Sprintf("%s %s: %d games, +%d =%d -%d, score %.0f%%\n", player, result.Speed,
+					result.Games, result.Wins, result.Draws, result.Losses, 100*result.Score))
+			}
+			if !found {
+				builder.WriteString(player + ": no games\n")
+			}
+		}
+		return nil
+	})
+	return strings.TrimSpace(builder.String()), err
+}
+
+// withGames ... call {do} with the games collection
+func withGames(do func(ctx context.Context, games *mongo.Collection) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(ctx)
+	return do(ctx, mongodb.Collection(client, "games"))
+}
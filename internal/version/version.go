@@ -1,6 +1,20 @@
 package version
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+/*
+Build information is injected when building:
+
+	go build -ldflags "-X github.com/flutterbar/chess-explorer-go/internal/version.GitTag=v1.2.3
+	  -X github.com/flutterbar/chess-explorer-go/internal/version.GitCommit=$(git rev-parse HEAD)
+	  -X github.com/flutterbar/chess-explorer-go/internal/version.GitRepository=https://github.com/flutterbar/chess-explorer-go
+	  -X github.com/flutterbar/chess-explorer-go/internal/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+*/
 
 // BuildTime ... When this tool was built
 var BuildTime string
@@ -14,15 +28,47 @@ var GitTag string
 // GitCommit ... The exact level of code
 var GitCommit string
 
+// Info ... build information
+type Info struct {
+	Version    string `json:"version,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	BuildTime  string `json:"buildtime,omitempty"`
+	Commit     string `json:"commit,omitempty"`
+	GoVersion  string `json:"goversion"`
+	Platform   string `json:"platform"`
+}
+
+// Current ... build information of this executable
+func Current() Info {
+	return Info{
+		Version:    GitTag,
+		Repository: GitRepository,
+		BuildTime:  BuildTime,
+		Commit:     GitCommit,
+		GoVersion:  runtime.Version(),
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+	}
+}
+
 // DisplayVersion ... Displays build information
-func DisplayVersion() {
+func DisplayVersion(asJSON bool) {
+	info := Current()
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(info)
+		return
+	}
+
 	fmt.Println("Build information:")
 	if BuildTime == "" {
 		fmt.Println("There is no build information ... this must be a local build.")
 	} else {
-		fmt.Println("Version: ", GitTag)
-		fmt.Println("Source code:", GitRepository)
-		fmt.Println("Built on:", BuildTime)
-		fmt.Println("Git commit:", GitCommit)
+		fmt.Println("Version:    ", info.Version)
+		fmt.Println("Source code:", info.Repository)
+		fmt.Println("Built on:   ", info.BuildTime)
+		fmt.Println("Git commit: ", info.Commit)
 	}
+	fmt.Println("Go version: ", info.GoVersion)
+	fmt.Println("Platform:   ", info.Platform)
 }
@@ -0,0 +1,132 @@
+package warehouse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/flutterbar/chess-explorer-go/internal/httpclient"
+	"github.com/spf13/viper"
+)
+
+// bigQuery ... table of Google BigQuery (REST API, streaming inserts)
+type bigQuery struct {
+	project string
+	dataset string
+	table   string
+	token   string // OAuth access token (gcloud auth print-access-token)
+	client  *httpclient.Client
+}
+
+func newBigQuery(dsn *url.URL) (*bigQuery, error) {
+	path := strings.Split(strings.Trim(dsn.Path, "/"), "/")
+	if dsn.Host == "" || len(path) != 2 || path[0] == "" || path[1] == "" {
+		return nil, errors.New("Invalid BigQuery target (expected bigquery://project/dataset/table)")
+	}
+	token := viper.GetString("bigquery-token")
+	if token == "" {
+		return nil, errors.New("A BigQuery access token is needed: --token $(gcloud auth print-access-token) or bigquery-token in the config file")
+	}
+	client, err := httpclient.New()
+	if err != nil {
+		return nil, err
+	}
+	return &bigQuery{project: dsn.Host, dataset: path[0], table: path[1], token: token, client: client}, nil
+}
+
+func (target *bigQuery) batchSize() int {
+	return 500 // recommended maximum of rows per insertAll request
+}
+
+func (target *bigQuery) tablesURL() string {
+	return "https://bigquery.googleapis.com/bigquery/v2/projects/" + url.PathEscape(target.project) +
+		"/datasets/" + url.PathEscape(target.dataset) + "/tables"
+}
+
+func (target *bigQuery) createTable() error {
+	fields := make([]map[string]string, len(columns))
+	for i, column := range columns {
+		fields[i] = map[string]string{"name": column.name, "type": column.bigquery}
+	}
+	table := map[string]interface{}{
+		"tableReference":   map[string]string{"projectId": target.project, "datasetId": target.dataset, "tableId": target.table},
+		"schema":           map[string]interface{}{"fields": fields},
+		"timePartitioning": map[string]string{"type": "MONTH", "field": "datetime"},
+	}
+	status, _, err := target.post(target.tablesURL(), table)
+	if status == http.StatusConflict {
+		return nil // already exists
+	}
+	return err
+}
+
+func (target *bigQuery) insert(rows []Row) error {
+	type insertRow struct {
+		InsertID string `json:"insertId"` // best effort deduplication
+		JSON     Row    `json:"json"`
+	}
+	request := struct {
+		Rows []insertRow `json:"rows"`
+	}{Rows: make([]insertRow, len(rows))}
+	for i, row := range rows {
+		request.Rows[i] = insertRow{InsertID: row.ID, JSON: row}
+	}
+
+	_, body, err := target.post(target.tablesURL()+"/"+url.PathEscape(target.table)+"/insertAll", request)
+	if err != nil {
+		return err
+	}
+	var response struct {
+		InsertErrors []struct {
+			Index  int `json:"index"`
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		} `json:"insertErrors"`
+	}
+	if err = json.Unmarshal(body, &response); err != nil {
+		return err
+	}
+	if len(response.InsertErrors) > 0 {
+		first := response.InsertErrors[0]
+		message := ""
+		if len(first.Errors) > 0 {
+			message = first.Errors[0].Message
+		}
+		return fmt.Errorf("%d games rejected by BigQuery, first: %s (%s)", len(response.InsertErrors), rows[first.Index].ID, message)
+	}
+	return nil
+}
+
+// post ... POST {payload} as JSON, status and body of the response
+func (target *bigQuery) post(endpoint string, payload interface{}) (int, []byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+target.token)
+
+	resp, err := target.client.DoIdempotent(req) // a created table answers 409, the rows are deduplicated by their insertId
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	answer, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, answer, &url.Error{Op: "Post", URL: endpoint, Err: errors.New(resp.Status + ": " + strings.TrimSpace(string(answer)))}
+	}
+	return resp.StatusCode, answer, nil
+}
@@ -0,0 +1,107 @@
+package warehouse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/flutterbar/chess-explorer-go/internal/httpclient"
+)
+
+// clickHouse ... table of a ClickHouse server (HTTP interface)
+type clickHouse struct {
+	endpoint string // http://host:8123/
+	user     string
+	password string
+	database string
+	table    string
+	client   *httpclient.Client
+}
+
+func newClickHouse(dsn *url.URL) (*clickHouse, error) {
+	scheme, port := "http", "8123"
+	if dsn.Scheme == "clickhouses" {
+		scheme, port = "https", "8443"
+	}
+	host := dsn.Host
+	if dsn.Port() == "" {
+		host += ":" + port
+	}
+	client, err := httpclient.New()
+	if err != nil {
+		return nil, err
+	}
+
+	target := clickHouse{
+		endpoint: scheme + "://" + host + "/",
+		user:     dsn.User.Username(),
+		database: strings.Trim(dsn.Path, "/"),
+		table:    dsn.Query().Get("table"),
+		client:   client,
+	}
+	target.password, _ = dsn.User.Password()
+	if target.database == "" {
+		target.database = "default"
+	}
+	if target.table == "" {
+		target.table = "games"
+	}
+	return &target, nil
+}
+
+func (target *clickHouse) batchSize() int {
+	return 10000
+}
+
+func (target *clickHouse) createTable() error {
+	definitions := make([]string, len(columns))
+	for i, column := range columns {
+		definitions[i] = column.name + " " + column.clickhouse
+	}
+	// exporting the same games again replaces them (eventually, when parts are merged)
+	query := "CREATE TABLE IF NOT EXISTS `" + target.table + "` (" + strings.Join(definitions, ", ") + ") " +
+		"ENGINE = ReplacingMergeTree ORDER BY (site, datetime, id)"
+	return target.query(query, nil)
+}
+
+func (target *clickHouse) insert(rows []Row) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return err
+		}
+	}
+	return target.query("INSERT INTO `"+target.table+"` FORMAT JSONEachRow", body.Bytes())
+}
+
+func (target *clickHouse) query(query string, body []byte) error {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("database", target.database)
+	params.Set("date_time_input_format", "best_effort") // RFC 3339 dates of the JSON rows
+
+	req, err := http.NewRequest("POST", target.endpoint+"?"+params.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if target.user != "" {
+		req.Header.Set("X-ClickHouse-User", target.user)
+		req.Header.Set("X-ClickHouse-Key", target.password)
+	}
+
+	resp, err := target.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return &url.Error{Op: "Post", URL: target.endpoint, Err: errors.New(resp.Status + ": " + strings.TrimSpace(string(message)))}
+	}
+	return nil
+}
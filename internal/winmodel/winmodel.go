@@ -0,0 +1,258 @@
+package winmodel
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+Expected score of white in a game, logistic model by speed (bullet, blitz, rapid, classical, correspondence):
+
+	expected = 1 / (1 + exp(-(Bias + Rating * (whiteelo - blackelo) / 100 + Eval * eval in pawns)))
+
+The eval is the evaluation after the move of the explorer (0 for games without evaluations).
+Defaults: Elo formula for the ratings, lichess winning chances for the evals.
+`winmodel train` fits the coefficients on the games of the database (collection winmodel).
+The explorer reads them once (Current): again after a training, and every few minutes for the trainings of other processes.
+*/
+
+// Speeds ... time control categories of the model
+var Speeds = pgntodb.Speeds
+
+// Coefficients ... model of a speed
+type Coefficients struct {
+	Speed  string  `json:"speed" bson:"_id"`
+	Bias   float64 `json:"bias" bson:"bias"`     // first move advantage
+	Rating float64 `json:"rating" bson:"rating"` // per 100 Elo of difference
+	Eval   float64 `json:"eval" bson:"eval"`     // per pawn
+	Games  int     `json:"games" bson:"games"`   // games of the training, 0 for the defaults
+}
+
+// Model ... coefficients by speed
+type Model map[string]Coefficients
+
+// maxEval ... evaluations are clamped (pawns), as stored by pgntodb
+const maxEval = 10.0
+
+// Default ... coefficients without training
+func Default() Model {
+	model := Model{}
+	for _, speed := range Speeds {
+		// Elo: 1 / (1 + 10^(-diff/400)); lichess: 1 / (1 + exp(-0.00368208 * centipawns))
+		model[speed] = Coefficients{Speed: speed, Bias: 0.1, Rating: math.Ln10 / 4, Eval: 0.368208}
+	}
+	return model
+}
+
+// Load ... trained coefficients of the database, defaults for the speeds without training
+func Load(ctx context.Context, client *mongodb.Client) (Model, error) {
+	model := Default()
+	cursor, err := mongodb.Collection(client, "winmodel").Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var trained []Coefficients
+	if err = cursor.All(ctx, &trained); err != nil {
+		return nil, err
+	}
+	for _, coefficients := range trained {
+		model[coefficients.Speed] = coefficients
+	}
+	return model, nil
+}
+
+// currentRefresh ... the coefficients of the database are read again after this delay (winmodel train of another process)
+const currentRefresh = 10 * time.Minute
+
+// loadedModel ... the model of a database, as last read or trained
+type loadedModel struct {
+	model  Model
+	loaded time.Time
+}
+
+// current ... the models of the databases (by name: a library may use several), as last read or trained
+var current = struct {
+	sync.Mutex
+	models map[string]loadedModel
+}{models: map[string]loadedModel{}}
+
+// Current ... Load, read once for the queries of the explorer: the model of the last training of this process on the
+// database of {client}, or the one read less than currentRefresh ago
+func Current(ctx context.Context, client *mongodb.Client) (Model, error) {
+	database := client.Settings().Name
+	current.Lock()
+	defer current.Unlock()
+	if loaded, ok := current.models[database]; ok && time.Since(loaded.loaded) < currentRefresh {
+		return loaded.model, nil
+	}
+	model, err := Load(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	current.models[database] = loadedModel{model: model, loaded: time.Now()}
+	return model, nil
+}
+
+// setCurrent ... {model} is the one of the explorer on the database of {client} from now on
+func setCurrent(client *mongodb.Client, model Model) {
+	current.Lock()
+	defer current.Unlock()
+	current.models[client.Settings().Name] = loadedModel{model: model, loaded: time.Now()}
+}
+
+// Expected ... expected score of white in {game} with the evaluation after the move of index {ply}
+// false when a rating is missing
+func (model Model) Expected(game *pgntodb.Game, ply int) (float64, bool) {
+	if game.WhiteRating() == 0 || game.BlackRating() == 0 {
+		return 0, false
+	}
+	eval := 0.0
+	if ply >= 0 && ply < len(game.Evals) {
+		eval = float64(game.Evals[ply]) / 100
+	}
+	coefficients := model[pgntodb.Speed(game.TimeControl)]
+	return logistic(coefficients.Bias + coefficients.Rating*float64(game.WhiteRating()-game.BlackRating())/100 + coefficients.Eval*eval), true
+}
+
+// Expression ... aggregation expression of the expected score of white with the evaluation after the move of index {ply},
+// null when a rating is missing (speed of the structured time control, correspondence when unknown as Speed)
+func (model Model) Expression(ply int) bson.M {
+	speed := bson.M{"$ifNull": bson.A{"$clock.speed", "correspondence"}}
+	coefficient := func(value func(Coefficients) float64) interface{} {
+		branches := bson.A{}
+		for _, s := range Speeds {
+			branches = append(branches, bson.M{"case": bson.M{"$eq": bson.A{speed, s}}, "then": value(model[s])})
+		}
+		return bson.M{"$switch": bson.M{"branches": branches, "default": value(model["correspondence"])}}
+	}
+
+	eval := bson.M{"$divide": bson.A{bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$evals", ply}}, 0}}, 100}}
+	logit := bson.M{"$add": bson.A{
+		coefficient(func(c Coefficients) float64 { return c.Bias }),
+		bson.M{"$multiply": bson.A{coefficient(func(c Coefficients) float64 { return c.Rating }),
+			bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{"$whiteelo", "$blackelo"}}, 100}}}},
+		bson.M{"$multiply": bson.A{coefficient(func(c Coefficients) float64 { return c.Eval }), eval}},
+	}}
+	return bson.M{"$cond": bson.A{
+		bson.M{"$and": bson.A{bson.M{"$gt": bson.A{"$whiteelo", 0}}, bson.M{"$gt": bson.A{"$blackelo", 0}}}},
+		bson.M{"$divide": bson.A{1, bson.M{"$add": bson.A{1, bson.M{"$exp": bson.M{"$multiply": bson.A{-1, logit}}}}}}},
+		nil,
+	}}
+}
+
+func logistic(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+// sample ... a game of the training
+type sample struct {
+	rating float64 // difference / 100
+	eval   float64 // pawns, after the move of trainingPly
+	score  float64 // of white
+}
+
+// evaluation of the training samples: the opening is over
+const trainingPly = 20
+
+// Train ... fit the coefficients of every speed on the rated games of the database and store them
+// (speeds with less than {minGames} games keep the defaults)
+func Train(ctx context.Context, client *mongodb.Client, minGames int) (Model, error) {
+	games := mongodb.Collection(client, "games")
+	findOptions := options.Find().SetProjection(bson.M{"whiteelo": 1, "blackelo": 1, "timecontrol": 1, "result": 1, "evals": 1})
+	cursor, err := games.Find(ctx, bson.M{"whiteelo": bson.M{"$gt": 0}, "blackelo": bson.M{"$gt": 0}}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	samples := map[string][]sample{}
+	for cursor.Next(ctx) {
+		var game pgntodb.Game
+		if err = cursor.Decode(&game); err != nil {
+			return nil, err
+		}
+		s := sample{rating: float64(game.WhiteRating()-game.BlackRating()) / 100}
+		switch game.Result {
+		case "1-0":
+			s.score = 1
+		case "0-1":
+			s.score = 0
+		case "1/2-1/2":
+			s.score = 0.5
+		default:
+			continue
+		}
+		if len(game.Evals) > 0 {
+			ply := trainingPly
+			if ply >= len(game.Evals) {
+				ply = len(game.Evals) - 1
+			}
+			s.eval = float64(game.Evals[ply]) / 100
+		}
+		speed := pgntodb.Speed(game.TimeControl)
+		samples[speed] = append(samples[speed], s)
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	model := Default()
+	collection := mongodb.Collection(client, "winmodel")
+	speeds := make([]string, 0, len(samples))
+	for speed := range samples {
+		speeds = append(speeds, speed)
+	}
+	sort.Strings(speeds)
+	for _, speed := range speeds {
+		if len(samples[speed]) < minGames {
+			continue
+		}
+		coefficients := fit(samples[speed], model[speed])
+		model[speed] = coefficients
+		_, err = collection.ReplaceOne(ctx, bson.M{"_id": speed}, coefficients, options.Replace().SetUpsert(true))
+		if err != nil {
+			return nil, err
+		}
+	}
+	setCurrent(client, model)
+	return model, nil
+}
+
+// fit ... logistic regression (gradient descent on the log loss, draws count as half a win), from {start}
+func fit(samples []sample, start Coefficients) Coefficients {
+	bias, rating, eval := start.Bias, start.Rating, start.Eval
+	hasEvals := false
+	for _, s := range samples {
+		if s.eval != 0 {
+			hasEvals = true
+			break
+		}
+	}
+
+	const rate = 0.1
+	n := float64(len(samples))
+	for iteration := 0; iteration < 500; iteration++ {
+		var gradBias, gradRating, gradEval float64
+		for _, s := range samples {
+			e := math.Max(-maxEval, math.Min(maxEval, s.eval))
+			delta := logistic(bias+rating*s.rating+eval*e) - s.score
+			gradBias += delta
+			gradRating += delta * s.rating
+			gradEval += delta * e
+		}
+		bias -= rate * gradBias / n
+		rating -= rate * gradRating / n
+		if hasEvals {
+			eval -= rate * gradEval / n
+		}
+	}
+	return Coefficients{Speed: start.Speed, Bias: bias, Rating: rating, Eval: eval, Games: len(samples)}
+}
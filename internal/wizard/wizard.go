@@ -0,0 +1,169 @@
+package wizard
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+/*
+Interactive creation of the config file (chess-explorer init)
+*/
+
+// Answers ... what the user chose
+type Answers struct {
+	MongoURL     string
+	MongoDBName  string
+	Users        []string // lichess.org:username or chess.com:username
+	LichessToken string
+	Download     bool // download the games now
+}
+
+// prompter ... questions on {out}, answers from {in}
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// Run ... ask the questions and write the config file {configFile}
+func Run(in io.Reader, out io.Writer, configFile string) (*Answers, error) {
+	p := prompter{in: bufio.NewReader(in), out: out}
+
+	if _, err := os.Stat(configFile); err == nil {
+		overwrite, err := p.confirm(configFile+" already exists, overwrite it?", false)
+		if err != nil || !overwrite {
+			return nil, err
+		}
+	}
+
+	answers := Answers{}
+	var err error
+
+	// Database
+	fmt.Fprintln(out, "Games are stored in a MongoDB database (https://www.mongodb.com/try/download/community).")
+	for {
+		if answers.MongoURL, err = p.ask("MongoDB connection URL", viper.GetString("mongo-url")); err != nil {
+			return nil, err
+		}
+		if err = ping(answers.MongoURL); err == nil {
+			fmt.Fprintln(out, "Connected.")
+			break
+		}
+		fmt.Fprintln(out, "Cannot connect to "+answers.MongoURL+": "+err.Error())
+		keep, err := p.confirm("Keep this URL anyway?", false)
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			break
+		}
+	}
+	if answers.MongoDBName, err = p.ask("Database name", viper.GetString("mongo-db-name")); err != nil {
+		return nil, err
+	}
+
+	// Accounts
+	for _, site := range []string{"chess.com", "lichess.org"} {
+		names, err := p.ask("Your "+site+" usernames (comma separated, empty for none)", "")
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range strings.Split(names, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				answers.Users = append(answers.Users, site+":"+name)
+			}
+		}
+	}
+	if strings.Contains(strings.Join(answers.Users, " "), "lichess.org:") {
+		fmt.Fprintln(out, "A lichess.org personal API access token makes downloads faster (https://lichess.org/account/oauth/token).")
+		if answers.LichessToken, err = p.ask("Lichess token (empty for none)", viper.GetString("lichess-token")); err != nil {
+			return nil, err
+		}
+	}
+
+	// Config file
+	config := viper.New()
+	config.Set("mongo-url", answers.MongoURL)
+	config.Set("mongo-db-name", answers.MongoDBName)
+	if len(answers.Users) > 0 {
+		config.Set("users", answers.Users)
+	}
+	if answers.LichessToken != "" {
+		config.Set("lichess-token", answers.LichessToken)
+	}
+	if err = config.WriteConfigAs(configFile); err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(out, "Config written to "+configFile)
+
+	if len(answers.Users) > 0 {
+		if answers.Download, err = p.confirm("Download the games now?", true); err != nil {
+			return nil, err
+		}
+	}
+
+	return &answers, nil
+}
+
+// ask ... a question with a default answer (empty reply)
+func (p *prompter) ask(question string, defaultAnswer string) (string, error) {
+	if defaultAnswer != "" {
+		fmt.Fprint(p.out, question+" ["+defaultAnswer+"]: ")
+	} else {
+		fmt.Fprint(p.out, question+": ")
+	}
+	line, err := p.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return defaultAnswer, nil
+	}
+	return line, nil
+}
+
+// confirm ... a yes/no question
+func (p *prompter) confirm(question string, defaultAnswer bool) (bool, error) {
+	choices := "y/N"
+	if defaultAnswer {
+		choices = "Y/n"
+	}
+	for {
+		answer, err := p.ask(question+" ("+choices+")", "")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return defaultAnswer, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+}
+
+func ping(mongoURL string) error {
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURL))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err = client.Connect(ctx); err != nil {
+		return err
+	}
+	defer client.Disconnect(ctx)
+	return client.Ping(ctx, readpref.Primary())
+}
@@ -0,0 +1,107 @@
+package zobrist
+
+import (
+	"math/rand"
+	"strings"
+
+	"github.com/flutterbar/chess-explorer-go/internal/pgn"
+	"github.com/notnil/chess"
+)
+
+/*
+Zobrist hashes of chess positions: a random key for every piece on every square, castling right,
+en passant file and side to move, combined with xor.
+
+The keys come from a fixed seed: hashes stored in the database stay valid from one version to the next.
+The en passant file counts only when a pawn can take en passant (as Polyglot), so that transpositions
+with and without a double pawn step get the same hash.
+Hashes are int64: mongo has no unsigned integers.
+*/
+
+// seed of the keys, never change it (stored hashes would not match anymore)
+const seed = 20210417
+
+var pieceKeys [13][64]int64 // by chess.Piece (1 to 12) and square
+var castleKeys [4]int64     // white king side, white queen side, black king side, black queen side
+var enPassantKeys [8]int64  // by file
+var blackKey int64          // black to move
+
+func init() {
+	random := rand.New(rand.NewSource(seed))
+	for piece := 1; piece < 13; piece++ {
+		for square := 0; square < 64; square++ {
+			pieceKeys[piece][square] = int64(random.Uint64())
+		}
+	}
+	for i := range castleKeys {
+		castleKeys[i] = int64(random.Uint64())
+	}
+	for i := range enPassantKeys {
+		enPassantKeys[i] = int64(random.Uint64())
+	}
+	blackKey = int64(random.Uint64())
+}
+
+// Hash ... Zobrist hash of {position}
+func Hash(position *chess.Position) int64 {
+	var hash int64
+	for square, piece := range position.Board().SquareMap() {
+		hash ^= pieceKeys[piece][square]
+	}
+
+	rights := position.CastleRights()
+	for i, castle := range []struct {
+		color chess.Color
+		side  chess.Side
+	}{{chess.White, chess.KingSide}, {chess.White, chess.QueenSide}, {chess.Black, chess.KingSide}, {chess.Black, chess.QueenSide}} {
+		if rights.CanCastle(castle.color, castle.side) {
+			hash ^= castleKeys[i]
+		}
+	}
+
+	if file, ok := enPassantFile(position); ok {
+		hash ^= enPassantKeys[file]
+	}
+	if position.Turn() == chess.Black {
+		hash ^= blackKey
+	}
+	return hash
+}
+
+// enPassantFile ... file of the en passant square when a pawn of the side to move can take en passant
+func enPassantFile(position *chess.Position) (int, bool) {
+	// the position has no getter for its en passant square: 4th field of the FEN
+	fields := strings.Fields(position.String())
+	if len(fields) < 4 || fields[3] == "-" || len(fields[3]) != 2 {
+		return 0, false
+	}
+	file := int(fields[3][0] - 'a')
+	rank := chess.Rank4 // black pawns take on the 3rd rank from the 4th
+	pawn := chess.BlackPawn
+	if position.Turn() == chess.White {
+		rank, pawn = chess.Rank5, chess.WhitePawn
+	}
+	board := position.Board()
+	for _, neighbour := range []int{file - 1, file + 1} {
+		if neighbour >= 0 && neighbour < 8 && board.Piece(chess.NewSquare(chess.File(neighbour), rank)) == pawn {
+			return file, true
+		}
+	}
+	return 0, false
+}
+
+// Hashes ... hash of the position after every move of {moves} (SAN), from the starting position
+// the hashes of the moves before an illegal move, and the error
+func Hashes(moves []string) ([]int64, error) {
+	hashes := make([]int64, 0, len(moves))
+	position := chess.StartingPosition()
+	for i, move := range moves {
+		m, err := pgn.DecodeMove(position, move)
+		if err != nil {
+			return hashes, &pgn.MoveError{Ply: i + 1, Move: move, FEN: position.String()}
+		}
+		position = position.Update(m)
+		hashes = append(hashes, Hash(position))
+	}
+	return hashes, nil
+}
@@ -0,0 +1,147 @@
+package zobrist
+
+import (
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+// position ... position of {fen}
+func position(t *testing.T, fen string) *chess.Position {
+	t.Helper()
+	option, err := chess.FEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chess.NewGame(option).Position()
+}
+
+func TestHash(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string // FEN
+		equal bool
+	}{
+		{
+			name:  "move counters are ignored",
+			a:     "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+			b:     "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 12 40",
+			equal: true,
+		},
+		{
+			name: "side to move",
+			a:    "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+			b:    "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 0 1",
+		},
+		{
+			name: "castling rights",
+			a:    "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1",
+			b:    "r3k2r/8/8/8/8/8/8/R3K2R w Qkq - 0 1",
+		},
+		{
+			name: "pieces",
+			a:    "4k3/8/8/8/8/8/8/4K2R w - - 0 1",
+			b:    "4k3/8/8/8/8/8/8/4K2N w - - 0 1",
+		},
+		{
+			name:  "en passant square without a pawn to take: ignored",
+			a:     "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1",
+			b:     "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1",
+			equal: true,
+		},
+		{
+			name:  "en passant square with a pawn of the other side next to it: ignored",
+			a:     "rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR w KQkq e3 0 1",
+			b:     "rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 1",
+			equal: true,
+		},
+		{
+			name: "en passant capture possible for black",
+			a:    "rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1",
+			b:    "rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1",
+		},
+		{
+			name: "en passant capture possible for white",
+			a:    "rnbqkbnr/ppp1pppp/8/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 1",
+			b:    "rnbqkbnr/ppp1pppp/8/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq - 0 1",
+		},
+		{
+			name: "en passant on the a file",
+			a:    "4k3/8/8/pP6/8/8/8/4K3 w - a6 0 1",
+			b:    "4k3/8/8/pP6/8/8/8/4K3 w - - 0 1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, b := Hash(position(t, test.a)), Hash(position(t, test.b))
+			if (a == b) != test.equal {
+				t.Errorf("Hash(%s) = %d, Hash(%s) = %d, equal: %v, want %v", test.a, a, test.b, b, a == b, test.equal)
+			}
+		})
+	}
+}
+
+func TestHashStable(t *testing.T) {
+	// stored in the databases: a change of the keys breaks the searches by position
+	want := Hash(chess.StartingPosition())
+	if hash := Hash(position(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")); hash != want {
+		t.Errorf("starting position: %d and %d", hash, want)
+	}
+	if want == 0 {
+		t.Error("starting position: hash 0")
+	}
+}
+
+func TestHashes(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   []string
+		equal  bool // hashes of the last positions
+		errPly int  // ply of the illegal move of a, 0 without
+	}{
+		{
+			name:  "transposition",
+			a:     []string{"Nf3", "Nf6", "Nc3", "Nc6"},
+			b:     []string{"Nc3", "Nc6", "Nf3", "Nf6"},
+			equal: true,
+		},
+		{
+			name:  "double step without en passant capture: transposition",
+			a:     []string{"Nf3", "Nf6", "Ng1", "Ng8", "e4"},
+			b:     []string{"e4", "Nf6", "Nf3", "Ng8", "Ng1"},
+			equal: true,
+		},
+		{
+			name:  "double step with an en passant capture: not a transposition",
+			a:     []string{"Nf3", "d5", "Ng1", "d4", "e4"},
+			b:     []string{"e4", "d5", "Nf3", "d4", "Ng1"},
+			equal: false,
+		},
+		{
+			name:   "illegal move",
+			a:      []string{"e4", "e5", "Ke3"},
+			errPly: 3,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := Hashes(test.a)
+			if test.errPly != 0 {
+				if err == nil || len(a) != test.errPly-1 {
+					t.Errorf("Hashes(%v) = %v, %v: want %d hashes and an error", test.a, a, err, test.errPly-1)
+				}
+				return
+			}
+			if err != nil || len(a) != len(test.a) {
+				t.Fatalf("Hashes(%v) = %v, %v", test.a, a, err)
+			}
+			b, err := Hashes(test.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (a[len(a)-1] == b[len(b)-1]) != test.equal {
+				t.Errorf("last hashes of %v and %v equal: %v, want %v", test.a, test.b, !test.equal, test.equal)
+			}
+		})
+	}
+}
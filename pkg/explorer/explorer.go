@@ -0,0 +1,210 @@
+/*
+Package explorer ... the chess explorer as a Go library: download games into MongoDB, explore the opening tree, report
+
+	store, err := explorer.Open(explorer.Config{MongoURL: "mongodb://127.0.0.1:27017", Database: "chess-explorer"})
+	summary, err := store.Ingestor().Lichess("username")
+	moves, err := store.Explorer().NextMoves(explorer.Filter{White: "lichess.org:username", PGN: "1. e4"})
+	openings, err := store.Reports().Openings(ctx, "lichess.org:username", "white", explorer.Filter{})
+
+The chess-explorer commands and web server use the same packages. Every Store reads its own database: a program may
+open several stores (the settings of the chess-explorer commands are not changed).
+*/
+package explorer
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/lichess"
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/flutterbar/chess-explorer-go/internal/server"
+)
+
+// Game ... a game of the database
+type Game = pgntodb.Game
+
+// Summary ... games read, imported, already in the database and skipped by an import
+type Summary = pgntodb.Summary
+
+// NextMove ... a move played in a position, with the results of the games
+type NextMove = server.NextMove
+
+// TreeNode ... a move of the opening tree, with the results of its games and the moves played after it
+type TreeNode = server.TreeNode
+
+// OpponentStats ... games of an opponent in a position, results from the opponent's side
+type OpponentStats = server.OpponentStats
+
+// Opening ... games of a player in an opening, results from the player's side
+type Opening = server.Opening
+
+// SpeedResults ... games of a player at a speed (bullet, blitz ...), results from the player's side
+type SpeedResults = server.SpeedResults
+
+// Config ... database of the explorer, empty fields keep the current settings
+type Config struct {
+	MongoURL     string // mongodb://127.0.0.1:27017 by default
+	Database     string // chess-explorer by default
+	LichessToken string // faster lichess.org downloads
+}
+
+// Store ... the games database
+type Store struct {
+	database     mongodb.Settings
+	lichessToken string // "": the lichess-token setting
+	demo         bool   // games in memory, read only
+	games        []Game // of the demo
+	player       string // owner of the games of the demo
+}
+
+// Open ... connect to the database of {config} (the connection is checked)
+func Open(config Config) (*Store, error) {
+	store := FromSettings()
+	if config.MongoURL != "" {
+		store.database.URL = config.MongoURL
+	} else if store.database.URL == "" {
+		store.database.URL = "mongodb://127.0.0.1:27017"
+	}
+	if config.Database != "" {
+		store.database.Name = config.Database
+	} else if store.database.Name == "" {
+		store.database.Name = "chess-explorer"
+	}
+	store.lichessToken = config.LichessToken
+
+	ctx, cancel := context.WithTimeout(store.context(context.Background()), 10*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client.Disconnect(ctx)
+	return store, nil
+}
+
+// FromSettings ... store of the current settings (the config file and flags of the chess-explorer commands),
+// the connection is not checked
+func FromSettings() *Store {
+	return &Store{database: mongodb.Configured()}
+}
+
+// OpenDemo ... a read-only store of {games} kept in memory, as chess-explorer server --demo ({player} is their owner)
+func OpenDemo(games []Game, player string) *Store {
+	return &Store{demo: true, games: games, player: player}
+}
+
+// ReadGames ... standard games of the PGN of {reader} (OpenDemo), nothing is stored
+func ReadGames(reader io.Reader) ([]Game, error) {
+	return pgntodb.ReadGames(reader)
+}
+
+// context ... {ctx} reading the games of the store: its database and lichess.org token, or the games of the demo
+func (store *Store) context(ctx context.Context) context.Context {
+	if store.demo {
+		return server.WithDemo(ctx, store.games, store.player)
+	}
+	ctx = mongodb.WithSettings(ctx, store.database)
+	if store.lichessToken != "" {
+		ctx = lichess.WithToken(ctx, store.lichessToken)
+	}
+	return ctx
+}
+
+// ErrUnavailable ... the database cannot be reached (errors.Is)
+var ErrUnavailable = mongodb.ErrUnavailable
+
+// Filter ... selection of games, as the filter form of the web page
+type Filter struct {
+	PGN                 string // opening line, "1. e4 e5"
+	FEN                 string // position instead of PGN, reached with any move order
+	White               string // player(s), comma separated: username, lichess.org:username or chess.com:username
+	Black               string
+	TimeControl         string // comma separated: 600, 180+2
+	SimplifyTimeControl bool   // 600 also selects 600+5, 1/n selects -
+	Speed               string // bullet, blitz, rapid, classical, correspondence, comma separated
+	From                string // YYYY-MM-DD
+	To                  string
+	TimeZone            string // of From and To: Europe/Paris, +02:00 ("": UTC)
+	MinElo              int    // both players
+	MaxElo              int
+	UnknownElo          string // include or exclude the games with an unknown rating ("": excluded by MinElo and MaxElo)
+	Site                string // lichess.org, chess.com, iccf.com, comma separated, -iccf.com to exclude a site
+	Result              string // 1-0, 0-1, 1/2-1/2, or win, loss, draw of White (of Black when White is empty)
+	Batch               string // import batch(es), comma separated (Ingestor.Batch, Reports.Batches)
+	Tag                 string // tag(s) set by the user, comma separated
+	Aggregation         string // mongo or algorithmic to force how NextMoves counts ("": mongo below 20 moves)
+}
+
+func (filter Filter) gameFilter() *server.GameFilter {
+	values := url.Values{}
+	set := func(key string, value string) {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	set("pgn", filter.PGN)
+	set("fen", filter.FEN)
+	set("white", filter.White)
+	set("black", filter.Black)
+	set("timecontrol", filter.TimeControl)
+	set("speed", filter.Speed)
+	if filter.SimplifyTimeControl {
+		values.Set("simplifyTimecontrol", "true")
+	}
+	set("from", filter.From)
+	set("to", filter.To)
+	set("tz", filter.TimeZone)
+	if filter.MinElo > 0 {
+		values.Set("minelo", strconv.Itoa(filter.MinElo))
+	}
+	if filter.MaxElo > 0 {
+		values.Set("maxelo", strconv.Itoa(filter.MaxElo))
+	}
+	set("unknownelo", filter.UnknownElo)
+	set("site", filter.Site)
+	set("result", filter.Result)
+	set("batch", filter.Batch)
+	set("tag", filter.Tag)
+	set("aggregation", filter.Aggregation)
+	return server.NewGameFilter(values)
+}
+
+// Explorer ... the opening tree of the games
+type Explorer struct {
+	store *Store
+}
+
+// Explorer ... explore the games of the store
+func (store *Store) Explorer() *Explorer {
+	return &Explorer{store: store}
+}
+
+// NextMoves ... moves played after the line of {filter}, most played first
+func (explorer *Explorer) NextMoves(filter Filter) ([]NextMove, error) {
+	return server.Explore(explorer.store.context(context.Background()), filter.gameFilter())
+}
+
+// Tree ... opening tree after the line of {filter}, {depth} plies deep (40 at most), without the moves of less than {minGames} games
+func (explorer *Explorer) Tree(filter Filter, depth int, minGames int) (*TreeNode, error) {
+	return server.OpeningTree(explorer.store.context(context.Background()), filter.gameFilter(), depth, minGames)
+}
+
+// Opponents ... opponents of the player(s) of {filter} (White, or Black when White is empty) in the games reaching its line,
+// with their scores and next moves, most frequent opponent first
+func (explorer *Explorer) Opponents(filter Filter) ([]OpponentStats, error) {
+	return server.Opponents(explorer.store.context(context.Background()), filter.gameFilter())
+}
+
+// Games ... call {do} for every game of {filter}, oldest first (stops at the first error), number of games
+func (explorer *Explorer) Games(filter Filter, do func(game *Game) error) (int, error) {
+	return server.EachGame(explorer.store.context(context.Background()), filter.gameFilter(), do)
+}
+
+// ExportPGN ... write the games of {filter} as PGN, number of games
+func (explorer *Explorer) ExportPGN(w io.Writer, filter Filter) (int, error) {
+	return server.Export(explorer.store.context(context.Background()), w, filter.gameFilter())
+}
@@ -0,0 +1,230 @@
+package explorer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+)
+
+const alicePGN = `[Event "Rated Blitz game"]
+[Site "https://lichess.org/game0001"]
+[Date "2024.01.01"]
+[UTCDate "2024.01.01"]
+[UTCTime "10:00:00"]
+[White "alice"]
+[Black "bob"]
+[Result "1-0"]
+[WhiteElo "1500"]
+[BlackElo "1500"]
+[TimeControl "180+0"]
+
+1. e4 e5 2. Nf3 Nc6 1-0
+
+[Event "Rated Blitz game"]
+[Site "https://lichess.org/game0002"]
+[Date "2024.01.02"]
+[UTCDate "2024.01.02"]
+[UTCTime "10:00:00"]
+[White "alice"]
+[Black "carol"]
+[Result "0-1"]
+[WhiteElo "1500"]
+[BlackElo "1500"]
+[TimeControl "180+0"]
+
+1. e4 c5 0-1
+
+`
+
+const davePGN = `[Event "Rated Blitz game"]
+[Site "https://lichess.org/game0003"]
+[Date "2024.01.03"]
+[UTCDate "2024.01.03"]
+[UTCTime "10:00:00"]
+[White "dave"]
+[Black "erin"]
+[Result "1/2-1/2"]
+[WhiteElo "1500"]
+[BlackElo "1500"]
+[TimeControl "180+0"]
+
+1. d4 d5 1/2-1/2
+
+`
+
+// demoStore ... a demo store of the games of {pgn}
+func demoStore(t *testing.T, pgn string, player string) *Store {
+	games, err := ReadGames(strings.NewReader(pgn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return OpenDemo(games, player)
+}
+
+// moves ... the moves of {nextmoves} with their number of games: e4:2 d4:1
+func moves(nextmoves []NextMove) string {
+	played := make([]string, 0, len(nextmoves))
+	for _, nextmove := range nextmoves {
+		played = append(played, fmt.Sprintf("%s:%d", nextmove.Move, nextmove.Total))
+	}
+	return strings.Join(played, " ")
+}
+
+func TestDemoExplorer(t *testing.T) {
+	explorer := demoStore(t, alicePGN, "alice").Explorer()
+
+	nextmoves, err := explorer.NextMoves(Filter{White: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := moves(nextmoves); got != "e4:2" {
+		t.Errorf("next moves %q, want e4:2", got)
+	}
+	nextmoves, err = explorer.NextMoves(Filter{White: "alice", PGN: "1. e4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nextmoves) != 2 {
+		t.Errorf("next moves after 1. e4 %q, want e5 and c5", moves(nextmoves))
+	}
+
+	tree, err := explorer.Tree(Filter{White: "alice"}, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Total != 2 || len(tree.Children) != 1 || len(tree.Children[0].Children) != 2 {
+		t.Errorf("tree of %d games with %d first moves, want 2 games, e4 then e5 and c5", tree.Total, len(tree.Children))
+	}
+
+	var links []string
+	count, err := explorer.Games(Filter{White: "alice"}, func(game *Game) error {
+		links = append(links, game.Link)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 || len(links) != 2 || links[0] != "https://lichess.org/game0001" {
+		t.Errorf("games %v, want the 2 games of alice, oldest first", links)
+	}
+
+	var pgn bytes.Buffer
+	count, err = explorer.ExportPGN(&pgn, Filter{Black: "carol"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || !strings.Contains(pgn.String(), `[Black "carol"]`) || strings.Contains(pgn.String(), `[Black "bob"]`) {
+		t.Errorf("export of %d games:\n%s\nwant the game against carol", count, pgn.String())
+	}
+}
+
+func TestDemoStoresAreIndependent(t *testing.T) {
+	alice := demoStore(t, alicePGN, "alice").Explorer()
+	dave := demoStore(t, davePGN, "dave").Explorer()
+
+	for i := 0; i < 2; i++ { // the store opened last does not replace the other one
+		nextmoves, err := alice.NextMoves(Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := moves(nextmoves); got != "e4:2" {
+			t.Errorf("next moves of the first store %q, want e4:2", got)
+		}
+		nextmoves, err = dave.NextMoves(Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := moves(nextmoves); got != "d4:1" {
+			t.Errorf("next moves of the second store %q, want d4:1", got)
+		}
+	}
+}
+
+func TestDemoIsReadOnly(t *testing.T) {
+	store := demoStore(t, alicePGN, "alice")
+	ingestor := store.Ingestor()
+	if _, err := ingestor.Lichess("alice"); !errors.Is(err, ErrDemo) {
+		t.Errorf("Lichess: %v, want ErrDemo", err)
+	}
+	if _, err := ingestor.PGN(strings.NewReader(davePGN), ""); !errors.Is(err, ErrDemo) {
+		t.Errorf("PGN: %v, want ErrDemo", err)
+	}
+	if _, err := ingestor.Sync(); !errors.Is(err, ErrDemo) {
+		t.Errorf("Sync: %v, want ErrDemo", err)
+	}
+	if _, err := ingestor.Delete("alice"); !errors.Is(err, ErrDemo) {
+		t.Errorf("Delete: %v, want ErrDemo", err)
+	}
+	if _, err := ingestor.Undo(ingestor.Batch()); !errors.Is(err, ErrDemo) {
+		t.Errorf("Undo: %v, want ErrDemo", err)
+	}
+	if _, err := ingestor.Generate(GenerateOptions{Games: 10, Players: 4}, nil); !errors.Is(err, ErrDemo) {
+		t.Errorf("Generate: %v, want ErrDemo", err)
+	}
+	if _, err := store.Reports().Batches(context.Background(), 0); !errors.Is(err, ErrDemo) {
+		t.Errorf("Batches: %v, want ErrDemo", err)
+	}
+}
+
+// testStore ... a store of a scratch database (dropped at the end of the test) on the MongoDB server of TEST_MONGO_URL,
+// the test is skipped without it
+func testStore(t *testing.T, name string) *Store {
+	mongoURL := os.Getenv("TEST_MONGO_URL")
+	if mongoURL == "" {
+		t.Skip("TEST_MONGO_URL not set: no MongoDB server for the test")
+	}
+	database := fmt.Sprintf("chess-explorer-test-%s-%d", name, time.Now().UnixNano())
+	store, err := Open(Config{MongoURL: mongoURL, Database: database})
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(store.context(context.Background()), 10*time.Second)
+		defer cancel()
+		if client, err := mongodb.Connect(ctx); err == nil {
+			client.Database(database).Drop(ctx)
+			client.Disconnect(ctx)
+		}
+	})
+	return store
+}
+
+func TestStoresAreIsolated(t *testing.T) {
+	alice := testStore(t, "alice")
+	dave := testStore(t, "dave")
+
+	if _, err := alice.Ingestor().PGN(strings.NewReader(alicePGN), "alice"); err != nil {
+		t.Fatal(err)
+	}
+	summary, err := dave.Ingestor().PGN(strings.NewReader(davePGN), "dave")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Inserted != 1 {
+		t.Errorf("import into the second store: %+v, want 1 game inserted", summary)
+	}
+
+	for store, want := range map[*Store]string{alice: "e4:2", dave: "d4:1"} {
+		nextmoves, err := store.Explorer().NextMoves(Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := moves(nextmoves); got != want {
+			t.Errorf("next moves of %s: %q, want %s", store.database.Name, got, want)
+		}
+	}
+	batches, err := dave.Reports().Batches(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 {
+		t.Errorf("%d import batches in the second store, want 1", len(batches))
+	}
+}
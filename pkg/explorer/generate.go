@@ -0,0 +1,30 @@
+package explorer
+
+import (
+	"io"
+
+	"github.com/flutterbar/chess-explorer-go/internal/generate"
+)
+
+// GenerateOptions ... random games of Generate: number of games and players, usernames, period, seed
+type GenerateOptions = generate.Options
+
+// WriteGenerated ... write the random games of {options} as PGN ({progress}: games written so far, may be nil)
+// the same options give the same games
+func WriteGenerated(w io.Writer, options GenerateOptions, progress func(written int)) error {
+	return generate.Write(w, options, progress)
+}
+
+// Generate ... import the random games of {options} (load tests, demos), Undo(Batch()) removes them
+func (ingestor *Ingestor) Generate(options GenerateOptions, progress func(written int)) (Summary, error) {
+	if ingestor.store.demo {
+		return Summary{}, ErrDemo
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(WriteGenerated(writer, options, progress))
+	}()
+	summary, err := ingestor.PGN(reader, "")
+	reader.Close() // the generation stops if the import failed
+	return summary, err
+}
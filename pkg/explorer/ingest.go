@@ -0,0 +1,182 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/flutterbar/chess-explorer-go/internal/delete"
+	"github.com/flutterbar/chess-explorer-go/internal/events"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/flutterbar/chess-explorer-go/internal/sites"
+	"github.com/flutterbar/chess-explorer-go/internal/sync"
+	log "github.com/sirupsen/logrus"
+)
+
+// SyncResult ... games imported for a tracked user by Sync
+type SyncResult = sync.Result
+
+// DeleteResult ... games and users removed by Delete
+type DeleteResult = delete.Result
+
+// ErrDemo ... not available with a demo store (games in memory, read only, no reports)
+var ErrDemo = errors.New("Not available with the demo store")
+
+// Ingestor ... imports games into the database (new games only, duplicates are skipped)
+// the games inserted by an ingestor are an import batch (provenance, see Batch); one import at a time in a program
+type Ingestor struct {
+	store     *Store
+	batch     *pgntodb.Provenance
+	reference bool
+	keep      string // file where the downloaded PGN is appended
+}
+
+// Ingestor ... import games into the store
+func (store *Store) Ingestor() *Ingestor {
+	return &Ingestor{store: store, batch: pgntodb.NewBatch("api")}
+}
+
+// Command ... name the command of the imports in their provenance (api by default)
+func (ingestor *Ingestor) Command(command string) *Ingestor {
+	ingestor.batch.Command = command
+	return ingestor
+}
+
+// Reference ... import the PGN files into the reference database (strong games, the comparison source of the explorer)
+// instead of the games of the players
+func (ingestor *Ingestor) Reference() *Ingestor {
+	ingestor.reference = true
+	return ingestor
+}
+
+// Keep ... append the PGN of the downloads to the file {path}
+func (ingestor *Ingestor) Keep(path string) *Ingestor {
+	ingestor.keep = path
+	return ingestor
+}
+
+// Batch ... id of the import batch of the ingestor (filters, import undo)
+func (ingestor *Ingestor) Batch() string {
+	return ingestor.batch.Batch
+}
+
+// Lichess ... download the new games of {username} from lichess.org
+func (ingestor *Ingestor) Lichess(username string) (Summary, error) {
+	return ingestor.Site("lichess.org", username)
+}
+
+// ChessCom ... download the new games of {username} from chess.com
+func (ingestor *Ingestor) ChessCom(username string) (Summary, error) {
+	return ingestor.Site("chess.com", username)
+}
+
+// Site ... download the new games of {username} from {site} (one of Sites)
+func (ingestor *Ingestor) Site(site string, username string) (Summary, error) {
+	if ingestor.store.demo {
+		return Summary{}, ErrDemo
+	}
+	defer pgntodb.LockImport()()
+	return sites.Download(ingestor.store.context(context.Background()), site, username, ingestor.keep, ingestor.batch)
+}
+
+// Users ... download the new games of {usernames} from {site}, one result per user
+// a user who cannot be downloaded does not stop the others (the first error is returned at the end)
+func (ingestor *Ingestor) Users(site string, usernames ...string) ([]SyncResult, error) {
+	results := make([]SyncResult, 0, len(usernames))
+	var firstErr error
+	for _, username := range usernames {
+		result := SyncResult{Site: site, Username: username}
+		var err error
+		result.Summary, err = ingestor.Site(site, username)
+		if err != nil {
+			log.Error(username + ": " + err.Error())
+			result.Error = err.Error()
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		results = append(results, result)
+	}
+	return results, firstErr
+}
+
+// Sites ... sites the games can be downloaded from
+func Sites() []string {
+	return sites.Names()
+}
+
+// PGNFile ... import the games of a pgn file, or of all the files of a folder ({username}: whose games they are, may be empty)
+func (ingestor *Ingestor) PGNFile(path string, username string) (Summary, error) {
+	return ingestor.pgnFile(path, "", username)
+}
+
+// pgnFile ... PGNFile, the games were read from {source} (provenance, "": the file)
+func (ingestor *Ingestor) pgnFile(path string, source string, username string) (Summary, error) {
+	if ingestor.store.demo {
+		return Summary{}, ErrDemo
+	}
+	defer pgntodb.LockImport()()
+	before := pgntodb.Totals()
+	_, err := pgntodb.ProcessFrom(ingestor.store.context(context.Background()), path, source, &pgntodb.LastGame{Username: username, Provenance: ingestor.batch, Reference: ingestor.reference})
+	return pgntodb.Totals().Minus(before), err
+}
+
+// PGN ... import the games read from {reader}
+func (ingestor *Ingestor) PGN(reader io.Reader, username string) (Summary, error) {
+	if ingestor.store.demo {
+		return Summary{}, ErrDemo
+	}
+	// the importer reads files
+	file, err := ioutil.TempFile("", "chess-explorer-*.pgn")
+	if err != nil {
+		return Summary{}, err
+	}
+	defer os.Remove(file.Name())
+	_, err = io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Summary{}, err
+	}
+	return ingestor.pgnFile(file.Name(), "reader", username)
+}
+
+// Sync ... download the new games of every user of the database and of the users setting
+// a user who cannot be synchronized does not stop the others (the first error is returned at the end)
+func (ingestor *Ingestor) Sync() ([]SyncResult, error) {
+	if ingestor.store.demo {
+		return nil, ErrDemo
+	}
+	return sync.All(ingestor.store.context(context.Background()))
+}
+
+// Delete ... move the games of {username} (username, lichess.org:username or chess.com:username) to the trash
+func (ingestor *Ingestor) Delete(username string) (DeleteResult, error) {
+	if ingestor.store.demo {
+		return DeleteResult{}, ErrDemo
+	}
+	return delete.Games(ingestor.store.context(context.Background()), username, false)
+}
+
+// UndoResult ... games removed by Undo
+type UndoResult = delete.BatchResult
+
+// Undo ... remove the games inserted by the import batch {batch} (Ingestor.Batch, Reports.Batches)
+func (ingestor *Ingestor) Undo(batch string) (UndoResult, error) {
+	if ingestor.store.demo {
+		return UndoResult{}, ErrDemo
+	}
+	return delete.Batch(ingestor.store.context(context.Background()), batch, false)
+}
+
+// Event ... a batch of new games stored in the database
+type Event = events.GamesIngested
+
+// Subscribe ... channel receiving the events of the imports (buffered by {size}, events are dropped when it is full)
+// call the returned function to unsubscribe
+func Subscribe(size int) (<-chan Event, func()) {
+	return events.Subscribe(size)
+}
@@ -0,0 +1,87 @@
+package explorer
+
+import (
+	"context"
+	"time"
+
+	"github.com/flutterbar/chess-explorer-go/internal/mongodb"
+	"github.com/flutterbar/chess-explorer-go/internal/pgntodb"
+	"github.com/flutterbar/chess-explorer-go/internal/server"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Reports ... statistics on the games of players
+type Reports struct {
+	store *Store
+}
+
+// Reports ... reports on the games of the store
+func (store *Store) Reports() *Reports {
+	return &Reports{store: store}
+}
+
+// Openings ... openings of {player} (username or site:username) with {color} (white, black or both)
+// in the games of {filter} (its White and Black are ignored), most played first
+func (reports *Reports) Openings(ctx context.Context, player string, color string, filter Filter) ([]Opening, error) {
+	var openings []Opening
+	err := reports.withGames(ctx, func(games *mongo.Collection) error {
+		var err error
+		openings, err = server.ReportOpenings(ctx, games, player, color, filter.gameFilter())
+		return err
+	})
+	return openings, err
+}
+
+// Results ... results of {player} by speed in the games of {filter} (its White and Black are ignored), most played first
+func (reports *Reports) Results(ctx context.Context, player string, filter Filter) ([]SpeedResults, error) {
+	var results []SpeedResults
+	err := reports.withGames(ctx, func(games *mongo.Collection) error {
+		var err error
+		results, err = server.ReportResults(ctx, games, player, filter.gameFilter())
+		return err
+	})
+	return results, err
+}
+
+// OpeningSlice ... a node of the opening distribution (family, variation, sub-line) for a sunburst or a treemap
+type OpeningSlice = server.OpeningSlice
+
+// Distribution ... games of {filter} by opening family, variation and sub-line, as a flat id/parent/value list
+func (reports *Reports) Distribution(ctx context.Context, filter Filter) ([]OpeningSlice, error) {
+	var slices []OpeningSlice
+	err := reports.withGames(ctx, func(games *mongo.Collection) error {
+		var err error
+		slices, err = server.OpeningDistribution(ctx, games, filter.gameFilter())
+		return err
+	})
+	return slices, err
+}
+
+// ImportBatch ... games inserted by an import run (Ingestor, command, synchronization)
+type ImportBatch = pgntodb.Batch
+
+// Batches ... import runs of the games of the database, most recent first ({limit}: 0 for all)
+func (reports *Reports) Batches(ctx context.Context, limit int) ([]ImportBatch, error) {
+	var batches []ImportBatch
+	err := reports.withGames(ctx, func(games *mongo.Collection) error {
+		var err error
+		batches, err = pgntodb.Batches(ctx, mongodb.ClientOf(games), limit)
+		return err
+	})
+	return batches, err
+}
+
+// withGames ... call {do} with the games collection of the store
+func (reports *Reports) withGames(ctx context.Context, do func(games *mongo.Collection) error) error {
+	if reports.store.demo {
+		return ErrDemo
+	}
+	connectCtx, cancel := context.WithTimeout(reports.store.context(ctx), 10*time.Second)
+	defer cancel()
+	client, err := mongodb.Connect(connectCtx)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+	return do(mongodb.Collection(client, "games"))
+}